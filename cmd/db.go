@@ -0,0 +1,187 @@
+/*
+Copyright © 2022 Snir Koppelman
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro"
+	"github.com/spf13/cobra"
+)
+
+// dbCmd groups the database maintenance commands
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Maintain the intervals database",
+}
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete intervals older than the retention period",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		keep, err := parseKeep(cmd.Flag("keep").Value.String())
+		if err != nil {
+			return err
+		}
+
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		defer closeRepo(repo)
+		config := pomodoro.NewConfig(repo, 0, 0, 0)
+
+		return pruneAction(os.Stdout, config, config.Now().Add(-keep))
+	},
+}
+
+var archiveCmd = &cobra.Command{
+	Use:   "archive",
+	Short: "Move finished intervals older than the given period out of everyday queries",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		olderThan, err := parseKeep(cmd.Flag("older-than").Value.String())
+		if err != nil {
+			return err
+		}
+
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		defer closeRepo(repo)
+		config := pomodoro.NewConfig(repo, 0, 0, 0)
+
+		return archiveAction(os.Stdout, config, config.Now().Add(-olderThan))
+	},
+}
+
+var backupCmd = &cobra.Command{
+	Use:   "backup DEST",
+	Short: "Copy the database to DEST, safely even while pomo is running",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		force, err := cmd.Flags().GetBool("force")
+		if err != nil {
+			return err
+		}
+
+		repo, err := getRepoUnlocked()
+		if err != nil {
+			return err
+		}
+		defer closeRepo(repo)
+
+		return backupAction(os.Stdout, repo, args[0], force)
+	},
+}
+
+func init() {
+	pruneCmd.Flags().String("keep", "90d", "Keep intervals started within this period, in days like 90d or a duration like 36h")
+	dbCmd.AddCommand(pruneCmd)
+	archiveCmd.Flags().String("older-than", "90d", "Archive intervals started before this period, in days like 90d or a duration like 36h")
+	dbCmd.AddCommand(archiveCmd)
+	backupCmd.Flags().Bool("force", false, "Overwrite DEST if it exists")
+	dbCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(dbCmd)
+}
+
+// parseKeep parses a retention period, accepting days on top of the units
+// of time.ParseDuration
+func parseKeep(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		n, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid retention period %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid retention period %q", s)
+	}
+	return d, nil
+}
+
+func pruneAction(out io.Writer, config *pomodoro.IntervalConfig, before time.Time) error {
+	n, err := pomodoro.Prune(config, before)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "Deleted %d intervals started before %s\n", n, before.Format("2006-01-02 15:04"))
+	return err
+}
+
+func archiveAction(out io.Writer, config *pomodoro.IntervalConfig, before time.Time) error {
+	n, err := pomodoro.Archive(config, before)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "Archived %d intervals started before %s\n", n, before.Format("2006-01-02 15:04"))
+	return err
+}
+
+func backupAction(out io.Writer, repo pomodoro.Repository, dest string, force bool) error {
+	b, ok := repo.(pomodoro.Backuper)
+	if !ok {
+		return fmt.Errorf("this database doesn't support backups")
+	}
+	n, err := b.Backup(dest, force)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%w, use --force to overwrite it", err)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "Backed up to %s (%d bytes)\n", dest, n)
+	return err
+}
+
+// inTempDir reports whether the file lives in the temporary directory
+func inTempDir(file string) bool {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return false
+	}
+	tmp, err := filepath.Abs(os.TempDir())
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(abs, tmp+string(filepath.Separator))
+}
+
+// closeRepo closes the repositories that hold resources. The interface
+// doesn't require it, so repositories opt in by implementing io.Closer
+func closeRepo(repo pomodoro.Repository) error {
+	if c, ok := repo.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+/*
+Copyright © 2022 Snir Koppelman
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro"
+	"github.com/snirkop89/pomo/pomodoro/export"
+	"github.com/spf13/cobra"
+)
+
+const dateLayout = "2006-01-02"
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the interval history",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		start, end, err := exportRange(cmd.Flag("from").Value.String(), cmd.Flag("to").Value.String())
+		if err != nil {
+			return err
+		}
+
+		archived, err := cmd.Flags().GetBool("archived")
+		if err != nil {
+			return err
+		}
+
+		repo, err := getRepoReadOnly()
+		if err != nil {
+			return err
+		}
+		defer closeRepo(repo)
+
+		breaks, err := cmd.Flags().GetBool("breaks")
+		if err != nil {
+			return err
+		}
+		ics := export.ICSOptions{IncludeBreaks: breaks}
+
+		var csv export.CSVOptions
+		if csv.Rounding, csv.RoundMode, err = roundFlags(cmd); err != nil {
+			return err
+		}
+
+		return exportAction(os.Stdout, repo, cmd.Flag("format").Value.String(), start, end, archived, csv, ics)
+	},
+}
+
+func init() {
+	exportCmd.Flags().String("format", "csv", "Output format, csv, ics or json. json exports the whole history")
+	exportCmd.Flags().String("from", "", "First day to export, as YYYY-MM-DD")
+	exportCmd.Flags().String("to", "", "Last day to export, as YYYY-MM-DD. Defaults to today")
+	exportCmd.Flags().Bool("archived", false, "Include archived intervals")
+	exportCmd.Flags().Bool("breaks", false, "Include breaks in ics calendars")
+	addRoundFlags(exportCmd, "Add a column to csv files with the actual duration rounded to a multiple of this, e.g. 15m")
+	rootCmd.AddCommand(exportCmd)
+}
+
+// exportRange turns the days given on the command line into the range
+// [start, end) in local time
+func exportRange(from, to string) (time.Time, time.Time, error) {
+	var start time.Time
+	if from != "" {
+		var err error
+		if start, err = time.ParseInLocation(dateLayout, from, time.Local); err != nil {
+			return start, start, fmt.Errorf("invalid --from date: %w", err)
+		}
+	}
+
+	now := time.Now()
+	last := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if to != "" {
+		var err error
+		if last, err = time.ParseInLocation(dateLayout, to, time.Local); err != nil {
+			return start, start, fmt.Errorf("invalid --to date: %w", err)
+		}
+	}
+	return start, last.AddDate(0, 0, 1), nil
+}
+
+func exportAction(out io.Writer, repo pomodoro.Repository, format string, start, end time.Time, archived bool,
+	csv export.CSVOptions, ics export.ICSOptions) error {
+	switch format {
+	case "csv":
+		return export.ExportRangeCSVOpts(out, repo, start, end, archived, csv)
+	case "ics":
+		return export.ExportRangeICS(out, repo, start, end, archived, ics)
+	case "json":
+		return export.ExportJSON(out, repo, archived)
+	}
+	return fmt.Errorf("unknown export format %q", format)
+}
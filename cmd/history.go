@@ -0,0 +1,93 @@
+/*
+Copyright © 2022 Snir Koppelman
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro"
+	"github.com/spf13/cobra"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List the latest intervals",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		limit, err := cmd.Flags().GetInt("limit")
+		if err != nil {
+			return err
+		}
+
+		repo, err := getRepoReadOnly()
+		if err != nil {
+			return err
+		}
+		defer closeRepo(repo)
+		config := pomodoro.NewConfig(repo, 0, 0, 0)
+
+		return historyAction(os.Stdout, config, cmd.Flag("search").Value.String(), limit)
+	},
+}
+
+func init() {
+	historyCmd.Flags().String("search", "", "Only list intervals whose label or note contains this text, ignoring case")
+	historyCmd.Flags().Int("limit", pomodoro.DefaultPageSize, "Number of intervals to list")
+	rootCmd.AddCommand(historyCmd)
+}
+
+// historyAction lists the intervals matching search, or the latest ones if
+// it's empty, newest first
+func historyAction(out io.Writer, config *pomodoro.IntervalConfig, search string, limit int) error {
+	var (
+		intervals []pomodoro.Interval
+		err       error
+	)
+	if search != "" {
+		intervals, err = pomodoro.SearchIntervals(config, search, limit)
+	} else {
+		intervals, err = pomodoro.FindIntervals(config, pomodoro.Filter{Order: pomodoro.OrderDesc, Limit: limit})
+	}
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, i := range intervals {
+		start := "-"
+		if !i.StartTime.IsZero() {
+			start = i.StartTime.Local().Format("2006-01-02 15:04")
+		}
+		device := "-"
+		if i.Device != "" {
+			device = i.Device
+		}
+		note := strings.ReplaceAll(i.Note, "\n", " ")
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", start, i.Category, pomodoro.StateName(i.State),
+			i.ActualDuration.Round(time.Second), device, i.Label, note)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,72 @@
+/*
+Copyright © 2022 Snir Koppelman
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/snirkop89/pomo/pomodoro"
+	"github.com/snirkop89/pomo/pomodoro/export"
+	"github.com/spf13/cobra"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import FILE",
+	Short: "Import intervals from a JSON export",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		defer closeRepo(repo)
+
+		force, err := cmd.Flags().GetBool("force")
+		if err != nil {
+			return err
+		}
+
+		return importAction(os.Stdout, f, repo, force)
+	},
+}
+
+func init() {
+	importCmd.Flags().Bool("force", false, "Import even if the file doesn't match its checksum or interval count")
+	rootCmd.AddCommand(importCmd)
+}
+
+func importAction(out io.Writer, in io.Reader, repo pomodoro.Repository, force bool) error {
+	n, err := export.ImportJSON(in, repo, force)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "Imported %d intervals\n", n)
+	return err
+}
@@ -0,0 +1,6 @@
+//go:build postgres
+
+package cmd
+
+// Registers the driver used by repository.NewPostgresRepo
+import _ "github.com/lib/pq"
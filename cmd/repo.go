@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/snirkop89/pomo/pomodoro"
+	"github.com/snirkop89/pomo/pomodoro/repository"
+	"github.com/spf13/viper"
+)
+
+// getRepo opens the repository selected by the repo and db settings. Each
+// comes from its flag, or else the POMO_REPO and POMO_DB environment
+// variables, or else its default. The default backend is set by build
+// tags, except that a postgres:// URL as db selects postgres
+func getRepo() (pomodoro.Repository, error) {
+	return repository.Open(repoBackend(), viper.GetString("db"))
+}
+
+// getRepoUnlocked opens the repository like getRepo, but without the lock
+// keeping other pomo instances from changing it. Commands that only read
+// the repository use it, so they work while pomo is running
+func getRepoUnlocked() (pomodoro.Repository, error) {
+	return repository.OpenUnlocked(repoBackend(), viper.GetString("db"))
+}
+
+// getRepoReadOnly opens the repository read-only, for commands that only
+// report on it. Its writes fail with pomodoro.ErrReadOnly, and it never
+// creates or migrates the database
+func getRepoReadOnly() (pomodoro.Repository, error) {
+	return repository.OpenReadOnly(repoBackend(), viper.GetString("db"))
+}
+
+// repoBackend returns the name of the selected repository backend
+func repoBackend() string {
+	if backend := viper.GetString("repo"); backend != "" {
+		return backend
+	}
+	if isPostgresDSN(viper.GetString("db")) {
+		return "postgres"
+	}
+	return defaultBackend
+}
+
+// isPostgresDSN reports whether db names a postgres database rather than a
+// file
+func isPostgresDSN(db string) bool {
+	return strings.HasPrefix(db, "postgres://") || strings.HasPrefix(db, "postgresql://")
+}
+
+// repoDisposable reports whether the repository is thrown away on exit or
+// lives in the temporary directory, so it's safe to fill it with test data
+func repoDisposable() bool {
+	db := viper.GetString("db")
+	switch repoBackend() {
+	case "memory":
+		return true
+	case "postgres":
+		return false
+	case "sqlite3":
+		if db == ":memory:" || strings.HasPrefix(db, "file::memory:") {
+			return true
+		}
+	}
+	return inTempDir(db)
+}
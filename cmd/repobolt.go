@@ -0,0 +1,7 @@
+//go:build bolt && !inmemory
+
+package cmd
+
+// defaultBackend is the repository backend used without --repo. The bolt
+// build leaves the sqlite backend out, so it builds without cgo
+const defaultBackend = "bolt"
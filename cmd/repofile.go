@@ -0,0 +1,6 @@
+//go:build file && !inmemory
+
+package cmd
+
+// defaultBackend is the repository backend used without --repo
+const defaultBackend = "file"
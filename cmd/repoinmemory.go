@@ -2,11 +2,5 @@
 
 package cmd
 
-import (
-	"github.com/snirkop89/pomo/pomodoro"
-	"github.com/snirkop89/pomo/pomodoro/repository"
-)
-
-func getRepo() (pomodoro.Repository, error) {
-	return repository.NewInMemoryRepo(), nil
-}
+// defaultBackend is the repository backend used without --repo
+const defaultBackend = "memory"
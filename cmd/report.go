@@ -0,0 +1,324 @@
+/*
+Copyright © 2022 Snir Koppelman
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro"
+	"github.com/snirkop89/pomo/pomodoro/export"
+	"github.com/spf13/cobra"
+)
+
+const monthLayout = "2006-01"
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Summarize the work of a month day by day",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		month := time.Now()
+		if m := cmd.Flag("month").Value.String(); m != "" {
+			var err error
+			if month, err = time.ParseInLocation(monthLayout, m, time.Local); err != nil {
+				return fmt.Errorf("invalid --month: %w", err)
+			}
+		}
+
+		repo, err := getRepoReadOnly()
+		if err != nil {
+			return err
+		}
+		defer closeRepo(repo)
+		config := pomodoro.NewConfig(repo, 0, 0, 0)
+		if config.ReportRounding, config.ReportRoundMode, err = roundFlags(cmd); err != nil {
+			return err
+		}
+
+		if year, _ := cmd.Flags().GetInt("year"); year > 0 {
+			asJSON, _ := cmd.Flags().GetBool("json")
+			return yearAction(os.Stdout, config, year, asJSON)
+		}
+		if weeks, _ := cmd.Flags().GetInt("heatmap"); weeks > 0 {
+			config.WeekStartsSunday, _ = cmd.Flags().GetBool("sunday")
+			return heatmapAction(os.Stdout, config, time.Now(), weeks)
+		}
+		if markdown, _ := cmd.Flags().GetBool("markdown"); markdown {
+			week := time.Now()
+			if d := cmd.Flag("week").Value.String(); d != "" {
+				if week, err = time.ParseInLocation(dateLayout, d, time.Local); err != nil {
+					return fmt.Errorf("invalid --week: %w", err)
+				}
+			}
+			config.WeekStartsSunday, _ = cmd.Flags().GetBool("sunday")
+			return export.GenerateMarkdownReport(os.Stdout, config, week)
+		}
+		if compare, _ := cmd.Flags().GetBool("compare"); compare {
+			config.WeekStartsSunday, _ = cmd.Flags().GetBool("sunday")
+			return compareAction(os.Stdout, config, time.Now())
+		}
+		if weeks, _ := cmd.Flags().GetInt("weekdays"); weeks > 0 {
+			if total, _ := cmd.Flags().GetBool("total"); total {
+				config.WeekdayMode = pomodoro.WeekdayTotal
+			}
+			return weekdayAction(os.Stdout, config, weeks)
+		}
+		if sessions, _ := cmd.Flags().GetBool("sessions"); sessions {
+			return sessionsAction(os.Stdout, config, month)
+		}
+		if hourly, _ := cmd.Flags().GetBool("hourly"); hourly {
+			return hourlyAction(os.Stdout, config, month)
+		}
+		return reportAction(os.Stdout, config, month)
+	},
+}
+
+func init() {
+	reportCmd.Flags().String("month", "", "Month to summarize, as YYYY-MM. Defaults to this month")
+	reportCmd.Flags().Int("year", 0, "Review the work of a year instead, up to today for this year")
+	reportCmd.Flags().Bool("json", false, "With --year, write the review as JSON")
+	reportCmd.Flags().Bool("hourly", false, "Summarize the work of the month by hour of the day instead")
+	reportCmd.Flags().Bool("sessions", false, "Summarize the sessions of the month instead")
+	reportCmd.Flags().Int("weekdays", 0, "Summarize the work of the last N weeks by weekday instead")
+	reportCmd.Flags().Bool("total", false, "With --weekdays, total the weeks instead of averaging them")
+	reportCmd.Flags().Bool("compare", false, "Compare this week so far with the same span of last week instead")
+	reportCmd.Flags().Bool("markdown", false, "Write a markdown review of a week instead")
+	reportCmd.Flags().String("week", "", "With --markdown, a day of the week to review, as YYYY-MM-DD. Defaults to today")
+	reportCmd.Flags().Int("heatmap", 0, "Write the pomodoros of each day of the last N weeks as JSON instead")
+	addRoundFlags(reportCmd, "Round the durations shown to a multiple of this, e.g. 15m")
+	reportCmd.Flags().Bool("sunday", false, "With --compare, --markdown or --heatmap, start weeks on Sunday instead of Monday")
+	rootCmd.AddCommand(reportCmd)
+}
+
+// addRoundFlags adds the flags read by roundFlags to cmd, usage describing
+// --round
+func addRoundFlags(cmd *cobra.Command, usage string) {
+	cmd.Flags().Duration("round", 0, usage)
+	cmd.Flags().String("round-mode", pomodoro.RoundNearest.String(), "With --round, round to the nearest multiple, or floor or ceil")
+}
+
+// roundFlags returns the rounding given by the --round and --round-mode
+// flags
+func roundFlags(cmd *cobra.Command) (time.Duration, pomodoro.RoundMode, error) {
+	to, err := cmd.Flags().GetDuration("round")
+	if err != nil {
+		return 0, 0, err
+	}
+	if to < 0 {
+		return 0, 0, fmt.Errorf("invalid --round: %s", to)
+	}
+	mode, err := pomodoro.ParseRoundMode(cmd.Flag("round-mode").Value.String())
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid --round-mode: %w", err)
+	}
+	return to, mode, nil
+}
+
+// reportAction prints the focus and break time and the pomodoros done on
+// each day of the month, then the totals and how many pomodoros were
+// completed
+func reportAction(out io.Writer, config *pomodoro.IntervalConfig, month time.Time) error {
+	s, err := pomodoro.MonthlySummary(month, config)
+	if err != nil {
+		return err
+	}
+	first := s.Days[0].Day
+	stats, err := pomodoro.CompletionStats(first, first.AddDate(0, 1, 0), config)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Day\tFocus\tBreaks\tPomodoros\t")
+	for _, d := range s.Days {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t\n", d.Day.Format(pomodoro.DayLayout),
+			config.RoundReport(d.Focus), config.RoundReport(d.Breaks), d.Pomodoros)
+	}
+	fmt.Fprintf(w, "Total\t%s\t%s\t%d\t\n", config.RoundReport(s.Focus), config.RoundReport(s.Breaks), s.Pomodoros)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(out, "\nCompletion: %s (%d done, %d cancelled, %d skipped)\n",
+		completionRatio(stats), stats.Done, stats.Cancelled, stats.Skipped)
+	return err
+}
+
+// yearAction prints the review of year as text, or as JSON if asJSON
+func yearAction(out io.Writer, config *pomodoro.IntervalConfig, year int, asJSON bool) error {
+	r, err := pomodoro.YearSummary(year, config)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	}
+
+	title := fmt.Sprintf("%d in review", r.Year)
+	if r.Partial {
+		title += fmt.Sprintf(" (up to %s)", r.End.AddDate(0, 0, -1).Format(pomodoro.DayLayout))
+	}
+	fmt.Fprintln(out, title)
+	fmt.Fprintln(out)
+	if r.Focus == 0 && r.Pomodoros == 0 {
+		_, err := fmt.Fprintln(out, "No work this year.")
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Pomodoros:\t%d\n", r.Pomodoros)
+	fmt.Fprintf(w, "Focus:\t%.1f hours\n", config.RoundReport(r.Focus).Hours())
+	fmt.Fprintf(w, "Busiest day:\t%s (%s, %d pomodoros)\n",
+		r.BusiestDay.Day.Format(pomodoro.DayLayout), config.RoundReport(r.BusiestDay.Focus), r.BusiestDay.Pomodoros)
+	fmt.Fprintf(w, "Busiest month:\t%s (%.1f hours)\n", r.BusiestMonth, r.BusiestMonthFocus.Hours())
+	fmt.Fprintf(w, "Longest streak:\t%d days\n", r.LongestStreak)
+	fmt.Fprintf(w, "Average per working day:\t%s (%d days)\n",
+		r.AveragePerWorkingDay.Round(time.Minute), r.WorkingDays)
+	return w.Flush()
+}
+
+// heatmapJSON is the JSON representation of pomodoro.HeatmapData
+type heatmapJSON struct {
+	// Weeks holds a column of 7 days per week, -1 out of the range
+	Weeks  [][7]int `json:"weeks"`
+	Months []string `json:"months"`
+}
+
+// heatmapAction writes the pomodoros completed on each day of the last
+// weeks weeks up to now as JSON, for dashboards to draw
+func heatmapAction(out io.Writer, config *pomodoro.IntervalConfig, now time.Time, weeks int) error {
+	grid, months, err := pomodoro.HeatmapData(now, weeks, config)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(out).Encode(heatmapJSON{Weeks: grid, Months: months})
+}
+
+// completionRatio formats the ratio of stats as a percentage, or "-" if
+// no pomodoro ended
+func completionRatio(stats pomodoro.Stats) string {
+	if !stats.HasRatio {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", stats.Ratio*100)
+}
+
+// hourlyWidth is the width of the longest bar printed by hourlyAction
+const hourlyWidth = 40
+
+// hourlyAction prints the focus time of the month by hour of the day, with
+// a bar scaled to the busiest hour
+func hourlyAction(out io.Writer, config *pomodoro.IntervalConfig, month time.Time) error {
+	first := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, month.Location())
+	hours, err := pomodoro.HourlyHistogram(first, first.AddDate(0, 1, 0), config)
+	if err != nil {
+		return err
+	}
+
+	var max time.Duration
+	for _, d := range hours {
+		if d > max {
+			max = d
+		}
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Hour\tFocus\t")
+	for h, d := range hours {
+		bar := 0
+		if max > 0 {
+			bar = int(d * hourlyWidth / max)
+		}
+		fmt.Fprintf(w, "%02d:00\t%s\t%s\n", h, config.RoundReport(d), strings.Repeat("#", bar))
+	}
+	return w.Flush()
+}
+
+// sessionsAction prints the average pomodoro length and longest streak of
+// the month, then how many intervals ended in each state
+func sessionsAction(out io.Writer, config *pomodoro.IntervalConfig, month time.Time) error {
+	first := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, month.Location())
+	s, err := pomodoro.SessionStats(first, first.AddDate(0, 1, 0), config)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Average pomodoro: %s\n", s.AverageLength.Round(time.Second))
+	fmt.Fprintf(out, "Longest streak: %d pomodoros\n\n", s.LongestStreak)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "State\tIntervals\t")
+	for _, state := range []int{pomodoro.StateDone, pomodoro.StateCancelled, pomodoro.StateSkipped,
+		pomodoro.StateRunning, pomodoro.StatePaused, pomodoro.StateNotStarted} {
+		if n := s.States[state]; n > 0 {
+			fmt.Fprintf(w, "%s\t%d\t\n", pomodoro.StateName(state), n)
+		}
+	}
+	return w.Flush()
+}
+
+// weekdayAction prints the focus time of each weekday over the last weeks
+// weeks, from Monday
+func weekdayAction(out io.Writer, config *pomodoro.IntervalConfig, weeks int) error {
+	days, err := pomodoro.WeekdaySummary(weeks, config)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Weekday\tFocus\t")
+	for k := 1; k <= 7; k++ {
+		wd := time.Weekday(k % 7)
+		fmt.Fprintf(w, "%s\t%s\t\n", wd, config.RoundReport(days[wd]))
+	}
+	return w.Flush()
+}
+
+// compareAction prints the focus time, pomodoros and completion of the week
+// of now so far, along with their change since last week
+func compareAction(out io.Writer, config *pomodoro.IntervalConfig, now time.Time) error {
+	c, err := pomodoro.CompareWeeks(config, now)
+	if err != nil {
+		return err
+	}
+
+	this := c.ThisWeek
+	fmt.Fprintf(out, "Focus: %s (%s)\n", config.RoundReport(this.Focus), changeString(c.Focus))
+	fmt.Fprintf(out, "Pomodoros: %d (%s)\n", this.Pomodoros, changeString(c.Pomodoros))
+	_, err = fmt.Fprintf(out, "Completion: %s (%s)\n", completionRatio(this.Completion), changeString(c.Completion))
+	return err
+}
+
+// changeString formats a change since last week
+func changeString(c pomodoro.Change) string {
+	if !c.Valid {
+		return "nothing last week"
+	}
+	return fmt.Sprintf("%+.0f%% vs last week", c.Percent)
+}
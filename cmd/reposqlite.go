@@ -1,18 +1,6 @@
-//go:build !inmemory
+//go:build !inmemory && !bolt && !file
 
 package cmd
 
-import (
-	"github.com/snirkop89/pomo/pomodoro"
-	"github.com/snirkop89/pomo/pomodoro/repository"
-	"github.com/spf13/viper"
-)
-
-func getRepo() (pomodoro.Repository, error) {
-	repo, err := repository.NewSQLite3Repo(viper.GetString("db"))
-	if err != nil {
-		return nil, err
-	}
-
-	return repo, nil
-}
+// defaultBackend is the repository backend used without --repo
+const defaultBackend = "sqlite3"
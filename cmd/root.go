@@ -22,11 +22,17 @@ THE SOFTWARE.
 package cmd
 
 import (
-	"io"
+	"errors"
+	"fmt"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/mum4k/termdash/keyboard"
 	"github.com/snirkop89/pomo/pomodoro"
+	"github.com/snirkop89/pomo/pomodoro/hooks"
+	"github.com/snirkop89/pomo/pomodoro/idle"
+	"github.com/snirkop89/pomo/pomodoro/repository"
 	app "github.com/snirkop89/pomo/tui"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -37,17 +43,94 @@ var rootCmd = &cobra.Command{
 	Use:   "pomo",
 	Short: "Interactive Pomodoro Timer",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		repo, err := getRepo()
+		scale := viper.GetFloat64("time-scale")
+		if scale <= 0 {
+			return errors.New("time scale must be greater than zero")
+		}
+		theme, err := app.LookupTheme(viper.GetString("theme"))
+		if err != nil {
+			return err
+		}
+		theme.ASCII = viper.GetBool("ascii")
+		keys, err := keyMap()
+		if err != nil {
+			return err
+		}
+		opts := app.Options{
+			Theme:          theme,
+			Bell:           viper.GetBool("bell"),
+			Flash:          viper.GetBool("flash"),
+			TimerStyle:     viper.GetString("timer-style"),
+			Keys:           keys,
+			PromptLabel:    viper.GetBool("prompt-label"),
+			SummaryRefresh: viper.GetDuration("summary-refresh"),
+		}
+		dryRun := viper.GetBool("dry-run")
+		if scale != 1 && !dryRun && !repoDisposable() && !viper.GetBool("unsafe") {
+			return errors.New("refusing to use --time-scale with a persistent database, use a temporary database or pass --unsafe")
+		}
+
+		pomo, short, long := viper.GetDuration("pomo"), viper.GetDuration("short"), viper.GetDuration("long")
+		// Dry runs keep the intervals in memory and never touch the
+		// database, so don't even open it
+		var config *pomodoro.IntervalConfig
+		if dryRun {
+			config = pomodoro.NewEphemeralConfig(pomo, short, long)
+		} else {
+			repo, err := getRepo()
+			if err != nil {
+				return err
+			}
+			// Deferred first, so it runs after the hooks finish
+			defer closeRepo(repo)
+			// The summaries are redrawn after every interval
+			config = pomodoro.NewConfig(pomodoro.NewSummaryCache(repo), pomo, short, long)
+		}
+		if scale != 1 {
+			config.Clock = pomodoro.NewScaledClock(scale)
+		}
+		config.AutoStart = viper.GetBool("auto-start")
+		config.MicroBreakEvery = viper.GetDuration("micro-break")
+		config.Label = viper.GetString("label")
+		config.Device = viper.GetString("device")
+		config.LongBreakAfter = viper.GetDuration("long-break-after")
+		config.CompletionThreshold = viper.GetFloat64("completion-threshold")
+		config.SummaryCancelledRatio = viper.GetFloat64("summary-cancelled-ratio")
+		config.DailyGoal = viper.GetInt("daily-goal")
+		config.AdaptiveBreaks = viper.GetBool("adaptive-breaks")
+		if d := viper.GetDuration("idle-pause"); d > 0 {
+			config.IdleChecker = idle.New()
+			config.IdleThreshold = d
+		}
+
+		a, err := app.New(config, opts)
 		if err != nil {
 			return err
 		}
-		config := pomodoro.NewConfig(
-			repo,
-			viper.GetDuration("pomo"),
-			viper.GetDuration("short"),
-			viper.GetDuration("long"),
-		)
-		return rootAction(os.Stdout, config)
+
+		// The app draws on the terminal, so failures of hooks and sounds go
+		// to its error log
+		logger := a.Logger()
+		h := &hooks.Hooks{
+			OnStart:  viper.GetString("on-start"),
+			OnEnd:    viper.GetString("on-end"),
+			OnPause:  viper.GetString("on-pause"),
+			OnResume: viper.GetString("on-resume"),
+			OnCancel: viper.GetString("on-cancel"),
+			Logger:   logger,
+		}
+		h.Attach(config)
+		defer h.Wait()
+
+		sounds := &hooks.Sounds{
+			WorkEnd:  viper.GetString("sound-work-end"),
+			BreakEnd: viper.GetString("sound-break-end"),
+			Logger:   logger,
+		}
+		sounds.Attach(config)
+		defer sounds.Wait()
+
+		return a.Run()
 	},
 }
 
@@ -66,24 +149,114 @@ func init() {
 	// will be global for your application.
 
 	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.pomo.yaml)")
-	rootCmd.Flags().StringP("db", "d", "pomo.db", "Database file")
+	rootCmd.PersistentFlags().StringP("db", "d", "pomo.db", "Database file, or a postgres:// URL when built with the postgres tag")
+	rootCmd.PersistentFlags().String("repo", "", fmt.Sprintf("Repository backend, one of %s (default %q)",
+		strings.Join(repository.Backends(), ", "), defaultBackend))
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 	rootCmd.Flags().DurationP("pomo", "p", 25*time.Minute, "Pomodoro duration")
 	rootCmd.Flags().DurationP("short", "s", 5*time.Minute, "Short break duration")
 	rootCmd.Flags().DurationP("long", "l", 15*time.Minute, "Long break duration")
+	rootCmd.Flags().BoolP("auto-start", "a", false, "Start the next interval automatically")
+	rootCmd.Flags().Duration("micro-break", 0, "Remind to rest the eyes every so often during a pomodoro")
+	rootCmd.Flags().Float64("completion-threshold", 0, "Fraction of a pomodoro after which cancelling counts it as done, e.g. 0.9")
+	rootCmd.Flags().Float64("summary-cancelled-ratio", 0, "Count cancelled intervals that ran at least this fraction in summaries, e.g. 0.8")
+	rootCmd.Flags().Duration("long-break-after", 0, "Take a long break after this much work instead of every 4 pomodoros")
+	rootCmd.Flags().Int("daily-goal", 0, "Number of pomodoros to complete each day")
+	rootCmd.Flags().Bool("adaptive-breaks", false, "Shorten short breaks while behind the daily goal pace")
+	rootCmd.Flags().Duration("idle-pause", 0, "Pause the pomodoro after being idle this long (needs a build with the xprintidle tag)")
+	rootCmd.Flags().String("label", "", "Label attached to new pomodoros")
+	rootCmd.Flags().String("device", "", "Name of this machine recorded on new intervals (default the host name)")
+	rootCmd.Flags().String("on-start", "", "Command to run when an interval starts")
+	rootCmd.Flags().String("on-end", "", "Command to run when an interval ends")
+	rootCmd.Flags().String("on-pause", "", "Command to run when an interval is paused")
+	rootCmd.Flags().String("on-resume", "", "Command to run when an interval is resumed")
+	rootCmd.Flags().String("on-cancel", "", "Command to run when an interval is cancelled")
+	rootCmd.Flags().String("sound-work-end", "", "Sound file to play when a pomodoro ends")
+	rootCmd.Flags().String("sound-break-end", "", "Sound file to play when a break ends")
+	rootCmd.Flags().Float64("time-scale", 1, "Speed up time for demos, e.g. 60 makes one second count as one minute")
+	rootCmd.Flags().Bool("unsafe", false, "Allow --time-scale with a persistent database")
+	rootCmd.Flags().Bool("dry-run", false, "Keep intervals in memory without writing to the database")
+	rootCmd.Flags().Bool("bell", true, "Ring the terminal bell when an interval ends")
+	rootCmd.Flags().Bool("flash", true, "Flash the interval type when an interval ends")
+	rootCmd.Flags().String("timer-style", app.TimerDonut, fmt.Sprintf("Timer drawn, one of %s", strings.Join(app.TimerStyles(), ", ")))
+	defaultKeys := app.DefaultKeyMap()
+	for _, k := range []struct {
+		action string
+		keys   []keyboard.Key
+	}{
+		{"start", defaultKeys.Start},
+		{"pause", defaultKeys.Pause},
+		{"skip", defaultKeys.Skip},
+		{"stop", defaultKeys.Stop},
+		{"help", defaultKeys.Help},
+		{"view", defaultKeys.View},
+		{"quit", defaultKeys.Quit},
+	} {
+		flag := "key-" + k.action
+		rootCmd.Flags().String(flag, app.FormatKeys(k.keys), fmt.Sprintf("Keys of the %s action, comma separated, e.g. x,Ctrl+C", k.action))
+		viper.BindPFlag(flag, rootCmd.Flags().Lookup(flag))
+	}
+	rootCmd.Flags().Duration("summary-refresh", app.DefaultSummaryRefresh, "How often the summaries refresh while shown")
+	rootCmd.Flags().Bool("prompt-label", true, "Ask what each pomodoro is about before starting it")
+	rootCmd.Flags().Bool("ascii", false, "Draw symbols as plain text, for fonts without emoji")
+	rootCmd.Flags().String("theme", app.DefaultTheme, fmt.Sprintf("Color theme, one of %s", strings.Join(app.Themes(), ", ")))
 
-	viper.BindPFlag("db", rootCmd.Flags().Lookup("db"))
+	viper.BindPFlag("db", rootCmd.PersistentFlags().Lookup("db"))
+	viper.BindPFlag("repo", rootCmd.PersistentFlags().Lookup("repo"))
+	viper.BindEnv("db", "POMO_DB")
+	viper.BindEnv("repo", "POMO_REPO")
 	viper.BindPFlag("pomo", rootCmd.Flags().Lookup("pomo"))
 	viper.BindPFlag("short", rootCmd.Flags().Lookup("short"))
 	viper.BindPFlag("long", rootCmd.Flags().Lookup("long"))
+	viper.BindPFlag("auto-start", rootCmd.Flags().Lookup("auto-start"))
+	viper.BindPFlag("micro-break", rootCmd.Flags().Lookup("micro-break"))
+	viper.BindPFlag("completion-threshold", rootCmd.Flags().Lookup("completion-threshold"))
+	viper.BindPFlag("summary-cancelled-ratio", rootCmd.Flags().Lookup("summary-cancelled-ratio"))
+	viper.BindPFlag("long-break-after", rootCmd.Flags().Lookup("long-break-after"))
+	viper.BindPFlag("daily-goal", rootCmd.Flags().Lookup("daily-goal"))
+	viper.BindPFlag("adaptive-breaks", rootCmd.Flags().Lookup("adaptive-breaks"))
+	viper.BindPFlag("idle-pause", rootCmd.Flags().Lookup("idle-pause"))
+	viper.BindPFlag("label", rootCmd.Flags().Lookup("label"))
+	viper.BindPFlag("device", rootCmd.Flags().Lookup("device"))
+	for _, hook := range []string{"on-start", "on-end", "on-pause", "on-resume", "on-cancel"} {
+		viper.BindPFlag(hook, rootCmd.Flags().Lookup(hook))
+	}
+	viper.BindPFlag("sound-work-end", rootCmd.Flags().Lookup("sound-work-end"))
+	viper.BindPFlag("sound-break-end", rootCmd.Flags().Lookup("sound-break-end"))
+	viper.BindPFlag("time-scale", rootCmd.Flags().Lookup("time-scale"))
+	viper.BindPFlag("unsafe", rootCmd.Flags().Lookup("unsafe"))
+	viper.BindPFlag("dry-run", rootCmd.Flags().Lookup("dry-run"))
+	viper.BindPFlag("bell", rootCmd.Flags().Lookup("bell"))
+	viper.BindPFlag("flash", rootCmd.Flags().Lookup("flash"))
+	viper.BindPFlag("timer-style", rootCmd.Flags().Lookup("timer-style"))
+	viper.BindPFlag("prompt-label", rootCmd.Flags().Lookup("prompt-label"))
+	viper.BindPFlag("summary-refresh", rootCmd.Flags().Lookup("summary-refresh"))
+	viper.BindPFlag("ascii", rootCmd.Flags().Lookup("ascii"))
+	viper.BindPFlag("theme", rootCmd.Flags().Lookup("theme"))
 }
 
-func rootAction(out io.Writer, config *pomodoro.IntervalConfig) error {
-	a, err := app.New(config)
-	if err != nil {
-		return err
+// keyMap returns the keys set by the key-* settings
+func keyMap() (app.KeyMap, error) {
+	var m app.KeyMap
+	for _, k := range []struct {
+		action string
+		keys   *[]keyboard.Key
+	}{
+		{"start", &m.Start},
+		{"pause", &m.Pause},
+		{"skip", &m.Skip},
+		{"stop", &m.Stop},
+		{"help", &m.Help},
+		{"view", &m.View},
+		{"quit", &m.Quit},
+	} {
+		keys, err := app.ParseKeys(viper.GetString("key-" + k.action))
+		if err != nil {
+			return m, fmt.Errorf("keys to %s: %w", k.action, err)
+		}
+		*k.keys = keys
 	}
-	return a.Run()
+	return m, nil
 }
@@ -0,0 +1,105 @@
+/*
+Copyright © 2022 Snir Koppelman
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Print today's progress as JSON",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := getRepoReadOnly()
+		if err != nil {
+			return err
+		}
+		defer closeRepo(repo)
+
+		config := pomodoro.NewConfig(
+			repo,
+			viper.GetDuration("pomo"),
+			viper.GetDuration("short"),
+			viper.GetDuration("long"),
+		)
+		config.DailyGoal = viper.GetInt("daily-goal")
+		if cmd.Flags().Changed("daily-goal") {
+			config.DailyGoal, _ = cmd.Flags().GetInt("daily-goal")
+		}
+
+		return statusAction(os.Stdout, config)
+	},
+}
+
+func init() {
+	statusCmd.Flags().Int("daily-goal", 0, "Number of pomodoros to complete each day. Defaults to the configured goal")
+	rootCmd.AddCommand(statusCmd)
+}
+
+// goalJSON is the JSON representation of a pomodoro.GoalProgress
+type goalJSON struct {
+	Completed     int        `json:"completed"`
+	Goal          int        `json:"goal"`
+	Percent       float64    `json:"percent"`
+	LastCompleted *time.Time `json:"last_completed,omitempty"`
+	Remaining     string     `json:"remaining"`
+	Reachable     bool       `json:"reachable"`
+}
+
+type statusJSON struct {
+	// Goal is left out without a daily goal
+	Goal *goalJSON `json:"goal,omitempty"`
+}
+
+// statusAction prints the progress of today towards the daily goal
+func statusAction(out io.Writer, config *pomodoro.IntervalConfig) error {
+	var s statusJSON
+
+	p, err := pomodoro.GoalSummary(config, config.Now())
+	switch {
+	case err == nil:
+		s.Goal = &goalJSON{
+			Completed: p.Completed,
+			Goal:      p.Goal,
+			Percent:   p.Percent,
+			Remaining: p.Remaining.String(),
+			Reachable: p.Reachable,
+		}
+		if !p.LastCompleted.IsZero() {
+			s.Goal.LastCompleted = &p.LastCompleted
+		}
+	case !errors.Is(err, pomodoro.ErrNoGoal):
+		return err
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
@@ -0,0 +1,26 @@
+//go:build bolt && !inmemory
+
+package pomodoro_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/snirkop89/pomo/pomodoro"
+	"github.com/snirkop89/pomo/pomodoro/repository"
+)
+
+func getRepo(t *testing.T) (pomodoro.Repository, func()) {
+	t.Helper()
+
+	boltRepo, err := repository.NewBoltRepo(filepath.Join(t.TempDir(), "pomo.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return boltRepo, func() {
+		if err := boltRepo.Close(); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package pomodoro
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SummaryCache wraps a repository, keeping the results of the summary
+// queries redrawn by the TUI until the next write through it. Writes made
+// by other processes aren't seen until Invalidate is called, so it suits a
+// config driving a single interactive session. Cached results are shared
+// and must not be modified
+type SummaryCache struct {
+	Repository
+
+	mu      sync.Mutex
+	gen     uint64
+	entries map[string]cacheEntry
+	stats   CacheStats
+}
+
+type cacheEntry struct {
+	gen   uint64
+	value any
+}
+
+// CacheStats counts the summary reads served from the cache and from the
+// repository
+type CacheStats struct {
+	Hits   int
+	Misses int
+}
+
+// NewSummaryCache returns a cache of the summaries of repo
+func NewSummaryCache(repo Repository) *SummaryCache {
+	return &SummaryCache{
+		Repository: repo,
+		entries:    make(map[string]cacheEntry),
+	}
+}
+
+// Stats returns the hits and misses of the cache so far
+func (c *SummaryCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Invalidate drops every cached result, so the next reads see the writes
+// made elsewhere. Queries running meanwhile keep the previous generation,
+// so their results aren't cached
+func (c *SummaryCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.entries = make(map[string]cacheEntry)
+}
+
+// Invalidator is implemented by repositories caching what they read, like
+// SummaryCache
+type Invalidator interface {
+	Invalidate()
+}
+
+// InvalidateCache drops what the repository of config caches, if it does,
+// so the next reads see the changes made by other processes
+func InvalidateCache(config *IntervalConfig) {
+	if c, ok := config.store().(Invalidator); ok {
+		c.Invalidate()
+	}
+}
+
+// cached returns the result of query cached under key, running it on a
+// miss
+func cached[T any](c *SummaryCache, key string, query func() (T, error)) (T, error) {
+	c.mu.Lock()
+	gen := c.gen
+	if e, ok := c.entries[key]; ok && e.gen == gen {
+		c.stats.Hits++
+		c.mu.Unlock()
+		return e.value.(T), nil
+	}
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	v, err := query()
+	if err != nil {
+		return v, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gen == gen {
+		c.entries[key] = cacheEntry{gen: gen, value: v}
+	}
+	return v, nil
+}
+
+// cacheKey identifies a query by method and arguments. Times keep their
+// location, which picks the days queries bucket by
+func cacheKey(method string, args ...any) string {
+	var b strings.Builder
+	b.WriteString(method)
+	for _, a := range args {
+		if t, ok := a.(time.Time); ok {
+			a = t.Format(time.RFC3339Nano) + " " + t.Location().String()
+		}
+		fmt.Fprintf(&b, "|%v", a)
+	}
+	return b.String()
+}
+
+// CategorySummary is cached by calendar day, which is all the time of day
+// tells the query
+func (c *SummaryCache) CategorySummary(day time.Time, filter string, cancelledRatio float64) (time.Duration, error) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	return cached(c, cacheKey("CategorySummary", start, filter, cancelledRatio), func() (time.Duration, error) {
+		return c.Repository.CategorySummary(day, filter, cancelledRatio)
+	})
+}
+
+func (c *SummaryCache) DailyTotals(start, end time.Time, filter string, cancelledRatio float64, tag string) (map[string]time.Duration, error) {
+	return cached(c, cacheKey("DailyTotals", start, end, filter, cancelledRatio, tag), func() (map[string]time.Duration, error) {
+		return c.Repository.DailyTotals(start, end, filter, cancelledRatio, tag)
+	})
+}
+
+func (c *SummaryCache) CompletedPerDay(start, end time.Time) (map[string]int, error) {
+	return cached(c, cacheKey("CompletedPerDay", start, end), func() (map[string]int, error) {
+		return c.Repository.CompletedPerDay(start, end)
+	})
+}
+
+func (c *SummaryCache) StateCounts(start, end time.Time, category string) (map[int]int, error) {
+	return cached(c, cacheKey("StateCounts", start, end, category), func() (map[int]int, error) {
+		return c.Repository.StateCounts(start, end, category)
+	})
+}
+
+// Writes invalidate the cache once done, even if they fail, as a failed
+// write may have been applied
+
+func (c *SummaryCache) Create(i Interval) (int64, error) {
+	defer c.Invalidate()
+	return c.Repository.Create(i)
+}
+
+func (c *SummaryCache) Update(i Interval) error {
+	defer c.Invalidate()
+	return c.Repository.Update(i)
+}
+
+// UpdateProgress leaves the cache alone while an interval keeps running,
+// as summaries only count finished intervals, so ticks don't invalidate it
+func (c *SummaryCache) UpdateProgress(id, version int64, actual time.Duration, state int) error {
+	if state != StateRunning {
+		defer c.Invalidate()
+	}
+	return c.Repository.UpdateProgress(id, version, actual, state)
+}
+
+func (c *SummaryCache) Delete(id int64) error {
+	defer c.Invalidate()
+	return c.Repository.Delete(id)
+}
+
+func (c *SummaryCache) DeleteOlderThan(t time.Time) (int64, error) {
+	defer c.Invalidate()
+	return c.Repository.DeleteOlderThan(t)
+}
+
+func (c *SummaryCache) Archive(olderThan time.Time) (int64, error) {
+	defer c.Invalidate()
+	return c.Repository.Archive(olderThan)
+}
+
+func (c *SummaryCache) AddTag(intervalID int64, tag string) error {
+	defer c.Invalidate()
+	return c.Repository.AddTag(intervalID, tag)
+}
+
+func (c *SummaryCache) AddInterruption(id int64) error {
+	defer c.Invalidate()
+	return c.Repository.AddInterruption(id)
+}
+
+func (c *SummaryCache) SetNote(id int64, note string) error {
+	defer c.Invalidate()
+	return c.Repository.SetNote(id, note)
+}
+
+func (c *SummaryCache) CreateTask(t Task) (int64, error) {
+	defer c.Invalidate()
+	return c.Repository.CreateTask(t)
+}
+
+// DurationUnit is that of the wrapped repository, 0 if it stores
+// nanoseconds
+func (c *SummaryCache) DurationUnit() time.Duration {
+	if u, ok := c.Repository.(DurationUnit); ok {
+		return u.DurationUnit()
+	}
+	return 0
+}
+
+// SetNow sets how the wrapped repository stamps state events, if it does
+func (c *SummaryCache) SetNow(now func() time.Time) {
+	if s, ok := c.Repository.(Stamper); ok {
+		s.SetNow(now)
+	}
+}
+
+// RunInTransaction runs fn in a transaction of the wrapped repository,
+// if it supports them, invalidating the cache once it's over. The
+// repository given to fn isn't cached
+func (c *SummaryCache) RunInTransaction(fn func(Repository) error) error {
+	defer c.Invalidate()
+	t, ok := c.Repository.(Transactor)
+	if !ok {
+		return fn(c.Repository)
+	}
+	return t.RunInTransaction(fn)
+}
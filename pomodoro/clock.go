@@ -0,0 +1,60 @@
+package pomodoro
+
+import "time"
+
+// Clock abstracts the passage of time so the interval engine can be driven
+// by something other than the wall clock
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) *time.Ticker
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) NewTicker(d time.Duration) *time.Ticker { return time.NewTicker(d) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// RealClock is the wall clock used by default
+var RealClock Clock = realClock{}
+
+type scaledClock struct {
+	origin time.Time
+	factor float64
+}
+
+// NewScaledClock returns a Clock where one real second counts as factor
+// seconds. Timestamps are anchored at the moment the clock is created, so
+// times reported by Now stay coherent with history recorded before it.
+func NewScaledClock(factor float64) Clock {
+	if factor <= 0 {
+		factor = 1
+	}
+	return &scaledClock{
+		origin: time.Now(),
+		factor: factor,
+	}
+}
+
+func (c *scaledClock) Now() time.Time {
+	elapsed := time.Since(c.origin)
+	return c.origin.Add(time.Duration(float64(elapsed) * c.factor))
+}
+
+func (c *scaledClock) NewTicker(d time.Duration) *time.Ticker {
+	return time.NewTicker(c.real(d))
+}
+
+func (c *scaledClock) After(d time.Duration) <-chan time.Time {
+	return time.After(c.real(d))
+}
+
+// real converts a duration in scaled time to real time
+func (c *scaledClock) real(d time.Duration) time.Duration {
+	r := time.Duration(float64(d) / c.factor)
+	if r <= 0 {
+		r = 1
+	}
+	return r
+}
@@ -0,0 +1,17 @@
+package pomodoro
+
+import "time"
+
+// StateEvent records a change in the state of an interval. Repositories
+// write one whenever Update changes the state
+type StateEvent struct {
+	IntervalID int64
+	From       int
+	To         int
+	Time       time.Time
+}
+
+// IntervalEvents returns the state changes of an interval, oldest first
+func IntervalEvents(config *IntervalConfig, id int64) ([]StateEvent, error) {
+	return config.store().EventsFor(id)
+}
@@ -0,0 +1,143 @@
+// Package export writes and reads interval history in portable formats
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro"
+)
+
+// CSVHeader names the columns written by ExportCSV
+var CSVHeader = []string{
+	"id", "start_time", "category", "state",
+	"planned_seconds", "actual_seconds", "label", "note", "tags", "device",
+}
+
+// CSVRoundedColumn is the column added by ExportCSVOpts when rounding
+const CSVRoundedColumn = "rounded_seconds"
+
+// CSVOptions changes what ExportCSVOpts writes
+type CSVOptions struct {
+	// Rounding, if positive, adds a CSVRoundedColumn column with the
+	// actual duration rounded to a multiple of it as RoundMode picks
+	Rounding  time.Duration
+	RoundMode pomodoro.RoundMode
+}
+
+// ExportCSV writes a header row and one row per interval. Rows are written
+// as they're produced, so large histories aren't held in memory twice.
+// tags holds the tags of the intervals by ID, and may be nil
+func ExportCSV(w io.Writer, intervals []pomodoro.Interval, tags map[int64][]string) error {
+	return ExportCSVOpts(w, intervals, tags, CSVOptions{})
+}
+
+// ExportCSVOpts is like ExportCSV with the given options
+func ExportCSVOpts(w io.Writer, intervals []pomodoro.Interval, tags map[int64][]string, opts CSVOptions) error {
+	cw := csv.NewWriter(w)
+	header := CSVHeader
+	if opts.Rounding > 0 {
+		header = append(header[:len(header):len(header)], CSVRoundedColumn)
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, i := range intervals {
+		record := csvRecord(i, tags[i.ID])
+		if opts.Rounding > 0 {
+			record = append(record, seconds(pomodoro.RoundDuration(i.ActualDuration, opts.Rounding, opts.RoundMode)))
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvRecord(i pomodoro.Interval, tags []string) []string {
+	var start string
+	if !i.StartTime.IsZero() {
+		start = i.StartTime.Format(time.RFC3339)
+	}
+	return []string{
+		strconv.FormatInt(i.ID, 10),
+		start,
+		i.Category,
+		pomodoro.StateName(i.State),
+		seconds(i.PlannedDuration),
+		seconds(i.ActualDuration),
+		i.Label,
+		i.Note,
+		strings.Join(tags, ","),
+		i.Device,
+	}
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+// ExportRangeCSV writes the intervals started in [start, end), along with
+// the archived ones if archived is set
+func ExportRangeCSV(w io.Writer, repo pomodoro.Repository, start, end time.Time, archived bool) error {
+	return ExportRangeCSVOpts(w, repo, start, end, archived, CSVOptions{})
+}
+
+// ExportRangeCSVOpts is like ExportRangeCSV with the given options
+func ExportRangeCSVOpts(w io.Writer, repo pomodoro.Repository, start, end time.Time, archived bool, opts CSVOptions) error {
+	intervals, err := inRange(repo, start, end, archived)
+	if err != nil {
+		return err
+	}
+	tags, err := tagsOf(repo, intervals)
+	if err != nil {
+		return err
+	}
+	return ExportCSVOpts(w, intervals, tags, opts)
+}
+
+// inRange returns the intervals started in [start, end) ordered by start
+// time like ByRange, which doesn't see archived intervals. Find does, so
+// it's used instead when archived is set
+func inRange(repo pomodoro.Repository, start, end time.Time, archived bool) ([]pomodoro.Interval, error) {
+	if !archived {
+		return repo.ByRange(start, end)
+	}
+
+	found, err := repo.Find(pomodoro.Filter{From: start, To: end, IncludeArchived: true})
+	if err != nil {
+		return nil, err
+	}
+	var intervals []pomodoro.Interval
+	for _, i := range found {
+		if !i.StartTime.IsZero() {
+			intervals = append(intervals, i)
+		}
+	}
+	sort.SliceStable(intervals, func(a, b int) bool {
+		return intervals[a].StartTime.Before(intervals[b].StartTime)
+	})
+	return intervals, nil
+}
+
+// tagsOf returns the tags of the intervals that have any, by ID
+func tagsOf(repo pomodoro.Repository, intervals []pomodoro.Interval) (map[int64][]string, error) {
+	tags := make(map[int64][]string)
+	for _, i := range intervals {
+		t, err := repo.TagsFor(i.ID)
+		if err != nil {
+			return nil, err
+		}
+		if len(t) > 0 {
+			tags[i.ID] = t
+		}
+	}
+	return tags, nil
+}
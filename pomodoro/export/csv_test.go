@@ -0,0 +1,173 @@
+package export_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro"
+	"github.com/snirkop89/pomo/pomodoro/export"
+	"github.com/snirkop89/pomo/pomodoro/repository"
+)
+
+func TestExportCSV(t *testing.T) {
+	start := time.Date(2023, time.May, 10, 9, 30, 0, 0, time.FixedZone("", 2*60*60))
+	intervals := []pomodoro.Interval{
+		{
+			ID: 1, StartTime: start, PlannedDuration: 25 * time.Minute,
+			ActualDuration: 25 * time.Minute, Category: pomodoro.CategoryPomodoro,
+			State: pomodoro.StateDone, Label: "write, edit", Note: "said \"done\"\nnext line",
+			Device: "desk",
+		},
+		{
+			ID: 2, StartTime: start.Add(25 * time.Minute), PlannedDuration: 5 * time.Minute,
+			ActualDuration: 90*time.Second + 500*time.Millisecond, Category: pomodoro.CategoryShortBreak,
+			State: pomodoro.StateCancelled,
+		},
+		{ID: 3, PlannedDuration: 25 * time.Minute, Category: pomodoro.CategoryPomodoro},
+	}
+	tags := map[int64][]string{1: {"client-a", "deep"}}
+
+	var buf bytes.Buffer
+	if err := export.ExportCSV(&buf, intervals, tags); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(intervals)+1 {
+		t.Fatalf("expected %d records, got %d", len(intervals)+1, len(records))
+	}
+	for k, h := range export.CSVHeader {
+		if records[0][k] != h {
+			t.Errorf("expected header %q, got %q", h, records[0][k])
+		}
+	}
+
+	for k, r := range records[1:] {
+		exp := intervals[k]
+
+		id, err := strconv.ParseInt(r[0], 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var startTime time.Time
+		if r[1] != "" {
+			if startTime, err = time.Parse(time.RFC3339, r[1]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		planned, err := strconv.ParseFloat(r[4], 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := strconv.ParseFloat(r[5], 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if id != exp.ID || !startTime.Equal(exp.StartTime) || r[2] != exp.Category ||
+			r[3] != pomodoro.StateName(exp.State) || r[6] != exp.Label || r[7] != exp.Note {
+			t.Errorf("expected %+v, got %q", exp, r)
+		}
+		if expTags := strings.Join(tags[exp.ID], ","); r[8] != expTags {
+			t.Errorf("expected tags %q, got %q", expTags, r[8])
+		}
+		if r[9] != exp.Device {
+			t.Errorf("expected device %q, got %q", exp.Device, r[9])
+		}
+		if planned != exp.PlannedDuration.Seconds() || actual != exp.ActualDuration.Seconds() {
+			t.Errorf("expected durations %v and %v, got %v and %v",
+				exp.PlannedDuration.Seconds(), exp.ActualDuration.Seconds(), planned, actual)
+		}
+	}
+}
+
+func TestExportCSVRounded(t *testing.T) {
+	start := time.Date(2023, time.May, 10, 9, 30, 0, 0, time.UTC)
+	intervals := []pomodoro.Interval{
+		{ID: 1, StartTime: start, ActualDuration: 4*time.Hour + 58*time.Minute + 12*time.Second},
+		{ID: 2, StartTime: start.Add(5 * time.Hour), ActualDuration: 7*time.Minute + 29*time.Second},
+	}
+
+	var buf bytes.Buffer
+	opts := export.CSVOptions{Rounding: 15 * time.Minute, RoundMode: pomodoro.RoundCeil}
+	if err := export.ExportCSVOpts(&buf, intervals, nil, opts); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := len(export.CSVHeader)
+	if len(records[0]) != n+1 || records[0][n] != export.CSVRoundedColumn {
+		t.Fatalf("expected the %q column last, got %q", export.CSVRoundedColumn, records[0])
+	}
+	for k, exp := range []string{"18000", "900"} {
+		if r := records[k+1]; r[n] != exp || r[5] != strconv.FormatFloat(intervals[k].ActualDuration.Seconds(), 'f', -1, 64) {
+			t.Errorf("expected %s rounded seconds and the exact ones kept, got %q", exp, r)
+		}
+	}
+}
+
+func TestExportRangeCSVArchived(t *testing.T) {
+	repo := repository.NewInMemoryRepo()
+	start := time.Date(2023, time.May, 10, 9, 0, 0, 0, time.Local)
+	for k := 0; k < 4; k++ {
+		i := pomodoro.Interval{
+			StartTime:       start.AddDate(0, 0, k),
+			PlannedDuration: 25 * time.Minute,
+			ActualDuration:  25 * time.Minute,
+			Category:        pomodoro.CategoryPomodoro,
+			State:           pomodoro.StateDone,
+		}
+		if _, err := repo.Create(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.AddTag(1, "deep"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Archive(start.AddDate(0, 0, 2)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		archived bool
+		exp      []string
+	}{
+		{"Live", false, []string{"3", "4"}},
+		{"Archived", true, []string{"1", "2", "3", "4"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := export.ExportRangeCSV(&buf, repo, start, start.AddDate(0, 0, 4), tc.archived)
+			if err != nil {
+				t.Fatal(err)
+			}
+			records, err := csv.NewReader(&buf).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, r := range records[1:] {
+				got = append(got, r[0])
+			}
+			if strings.Join(got, ",") != strings.Join(tc.exp, ",") {
+				t.Errorf("expected intervals %v, got %v", tc.exp, got)
+			}
+			if tc.archived && records[1][8] != "deep" {
+				t.Errorf("expected archived interval tagged deep, got %q", records[1][8])
+			}
+		})
+	}
+}
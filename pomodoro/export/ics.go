@@ -0,0 +1,126 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/snirkop89/pomo/pomodoro"
+)
+
+// icsTimeLayout formats times in UTC as RFC 5545 requires of Z-times
+const icsTimeLayout = "20060102T150405Z"
+
+// icsLineLength is the longest content line in octets, without its CRLF
+const icsLineLength = 75
+
+// ICSOptions changes what ExportICSOpts writes
+type ICSOptions struct {
+	// IncludeBreaks writes done breaks as well as pomodoros and snoozes
+	IncludeBreaks bool
+}
+
+// ExportICS writes an iCalendar with an event per done pomodoro or snooze
+// in intervals. See ExportICSOpts
+func ExportICS(w io.Writer, intervals []pomodoro.Interval) error {
+	return ExportICSOpts(w, intervals, ICSOptions{})
+}
+
+// ExportICSOpts writes an iCalendar with an event per done interval in
+// intervals, from its start for its actual duration. Events are identified
+// by interval ID, so importing an export again updates them rather than
+// adding duplicates. Times are written in UTC
+func ExportICSOpts(w io.Writer, intervals []pomodoro.Interval, opts ICSOptions) error {
+	bw := bufio.NewWriter(w)
+	line := func(name, value string) {
+		writeICSLine(bw, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//pomo//pomo//EN")
+	line("CALSCALE", "GREGORIAN")
+	for _, i := range intervals {
+		if i.State != pomodoro.StateDone || i.StartTime.IsZero() {
+			continue
+		}
+		if isBreak(i.Category) && !opts.IncludeBreaks {
+			continue
+		}
+
+		start := i.StartTime.UTC()
+		end := start.Add(i.ActualDuration)
+		line("BEGIN", "VEVENT")
+		line("UID", fmt.Sprintf("interval-%d@pomo", i.ID))
+		// The event is as old as the end of the interval
+		line("DTSTAMP", end.Format(icsTimeLayout))
+		line("DTSTART", start.Format(icsTimeLayout))
+		line("DTEND", end.Format(icsTimeLayout))
+		line("SUMMARY", escapeICSText(icsSummary(i)))
+		if i.Note != "" {
+			line("DESCRIPTION", escapeICSText(i.Note))
+		}
+		line("CATEGORIES", escapeICSText(i.Category))
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+
+	return bw.Flush()
+}
+
+// ExportRangeICS writes the intervals started in [start, end) as
+// ExportICSOpts does, along with the archived ones if archived is set
+func ExportRangeICS(w io.Writer, repo pomodoro.Repository, start, end time.Time, archived bool, opts ICSOptions) error {
+	intervals, err := inRange(repo, start, end, archived)
+	if err != nil {
+		return err
+	}
+	return ExportICSOpts(w, intervals, opts)
+}
+
+func isBreak(category string) bool {
+	return category == pomodoro.CategoryShortBreak || category == pomodoro.CategoryLongBreak
+}
+
+// icsSummary titles the event of an interval with its category and label
+func icsSummary(i pomodoro.Interval) string {
+	if i.Label == "" {
+		return i.Category
+	}
+	return i.Category + ": " + i.Label
+}
+
+// escapeICSText escapes a TEXT value as RFC 5545 section 3.3.11 describes
+func escapeICSText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(s)
+}
+
+// writeICSLine writes a content line ended by CRLF, folded into lines of
+// at most icsLineLength octets continued by a space. Folds never split a
+// UTF-8 sequence. Errors are left for the writer to report on Flush
+func writeICSLine(w *bufio.Writer, line string) {
+	limit := icsLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut])
+		w.WriteString("\r\n ")
+		line = line[cut:]
+		// The space starting the next line counts
+		limit = icsLineLength - 1
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
@@ -0,0 +1,132 @@
+package export_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro"
+	"github.com/snirkop89/pomo/pomodoro/export"
+)
+
+type icsEvent map[string]string
+
+// parseICS checks the content lines of an iCalendar as RFC 5545 lays them
+// out and returns its events, their text values unescaped
+func parseICS(t *testing.T, data []byte) []icsEvent {
+	t.Helper()
+
+	s := string(data)
+	if !strings.HasSuffix(s, "\r\n") {
+		t.Fatalf("expected the calendar to end with CRLF, got %q", s)
+	}
+	raw := strings.Split(strings.TrimSuffix(s, "\r\n"), "\r\n")
+	var lines []string
+	for _, l := range raw {
+		if len(l) > 75 {
+			t.Errorf("expected lines of at most 75 octets, got %d: %q", len(l), l)
+		}
+		if strings.ContainsAny(l, "\r\n") {
+			t.Errorf("expected no bare line breaks, got %q", l)
+		}
+		if strings.HasPrefix(l, " ") {
+			if len(lines) == 0 {
+				t.Fatal("expected a line to continue")
+			}
+			lines[len(lines)-1] += l[1:]
+			continue
+		}
+		lines = append(lines, l)
+	}
+
+	if lines[0] != "BEGIN:VCALENDAR" || lines[len(lines)-1] != "END:VCALENDAR" {
+		t.Fatalf("expected a VCALENDAR, got %q", lines)
+	}
+	unescape := strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+	var (
+		events []icsEvent
+		event  icsEvent
+	)
+	for _, l := range lines[1 : len(lines)-1] {
+		name, value, ok := strings.Cut(l, ":")
+		if !ok {
+			t.Fatalf("expected a name and value, got %q", l)
+		}
+		switch {
+		case l == "BEGIN:VEVENT":
+			event = icsEvent{}
+		case l == "END:VEVENT":
+			for _, p := range []string{"UID", "DTSTAMP", "DTSTART", "DTEND"} {
+				if event[p] == "" {
+					t.Errorf("expected %s in event %v", p, event)
+				}
+			}
+			events = append(events, event)
+			event = nil
+		case event != nil:
+			event[name] = unescape.Replace(value)
+		}
+	}
+	return events
+}
+
+func TestExportICS(t *testing.T) {
+	start := time.Date(2023, time.May, 10, 9, 30, 0, 0, time.FixedZone("", 2*60*60))
+	long := strings.Repeat("déjà vu, ", 12)
+	intervals := []pomodoro.Interval{
+		{
+			ID: 1, StartTime: start, ActualDuration: 25 * time.Minute,
+			Category: pomodoro.CategoryPomodoro, State: pomodoro.StateDone,
+			Label: "write; edit", Note: "said \"done\", \\o/\nnext line",
+		},
+		{
+			ID: 2, StartTime: start.Add(25 * time.Minute), ActualDuration: 5 * time.Minute,
+			Category: pomodoro.CategoryShortBreak, State: pomodoro.StateDone,
+		},
+		{
+			ID: 3, StartTime: start.Add(30 * time.Minute), ActualDuration: 10 * time.Minute,
+			Category: pomodoro.CategoryPomodoro, State: pomodoro.StateCancelled,
+		},
+		{ID: 4, Category: pomodoro.CategoryPomodoro},
+		{
+			ID: 5, StartTime: start.Add(time.Hour), ActualDuration: 25 * time.Minute,
+			Category: pomodoro.CategoryPomodoro, State: pomodoro.StateDone, Label: long,
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := export.ExportICS(&buf, intervals); err != nil {
+		t.Fatal(err)
+	}
+	events := parseICS(t, buf.Bytes())
+	if len(events) != 2 {
+		t.Fatalf("expected the 2 done pomodoros, got %v", events)
+	}
+
+	exp := icsEvent{
+		"UID":         "interval-1@pomo",
+		"DTSTART":     "20230510T073000Z",
+		"DTEND":       "20230510T075500Z",
+		"SUMMARY":     "Pomodoro: write; edit",
+		"DESCRIPTION": "said \"done\", \\o/\nnext line",
+	}
+	for p, v := range exp {
+		if events[0][p] != v {
+			t.Errorf("expected %s %q, got %q", p, v, events[0][p])
+		}
+	}
+	if s := events[1]["SUMMARY"]; s != "Pomodoro: "+long {
+		t.Errorf("expected the long label unfolded, got %q", s)
+	}
+
+	buf.Reset()
+	if err := export.ExportICSOpts(&buf, intervals, export.ICSOptions{IncludeBreaks: true}); err != nil {
+		t.Fatal(err)
+	}
+	events = parseICS(t, buf.Bytes())
+	if len(events) != 3 || events[1]["UID"] != "interval-2@pomo" {
+		t.Errorf("expected the break included, got %v", events)
+	}
+}
@@ -0,0 +1,301 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro"
+)
+
+// ErrCorruptExport is returned by ImportJSON when a JSON export doesn't
+// hold what its metadata and checksum say, e.g. it was truncated or edited
+var ErrCorruptExport = errors.New("corrupt export")
+
+// SchemaVersion is the version of the JSON export format written by
+// ExportJSON
+const SchemaVersion = 1
+
+// Metadata describes the intervals of a JSON export
+type Metadata struct {
+	SchemaVersion int       `json:"schema_version"`
+	ExportedAt    time.Time `json:"exported_at"`
+	// Count is the number of intervals exported
+	Count int `json:"count"`
+}
+
+// bundle is a JSON export. SHA256 is the hex encoded checksum of the
+// metadata followed by the intervals, both without insignificant
+// whitespace, so reformatting the file doesn't change it
+type bundle struct {
+	Metadata  json.RawMessage `json:"metadata"`
+	Intervals json.RawMessage `json:"intervals"`
+	SHA256    string          `json:"sha256"`
+}
+
+// ExportJSON writes every interval in the repository as a JSON object with
+// the export metadata, the intervals and a checksum of both, which
+// ImportJSON verifies. Intervals use the JSON representation of
+// pomodoro.Interval with the interval's tags added as a "tags" array.
+// Archived intervals are written if archived is set
+func ExportJSON(w io.Writer, repo pomodoro.Repository, archived bool) error {
+	intervals, err := repo.Find(pomodoro.Filter{IncludeArchived: archived})
+	if err != nil {
+		return err
+	}
+	tags, err := tagsOf(repo, intervals)
+	if err != nil {
+		return err
+	}
+
+	meta, err := json.Marshal(Metadata{
+		SchemaVersion: SchemaVersion,
+		ExportedAt:    time.Now().UTC(),
+		Count:         len(intervals),
+	})
+	if err != nil {
+		return err
+	}
+
+	// The file is indented with newlines, while the checksum is of the
+	// compact form
+	sum := sha256.New()
+	sum.Write(meta)
+	sum.Write([]byte("["))
+
+	bw := bufio.NewWriter(w)
+	if _, err := fmt.Fprintf(bw, "{\"metadata\":%s,\n\"intervals\":[", meta); err != nil {
+		return err
+	}
+	for k, i := range intervals {
+		data, err := json.Marshal(i)
+		if err != nil {
+			return err
+		}
+		if t, ok := tags[i.ID]; ok {
+			if data, err = withTags(data, t); err != nil {
+				return err
+			}
+		}
+		sep := ",\n"
+		if k == 0 {
+			sep = "\n"
+		} else {
+			sum.Write([]byte(","))
+		}
+		sum.Write(data)
+		if _, err := bw.WriteString(sep); err != nil {
+			return err
+		}
+		if _, err := bw.Write(data); err != nil {
+			return err
+		}
+	}
+	sum.Write([]byte("]"))
+	if _, err := fmt.Fprintf(bw, "\n],\n\"sha256\":%q}\n", hex.EncodeToString(sum.Sum(nil))); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// withTags adds a tags array to a JSON object
+func withTags(object []byte, tags []string) ([]byte, error) {
+	t, err := json.Marshal(tags)
+	if err != nil {
+		return nil, err
+	}
+	object = bytes.TrimSuffix(bytes.TrimSpace(object), []byte("}"))
+	object = append(object, `,"tags":`...)
+	object = append(object, t...)
+	return append(object, '}'), nil
+}
+
+// ImportJSON reads intervals written by ExportJSON into the repository and
+// returns how many were stored. Intervals that never started and those
+// already in the repository, matched by start time and category, are
+// skipped. The whole input is decoded and checked against its metadata and
+// checksum before anything is stored, failing with ErrCorruptExport unless
+// force is set. A bare array of intervals has nothing to check it against,
+// so it's only read when forced. The intervals get back the tags
+// they were exported with. Repositories implementing pomodoro.Transactor,
+// or pomodoro.Importer when no interval is tagged, store the intervals
+// atomically
+func ImportJSON(r io.Reader, repo pomodoro.Repository, force bool) (int, error) {
+	intervals, tags, err := decodeJSON(r, force)
+	if err != nil {
+		return 0, err
+	}
+
+	// The tags are added to the intervals once stored, so the intervals
+	// and their tags are in the same transaction when there's one
+	store := func(repo pomodoro.Repository) (int, error) {
+		var n int
+		var err error
+		if im, ok := repo.(pomodoro.Importer); ok {
+			n, err = im.Import(intervals)
+		} else {
+			n, err = importEach(repo, intervals)
+		}
+		if err != nil {
+			return 0, err
+		}
+		return n, restoreTags(repo, intervals, tags)
+	}
+	if _, ok := repo.(pomodoro.Importer); ok && len(tags) == 0 {
+		return store(repo)
+	}
+	if t, ok := repo.(pomodoro.Transactor); ok {
+		var n int
+		err := t.RunInTransaction(func(repo pomodoro.Repository) error {
+			var err error
+			n, err = store(repo)
+			return err
+		})
+		if err != nil {
+			return 0, err
+		}
+		return n, nil
+	}
+	return store(repo)
+}
+
+// decodeJSON reads the intervals of an export and their tags, verifying
+// them unless force is set
+func decodeJSON(r io.Reader, force bool) ([]pomodoro.Interval, map[int][]string, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrCorruptExport, err)
+	}
+
+	if bytes.HasPrefix(raw, []byte("[")) {
+		if !force {
+			return nil, nil, fmt.Errorf("%w: no metadata or checksum", ErrCorruptExport)
+		}
+		return decodeIntervals(raw)
+	}
+
+	var b bundle
+	if err := json.Unmarshal(raw, &b); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrCorruptExport, err)
+	}
+	if b.Metadata == nil || b.Intervals == nil {
+		return nil, nil, fmt.Errorf("%w: no metadata or intervals", ErrCorruptExport)
+	}
+	var meta Metadata
+	if err := json.Unmarshal(b.Metadata, &meta); err != nil {
+		return nil, nil, fmt.Errorf("%w: metadata: %v", ErrCorruptExport, err)
+	}
+	if meta.SchemaVersion > SchemaVersion {
+		return nil, nil, fmt.Errorf("export schema version %d is newer than %d", meta.SchemaVersion, SchemaVersion)
+	}
+	intervals, tags, err := decodeIntervals(b.Intervals)
+	if err != nil {
+		return nil, nil, err
+	}
+	if force {
+		return intervals, tags, nil
+	}
+
+	if len(intervals) != meta.Count {
+		return nil, nil, fmt.Errorf("%w: %d intervals, expected %d", ErrCorruptExport, len(intervals), meta.Count)
+	}
+	sum := sha256.New()
+	for _, part := range []json.RawMessage{b.Metadata, b.Intervals} {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, part); err != nil {
+			return nil, nil, err
+		}
+		sum.Write(compact.Bytes())
+	}
+	if hex.EncodeToString(sum.Sum(nil)) != b.SHA256 {
+		return nil, nil, fmt.Errorf("%w: checksum mismatch", ErrCorruptExport)
+	}
+	return intervals, tags, nil
+}
+
+// decodeIntervals decodes an array of exported intervals, along with the
+// tags of those tagged by their index
+func decodeIntervals(data []byte) ([]pomodoro.Interval, map[int][]string, error) {
+	var intervals []pomodoro.Interval
+	if err := json.Unmarshal(data, &intervals); err != nil {
+		return nil, nil, fmt.Errorf("decoding intervals: %w", err)
+	}
+	// Intervals decode themselves, leaving out the tags added by withTags
+	var tagged []struct {
+		Tags []string `json:"tags"`
+	}
+	if err := json.Unmarshal(data, &tagged); err != nil {
+		return nil, nil, fmt.Errorf("decoding tags: %w", err)
+	}
+	tags := make(map[int][]string)
+	for k, t := range tagged {
+		if len(t.Tags) > 0 {
+			tags[k] = t.Tags
+		}
+	}
+	return intervals, tags, nil
+}
+
+// restoreTags tags the stored intervals matching intervals by start time
+// and category with the tags of intervals by index. Adding a tag twice
+// does nothing, so intervals that were already stored are tagged too
+func restoreTags(repo pomodoro.Repository, intervals []pomodoro.Interval, tags map[int][]string) error {
+	for k, t := range tags {
+		i := intervals[k]
+		if i.StartTime.IsZero() {
+			continue
+		}
+		same, err := repo.ByRange(i.StartTime, i.StartTime.Add(time.Nanosecond))
+		if err != nil {
+			return err
+		}
+		for _, s := range same {
+			if s.Category != i.Category {
+				continue
+			}
+			for _, tag := range t {
+				if err := repo.AddTag(s.ID, tag); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// importEach stores intervals one by one, skipping those already stored
+func importEach(repo pomodoro.Repository, intervals []pomodoro.Interval) (int, error) {
+	n := 0
+	for _, i := range intervals {
+		if i.StartTime.IsZero() {
+			continue
+		}
+		same, err := repo.ByRange(i.StartTime, i.StartTime.Add(time.Nanosecond))
+		if err != nil {
+			return n, err
+		}
+		if hasCategory(same, i.Category) {
+			continue
+		}
+		if _, err := repo.Create(i); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func hasCategory(intervals []pomodoro.Interval, category string) bool {
+	for _, i := range intervals {
+		if i.Category == category {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,213 @@
+//go:build !inmemory && !bolt && !file
+
+package export_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro"
+	"github.com/snirkop89/pomo/pomodoro/export"
+	"github.com/snirkop89/pomo/pomodoro/repository"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	src := repository.NewInMemoryRepo()
+	start := time.Date(2023, time.May, 10, 9, 30, 0, 0, time.Local)
+	intervals := []pomodoro.Interval{
+		{StartTime: start, PlannedDuration: 25 * time.Minute, ActualDuration: 25 * time.Minute,
+			Category: pomodoro.CategoryPomodoro, State: pomodoro.StateDone, Label: "write", Note: "draft"},
+		{StartTime: start.Add(25 * time.Minute), PlannedDuration: 5 * time.Minute, ActualDuration: 5 * time.Minute,
+			Category: pomodoro.CategoryShortBreak, State: pomodoro.StateDone},
+		{StartTime: start.Add(30 * time.Minute), PlannedDuration: 25 * time.Minute, ActualDuration: 10 * time.Minute,
+			Category: pomodoro.CategoryPomodoro, State: pomodoro.StateCancelled, PausedDuration: 2 * time.Minute},
+	}
+	for _, i := range intervals {
+		if _, err := src.Create(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := src.AddTag(1, "#Deep"); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := export.ExportJSON(&buf, src, false); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	var bundle struct {
+		Metadata  export.Metadata `json:"metadata"`
+		Intervals []struct {
+			ID   int64    `json:"id"`
+			Tags []string `json:"tags"`
+		} `json:"intervals"`
+	}
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		t.Fatal(err)
+	}
+	if m := bundle.Metadata; m.SchemaVersion != export.SchemaVersion || m.Count != 3 || m.ExportedAt.IsZero() {
+		t.Errorf("expected metadata of 3 intervals, got %+v", m)
+	}
+	exported := bundle.Intervals
+	if len(exported) != 3 || len(exported[0].Tags) != 1 || exported[0].Tags[0] != "deep" || exported[1].Tags != nil {
+		t.Errorf("expected only the first interval tagged deep, got %+v", exported)
+	}
+
+	dst, err := repository.NewSQLite3Repo(filepath.Join(t.TempDir(), "pomo.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := export.ImportJSON(bytes.NewReader(data), dst, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("expected 3 intervals imported, got %d", n)
+	}
+
+	srcLast, err := src.Last()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dstLast, err := dst.Last()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dstLast.StartTime.Equal(srcLast.StartTime) || dstLast.ActualDuration != srcLast.ActualDuration ||
+		dstLast.PausedDuration != srcLast.PausedDuration || dstLast.State != srcLast.State {
+		t.Errorf("expected last interval %+v, got %+v", srcLast, dstLast)
+	}
+
+	srcSummary, err := src.CategorySummary(start, "%", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dstSummary, err := dst.CategorySummary(start, "%", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srcSummary != dstSummary {
+		t.Errorf("expected summary %v, got %v", srcSummary, dstSummary)
+	}
+
+	first, err := dst.ByRange(start, start.Add(time.Nanosecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 1 {
+		t.Fatalf("expected the first interval imported, got %+v", first)
+	}
+	tags, err := dst.TagsFor(first[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 1 || tags[0] != "deep" {
+		t.Errorf("expected the first interval tagged deep, got %v", tags)
+	}
+
+	t.Run("Duplicates", func(t *testing.T) {
+		n, err := export.ImportJSON(bytes.NewReader(data), dst, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 0 {
+			t.Errorf("expected no intervals imported, got %d", n)
+		}
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		empty, err := repository.NewSQLite3Repo(filepath.Join(t.TempDir(), "pomo.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		truncated := string(data[:len(data)/2])
+		_, err = export.ImportJSON(strings.NewReader(truncated), empty, true)
+		if !errors.Is(err, export.ErrCorruptExport) {
+			t.Fatalf("expected error %q importing a truncated file, got %v", export.ErrCorruptExport, err)
+		}
+		got, err := empty.Find(pomodoro.Filter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 0 {
+			t.Errorf("expected nothing imported, got %d intervals", len(got))
+		}
+	})
+}
+
+func TestJSONTampered(t *testing.T) {
+	src := repository.NewInMemoryRepo()
+	start := time.Date(2023, time.May, 10, 9, 30, 0, 0, time.Local)
+	for k, label := range []string{"write", "edit"} {
+		i := pomodoro.Interval{StartTime: start.Add(time.Duration(k) * time.Hour), PlannedDuration: 25 * time.Minute,
+			ActualDuration: 25 * time.Minute, Category: pomodoro.CategoryPomodoro, State: pomodoro.StateDone, Label: label}
+		if _, err := src.Create(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := export.ExportJSON(&buf, src, false); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.String()
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, buf.Bytes(), "", "\t"); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(data, "\n")
+
+	testCases := []struct {
+		name    string
+		data    string
+		corrupt bool
+	}{
+		{"Intact", data, false},
+		{"Reformatted", indented.String(), false},
+		{"Edited", strings.Replace(data, `"label":"edit"`, `"label":"idle"`, 1), true},
+		{"Recounted", strings.Replace(data, `"count":2`, `"count":3`, 1), true},
+		{"Dropped", strings.Join(append(lines[:2:2], lines[3:]...), "\n"), true},
+		{"Unwrapped", `[{"start_time":"2023-05-10T09:30:00Z","planned_duration":"25m0s",
+			"actual_duration":"25m0s","category":"Pomodoro","state":"Done"}]`, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dst := repository.NewInMemoryRepo()
+			n, err := export.ImportJSON(strings.NewReader(tc.data), dst, false)
+			if tc.corrupt {
+				if !errors.Is(err, export.ErrCorruptExport) {
+					t.Fatalf("expected error %q, got %v", export.ErrCorruptExport, err)
+				}
+				if got, err := dst.Find(pomodoro.Filter{}); err != nil || len(got) != 0 {
+					t.Errorf("expected nothing imported, got %d intervals, %v", len(got), err)
+				}
+
+				// Forcing imports what's there
+				if n, err = export.ImportJSON(strings.NewReader(tc.data), dst, true); err != nil {
+					t.Fatal(err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if n == 0 {
+				t.Error("expected intervals imported")
+			}
+		})
+	}
+	// Forcing can't make up for something that isn't an export
+	for _, data := range []string{`"intervals"`, `{"metadata":[],"intervals":[]}`} {
+		_, err := export.ImportJSON(strings.NewReader(data), repository.NewInMemoryRepo(), true)
+		if !errors.Is(err, export.ErrCorruptExport) {
+			t.Errorf("expected error %q importing %s, got %v", export.ErrCorruptExport, data, err)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro"
+)
+
+// markdownTopLabels is the number of labels listed by
+// GenerateMarkdownReport
+const markdownTopLabels = 5
+
+// sparkLevels draws the sparkline of GenerateMarkdownReport, from no work
+// to the busiest day
+const sparkLevels = "_.:-=+*#"
+
+// GenerateMarkdownReport writes a markdown review of the week of week in
+// the configured location: the focus time and pomodoros of each day, the
+// labels with the most focus time and the completion rate. Durations are
+// rounded as config.RoundReport does. The output only depends on the
+// repository, so it can be compared with a golden file. A week without
+// work gets a short note instead
+func GenerateMarkdownReport(w io.Writer, config *pomodoro.IntervalConfig, week time.Time) error {
+	start := pomodoro.StartOfWeek(week, config)
+	end := start.AddDate(0, 0, 7)
+
+	days, err := pomodoro.DaySummaries(start, end, config)
+	if err != nil {
+		return err
+	}
+	labels, err := pomodoro.LabelSummary(config, start, end)
+	if err != nil {
+		return fmt.Errorf("summarizing labels: %w", err)
+	}
+	stats, err := pomodoro.CompletionStats(start, end, config)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# Week of %s\n\n", start.Format(pomodoro.DayLayout))
+
+	var (
+		focus     time.Duration
+		pomodoros int
+	)
+	for _, d := range days {
+		focus += d.Focus
+		pomodoros += d.Pomodoros
+	}
+	if focus == 0 && pomodoros == 0 && !stats.HasRatio {
+		fmt.Fprintln(bw, "No activity this week.")
+		return bw.Flush()
+	}
+
+	fmt.Fprintln(bw, "## Summary")
+	fmt.Fprintln(bw)
+	fmt.Fprintln(bw, "| Day | Focus | Pomodoros |")
+	fmt.Fprintln(bw, "| --- | ---: | ---: |")
+	for _, d := range days {
+		fmt.Fprintf(bw, "| %s | %s | %d |\n", d.Day.Format("Mon 2006-01-02"), config.RoundReport(d.Focus), d.Pomodoros)
+	}
+	fmt.Fprintf(bw, "| **Total** | **%s** | **%d** |\n\n", config.RoundReport(focus), pomodoros)
+	fmt.Fprintf(bw, "Focus by day: `%s`\n\n", sparkline(days))
+
+	fmt.Fprintln(bw, "## Top labels")
+	fmt.Fprintln(bw)
+	if len(labels) == 0 {
+		fmt.Fprintln(bw, "No done pomodoros.")
+	} else {
+		fmt.Fprintln(bw, "| Label | Focus | Pomodoros |")
+		fmt.Fprintln(bw, "| --- | ---: | ---: |")
+		if len(labels) > markdownTopLabels {
+			labels = labels[:markdownTopLabels]
+		}
+		for _, l := range labels {
+			fmt.Fprintf(bw, "| %s | %s | %d |\n", escapeMarkdownCell(l.Label), config.RoundReport(l.FocusTime), l.Pomodoros)
+		}
+	}
+	fmt.Fprintln(bw)
+
+	fmt.Fprintln(bw, "## Completion")
+	fmt.Fprintln(bw)
+	ratio := "-"
+	if stats.HasRatio {
+		ratio = fmt.Sprintf("%.0f%%", stats.Ratio*100)
+	}
+	fmt.Fprintf(bw, "%s of the pomodoros started were done: %d done, %d cancelled, %d skipped.\n",
+		ratio, stats.Done, stats.Cancelled, stats.Skipped)
+
+	return bw.Flush()
+}
+
+// sparkline draws the focus time of days with one character each, scaled
+// to the busiest day
+func sparkline(days []pomodoro.DaySummary) string {
+	var max time.Duration
+	for _, d := range days {
+		if d.Focus > max {
+			max = d.Focus
+		}
+	}
+
+	var b strings.Builder
+	top := time.Duration(len(sparkLevels) - 1)
+	for _, d := range days {
+		level := 0
+		if max > 0 {
+			level = int((d.Focus*top + max - 1) / max)
+		}
+		b.WriteByte(sparkLevels[level])
+	}
+	return b.String()
+}
+
+// escapeMarkdownCell keeps text from breaking out of a table cell
+func escapeMarkdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
@@ -0,0 +1,81 @@
+package export_test
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro"
+	"github.com/snirkop89/pomo/pomodoro/export"
+	"github.com/snirkop89/pomo/pomodoro/repository"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestGenerateMarkdownReport(t *testing.T) {
+	repo := repository.NewInMemoryRepo()
+	config := pomodoro.NewConfig(repo, 0, 0, 0)
+	config.Location = time.UTC
+
+	monday := time.Date(2024, time.June, 10, 0, 0, 0, 0, time.UTC)
+	for _, i := range []pomodoro.Interval{
+		{StartTime: monday.Add(9 * time.Hour), ActualDuration: 25 * time.Minute, Label: "write"},
+		{StartTime: monday.Add(10 * time.Hour), ActualDuration: 25 * time.Minute, Label: "write"},
+		{StartTime: monday.Add(11 * time.Hour), ActualDuration: 25 * time.Minute, Label: "review | fix"},
+		{StartTime: monday.AddDate(0, 0, 2).Add(9 * time.Hour), ActualDuration: 50 * time.Minute},
+		{StartTime: monday.AddDate(0, 0, 2).Add(10 * time.Hour), ActualDuration: 10 * time.Minute,
+			State: pomodoro.StateCancelled},
+		{StartTime: monday.AddDate(0, 0, 4).Add(9 * time.Hour), ActualDuration: 5 * time.Minute,
+			Category: pomodoro.CategoryShortBreak},
+		// The week after
+		{StartTime: monday.AddDate(0, 0, 7).Add(9 * time.Hour), ActualDuration: 25 * time.Minute},
+	} {
+		i.PlannedDuration = i.ActualDuration
+		if i.Category == "" {
+			i.Category = pomodoro.CategoryPomodoro
+		}
+		if i.State == pomodoro.StateNotStarted {
+			i.State = pomodoro.StateDone
+		}
+		if _, err := repo.Create(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		week     time.Time
+		rounding time.Duration
+		golden   string
+	}{
+		{"Week", monday.AddDate(0, 0, 3).Add(15 * time.Hour), 0, "week.md"},
+		{"Rounded", monday.AddDate(0, 0, 3).Add(15 * time.Hour), 15 * time.Minute, "week_rounded.md"},
+		{"Empty", monday.AddDate(0, 0, -7), 0, "empty_week.md"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			config.ReportRounding = tc.rounding
+			var buf bytes.Buffer
+			if err := export.GenerateMarkdownReport(&buf, config, tc.week); err != nil {
+				t.Fatal(err)
+			}
+
+			golden := filepath.Join("testdata", tc.golden)
+			if *update {
+				if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			exp, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), exp) {
+				t.Errorf("expected:\n%s\ngot:\n%s", exp, buf.Bytes())
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+//go:build file && !inmemory
+
+package pomodoro_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/snirkop89/pomo/pomodoro"
+	"github.com/snirkop89/pomo/pomodoro/repository"
+)
+
+func getRepo(t *testing.T) (pomodoro.Repository, func()) {
+	t.Helper()
+
+	fileRepo, err := repository.NewFileRepo(filepath.Join(t.TempDir(), "pomo.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fileRepo, func() {
+		if err := fileRepo.Close(); err != nil {
+			t.Error(err)
+		}
+	}
+}
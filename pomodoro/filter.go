@@ -0,0 +1,223 @@
+package pomodoro
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Order sorts query results
+type Order int
+
+const (
+	// OrderAsc sorts by interval ID
+	OrderAsc Order = iota
+	// OrderDesc sorts by interval ID, the last first
+	OrderDesc
+	// OrderLatestFirst sorts by start time, the latest first, with the
+	// intervals that never started last. Ties are sorted by ID, oldest
+	// first, so intervals written while paging don't shift the pages
+	// already read unless they start later
+	OrderLatestFirst
+)
+
+// Filter selects intervals from a repository. Zero values don't filter
+type Filter struct {
+	// From and To select intervals started in [From, To)
+	From time.Time
+	To   time.Time
+	// Categories and States select intervals matching any of the values
+	Categories []string
+	States     []int
+	// Label selects intervals whose label contains it
+	Label string
+	// Text selects intervals whose label or note contains it, ignoring
+	// case, in any script
+	Text string
+	// Limit caps the number of results when positive
+	Limit int
+	// Offset skips that many results, in Order, when positive
+	Offset int
+	Order  Order
+	// IncludeArchived selects archived intervals too, which are left out
+	// otherwise
+	IncludeArchived bool
+}
+
+// Matches reports whether i is selected by the filter, ignoring Limit and
+// Order. Repositories that filter in Go use it
+func (f Filter) Matches(i Interval) bool {
+	if !f.From.IsZero() && i.StartTime.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !i.StartTime.Before(f.To) {
+		return false
+	}
+	if len(f.Categories) > 0 && !containsString(f.Categories, i.Category) {
+		return false
+	}
+	if len(f.States) > 0 && !containsInt(f.States, i.State) {
+		return false
+	}
+	if f.Label != "" && !strings.Contains(i.Label, f.Label) {
+		return false
+	}
+	if f.Text != "" {
+		text := strings.ToLower(f.Text)
+		if !strings.Contains(strings.ToLower(i.Label), text) &&
+			!strings.Contains(strings.ToLower(i.Note), text) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(s []int, v int) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Select returns the intervals f selects from intervals, which are in ID
+// order, sorted and paged as f says. Repositories that filter in Go use it
+func (f Filter) Select(intervals []Interval) []Interval {
+	// Sorted by ID, the page is over once it's filled
+	end := f.Limit
+	if f.Offset > 0 {
+		end += f.Offset
+	}
+
+	var data []Interval
+	for k := range intervals {
+		i := intervals[k]
+		if f.Order == OrderDesc {
+			i = intervals[len(intervals)-1-k]
+		}
+		if !f.Matches(i) {
+			continue
+		}
+		data = append(data, i)
+		if f.Order != OrderLatestFirst && f.Limit > 0 && len(data) == end {
+			break
+		}
+	}
+	if f.Order == OrderLatestFirst {
+		sortLatestFirst(data)
+	}
+	return f.Page(data)
+}
+
+// Page returns the page of the intervals f selects, sorted in its Order,
+// given by its Offset and Limit
+func (f Filter) Page(data []Interval) []Interval {
+	if f.Offset > 0 {
+		if f.Offset >= len(data) {
+			return nil
+		}
+		data = data[f.Offset:]
+	}
+	if f.Limit > 0 && len(data) > f.Limit {
+		data = data[:f.Limit]
+	}
+	return data
+}
+
+// ErrEmptyQuery is returned by SearchIntervals for a query with nothing to match,
+// rather than matching everything
+var ErrEmptyQuery = errors.New("empty search query")
+
+// FindIntervals returns the intervals selected by f
+func FindIntervals(config *IntervalConfig, f Filter) ([]Interval, error) {
+	return config.store().Find(f)
+}
+
+// dayFilter selects the intervals started on the calendar day of day, in
+// its location
+func dayFilter(day time.Time) Filter {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	return Filter{From: start, To: start.AddDate(0, 0, 1)}
+}
+
+// completedOn counts the pomodoros completed on day, in its location
+func completedOn(repo Repository, day time.Time) (int64, error) {
+	f := dayFilter(day)
+	counts, err := repo.StateCounts(f.From, f.To, CategoryPomodoro)
+	return int64(counts[StateDone]), err
+}
+
+// WithArchived returns the intervals searched by f: intervals, joined by
+// archived when f.IncludeArchived is set, in ID order. Repositories that
+// filter in Go use it
+func WithArchived(f Filter, intervals, archived []Interval) []Interval {
+	if !f.IncludeArchived || len(archived) == 0 {
+		return intervals
+	}
+
+	all := make([]Interval, 0, len(intervals)+len(archived))
+	all = append(all, archived...)
+	all = append(all, intervals...)
+	sort.Slice(all, func(a, b int) bool { return all[a].ID < all[b].ID })
+	return all
+}
+
+// Archivable reports whether Archive moves i, given the time before which
+// intervals are archived and whether i is the last interval, which stays
+func Archivable(i Interval, olderThan time.Time, last bool) bool {
+	return !last && i.StartTime.Before(olderThan) &&
+		(i.State == StateDone || i.State == StateCancelled || i.State == StateSkipped)
+}
+
+// DefaultPageSize is the number of intervals ListByCategory and
+// SearchIntervals return when no limit is given
+const DefaultPageSize = 50
+
+// ListByCategory returns a page of the intervals of a category, sorted by
+// OrderLatestFirst. A limit of zero means DefaultPageSize
+func ListByCategory(config *IntervalConfig, category string, limit, offset int) ([]Interval, error) {
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	return config.store().Find(Filter{
+		Categories: []string{category},
+		Order:      OrderLatestFirst,
+		Limit:      limit,
+		Offset:     offset,
+	})
+}
+
+// SearchIntervals returns the intervals whose label or note contains
+// query, ignoring case, the latest started first. A limit of zero means
+// DefaultPageSize
+func SearchIntervals(config *IntervalConfig, query string, limit int) ([]Interval, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, ErrEmptyQuery
+	}
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	return config.store().Find(Filter{Text: query, Order: OrderLatestFirst, Limit: limit})
+}
+
+// sortLatestFirst orders intervals by start time, the latest first, then
+// by ID, the oldest first
+func sortLatestFirst(data []Interval) {
+	sort.Slice(data, func(a, b int) bool {
+		if !data[a].StartTime.Equal(data[b].StartTime) {
+			return data[a].StartTime.After(data[b].StartTime)
+		}
+		return data[a].ID < data[b].ID
+	})
+}
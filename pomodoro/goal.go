@@ -0,0 +1,154 @@
+package pomodoro
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNoGoal is returned by GoalSummary when no DailyGoal is configured
+var ErrNoGoal = errors.New("no daily goal")
+
+// Defaults for adaptive breaks and the working day
+const (
+	DefaultWorkdayStart   = 9 * time.Hour
+	DefaultWorkdayEnd     = 17 * time.Hour
+	DefaultBreakReduction = 0.5
+	DefaultMinShortBreak  = 2 * time.Minute
+)
+
+// workday returns the start and end of the working day containing now
+func (c *IntervalConfig) workday(now time.Time) (time.Time, time.Time) {
+	start, end := c.WorkdayStart, c.WorkdayEnd
+	if start <= 0 && end <= 0 {
+		start, end = DefaultWorkdayStart, DefaultWorkdayEnd
+	}
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return midnight.Add(start), midnight.Add(end)
+}
+
+// behindPace reports whether fewer pomodoros were completed today than the
+// daily goal prorated to the part of the working day already gone
+func (c *IntervalConfig) behindPace() (bool, error) {
+	if c.DailyGoal <= 0 {
+		return false, nil
+	}
+
+	now := c.today()
+	start, end := c.workday(now)
+	if !end.After(start) {
+		return false, nil
+	}
+
+	elapsed := float64(now.Sub(start)) / float64(end.Sub(start))
+	if elapsed <= 0 {
+		return false, nil
+	}
+	if elapsed > 1 {
+		elapsed = 1
+	}
+
+	done, err := completedOn(c.store(), now)
+	if err != nil {
+		return false, fmt.Errorf("counting today's pomodoros: %w", err)
+	}
+	return float64(done) < float64(c.DailyGoal)*elapsed, nil
+}
+
+// adaptBreak shortens a short break when AdaptiveBreaks is set and the day
+// is behind the goal pace. It never goes below MinShortBreak, nor makes
+// the break longer than planned
+func (c *IntervalConfig) adaptBreak(i Interval) (Interval, error) {
+	if !c.AdaptiveBreaks || i.Category != CategoryShortBreak {
+		return i, nil
+	}
+
+	behind, err := c.behindPace()
+	if err != nil || !behind {
+		return i, err
+	}
+
+	reduction := c.BreakReduction
+	if reduction <= 0 || reduction >= 1 {
+		reduction = DefaultBreakReduction
+	}
+	floor := c.MinShortBreak
+	if floor <= 0 {
+		floor = DefaultMinShortBreak
+	}
+
+	d := time.Duration(float64(i.PlannedDuration) * (1 - reduction))
+	if d < floor {
+		d = floor
+	}
+	if d < i.PlannedDuration {
+		i.PlannedDuration = d
+	}
+	return i, nil
+}
+
+// GoalProgress is the progress towards the daily goal on a day
+type GoalProgress struct {
+	Completed int
+	Goal      int
+	// Percent is Completed in percent of Goal, over 100 past the goal
+	Percent float64
+	// LastCompleted is the time the last pomodoro done on the day ended,
+	// zero if there's none
+	LastCompleted time.Time
+	// Remaining is the time left in the working day, all of it for a day
+	// yet to come
+	Remaining time.Duration
+	// Reachable reports whether the pomodoros still needed fit in the time
+	// left, run back to back with short breaks in between
+	Reachable bool
+}
+
+// GoalSummary returns the progress towards the daily goal on the calendar
+// day of day in the configured location, projected from the current time.
+// It fails with ErrNoGoal without a DailyGoal
+func GoalSummary(config *IntervalConfig, day time.Time) (GoalProgress, error) {
+	if config.DailyGoal <= 0 {
+		return GoalProgress{}, ErrNoGoal
+	}
+
+	day = day.In(config.location())
+	midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	done, err := config.store().Find(Filter{
+		From:       midnight,
+		To:         midnight.AddDate(0, 0, 1),
+		Categories: []string{CategoryPomodoro},
+		States:     []int{StateDone},
+	})
+	if err != nil {
+		return GoalProgress{}, fmt.Errorf("finding pomodoros: %w", err)
+	}
+
+	p := GoalProgress{
+		Completed: len(done),
+		Goal:      config.DailyGoal,
+		Percent:   float64(len(done)) / float64(config.DailyGoal) * 100,
+	}
+	for _, i := range done {
+		if end := i.ProjectedEnd(i.StartTime); end.After(p.LastCompleted) {
+			p.LastCompleted = end
+		}
+	}
+
+	start, end := config.workday(day)
+	if now := config.Now(); now.After(start) {
+		start = now
+	}
+	if end.After(start) {
+		p.Remaining = end.Sub(start)
+	}
+
+	needed := time.Duration(config.DailyGoal - p.Completed)
+	if needed <= 0 {
+		p.Reachable = true
+		return p, nil
+	}
+	work := needed*config.PomodoroDuration + (needed-1)*config.ShortBreakDuration
+	p.Reachable = work <= p.Remaining
+	return p, nil
+}
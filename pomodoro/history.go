@@ -0,0 +1,49 @@
+package pomodoro
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro/schedule"
+)
+
+// repoHistory adapts a Repository to the schedule.FocusHistory interface
+type repoHistory struct {
+	repo  Repository
+	today func() time.Time
+}
+
+func toEntry(i Interval) schedule.Entry {
+	return schedule.Entry{
+		Category:       i.Category,
+		StartTime:      i.StartTime,
+		ActualDuration: i.ActualDuration,
+	}
+}
+
+func (h repoHistory) Last() (schedule.Entry, error) {
+	i, err := h.repo.Last()
+	if err == ErrNoIntervals {
+		return schedule.Entry{}, schedule.ErrNoHistory
+	}
+	if err != nil {
+		return schedule.Entry{}, fmt.Errorf("fetching last interval: %w", err)
+	}
+	return toEntry(i), nil
+}
+
+func (h repoHistory) Breaks(n int) ([]schedule.Entry, error) {
+	breaks, err := h.repo.Breaks(n)
+	if err != nil {
+		return nil, fmt.Errorf("fetching breaks: %w", err)
+	}
+	entries := make([]schedule.Entry, 0, len(breaks))
+	for _, i := range breaks {
+		entries = append(entries, toEntry(i))
+	}
+	return entries, nil
+}
+
+func (h repoHistory) WorkSinceLongBreak() (time.Duration, error) {
+	return h.repo.WorkSinceLongBreak(h.today())
+}
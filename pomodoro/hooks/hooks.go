@@ -0,0 +1,114 @@
+// Package hooks runs external commands when intervals change state, e.g. to
+// send a desktop notification when a pomodoro ends
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro"
+)
+
+// DefaultTimeout bounds how long a hook command may run
+const DefaultTimeout = 10 * time.Second
+
+// ExecFunc runs command with the given extra environment
+type ExecFunc func(ctx context.Context, command string, env []string) error
+
+// Hooks holds the shell commands to run on each lifecycle event. Empty
+// commands are skipped.
+type Hooks struct {
+	OnStart  string
+	OnEnd    string
+	OnPause  string
+	OnResume string
+	OnCancel string
+
+	// Timeout bounds each command. Zero means DefaultTimeout
+	Timeout time.Duration
+	// Logger receives failures. Defaults to the standard logger
+	Logger *log.Logger
+	// Exec runs the commands. Defaults to running them with sh -c
+	Exec ExecFunc
+
+	wg sync.WaitGroup
+}
+
+func shellExec(ctx context.Context, command string, env []string) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(), env...)
+	return cmd.Run()
+}
+
+// Env returns the environment variables describing the interval
+func Env(i pomodoro.Interval) []string {
+	return []string{
+		"POMO_CATEGORY=" + i.Category,
+		"POMO_STATE=" + pomodoro.StateName(i.State),
+		"POMO_REMAINING=" + (i.PlannedDuration - i.ActualDuration).String(),
+		"POMO_LABEL=" + i.Label,
+	}
+}
+
+// Attach chains the hooks to the lifecycle callbacks of config, keeping any
+// callbacks already set
+func (h *Hooks) Attach(config *pomodoro.IntervalConfig) {
+	config.OnStart = h.chain(config.OnStart, h.OnStart)
+	config.OnEnd = h.chain(config.OnEnd, h.OnEnd)
+	config.OnPause = h.chain(config.OnPause, h.OnPause)
+	config.OnResume = h.chain(config.OnResume, h.OnResume)
+	config.OnCancel = h.chain(config.OnCancel, h.OnCancel)
+}
+
+// Wait blocks until all running commands have finished
+func (h *Hooks) Wait() {
+	h.wg.Wait()
+}
+
+func (h *Hooks) chain(cb pomodoro.Callback, command string) pomodoro.Callback {
+	if command == "" {
+		return cb
+	}
+	return func(i pomodoro.Interval) {
+		if cb != nil {
+			cb(i)
+		}
+		h.run(command, i)
+	}
+}
+
+// run executes command in the background so a slow hook never holds up
+// the interval
+func (h *Hooks) run(command string, i pomodoro.Interval) {
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+
+		timeout := h.Timeout
+		if timeout <= 0 {
+			timeout = DefaultTimeout
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		run := h.Exec
+		if run == nil {
+			run = shellExec
+		}
+		if err := run(ctx, command, Env(i)); err != nil {
+			h.logger().Print(fmt.Errorf("hook %q: %w", command, err))
+		}
+	}()
+}
+
+func (h *Hooks) logger() *log.Logger {
+	if h.Logger == nil {
+		return log.Default()
+	}
+	return h.Logger
+}
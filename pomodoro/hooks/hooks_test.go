@@ -0,0 +1,103 @@
+package hooks_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro"
+	"github.com/snirkop89/pomo/pomodoro/hooks"
+)
+
+type call struct {
+	command string
+	env     []string
+}
+
+type fakeExec struct {
+	sync.Mutex
+	calls []call
+	err   error
+}
+
+func (f *fakeExec) exec(ctx context.Context, command string, env []string) error {
+	f.Lock()
+	defer f.Unlock()
+	f.calls = append(f.calls, call{command, env})
+	return f.err
+}
+
+func TestAttach(t *testing.T) {
+	f := &fakeExec{}
+	h := &hooks.Hooks{
+		OnStart: "slack-status busy",
+		OnEnd:   "notify-send done",
+		Exec:    f.exec,
+	}
+
+	config := &pomodoro.IntervalConfig{}
+	previous := 0
+	config.OnEnd = func(pomodoro.Interval) { previous++ }
+	h.Attach(config)
+
+	if config.OnPause != nil {
+		t.Errorf("expected no pause callback without a pause command")
+	}
+
+	i := pomodoro.Interval{
+		Category:        pomodoro.CategoryPomodoro,
+		State:           pomodoro.StateDone,
+		PlannedDuration: 25 * time.Minute,
+		ActualDuration:  20 * time.Minute,
+		Label:           "writing",
+	}
+	config.OnEnd(i)
+	h.Wait()
+
+	if previous != 1 {
+		t.Errorf("expected existing callback to be kept")
+	}
+	if len(f.calls) != 1 {
+		t.Fatalf("expected 1 command, got %d", len(f.calls))
+	}
+	if f.calls[0].command != h.OnEnd {
+		t.Errorf("expected command %q, got %q", h.OnEnd, f.calls[0].command)
+	}
+
+	expEnv := []string{
+		"POMO_CATEGORY=Pomodoro",
+		"POMO_STATE=Done",
+		"POMO_REMAINING=5m0s",
+		"POMO_LABEL=writing",
+	}
+	env := strings.Join(f.calls[0].env, "\n")
+	for _, e := range expEnv {
+		if !strings.Contains(env, e) {
+			t.Errorf("expected environment to contain %q, got %q", e, env)
+		}
+	}
+}
+
+func TestFailingHookIsLogged(t *testing.T) {
+	f := &fakeExec{err: errors.New("exit status 1")}
+	var buf bytes.Buffer
+	h := &hooks.Hooks{
+		OnCancel: "false",
+		Exec:     f.exec,
+		Logger:   log.New(&buf, "", 0),
+	}
+
+	config := &pomodoro.IntervalConfig{}
+	h.Attach(config)
+	config.OnCancel(pomodoro.Interval{State: pomodoro.StateCancelled})
+	h.Wait()
+
+	if !strings.Contains(buf.String(), "exit status 1") {
+		t.Errorf("expected failure to be logged, got %q", buf.String())
+	}
+}
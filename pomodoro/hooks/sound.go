@@ -0,0 +1,145 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro"
+)
+
+// SoundPlayer plays sound files
+type SoundPlayer interface {
+	Play(ctx context.Context, file string) error
+}
+
+// CommandPlayer plays sound files with the audio player of the platform:
+// afplay on macOS, PowerShell on Windows and paplay elsewhere
+type CommandPlayer struct{}
+
+// Play plays file, returning once it's done or ctx is done
+func (CommandPlayer) Play(ctx context.Context, file string) error {
+	name, args := playerCommand(runtime.GOOS, file)
+	return exec.CommandContext(ctx, name, args...).Run()
+}
+
+func playerCommand(goos, file string) (string, []string) {
+	switch goos {
+	case "darwin":
+		return "afplay", []string{file}
+	case "windows":
+		script := fmt.Sprintf("(New-Object Media.SoundPlayer '%s').PlaySync()", strings.ReplaceAll(file, "'", "''"))
+		return "powershell", []string{"-NoProfile", "-Command", script}
+	}
+	return "paplay", []string{file}
+}
+
+// Sounds plays a sound file when an interval ends
+type Sounds struct {
+	// WorkEnd is played when a pomodoro ends and BreakEnd when a break
+	// does. Empty files are skipped
+	WorkEnd  string
+	BreakEnd string
+
+	// Timeout bounds each sound. Zero means DefaultTimeout
+	Timeout time.Duration
+	// Logger receives failures. Defaults to the standard logger
+	Logger *log.Logger
+	// Player plays the files. Defaults to CommandPlayer
+	Player SoundPlayer
+
+	wg sync.WaitGroup
+
+	mu sync.Mutex
+	// missing holds the files already reported missing
+	missing map[string]bool
+}
+
+// Attach chains the sounds to the end callback of config, keeping any
+// callback already set
+func (s *Sounds) Attach(config *pomodoro.IntervalConfig) {
+	if s.WorkEnd == "" && s.BreakEnd == "" {
+		return
+	}
+	cb := config.OnEnd
+	config.OnEnd = func(i pomodoro.Interval) {
+		if cb != nil {
+			cb(i)
+		}
+		file := s.BreakEnd
+		if i.Category == pomodoro.CategoryPomodoro || i.Category == pomodoro.CategorySnooze {
+			file = s.WorkEnd
+		}
+		if file != "" {
+			s.play(file)
+		}
+	}
+}
+
+// Wait blocks until all sounds playing have finished
+func (s *Sounds) Wait() {
+	s.wg.Wait()
+}
+
+// play plays file in the background so the interval never waits for it.
+// A missing file is reported once rather than every time
+func (s *Sounds) play(file string) {
+	if _, err := os.Stat(file); err != nil {
+		if errors.Is(err, fs.ErrNotExist) && !s.reportMissing(file) {
+			return
+		}
+		s.logger().Print(fmt.Errorf("sound %q: %w", file, err))
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		timeout := s.Timeout
+		if timeout <= 0 {
+			timeout = DefaultTimeout
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		var player SoundPlayer = CommandPlayer{}
+		if s.Player != nil {
+			player = s.Player
+		}
+		if err := player.Play(ctx, file); err != nil {
+			s.logger().Print(fmt.Errorf("sound %q: %w", file, err))
+		}
+	}()
+}
+
+// reportMissing records file as missing, returning whether it's the
+// first time
+func (s *Sounds) reportMissing(file string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.missing[file] {
+		return false
+	}
+	if s.missing == nil {
+		s.missing = make(map[string]bool)
+	}
+	s.missing[file] = true
+	return true
+}
+
+func (s *Sounds) logger() *log.Logger {
+	if s.Logger == nil {
+		return log.Default()
+	}
+	return s.Logger
+}
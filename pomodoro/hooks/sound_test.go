@@ -0,0 +1,116 @@
+package hooks_test
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/snirkop89/pomo/pomodoro"
+	"github.com/snirkop89/pomo/pomodoro/hooks"
+)
+
+type fakePlayer struct {
+	sync.Mutex
+	files []string
+}
+
+func (f *fakePlayer) Play(ctx context.Context, file string) error {
+	f.Lock()
+	defer f.Unlock()
+	f.files = append(f.files, file)
+	return nil
+}
+
+func soundFile(t *testing.T, name string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, []byte("RIFF"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestSounds(t *testing.T) {
+	f := &fakePlayer{}
+	s := &hooks.Sounds{
+		WorkEnd:  soundFile(t, "work.wav"),
+		BreakEnd: soundFile(t, "break.ogg"),
+		Player:   f,
+	}
+
+	config := &pomodoro.IntervalConfig{}
+	previous := 0
+	config.OnEnd = func(pomodoro.Interval) { previous++ }
+	s.Attach(config)
+
+	for _, category := range []string{
+		pomodoro.CategoryPomodoro,
+		pomodoro.CategoryShortBreak,
+		pomodoro.CategoryLongBreak,
+		pomodoro.CategorySnooze,
+	} {
+		config.OnEnd(pomodoro.Interval{Category: category, State: pomodoro.StateDone})
+	}
+	s.Wait()
+
+	if previous != 4 {
+		t.Errorf("expected existing callback to be kept")
+	}
+	// Sounds play in the background, in no particular order
+	played := make(map[string]int)
+	for _, file := range f.files {
+		played[file]++
+	}
+	if played[s.WorkEnd] != 2 || played[s.BreakEnd] != 2 || len(f.files) != 4 {
+		t.Errorf("expected work and break sounds played twice each, got %v", f.files)
+	}
+}
+
+func TestSoundsSkipped(t *testing.T) {
+	f := &fakePlayer{}
+	s := &hooks.Sounds{BreakEnd: soundFile(t, "break.wav"), Player: f}
+
+	config := &pomodoro.IntervalConfig{}
+	s.Attach(config)
+	config.OnEnd(pomodoro.Interval{Category: pomodoro.CategoryPomodoro})
+	s.Wait()
+
+	if len(f.files) != 0 {
+		t.Errorf("expected no sound without a work sound, got %v", f.files)
+	}
+
+	config = &pomodoro.IntervalConfig{}
+	(&hooks.Sounds{Player: f}).Attach(config)
+	if config.OnEnd != nil {
+		t.Errorf("expected no end callback without sounds")
+	}
+}
+
+func TestSoundMissingFile(t *testing.T) {
+	f := &fakePlayer{}
+	var buf bytes.Buffer
+	s := &hooks.Sounds{
+		WorkEnd: filepath.Join(t.TempDir(), "missing.wav"),
+		Player:  f,
+		Logger:  log.New(&buf, "", 0),
+	}
+
+	config := &pomodoro.IntervalConfig{}
+	s.Attach(config)
+	for n := 0; n < 3; n++ {
+		config.OnEnd(pomodoro.Interval{Category: pomodoro.CategoryPomodoro})
+	}
+	s.Wait()
+
+	if len(f.files) != 0 {
+		t.Errorf("expected missing file not played, got %v", f.files)
+	}
+	if n := strings.Count(buf.String(), "\n"); n != 1 || !strings.Contains(buf.String(), "missing.wav") {
+		t.Errorf("expected a single warning, got %d: %q", n, buf.String())
+	}
+}
@@ -0,0 +1,26 @@
+package pomodoro
+
+import "time"
+
+// IdleChecker reports for how long the user has been away, e.g. without
+// touching the keyboard or mouse
+type IdleChecker interface {
+	IdleFor() (time.Duration, error)
+}
+
+// idle reports whether i should be paused because the user is idle. Only
+// work is paused, breaks are meant to be spent away. A failing checker
+// never interrupts the interval.
+func (c *IntervalConfig) idle(i Interval) bool {
+	if c.IdleChecker == nil || c.IdleThreshold <= 0 {
+		return false
+	}
+	if i.Category != CategoryPomodoro && i.Category != CategorySnooze {
+		return false
+	}
+	d, err := c.IdleChecker.IdleFor()
+	if err != nil {
+		return false
+	}
+	return d >= c.IdleThreshold
+}
@@ -0,0 +1,4 @@
+// Package idle provides pomodoro.IdleChecker implementations. The X11 one,
+// based on xprintidle, is only built with the xprintidle build tag. Other
+// builds get a checker that never reports the user as idle.
+package idle
@@ -0,0 +1,21 @@
+//go:build !xprintidle
+
+package idle
+
+import (
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro"
+)
+
+type stub struct{}
+
+// New returns a checker that always reports zero idle time. Build with the
+// xprintidle tag for X11 idle detection
+func New() pomodoro.IdleChecker {
+	return stub{}
+}
+
+func (stub) IdleFor() (time.Duration, error) {
+	return 0, nil
+}
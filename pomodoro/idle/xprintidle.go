@@ -0,0 +1,40 @@
+//go:build xprintidle
+
+package idle
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro"
+)
+
+// timeout bounds each call to xprintidle
+const timeout = 2 * time.Second
+
+type xprintidle struct{}
+
+// New returns a checker running xprintidle, which prints the X11 idle time
+// in milliseconds
+func New() pomodoro.IdleChecker {
+	return xprintidle{}
+}
+
+func (xprintidle) IdleFor() (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "xprintidle").Output()
+	if err != nil {
+		return 0, fmt.Errorf("running xprintidle: %w", err)
+	}
+	ms, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing xprintidle output %q: %w", out, err)
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
@@ -11,5 +11,11 @@ import (
 
 func getRepo(t *testing.T) (pomodoro.Repository, func()) {
 	t.Helper()
-	return repository.NewInMemoryRepo(), func() {}
+
+	memRepo := repository.NewInMemoryRepo()
+	return memRepo, func() {
+		if err := memRepo.Close(); err != nil {
+			t.Error(err)
+		}
+	}
 }
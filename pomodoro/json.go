@@ -0,0 +1,173 @@
+package pomodoro
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// JSONOptions tunes the JSON representation of intervals
+type JSONOptions struct {
+	// DurationSeconds writes durations as a number of seconds instead of
+	// strings like "25m0s"
+	DurationSeconds bool
+}
+
+// intervalJSON is the JSON representation of an Interval. Durations and the
+// start time are raw so they can be written in more than one format
+type intervalJSON struct {
+	ID              int64           `json:"id"`
+	StartTime       *time.Time      `json:"start_time"`
+	PlannedDuration json.RawMessage `json:"planned_duration"`
+	ActualDuration  json.RawMessage `json:"actual_duration"`
+	Category        string          `json:"category"`
+	State           json.RawMessage `json:"state"`
+	Label           string          `json:"label,omitempty"`
+	DailyOrdinal    int             `json:"daily_ordinal,omitempty"`
+	Interruptions   int             `json:"interruptions,omitempty"`
+	Note            string          `json:"note,omitempty"`
+	TaskID          int64           `json:"task_id,omitempty"`
+	AutoPaused      bool            `json:"auto_paused,omitempty"`
+	PausedDuration  json.RawMessage `json:"paused_duration,omitempty"`
+	PausedAt        *time.Time      `json:"paused_at,omitempty"`
+	Device          string          `json:"device,omitempty"`
+}
+
+// MarshalJSON writes the interval with an RFC 3339 start time, durations
+// like "25m0s" and the state by name, e.g. "Done". A zero start time is
+// written as null
+func (i Interval) MarshalJSON() ([]byte, error) {
+	return i.MarshalJSONWith(JSONOptions{})
+}
+
+// MarshalJSONWith is like MarshalJSON with the given options
+func (i Interval) MarshalJSONWith(o JSONOptions) ([]byte, error) {
+	state, err := json.Marshal(StateName(i.State))
+	if err != nil {
+		return nil, err
+	}
+
+	v := intervalJSON{
+		ID:              i.ID,
+		PlannedDuration: marshalDuration(i.PlannedDuration, o),
+		ActualDuration:  marshalDuration(i.ActualDuration, o),
+		Category:        i.Category,
+		State:           state,
+		Label:           i.Label,
+		DailyOrdinal:    i.DailyOrdinal,
+		Interruptions:   i.Interruptions,
+		Note:            i.Note,
+		TaskID:          i.TaskID,
+		AutoPaused:      i.AutoPaused,
+		Device:          i.Device,
+	}
+	if !i.StartTime.IsZero() {
+		v.StartTime = &i.StartTime
+	}
+	if i.PausedDuration != 0 {
+		v.PausedDuration = marshalDuration(i.PausedDuration, o)
+	}
+	if !i.PausedAt.IsZero() {
+		v.PausedAt = &i.PausedAt
+	}
+	return json.Marshal(v)
+}
+
+func marshalDuration(d time.Duration, o JSONOptions) json.RawMessage {
+	if o.DurationSeconds {
+		return json.RawMessage(strconv.FormatFloat(d.Seconds(), 'f', -1, 64))
+	}
+	return json.RawMessage(strconv.Quote(d.String()))
+}
+
+// UnmarshalJSON reads intervals written by MarshalJSON or MarshalJSONWith.
+// Durations may be strings or seconds and states names or numbers
+func (i *Interval) UnmarshalJSON(data []byte) error {
+	var v intervalJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	planned, err := unmarshalDuration(v.PlannedDuration)
+	if err != nil {
+		return fmt.Errorf("planned_duration: %w", err)
+	}
+	actual, err := unmarshalDuration(v.ActualDuration)
+	if err != nil {
+		return fmt.Errorf("actual_duration: %w", err)
+	}
+	paused, err := unmarshalDuration(v.PausedDuration)
+	if err != nil {
+		return fmt.Errorf("paused_duration: %w", err)
+	}
+	state, err := unmarshalState(v.State)
+	if err != nil {
+		return fmt.Errorf("state: %w", err)
+	}
+
+	*i = Interval{
+		ID:              v.ID,
+		PlannedDuration: planned,
+		ActualDuration:  actual,
+		Category:        v.Category,
+		State:           state,
+		Label:           v.Label,
+		DailyOrdinal:    v.DailyOrdinal,
+		Interruptions:   v.Interruptions,
+		Note:            v.Note,
+		TaskID:          v.TaskID,
+		AutoPaused:      v.AutoPaused,
+		Device:          v.Device,
+		PausedDuration:  paused,
+	}
+	if v.StartTime != nil {
+		i.StartTime = *v.StartTime
+	}
+	if v.PausedAt != nil {
+		i.PausedAt = *v.PausedAt
+	}
+	return nil
+}
+
+func unmarshalDuration(raw json.RawMessage) (time.Duration, error) {
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return 0, nil
+	}
+	if raw[0] == '"' {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return 0, err
+		}
+		return time.ParseDuration(s)
+	}
+	var secs float64
+	if err := json.Unmarshal(raw, &secs); err != nil {
+		return 0, err
+	}
+	return time.Duration(math.Round(secs * float64(time.Second))), nil
+}
+
+func unmarshalState(raw json.RawMessage) (int, error) {
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return StateNotStarted, nil
+	}
+	if raw[0] != '"' {
+		var n int
+		err := json.Unmarshal(raw, &n)
+		return n, err
+	}
+
+	var name string
+	if err := json.Unmarshal(raw, &name); err != nil {
+		return 0, err
+	}
+	for state, n := range stateNames {
+		if n == name {
+			return state, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %q", ErrInvalidState, name)
+}
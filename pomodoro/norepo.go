@@ -0,0 +1,51 @@
+package pomodoro
+
+import "time"
+
+// noRepo stands in for a missing repository so every operation fails with
+// ErrNoRepository instead of a nil pointer dereference
+type noRepo struct{}
+
+func (noRepo) Create(Interval) (int64, error)                        { return 0, ErrNoRepository }
+func (noRepo) Update(Interval) error                                 { return ErrNoRepository }
+func (noRepo) UpdateProgress(int64, int64, time.Duration, int) error { return ErrNoRepository }
+func (noRepo) ByID(int64) (Interval, error)                          { return Interval{}, ErrNoRepository }
+func (noRepo) Last() (Interval, error)                               { return Interval{}, ErrNoRepository }
+func (noRepo) DeleteOlderThan(time.Time) (int64, error)              { return 0, ErrNoRepository }
+func (noRepo) Archive(time.Time) (int64, error)                      { return 0, ErrNoRepository }
+func (noRepo) ByRange(time.Time, time.Time) ([]Interval, error)      { return nil, ErrNoRepository }
+func (noRepo) Delete(int64) error                                    { return ErrNoRepository }
+func (noRepo) Find(Filter) ([]Interval, error)                       { return nil, ErrNoRepository }
+func (noRepo) Breaks(int) ([]Interval, error)                        { return nil, ErrNoRepository }
+func (noRepo) CategorySummary(time.Time, string, float64) (time.Duration, error) {
+	return 0, ErrNoRepository
+}
+func (noRepo) DailyTotals(time.Time, time.Time, string, float64, string) (map[string]time.Duration, error) {
+	return nil, ErrNoRepository
+}
+func (noRepo) CompletedPerDay(time.Time, time.Time) (map[string]int, error) {
+	return nil, ErrNoRepository
+}
+func (noRepo) StateCounts(time.Time, time.Time, string) (map[int]int, error) {
+	return nil, ErrNoRepository
+}
+func (noRepo) AddTag(int64, string) error      { return ErrNoRepository }
+func (noRepo) TagsFor(int64) ([]string, error) { return nil, ErrNoRepository }
+func (noRepo) TagSummary(time.Time, time.Time) (map[string]time.Duration, error) {
+	return nil, ErrNoRepository
+}
+func (noRepo) DeviceSummary(time.Time, time.Time) (map[string]time.Duration, error) {
+	return nil, ErrNoRepository
+}
+func (noRepo) LabelSummary(time.Time, time.Time) ([]LabelTotal, error) {
+	return nil, ErrNoRepository
+}
+func (noRepo) EventsFor(int64) ([]StateEvent, error)               { return nil, ErrNoRepository }
+func (noRepo) TotalCompleted() (int64, error)                      { return 0, ErrNoRepository }
+func (noRepo) AddInterruption(int64) error                         { return ErrNoRepository }
+func (noRepo) SetNote(int64, string) error                         { return ErrNoRepository }
+func (noRepo) WorkSinceLongBreak(time.Time) (time.Duration, error) { return 0, ErrNoRepository }
+func (noRepo) CreateTask(Task) (int64, error)                      { return 0, ErrNoRepository }
+func (noRepo) TaskByName(string) (Task, error)                     { return Task{}, ErrNoRepository }
+func (noRepo) Tasks() ([]Task, error)                              { return nil, ErrNoRepository }
+func (noRepo) TaskTotals(int64) (int64, time.Duration, error)      { return 0, 0, ErrNoRepository }
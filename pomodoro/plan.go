@@ -0,0 +1,77 @@
+package pomodoro
+
+import (
+	"fmt"
+	"time"
+)
+
+// PlannedInterval is a slot of the day plan. A zero Duration uses the
+// duration configured for the category
+type PlannedInterval struct {
+	Category string
+	Duration time.Duration
+}
+
+// planCategories are the intervals that fill plan slots. Snoozes are extra
+// work on top of the plan
+var planCategories = []string{CategoryPomodoro, CategoryShortBreak, CategoryLongBreak}
+
+// planSlot returns the index of the plan slot the next interval fills and
+// false when there's no plan or it's exhausted. The position is derived
+// from the intervals completed or skipped today, so cancelled intervals
+// repeat their slot and the plan survives restarts.
+func (c *IntervalConfig) planSlot() (int, bool, error) {
+	if len(c.Plan) == 0 {
+		return 0, false, nil
+	}
+
+	now := c.today()
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	filled, err := c.store().Find(Filter{
+		From:       start,
+		To:         start.AddDate(0, 0, 1),
+		Categories: planCategories,
+		States:     []int{StateDone, StateSkipped},
+	})
+	if err != nil {
+		return 0, false, fmt.Errorf("counting today's intervals: %w", err)
+	}
+
+	n := len(filled)
+	// An unfinished interval fills its own slot, what comes next is the
+	// slot after it
+	i, ok, err := activeInterval(c)
+	if err != nil {
+		return 0, false, err
+	}
+	if ok && i.Category != CategorySnooze {
+		n++
+	}
+
+	if n >= len(c.Plan) {
+		return 0, false, nil
+	}
+	return n, true, nil
+}
+
+// next returns the category and planned duration of the next interval,
+// following the day plan when there's one
+func next(config *IntervalConfig) (string, time.Duration, error) {
+	slot, ok, err := config.planSlot()
+	if err != nil {
+		return "", 0, err
+	}
+	if ok {
+		p := config.Plan[slot]
+		if p.Duration > 0 {
+			return p.Category, p.Duration, nil
+		}
+		return p.Category, plannedDuration(config, p.Category), nil
+	}
+
+	category, err := nextCategory(config)
+	if err != nil {
+		return "", 0, err
+	}
+	return category, plannedDuration(config, category), nil
+}
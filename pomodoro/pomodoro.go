@@ -5,14 +5,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"sync"
 	"time"
+
+	"github.com/snirkop89/pomo/pomodoro/schedule"
 )
 
 // Category constants
 const (
-	CategoryPomodoro   = "Pomodoro"
-	CategoryShortBreak = "ShortBreak"
-	CategoryLongBreak  = "LongBreak"
+	CategoryPomodoro   = schedule.CategoryPomodoro
+	CategoryShortBreak = schedule.CategoryShortBreak
+	CategoryLongBreak  = schedule.CategoryLongBreak
+	CategorySnooze     = schedule.CategorySnooze
 )
 
 // State constants
@@ -22,8 +27,28 @@ const (
 	StatePaused
 	StateDone
 	StateCancelled
+	// StateSkipped is the state of intervals moved past without running
+	// them out. Summaries never count them
+	StateSkipped
 )
 
+var stateNames = map[int]string{
+	StateNotStarted: "NotStarted",
+	StateRunning:    "Running",
+	StatePaused:     "Paused",
+	StateDone:       "Done",
+	StateCancelled:  "Cancelled",
+	StateSkipped:    "Skipped",
+}
+
+// StateName returns a human readable name for the state
+func StateName(state int) string {
+	if name, ok := stateNames[state]; ok {
+		return name
+	}
+	return fmt.Sprintf("State(%d)", state)
+}
+
 type Interval struct {
 	ID              int64
 	StartTime       time.Time
@@ -31,23 +56,213 @@ type Interval struct {
 	ActualDuration  time.Duration
 	Category        string
 	State           int
+	// Label describes what the interval was about, e.g. the task name
+	Label string
+	// DailyOrdinal is the number of the pomodoro within its day, starting
+	// at 1. It's zero for breaks
+	DailyOrdinal int
+	// Interruptions counts the times work was interrupted. Repositories
+	// only change it through AddInterruption so it can't be lost to a
+	// concurrent Update
+	Interruptions int
+	// Note is free text attached to the interval, usually after it's done.
+	// Repositories only change it through SetNote
+	Note string
+	// TaskID links a pomodoro to a Task. It's zero when there's none
+	TaskID int64
+	// AutoPaused is set when the interval was paused because the user was
+	// idle, and cleared when it's started again
+	AutoPaused bool
+	// PausedDuration is the time spent in finished pauses and PausedAt
+	// the time the current pause began, zero when not paused
+	PausedDuration time.Duration
+	PausedAt       time.Time
+	// Device names the machine the interval was recorded on. It's empty
+	// for intervals recorded before devices were. Repositories only set it
+	// on Create
+	Device string
+	// Version counts the updates of the interval. Repositories use it to
+	// detect concurrent writers, e.g. another pomo process
+	Version int64
+}
+
+// ProjectedEnd returns the time the interval ends, or ended, given the
+// current time. Time spent paused pushes it back, and a paused interval
+// is projected as if resumed now.
+func (i Interval) ProjectedEnd(now time.Time) time.Time {
+	switch i.State {
+	case StateNotStarted:
+		return now.Add(i.PlannedDuration)
+	case StateDone, StateCancelled, StateSkipped:
+		return i.StartTime.Add(i.PausedDuration + i.ActualDuration)
+	}
+
+	end := i.StartTime.Add(i.PausedDuration + i.PlannedDuration)
+	if i.State == StatePaused && !i.PausedAt.IsZero() {
+		end = end.Add(now.Sub(i.PausedAt))
+	}
+	return end
+}
+
+// Completed reports whether i counts as completed in summaries. Done
+// intervals always do. Cancelled ones only do when cancelledRatio is
+// positive and they ran for at least that fraction of their planned duration
+func (i Interval) Completed(cancelledRatio float64) bool {
+	switch i.State {
+	case StateDone:
+		return true
+	case StateCancelled:
+		return cancelledRatio > 0 &&
+			float64(i.ActualDuration) >= float64(i.PlannedDuration)*cancelledRatio
+	}
+	return false
 }
 
 type Repository interface {
 	Create(i Interval) (int64, error)
+	// Update stores the changes to an interval, failing with ErrInvalidID
+	// if there's none with its ID. It fails with ErrConflict if the
+	// interval was updated since i was read, as told by its Version, and
+	// bumps the stored version otherwise
 	Update(i Interval) error
+	// UpdateProgress stores the elapsed time and state of an interval,
+	// leaving its other fields alone. It's what ticking uses, so it can't
+	// clobber the start time with a stale copy. Like Update, it fails with
+	// ErrConflict if the stored version isn't the given one
+	UpdateProgress(id, version int64, actual time.Duration, state int) error
+	// ByID returns the interval with the given ID, failing with
+	// ErrInvalidID if there's none
 	ByID(id int64) (Interval, error)
 	Last() (Interval, error)
 	Breaks(n int) ([]Interval, error)
-	CategorySummary(day time.Time, filter string) (time.Duration, error)
+	// Find returns the intervals selected by the filter
+	Find(f Filter) ([]Interval, error)
+	// ByRange returns the intervals started in [start, end) ordered by
+	// start time. Intervals that never started are left out
+	ByRange(start, end time.Time) ([]Interval, error)
+	// Delete removes an interval. Running intervals can't be deleted
+	Delete(id int64) error
+	// DeleteOlderThan removes the intervals started before t and returns
+	// how many were removed. The last interval is kept if it's unfinished
+	DeleteOlderThan(t time.Time) (int64, error)
+	// Archive moves the done, cancelled and skipped intervals started before
+	// olderThan to the archive and returns how many were moved. The last
+	// interval is never archived. Archived intervals are kept out of every
+	// query but Find with Filter.IncludeArchived and TagsFor
+	Archive(olderThan time.Time) (int64, error)
+	// CategorySummary sums the time of the intervals started on day whose
+	// category matches filter and that count as completed according to
+	// Interval.Completed
+	CategorySummary(day time.Time, filter string, cancelledRatio float64) (time.Duration, error)
+	// DeviceSummary sums the time of the done pomodoros started in
+	// [start, end) by Interval.Device
+	DeviceSummary(start, end time.Time) (map[string]time.Duration, error)
+	// DailyTotals is CategorySummary for the intervals started in
+	// [start, end), keyed by calendar day in the location of start
+	// formatted with DayLayout. Days without intervals are left out. Unless
+	// tag is empty, only the intervals with that tag are summed
+	DailyTotals(start, end time.Time, filter string, cancelledRatio float64, tag string) (map[string]time.Duration, error)
+	// CompletedPerDay counts the pomodoros completed on each day of
+	// [start, end), keyed like DailyTotals. Days without any are left out
+	CompletedPerDay(start, end time.Time) (map[string]int, error)
+	// StateCounts counts the intervals of category started in
+	// [start, end) by state. States without intervals are left out
+	StateCounts(start, end time.Time, category string) (map[int]int, error)
+	// AddTag tags an interval, normalizing the tag with NormalizeTag.
+	// Adding a tag the interval already has does nothing
+	AddTag(intervalID int64, tag string) error
+	// TagsFor returns the tags of an interval in alphabetical order
+	TagsFor(intervalID int64) ([]string, error)
+	// TagSummary sums the time of the done intervals started in
+	// [start, end) by tag
+	TagSummary(start, end time.Time) (map[string]time.Duration, error)
+	// LabelSummary counts the done pomodoros started in [start, end) and
+	// sums their time by label, grouping unlabeled ones under NoLabel. It's
+	// sorted by time, longest first, then by label
+	LabelSummary(start, end time.Time) ([]LabelTotal, error)
+	// EventsFor returns the state changes recorded by Update for an
+	// interval, oldest first. It fails with ErrInvalidID if there's no
+	// such interval
+	EventsFor(id int64) ([]StateEvent, error)
+	// TotalCompleted counts all the pomodoros ever completed
+	TotalCompleted() (int64, error)
+	// AddInterruption increments the interruptions of an interval
+	AddInterruption(id int64) error
+	// SetNote replaces the note of an interval leaving other fields alone
+	SetNote(id int64, note string) error
+	// WorkSinceLongBreak sums the work done on day after the last long break
+	WorkSinceLongBreak(day time.Time) (time.Duration, error)
+
+	// CreateTask stores a task and returns its ID. It fails with
+	// ErrTaskExists if a task with the same name exists
+	CreateTask(t Task) (int64, error)
+	// TaskByName returns the task with that name or ErrTaskNotFound
+	TaskByName(name string) (Task, error)
+	// Tasks returns all tasks ordered by ID
+	Tasks() ([]Task, error)
+	// TaskTotals returns the completed pomodoros and the focus time of all
+	// pomodoros linked to a task
+	TaskTotals(taskID int64) (int64, time.Duration, error)
+}
+
+// Importer is implemented by repositories able to store many intervals at
+// once. Import skips intervals that never started and duplicates, matched
+// by start time and category, and returns the number stored. Either all
+// intervals are stored or none are
+type Importer interface {
+	Import(intervals []Interval) (int, error)
+}
+
+// BatchCreator is implemented by repositories able to create many
+// intervals faster than one by one. CreateBatch returns the IDs of the
+// intervals in the order given. Either all intervals are stored or none
+// are
+type BatchCreator interface {
+	CreateBatch(is []Interval) ([]int64, error)
+}
+
+// Transactor is implemented by repositories able to group operations. The
+// repository given to fn applies them all or, if fn fails, none
+type Transactor interface {
+	RunInTransaction(fn func(Repository) error) error
+}
+
+// Backuper is implemented by repositories able to copy their data to a
+// file while in use. Backup fails with an error wrapping fs.ErrExist when
+// dest exists, unless force is set, and returns the size of the backup
+type Backuper interface {
+	Backup(dest string, force bool) (int64, error)
+}
+
+// DurationUnit is implemented by repositories storing durations in units
+// coarser than a nanosecond, such as whole seconds. The planned durations
+// of new intervals are rounded to the nearest unit, so intervals run for
+// as long as they're stored to last. Units that aren't positive leave
+// them alone
+type DurationUnit interface {
+	DurationUnit() time.Duration
+}
+
+// Stamper is implemented by repositories stamping the state events they
+// record with the time. NewConfig has them take it from its Clock, so the
+// events line up with the intervals
+type Stamper interface {
+	SetNow(now func() time.Time)
 }
 
 var (
-	ErrNoIntervals        = errors.New("no intervals")
-	ErrIntervalNotRunning = errors.New("nterval not running")
-	ErrIntervalCompleted  = errors.New("interval is completed or cancelled")
-	ErrInvalidState       = errors.New("invalid state")
-	ErrInvalidID          = errors.New("invalid ID")
+	ErrNoIntervals            = errors.New("no intervals")
+	ErrIntervalNotRunning     = errors.New("interval not running")
+	ErrIntervalNotPaused      = errors.New("interval not paused")
+	ErrIntervalAlreadyRunning = errors.New("interval already running")
+	ErrIntervalCompleted      = errors.New("interval is completed or cancelled")
+	ErrInvalidState           = errors.New("invalid state")
+	ErrInvalidID              = errors.New("invalid ID")
+	ErrNothingToSnooze        = errors.New("no completed pomodoro to snooze")
+	ErrNothingToSkip          = errors.New("no interval to skip")
+	ErrNoRepository           = errors.New("no repository configured")
+	ErrConflict               = errors.New("interval changed by another writer")
+	ErrReadOnly               = errors.New("repository is read-only")
 )
 
 type IntervalConfig struct {
@@ -55,6 +270,96 @@ type IntervalConfig struct {
 	PomodoroDuration   time.Duration
 	ShortBreakDuration time.Duration
 	LongBreakDuration  time.Duration
+	// Clock drives ticking and timestamps. Defaults to RealClock
+	Clock Clock
+	// Location is used to split intervals into days for summaries and
+	// daily counts. Defaults to time.Local
+	Location *time.Location
+	// AutoStart starts the next interval as soon as the current one ends
+	AutoStart bool
+	// PauseOnExit pauses the running interval when its context is
+	// cancelled, e.g. on exit, so it can be resumed later. By default it's
+	// cancelled
+	PauseOnExit bool
+	// MicroBreakEvery, when non-zero, calls OnMicroBreak every time this
+	// much work has elapsed in a pomodoro, without ending it
+	MicroBreakEvery time.Duration
+	OnMicroBreak    Callback
+	// Label is attached to every new pomodoro
+	Label string
+	// Device is recorded on every new interval. Defaults to the host name
+	Device string
+	// Task is the name of the task new pomodoros are linked to. When it
+	// doesn't exist, GetInterval fails with ErrTaskNotFound unless
+	// AutoCreateTasks is set
+	Task            string
+	AutoCreateTasks bool
+
+	// LongBreakAfter, when non-zero, triggers long breaks based on the work
+	// done today since the last long break instead of the number of
+	// pomodoros. It takes precedence over the pomodoro count
+	LongBreakAfter time.Duration
+
+	// SummaryCancelledRatio lets summaries count cancelled intervals that
+	// ran for at least this fraction of their planned duration, e.g. 0.8.
+	// By default, zero, only done intervals are counted
+	SummaryCancelledRatio float64
+	// WeekdayMode picks whether WeekdaySummary averages or totals the
+	// weeks. Defaults to WeekdayAverage
+	WeekdayMode WeekdayMode
+	// WeekStartsSunday starts the weeks compared by CompareWeeks on Sunday
+	// instead of Monday
+	WeekStartsSunday bool
+	// ReportRounding rounds the durations shown by reports to a multiple
+	// of it, as ReportRoundMode picks, e.g. 15 minutes for billing. Zero
+	// shows them exactly
+	ReportRounding  time.Duration
+	ReportRoundMode RoundMode
+
+	// IdleChecker, when set along with IdleThreshold, is asked for the
+	// user's idle time on every tick of a work interval. The interval is
+	// paused once the idle time exceeds the threshold and must be started
+	// again explicitly
+	IdleChecker   IdleChecker
+	IdleThreshold time.Duration
+
+	// DailyGoal is the number of pomodoros to complete each day during
+	// the working day, which spans from WorkdayStart to WorkdayEnd after
+	// midnight, 9:00 to 17:00 when both are zero
+	DailyGoal    int
+	WorkdayStart time.Duration
+	WorkdayEnd   time.Duration
+
+	// AdaptiveBreaks shortens short breaks by BreakReduction, a fraction
+	// defaulting to DefaultBreakReduction, while the day is behind the
+	// DailyGoal pace. Breaks are never shortened below MinShortBreak,
+	// DefaultMinShortBreak when zero. The shortened duration is stored as
+	// the PlannedDuration of the break
+	AdaptiveBreaks bool
+	BreakReduction float64
+	MinShortBreak  time.Duration
+
+	// Plan lays out the intervals of the day, e.g. four pomodoros, a long
+	// lunch break and four more pomodoros. It's followed by counting the
+	// intervals completed today, and the regular cycle takes over once
+	// it's exhausted
+	Plan []PlannedInterval
+
+	// CompletionThreshold is the fraction of the planned duration after
+	// which cancelling a running interval marks it as done instead, e.g. 0.9.
+	// Zero disables it
+	CompletionThreshold float64
+
+	// Lifecycle callbacks, called for every interval run with this config
+	// in addition to the callbacks given to Start. OnCancel receives an
+	// interval in StateDone when it was counted as completed anyway
+	OnStart  Callback
+	OnEnd    Callback
+	OnPause  Callback
+	OnResume Callback
+	OnCancel Callback
+
+	runs *runGuard
 }
 
 func NewConfig(repo Repository, pomodoro, shortBreak, longBreak time.Duration) *IntervalConfig {
@@ -63,6 +368,8 @@ func NewConfig(repo Repository, pomodoro, shortBreak, longBreak time.Duration) *
 		PomodoroDuration:   25 * time.Minute,
 		ShortBreakDuration: 5 * time.Minute,
 		LongBreakDuration:  15 * time.Minute,
+		Clock:              RealClock,
+		runs:               newRunGuard(),
 	}
 
 	if pomodoro > 0 {
@@ -77,152 +384,845 @@ func NewConfig(repo Repository, pomodoro, shortBreak, longBreak time.Duration) *
 		c.LongBreakDuration = longBreak
 	}
 
+	// c.Now reads the Clock on every call, so setting it later still counts
+	if s, ok := repo.(Stamper); ok {
+		s.SetNow(c.Now)
+	}
+
 	return c
 }
 
-func nextCategory(r Repository) (string, error) {
-	li, err := r.Last()
-	if err != nil && err == ErrNoIntervals {
-		return CategoryPomodoro, nil
+// EphemeralRepository returns a new, empty repository keeping intervals in
+// memory for NewEphemeralConfig. The repository package, which can't be
+// imported from here, sets it to its in-memory repository
+var EphemeralRepository func() Repository
+
+// NewEphemeralConfig returns a config keeping its intervals in a fresh
+// in-memory repository, so nothing is read from or written to a database.
+// It's meant for demos and trying out settings. Unless EphemeralRepository
+// is set, every call fails with ErrNoRepository
+func NewEphemeralConfig(pomodoro, shortBreak, longBreak time.Duration) *IntervalConfig {
+	var repo Repository
+	if EphemeralRepository != nil {
+		repo = EphemeralRepository()
 	}
-	if err != nil {
-		return "", err
+	return NewConfig(repo, pomodoro, shortBreak, longBreak)
+}
+
+// runGuard tracks the intervals with an active tick loop so the same
+// interval is never ticked twice. Its lock also serializes the creation of
+// new intervals
+type runGuard struct {
+	sync.Mutex
+	active map[int64]bool
+}
+
+func newRunGuard() *runGuard {
+	return &runGuard{active: make(map[int64]bool)}
+}
+
+func (g *runGuard) release(id int64) {
+	g.Lock()
+	defer g.Unlock()
+	delete(g.active, id)
+}
+
+// defaultGuard is shared by configs not created with NewConfig
+var defaultGuard = newRunGuard()
+
+func (c *IntervalConfig) guard() *runGuard {
+	if c.runs == nil {
+		return defaultGuard
+	}
+	return c.runs
+}
+
+// notify calls cb with i if the callback is set
+func notify(cb Callback, i Interval) {
+	if cb != nil {
+		cb(i)
 	}
+}
+
+// store returns the repository the config works with. Without one, it
+// returns a repository failing every call with ErrNoRepository
+func (c *IntervalConfig) store() Repository {
+	if c.repo == nil {
+		return noRepo{}
+	}
+	return c.repo
+}
+
+// inTransaction calls fn with a copy of the config whose repository runs
+// every call in one transaction, when the repository supports it
+func (c *IntervalConfig) inTransaction(fn func(c *IntervalConfig) error) error {
+	t, ok := c.store().(Transactor)
+	if !ok {
+		return fn(c)
+	}
+	return t.RunInTransaction(func(repo Repository) error {
+		tc := *c
+		tc.repo = repo
+		return fn(&tc)
+	})
+}
+
+func (c *IntervalConfig) clock() Clock {
+	if c.Clock == nil {
+		return RealClock
+	}
+	return c.Clock
+}
+
+// Now returns the current time according to the configured clock
+func (c *IntervalConfig) Now() time.Time {
+	return c.clock().Now()
+}
+
+func (c *IntervalConfig) location() *time.Location {
+	if c.Location == nil {
+		return time.Local
+	}
+	return c.Location
+}
+
+// device returns the configured device, or else the host name
+func (c *IntervalConfig) device() string {
+	if c.Device != "" {
+		return c.Device
+	}
+	return hostname()
+}
 
-	// If we are in a break, next stage is work
-	if li.Category == CategoryLongBreak || li.Category == CategoryShortBreak {
-		return CategoryPomodoro, nil
+var (
+	hostnameOnce sync.Once
+	host         string
+)
+
+// hostname returns the name of the machine, read once. It's empty if the
+// system doesn't tell
+func hostname() string {
+	hostnameOnce.Do(func() {
+		host, _ = os.Hostname()
+	})
+	return host
+}
+
+// today returns the current time in the configured location
+func (c *IntervalConfig) today() time.Time {
+	return c.Now().In(c.location())
+}
+
+// policy returns the scheduling policy set by the config
+func (c *IntervalConfig) policy() schedule.Policy {
+	return schedule.Policy{
+		LongBreakAfter: c.LongBreakAfter,
 	}
-	lastBreaks, err := r.Breaks(3)
+}
+
+func (c *IntervalConfig) history() repoHistory {
+	return repoHistory{repo: c.store(), today: c.today}
+}
+
+func nextCategory(config *IntervalConfig) (string, error) {
+	d, err := schedule.Next(config.history(), config.policy())
 	if err != nil {
 		return "", err
 	}
-	// After every 4th work interval, there should be a long break
-	if len(lastBreaks) < 3 {
-		return CategoryShortBreak, nil
+	return d.Category, nil
+}
+
+// ExplainNext returns the category of the next interval along with the
+// reasons it was chosen
+func ExplainNext(config *IntervalConfig) (schedule.Decision, error) {
+	slot, ok, err := config.planSlot()
+	if err != nil {
+		return schedule.Decision{}, err
 	}
-	// If there was already a long break in the cycle, we'll return a short break
-	for _, i := range lastBreaks {
-		if i.Category == CategoryLongBreak {
-			return CategoryShortBreak, nil
-		}
+	if ok {
+		return schedule.Decision{
+			Category: config.Plan[slot].Category,
+			Reasons:  []string{fmt.Sprintf("slot %d of %d in the day plan", slot+1, len(config.Plan))},
+		}, nil
 	}
-	return CategoryLongBreak, nil
+	return schedule.Next(config.history(), config.policy())
+}
+
+// CyclePosition returns the position of the current or upcoming pomodoro
+// within the cycle leading to a long break
+func CyclePosition(config *IntervalConfig) (int, error) {
+	return schedule.CyclePosition(config.history(), config.policy())
 }
 
 type Callback func(Interval)
 
-func tick(ctx context.Context, id int64, config *IntervalConfig, start, periodic, end Callback) error {
-	ticker := time.NewTicker(time.Second)
+// ExitReason tells why an interval stopped running
+type ExitReason int
+
+const (
+	// ExitDone means the interval ran to completion
+	ExitDone ExitReason = iota
+	// ExitPaused means the interval was paused and can be resumed later
+	ExitPaused
+	// ExitCancelled means the interval was cancelled
+	ExitCancelled
+	// ExitCompletedEarly means the interval was cancelled past the
+	// completion threshold and was counted as done anyway
+	ExitCompletedEarly
+	// ExitSkipped means the interval was skipped, see Skip
+	ExitSkipped
+)
+
+func tick(ctx context.Context, id int64, config *IntervalConfig, start, periodic, end Callback) (ExitReason, error) {
+	clock := config.clock()
+	ticker := clock.NewTicker(time.Second)
 	defer ticker.Stop()
 
-	i, err := config.repo.ByID(id)
+	i, err := config.store().ByID(id)
 	if err != nil {
-		return err
+		return ExitCancelled, fmt.Errorf("fetching interval %d: %w", id, err)
 	}
-	expire := time.After(i.PlannedDuration - i.ActualDuration)
+	expire := clock.After(i.ProjectedEnd(clock.Now()).Sub(clock.Now()))
 
 	start(i)
 
 	for {
+		var step func() (ExitReason, bool, error)
 		select {
 		case <-ticker.C:
-			i, err := config.repo.ByID(id)
-			if err != nil {
-				return err
-			}
-			if i.State == StatePaused {
-				return nil
-			}
-			i.ActualDuration += time.Second
-			if err := config.repo.Update(i); err != nil {
-				return err
-			}
-			periodic(i)
+			step = func() (ExitReason, bool, error) { return tickStep(config, id, periodic) }
 		case <-expire:
-			i, err := config.repo.ByID(id)
-			if err != nil {
-				return err
-			}
-			i.State = StateDone
-			end(i)
-			return config.repo.Update(i)
+			step = func() (ExitReason, bool, error) { return expireStep(config, id, end) }
 		case <-ctx.Done():
-			i, err := config.repo.ByID(id)
-			if err != nil {
-				return err
+			step = func() (ExitReason, bool, error) { return stopStep(config, id) }
+			if config.PauseOnExit {
+				step = func() (ExitReason, bool, error) { return pauseStep(config, id) }
 			}
-			i.State = StateCancelled
-			return config.repo.Update(i)
+		}
+		reason, stop, err := step()
+		if errors.Is(err, ErrConflict) {
+			// Another writer changed the interval since the step read it.
+			// The step reads it again, so it honors the change, e.g.
+			// stopping if the interval was paused or cancelled meanwhile
+			reason, stop, err = step()
+		}
+		if err != nil || stop {
+			return reason, err
 		}
 	}
 }
 
-func newInterval(config *IntervalConfig) (Interval, error) {
-	category, err := nextCategory(config.repo)
+// current fetches interval id for tick, with the reason to stop ticking if
+// it's no longer running
+func current(config *IntervalConfig, id int64) (Interval, ExitReason, bool, error) {
+	i, err := config.store().ByID(id)
 	if err != nil {
-		return Interval{}, err
+		return i, ExitCancelled, true, fmt.Errorf("fetching interval %d: %w", id, err)
+	}
+	switch i.State {
+	case StatePaused:
+		return i, ExitPaused, true, nil
+	case StateDone:
+		return i, ExitDone, true, nil
+	case StateCancelled:
+		return i, ExitCancelled, true, nil
+	case StateSkipped:
+		return i, ExitSkipped, true, nil
+	}
+	return i, ExitDone, false, nil
+}
+
+// tickStep counts a second of the interval, unless the user is idle
+func tickStep(config *IntervalConfig, id int64, periodic Callback) (ExitReason, bool, error) {
+	i, reason, stop, err := current(config, id)
+	if stop {
+		return reason, true, err
+	}
+	if config.idle(i) {
+		i.State = StatePaused
+		i.AutoPaused = true
+		i.PausedAt = config.clock().Now()
+		if err := config.store().Update(i); err != nil {
+			return ExitCancelled, false, fmt.Errorf("pausing idle interval %d: %w", id, err)
+		}
+		i.Version++
+		notify(config.OnPause, i)
+		return ExitPaused, true, nil
+	}
+	prev := i.ActualDuration
+	i.ActualDuration += time.Second
+	if err := config.store().UpdateProgress(id, i.Version, i.ActualDuration, i.State); err != nil {
+		return ExitCancelled, false, fmt.Errorf("updating interval %d: %w", id, err)
+	}
+	i.Version++
+	periodic(i)
+	if config.microBreakDue(i, prev) {
+		config.OnMicroBreak(i)
+	}
+	return ExitDone, false, nil
+}
+
+// expireStep completes the interval once its time is up
+func expireStep(config *IntervalConfig, id int64, end Callback) (ExitReason, bool, error) {
+	i, reason, stop, err := current(config, id)
+	if stop {
+		return reason, true, err
+	}
+	// The ticker and the timer race, so the last ticks may not be
+	// counted yet. A finished interval ran for its planned duration
+	i.ActualDuration = i.PlannedDuration
+	i.State = StateDone
+	if err := config.store().UpdateProgress(id, i.Version, i.ActualDuration, i.State); err != nil {
+		return ExitCancelled, false, fmt.Errorf("completing interval %d: %w", id, err)
+	}
+	i.Version++
+	end(i)
+	notify(config.OnEnd, i)
+	return ExitDone, true, nil
+}
+
+// stopStep cancels the interval, or completes it if it's past the
+// completion threshold
+func stopStep(config *IntervalConfig, id int64) (ExitReason, bool, error) {
+	i, reason, stop, err := current(config, id)
+	if stop {
+		return reason, true, err
 	}
+	reason, err = i.cancel(config)
+	return reason, err == nil, err
+}
+
+// cancel cancels i, or completes it if it's past the completion threshold
+func (i Interval) cancel(config *IntervalConfig) (ExitReason, error) {
+	reason := ExitCancelled
+	i.State = StateCancelled
+	if config.pastThreshold(i) {
+		reason = ExitCompletedEarly
+		i.State = StateDone
+	}
+	if err := config.store().UpdateProgress(i.ID, i.Version, i.ActualDuration, i.State); err != nil {
+		return reason, fmt.Errorf("stopping interval %d: %w", i.ID, err)
+	}
+	i.Version++
+	notify(config.OnCancel, i)
+	return reason, nil
+}
+
+// pauseStep pauses the interval, for PauseOnExit
+func pauseStep(config *IntervalConfig, id int64) (ExitReason, bool, error) {
+	i, reason, stop, err := current(config, id)
+	if stop {
+		return reason, true, err
+	}
+	if err := i.pause(config); err != nil {
+		return ExitPaused, false, err
+	}
+	return ExitPaused, true, nil
+}
 
-	var pd time.Duration
+func plannedDuration(config *IntervalConfig, category string) time.Duration {
 	switch category {
 	case CategoryPomodoro:
-		pd = config.PomodoroDuration
+		return config.PomodoroDuration
 	case CategoryShortBreak:
-		pd = config.ShortBreakDuration
+		return config.ShortBreakDuration
 	case CategoryLongBreak:
-		pd = config.LongBreakDuration
+		return config.LongBreakDuration
+	}
+	return 0
+}
+
+// upcomingInterval builds the next interval in the cycle without storing it
+func upcomingInterval(config *IntervalConfig) (Interval, error) {
+	category, d, err := next(config)
+	if err != nil {
+		return Interval{}, err
 	}
 
 	i := Interval{
-		PlannedDuration: pd,
+		PlannedDuration: d,
 		Category:        category,
+		Device:          config.device(),
+	}
+	if category == CategoryPomodoro {
+		i.Label = config.Label
+	}
+	if i, err = config.adaptBreak(i); err != nil {
+		return Interval{}, err
+	}
+	i.PlannedDuration = config.roundPlanned(i.PlannedDuration)
+	return i, nil
+}
+
+// roundPlanned rounds d to the unit the repository stores durations in
+func (c *IntervalConfig) roundPlanned(d time.Duration) time.Duration {
+	if u, ok := c.store().(DurationUnit); ok {
+		return RoundDuration(d, u.DurationUnit(), RoundNearest)
 	}
+	return d
+}
 
-	if i.ID, err = config.repo.Create(i); err != nil {
+// pastThreshold reports whether i ran long enough to count as completed
+func (c *IntervalConfig) pastThreshold(i Interval) bool {
+	if c.CompletionThreshold <= 0 || i.PlannedDuration <= 0 {
+		return false
+	}
+	return float64(i.ActualDuration)/float64(i.PlannedDuration) >= c.CompletionThreshold
+}
+
+// microBreakDue reports whether the elapsed time of i crossed a micro break
+// boundary since prev. Comparing against the previous elapsed time fires
+// each boundary exactly once, even when the interval is paused around it.
+func (c *IntervalConfig) microBreakDue(i Interval, prev time.Duration) bool {
+	if c.MicroBreakEvery <= 0 || c.OnMicroBreak == nil {
+		return false
+	}
+	if i.Category != CategoryPomodoro {
+		return false
+	}
+	return prev/c.MicroBreakEvery < i.ActualDuration/c.MicroBreakEvery
+}
+
+func newInterval(config *IntervalConfig) (Interval, error) {
+	i, err := upcomingInterval(config)
+	if err != nil {
 		return Interval{}, err
 	}
 
+	if i.Category == CategoryPomodoro {
+		done, err := completedOn(config.store(), config.today())
+		if err != nil {
+			return Interval{}, fmt.Errorf("counting today's pomodoros: %w", err)
+		}
+		i.DailyOrdinal = int(done) + 1
+
+		if i.TaskID, err = config.configTask(); err != nil {
+			return Interval{}, err
+		}
+	}
+
+	if i.ID, err = config.store().Create(i); err != nil {
+		return Interval{}, fmt.Errorf("creating interval: %w", err)
+	}
+
 	return i, nil
 }
 
+// activeInterval returns the last interval if it's not finished yet
+func activeInterval(config *IntervalConfig) (Interval, bool, error) {
+	i, err := config.store().Last()
+	if err == ErrNoIntervals {
+		return Interval{}, false, nil
+	}
+	if err != nil {
+		return Interval{}, false, fmt.Errorf("fetching last interval: %w", err)
+	}
+	if i.State == StateCancelled || i.State == StateDone || i.State == StateSkipped {
+		return Interval{}, false, nil
+	}
+	return i, true, nil
+}
+
+// GetInterval returns the interval to work on, creating it in the repository
+// when the last one is finished. An interval that was created but never
+// started, for example by a previous run of the application, is returned
+// again instead of creating a duplicate.
 func GetInterval(config *IntervalConfig) (Interval, error) {
-	i, err := config.repo.Last()
-	if err != nil && err != ErrNoIntervals {
+	// Concurrent callers must agree on the pending interval instead of
+	// each creating their own
+	g := config.guard()
+	g.Lock()
+	defer g.Unlock()
+
+	// Other processes, and the task created for the interval, are kept
+	// from seeing a half made interval
+	var i Interval
+	err := config.inTransaction(func(c *IntervalConfig) error {
+		var (
+			ok  bool
+			err error
+		)
+		i, ok, err = activeInterval(c)
+		if err != nil || ok {
+			return err
+		}
+		i, err = newInterval(c)
+		return err
+	})
+	if err != nil {
 		return Interval{}, err
 	}
-	// If there's a current running interval
-	if err == nil && i.State != StateCancelled && i.State != StateDone {
-		return i, nil
+	return i, nil
+}
+
+// PeekInterval returns the active interval and true if there's one.
+// Otherwise it returns the interval that would come next, with its category
+// and planned duration, and false. It never writes to the repository.
+func PeekInterval(config *IntervalConfig) (Interval, bool, error) {
+	i, ok, err := activeInterval(config)
+	if err != nil || ok {
+		return i, ok, err
 	}
 
-	return newInterval(config)
+	i, err = upcomingInterval(config)
+	return i, false, err
 }
 
 func (i Interval) Start(ctx context.Context, config *IntervalConfig, start, periodic, end Callback) error {
-	switch i.State {
-	case StateRunning:
+	_, err := i.Run(ctx, config, start, periodic, end)
+	return err
+}
+
+// Run starts or resumes the interval like Start and reports why it stopped
+// running, e.g. ExitCompletedEarly for an interval stopped past the
+// completion threshold, which was counted as done
+func (i Interval) Run(ctx context.Context, config *IntervalConfig, start, periodic, end Callback) (ExitReason, error) {
+	g := config.guard()
+	g.Lock()
+	if g.active[i.ID] {
+		g.Unlock()
+		return ExitCancelled, fmt.Errorf("%w: cannot start interval %d", ErrIntervalAlreadyRunning, i.ID)
+	}
+
+	// Work on the stored state, the caller's copy may be stale. It's read
+	// and written back in one transaction so other processes can't
+	// change it in between
+	id := i.ID
+	var event Callback
+	err := config.inTransaction(func(c *IntervalConfig) error {
+		var err error
+		i, err = c.store().ByID(id)
+		if err != nil {
+			return fmt.Errorf("fetching interval %d: %w", id, err)
+		}
+
+		switch i.State {
+		case StateRunning:
+			// Nothing is ticking it in this process, e.g. the application
+			// was closed while it was running, so attach to it again. The
+			// time it went uncounted is treated as a pause
+			now := c.clock().Now()
+			elapsed := now.Sub(i.StartTime) - i.PausedDuration
+			if gap := elapsed - i.ActualDuration; gap > 0 {
+				i.PausedDuration += gap
+				if err := c.store().Update(i); err != nil {
+					return fmt.Errorf("attaching to interval %d: %w", i.ID, err)
+				}
+			}
+		case StateNotStarted, StatePaused:
+			event = c.OnResume
+			if i.State == StateNotStarted {
+				i.StartTime = c.clock().Now()
+				event = c.OnStart
+			}
+			if i.State == StatePaused && !i.PausedAt.IsZero() {
+				i.PausedDuration += c.clock().Now().Sub(i.PausedAt)
+			}
+			i.State = StateRunning
+			i.AutoPaused = false
+			i.PausedAt = time.Time{}
+			if err := c.store().Update(i); err != nil {
+				return fmt.Errorf("starting interval %d: %w", i.ID, err)
+			}
+		case StateCancelled, StateDone, StateSkipped:
+			return fmt.Errorf("%w: cannot start interval %d", ErrIntervalCompleted, i.ID)
+		default:
+			return fmt.Errorf("%w: cannot start interval %d in state %d", ErrInvalidState, i.ID, i.State)
+		}
 		return nil
-	case StateNotStarted:
-		i.StartTime = time.Now()
-		fallthrough
-	case StatePaused:
-		i.State = StateRunning
-		if err := config.repo.Update(i); err != nil {
-			return err
+	})
+	if err != nil {
+		g.Unlock()
+		if errors.Is(err, ErrIntervalCompleted) {
+			return ExitDone, err
 		}
-		return tick(ctx, i.ID, config, start, periodic, end)
-	case StateCancelled, StateDone:
-		return fmt.Errorf("%w: cannot start", ErrIntervalCompleted)
-	default:
-		return fmt.Errorf("%w: %d", ErrInvalidState, i.State)
+		return ExitCancelled, err
 	}
+
+	g.active[i.ID] = true
+	g.Unlock()
+	defer g.release(i.ID)
+
+	notify(event, i)
+	return tick(ctx, i.ID, config, start, periodic, end)
+}
+
+// ScheduleStart waits until at, according to the configured clock, and then
+// starts the current interval. If at is in the past it starts right away.
+// If ctx is cancelled while waiting, nothing is stored and ctx's error is
+// returned.
+func ScheduleStart(ctx context.Context, config *IntervalConfig, at time.Time, start, periodic, end Callback) error {
+	clock := config.clock()
+	if wait := at.Sub(clock.Now()); wait > 0 {
+		select {
+		case <-clock.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	i, err := GetInterval(config)
+	if err != nil {
+		return err
+	}
+	return i.Start(ctx, config, start, periodic, end)
+}
+
+// RunCycle keeps starting intervals one after the other until ctx is
+// cancelled. When an interval is paused, RunCycle waits for a value on
+// resume and continues the same interval instead of moving on. A skipped
+// interval is followed by the next one right away. It returns why the last
+// interval run stopped running, ExitCancelled if none ran
+func RunCycle(ctx context.Context, config *IntervalConfig, resume <-chan struct{}, start, periodic, end Callback) (ExitReason, error) {
+	last := ExitCancelled
+	for {
+		if ctx.Err() != nil {
+			return last, nil
+		}
+
+		i, err := GetInterval(config)
+		if err != nil {
+			return last, err
+		}
+
+		last, err = i.Run(ctx, config, start, periodic, end)
+		if err != nil {
+			return last, err
+		}
+
+		switch last {
+		case ExitPaused:
+			select {
+			case <-resume:
+			case <-ctx.Done():
+				return last, nil
+			}
+		case ExitCancelled, ExitCompletedEarly:
+			return last, nil
+		}
+	}
+}
+
+// TotalCompleted returns the number of pomodoros completed so far
+func TotalCompleted(config *IntervalConfig) (int64, error) {
+	return config.store().TotalCompleted()
+}
+
+// RecordInterruption counts an interruption on the running interval
+func RecordInterruption(config *IntervalConfig) error {
+	i, err := config.store().Last()
+	if err == ErrNoIntervals {
+		return ErrIntervalNotRunning
+	}
+	if err != nil {
+		return err
+	}
+	if i.State != StateRunning {
+		return fmt.Errorf("%w: cannot record interruption on interval %d in state %s",
+			ErrIntervalNotRunning, i.ID, StateName(i.State))
+	}
+	return config.store().AddInterruption(i.ID)
+}
+
+// DeleteInterval removes the interval with the given id from the
+// repository. A running interval must be paused or stopped first.
+func DeleteInterval(config *IntervalConfig, id int64) error {
+	return config.store().Delete(id)
+}
+
+// Prune removes the intervals started before t, keeping the current
+// interval if it's not finished, and returns the number removed
+func Prune(config *IntervalConfig, t time.Time) (int64, error) {
+	return config.store().DeleteOlderThan(t)
+}
+
+// Archive moves the finished intervals started before t out of the way of
+// everyday queries without deleting them, and returns the number moved
+func Archive(config *IntervalConfig, t time.Time) (int64, error) {
+	return config.store().Archive(t)
+}
+
+// SetNote attaches a note to the interval with the given id. Unlike
+// updating the interval, it works in any state, including done intervals.
+func SetNote(config *IntervalConfig, id int64, note string) error {
+	return config.store().SetNote(id, note)
+}
+
+// SetLabel labels the interval with the given id, e.g. with the task
+// entered right before starting it. Finished intervals keep their label
+// and fail with ErrIntervalCompleted
+func SetLabel(config *IntervalConfig, id int64, label string) (Interval, error) {
+	i, err := setLabel(config, id, label)
+	if errors.Is(err, ErrConflict) {
+		// It ticked since it was read, label it as it's now
+		i, err = setLabel(config, id, label)
+	}
+	return i, err
+}
+
+func setLabel(config *IntervalConfig, id int64, label string) (Interval, error) {
+	i, err := config.store().ByID(id)
+	if err != nil {
+		return Interval{}, err
+	}
+	switch i.State {
+	case StateDone, StateCancelled, StateSkipped:
+		return Interval{}, fmt.Errorf("%w: cannot label interval %d", ErrIntervalCompleted, id)
+	}
+	i.Label = label
+	if err := config.store().Update(i); err != nil {
+		return Interval{}, fmt.Errorf("labeling interval %d: %w", id, err)
+	}
+	i.Version++
+	return i, nil
+}
+
+// LastLabel returns the label of the last pomodoro that started, empty if
+// there's none
+func LastLabel(config *IntervalConfig) (string, error) {
+	last, err := config.store().Find(Filter{
+		Categories: []string{CategoryPomodoro},
+		States:     []int{StateRunning, StatePaused, StateDone, StateCancelled, StateSkipped},
+		Limit:      1,
+		Order:      OrderDesc,
+	})
+	if err != nil || len(last) == 0 {
+		return "", err
+	}
+	return last[0].Label, nil
+}
+
+// SnoozeBreak postpones the upcoming break by creating an extra work
+// interval of duration d right after a completed pomodoro. The snooze
+// doesn't count as a break, so the break that follows it keeps its type.
+func SnoozeBreak(config *IntervalConfig, d time.Duration) (Interval, error) {
+	last, err := config.store().Last()
+	if err == ErrNoIntervals {
+		return Interval{}, ErrNothingToSnooze
+	}
+	if err != nil {
+		return Interval{}, err
+	}
+	if last.State != StateDone || (last.Category != CategoryPomodoro && last.Category != CategorySnooze) {
+		return Interval{}, fmt.Errorf("%w: last interval is %s %s",
+			ErrNothingToSnooze, StateName(last.State), last.Category)
+	}
+
+	i := Interval{
+		PlannedDuration: config.roundPlanned(d),
+		Category:        CategorySnooze,
+		Label:           last.Label,
+		Device:          config.device(),
+	}
+	if i.ID, err = config.store().Create(i); err != nil {
+		return Interval{}, err
+	}
+	return i, nil
+}
+
+// Skip moves past the active interval, whether running, paused or not
+// started yet, marking it StateSkipped and returning it. A running interval
+// stops ticking with ExitSkipped and its end callback isn't called. One
+// that never started is dated when it's skipped, so it fills its slot of
+// the day plan. It fails with ErrNothingToSkip when no interval is active.
+func Skip(config *IntervalConfig) (Interval, error) {
+	i, err := skip(config)
+	if errors.Is(err, ErrConflict) {
+		// It ticked since it was read, skip it as it's now
+		i, err = skip(config)
+	}
+	return i, err
+}
+
+func skip(config *IntervalConfig) (Interval, error) {
+	i, ok, err := activeInterval(config)
+	if err != nil {
+		return Interval{}, err
+	}
+	if !ok {
+		return Interval{}, ErrNothingToSkip
+	}
+	i.State = StateSkipped
+	if i.StartTime.IsZero() {
+		i.StartTime = config.clock().Now()
+	}
+	if err := config.store().Update(i); err != nil {
+		return Interval{}, fmt.Errorf("skipping interval %d: %w", i.ID, err)
+	}
+	i.Version++
+	return i, nil
+}
+
+// Resume continues a paused interval. It fails with ErrIntervalNotPaused
+// for intervals in any other state.
+func (i Interval) Resume(ctx context.Context, config *IntervalConfig, start, periodic, end Callback) error {
+	cur, err := config.store().ByID(i.ID)
+	if err != nil {
+		return err
+	}
+	if cur.State != StatePaused {
+		return fmt.Errorf("%w: cannot resume interval %d in state %s",
+			ErrIntervalNotPaused, cur.ID, StateName(cur.State))
+	}
+	return cur.Start(ctx, config, start, periodic, end)
 }
 
 func (i Interval) Pause(config *IntervalConfig) error {
+	if config.repo == nil {
+		return ErrNoRepository
+	}
+	err := i.pause(config)
+	if errors.Is(err, ErrConflict) {
+		// It changed since i was read, e.g. it ticked. Pause it as it's
+		// now, if it's still running
+		cur, ferr := config.store().ByID(i.ID)
+		if ferr != nil {
+			return ferr
+		}
+		err = cur.pause(config)
+	}
+	return err
+}
+
+// Stop cancels the running or paused interval i, as stopping its runner
+// does. Past the completion threshold it's counted as done instead, which
+// the reason returned tells. It fails with ErrIntervalNotRunning for
+// intervals in any other state
+func (i Interval) Stop(config *IntervalConfig) (ExitReason, error) {
+	if config.repo == nil {
+		return ExitCancelled, ErrNoRepository
+	}
+	reason, err := stopInterval(config, i.ID)
+	if errors.Is(err, ErrConflict) {
+		// It ticked since it was read, stop it as it's now
+		reason, err = stopInterval(config, i.ID)
+	}
+	return reason, err
+}
+
+func stopInterval(config *IntervalConfig, id int64) (ExitReason, error) {
+	i, err := config.store().ByID(id)
+	if err != nil {
+		return ExitCancelled, err
+	}
+	if i.State != StateRunning && i.State != StatePaused {
+		return ExitCancelled, fmt.Errorf("%w: cannot stop interval %d in state %s",
+			ErrIntervalNotRunning, id, StateName(i.State))
+	}
+	return i.cancel(config)
+}
+
+func (i Interval) pause(config *IntervalConfig) error {
 	if i.State != StateRunning {
-		return ErrIntervalNotRunning
+		return fmt.Errorf("%w: cannot pause interval %d in state %s",
+			ErrIntervalNotRunning, i.ID, StateName(i.State))
 	}
 	i.State = StatePaused
-	return config.repo.Update(i)
+	i.PausedAt = config.Now()
+	if err := config.store().Update(i); err != nil {
+		return fmt.Errorf("pausing interval %d: %w", i.ID, err)
+	}
+	i.Version++
+	notify(config.OnPause, i)
+	return nil
 }
@@ -2,12 +2,21 @@ package pomodoro_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/snirkop89/pomo/pomodoro"
+	"github.com/snirkop89/pomo/pomodoro/repository"
+	"github.com/snirkop89/pomo/pomodoro/repository/repotest"
 )
 
 func TestNewConfig(t *testing.T) {
@@ -76,8 +85,8 @@ func TestGetInterval(t *testing.T) {
 	repo, cleanup := getRepo(t)
 	defer cleanup()
 
-	const duration = 1 * time.Millisecond
-	config := pomodoro.NewConfig(repo, 3*duration, duration, 2*duration)
+	const duration = 1 * time.Second
+	config := secondsConfig(repo, 3*duration, duration, 2*duration)
 
 	for i := 1; i <= 16; i++ {
 		var (
@@ -127,6 +136,106 @@ func TestGetInterval(t *testing.T) {
 	}
 }
 
+func TestGetIntervalReusesPending(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config := pomodoro.NewConfig(repo, 0, 0, 0)
+
+	first, err := pomodoro.GetInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := pomodoro.GetInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ID != second.ID {
+		t.Errorf("expected pending interval %d to be reused, got %d", first.ID, second.ID)
+	}
+	if second.State != pomodoro.StateNotStarted {
+		t.Errorf("expected state %d, got %d", pomodoro.StateNotStarted, second.State)
+	}
+}
+
+func TestDevice(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config := pomodoro.NewConfig(repo, 0, 0, 0)
+	config.Device = "desk"
+
+	i, err := pomodoro.GetInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := repo.ByID(i.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.Device != "desk" || stored.Device != "desk" {
+		t.Errorf("expected device %q, got %q and %q stored", "desk", i.Device, stored.Device)
+	}
+}
+
+func TestPeekInterval(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	const duration = 1 * time.Second
+	config := secondsConfig(repo, 3*duration, duration, 2*duration)
+
+	i, ok, err := pomodoro.PeekInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Errorf("expected no active interval")
+	}
+	if i.Category != pomodoro.CategoryPomodoro || i.PlannedDuration != 3*duration {
+		t.Errorf("expected upcoming %q of %s, got %q of %s",
+			pomodoro.CategoryPomodoro, 3*duration, i.Category, i.PlannedDuration)
+	}
+	if _, err := repo.Last(); !errors.Is(err, pomodoro.ErrNoIntervals) {
+		t.Fatalf("expected error %q, got %v", pomodoro.ErrNoIntervals, err)
+	}
+
+	created, err := pomodoro.GetInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i, ok, err = pomodoro.PeekInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || i.ID != created.ID {
+		t.Errorf("expected active interval %d, got %d (active: %t)", created.ID, i.ID, ok)
+	}
+
+	noop := func(pomodoro.Interval) {}
+	if err := created.Start(context.Background(), config, noop, noop, noop); err != nil {
+		t.Fatal(err)
+	}
+	i, ok, err = pomodoro.PeekInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Errorf("expected no active interval")
+	}
+	if i.Category != pomodoro.CategoryShortBreak || i.PlannedDuration != duration {
+		t.Errorf("expected upcoming %q of %s, got %q of %s",
+			pomodoro.CategoryShortBreak, duration, i.Category, i.PlannedDuration)
+	}
+	last, err := repo.Last()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.ID != created.ID {
+		t.Errorf("expected peek not to create intervals, last ID is %d", last.ID)
+	}
+}
+
 func TestPause(t *testing.T) {
 	const duration = 2 * time.Second
 
@@ -168,7 +277,7 @@ func TestPause(t *testing.T) {
 					t.Fatal(err)
 				}
 			}
-			i, err = pomodoro.GetInterval(config)
+			i, _, err = pomodoro.PeekInterval(config)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -270,3 +379,4004 @@ func TestStart(t *testing.T) {
 		})
 	}
 }
+
+func TestPauseOnExit(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config := pomodoro.NewConfig(repo, 5*time.Second, time.Second, time.Second)
+	config.Clock = pomodoro.NewScaledClock(100)
+	config.PauseOnExit = true
+	noop := func(pomodoro.Interval) {}
+
+	i, err := pomodoro.GetInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	periodic := func(pomodoro.Interval) { cancel() }
+	end := func(pomodoro.Interval) {
+		t.Error("expected no end callback for an interval paused on exit")
+	}
+	if err := i.Start(ctx, config, noop, periodic, end); err != nil {
+		t.Fatal(err)
+	}
+
+	if i, err = repo.ByID(i.ID); err != nil {
+		t.Fatal(err)
+	}
+	if i.State != pomodoro.StatePaused {
+		t.Errorf("expected state %d, got %d", pomodoro.StatePaused, i.State)
+	}
+	if i.ActualDuration == 0 || i.ActualDuration >= i.PlannedDuration {
+		t.Errorf("expected the progress kept, got %q of %q", i.ActualDuration, i.PlannedDuration)
+	}
+
+	// The paused interval is the one resumed
+	next, err := pomodoro.GetInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.ID != i.ID {
+		t.Errorf("expected interval %d resumed, got %d", i.ID, next.ID)
+	}
+}
+
+func TestStop(t *testing.T) {
+	testCases := []struct {
+		name      string
+		threshold float64
+		expReason pomodoro.ExitReason
+		expState  int
+	}{
+		{"Cancelled", 0, pomodoro.ExitCancelled, pomodoro.StateCancelled},
+		{"CompletedEarly", 0.1, pomodoro.ExitCompletedEarly, pomodoro.StateDone},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, cleanup := getRepo(t)
+			defer cleanup()
+
+			config := pomodoro.NewConfig(repo, 5*time.Second, time.Second, time.Second)
+			config.Clock = pomodoro.NewScaledClock(100)
+			config.CompletionThreshold = tt.threshold
+			cancelled := 0
+			config.OnCancel = func(pomodoro.Interval) { cancelled++ }
+			noop := func(pomodoro.Interval) {}
+
+			i, err := pomodoro.GetInterval(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Stopping makes the runner return on its next step
+			var reason pomodoro.ExitReason
+			var stopErr error
+			periodic := func(cur pomodoro.Interval) {
+				if cancelled == 0 {
+					reason, stopErr = cur.Stop(config)
+				}
+			}
+			if err := i.Start(context.Background(), config, noop, periodic, noop); err != nil {
+				t.Fatal(err)
+			}
+			if stopErr != nil {
+				t.Fatal(stopErr)
+			}
+			if reason != tt.expReason || cancelled != 1 {
+				t.Errorf("expected reason %d and 1 cancel event, got %d and %d", tt.expReason, reason, cancelled)
+			}
+
+			if i, err = repo.ByID(i.ID); err != nil {
+				t.Fatal(err)
+			}
+			if i.State != tt.expState {
+				t.Errorf("expected state %d, got %d", tt.expState, i.State)
+			}
+			if _, err := i.Stop(config); !errors.Is(err, pomodoro.ErrIntervalNotRunning) {
+				t.Errorf("expected error %q stopping again, got %v", pomodoro.ErrIntervalNotRunning, err)
+			}
+		})
+	}
+}
+
+func TestScaledClockCycle(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config := pomodoro.NewConfig(repo, 20*time.Second, 5*time.Second, 10*time.Second)
+	config.Clock = pomodoro.NewScaledClock(200)
+
+	expCategories := []string{
+		pomodoro.CategoryPomodoro, pomodoro.CategoryShortBreak,
+		pomodoro.CategoryPomodoro, pomodoro.CategoryShortBreak,
+		pomodoro.CategoryPomodoro, pomodoro.CategoryShortBreak,
+		pomodoro.CategoryPomodoro, pomodoro.CategoryLongBreak,
+	}
+
+	noop := func(pomodoro.Interval) {}
+	for _, expCategory := range expCategories {
+		i, err := pomodoro.GetInterval(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i.Category != expCategory {
+			t.Fatalf("expected category %q, got %q", expCategory, i.Category)
+		}
+		if err := i.Start(context.Background(), config, noop, noop, noop); err != nil {
+			t.Fatal(err)
+		}
+		i, err = repo.ByID(i.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i.State != pomodoro.StateDone {
+			t.Errorf("expected state %d, got %d", pomodoro.StateDone, i.State)
+		}
+		if i.ActualDuration != i.PlannedDuration {
+			t.Errorf("expected ActualDuration %q, got %q", i.PlannedDuration, i.ActualDuration)
+		}
+	}
+}
+
+func TestRunCyclePauseResume(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config := pomodoro.NewConfig(repo, 5*time.Second, 2*time.Second, 3*time.Second)
+	config.Clock = pomodoro.NewScaledClock(100)
+	config.AutoStart = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const expIntervals = 6
+	pauseIDs := map[int64]bool{1: true, 2: true, 4: true}
+	paused := map[int64]bool{}
+	resume := make(chan struct{})
+	completed := 0
+
+	noop := func(pomodoro.Interval) {}
+	periodic := func(i pomodoro.Interval) {
+		if !pauseIDs[i.ID] || paused[i.ID] {
+			return
+		}
+		paused[i.ID] = true
+		if err := i.Pause(config); err != nil {
+			t.Error(err)
+			return
+		}
+		go func() { resume <- struct{}{} }()
+	}
+	end := func(pomodoro.Interval) {
+		completed++
+		if completed == expIntervals {
+			cancel()
+		}
+	}
+
+	reason, err := pomodoro.RunCycle(ctx, config, resume, noop, periodic, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reason != pomodoro.ExitDone {
+		t.Errorf("expected the last interval done, got reason %d", reason)
+	}
+
+	last, err := repo.Last()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.ID != expIntervals {
+		t.Errorf("expected %d intervals, got %d", expIntervals, last.ID)
+	}
+	for id := int64(1); id <= expIntervals; id++ {
+		i, err := repo.ByID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i.State != pomodoro.StateDone {
+			t.Errorf("expected interval %d to be done, got state %d", id, i.State)
+		}
+	}
+	for id := range pauseIDs {
+		if !paused[id] {
+			t.Errorf("expected interval %d to have been paused", id)
+		}
+	}
+}
+
+func TestSkip(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config := pomodoro.NewConfig(repo, 5*time.Second, 5*time.Second, 5*time.Second)
+	config.Clock = pomodoro.NewScaledClock(100)
+	noop := func(pomodoro.Interval) {}
+
+	if _, err := pomodoro.Skip(config); !errors.Is(err, pomodoro.ErrNothingToSkip) {
+		t.Fatalf("expected error %q, got %v", pomodoro.ErrNothingToSkip, err)
+	}
+
+	// A pending interval
+	pending, err := pomodoro.GetInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	skipped, err := pomodoro.Skip(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skipped.ID != pending.ID || skipped.State != pomodoro.StateSkipped {
+		t.Errorf("expected interval %d skipped, got %+v", pending.ID, skipped)
+	}
+	if _, ok, err := pomodoro.PeekInterval(config); err != nil || ok {
+		t.Errorf("expected no active interval after skipping, got %v, %v", ok, err)
+	}
+
+	// A running interval stops ticking without ending
+	i, err := pomodoro.GetInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.ID == pending.ID {
+		t.Fatal("expected a new interval after skipping")
+	}
+	periodic := func(pomodoro.Interval) {
+		if _, err := pomodoro.Skip(config); err != nil && !errors.Is(err, pomodoro.ErrNothingToSkip) {
+			t.Error(err)
+		}
+	}
+	end := func(pomodoro.Interval) {
+		t.Error("expected no end callback for a skipped interval")
+	}
+	if err := i.Start(context.Background(), config, noop, periodic, end); err != nil {
+		t.Fatal(err)
+	}
+	if i, err = repo.ByID(i.ID); err != nil {
+		t.Fatal(err)
+	}
+	if i.State != pomodoro.StateSkipped || i.ActualDuration >= i.PlannedDuration {
+		t.Errorf("expected the interval skipped before its end, got %+v", i)
+	}
+}
+
+func TestSkipNeverStartedStats(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	day := time.Date(2024, time.June, 3, 0, 0, 0, 0, time.UTC)
+	config := pomodoro.NewConfig(repo, 0, 0, 0)
+	config.Clock = fixedClock{now: day.Add(12 * time.Hour)}
+	config.Location = time.UTC
+	for _, i := range []pomodoro.Interval{
+		{StartTime: day.Add(9 * time.Hour), PlannedDuration: 25 * time.Minute, ActualDuration: 25 * time.Minute,
+			Category: pomodoro.CategoryPomodoro, State: pomodoro.StateDone},
+		{StartTime: day.Add(10 * time.Hour), PlannedDuration: 5 * time.Minute, ActualDuration: 5 * time.Minute,
+			Category: pomodoro.CategoryShortBreak, State: pomodoro.StateDone},
+	} {
+		if _, err := repo.Create(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The next pomodoro is skipped without ever starting
+	if i, err := pomodoro.GetInterval(config); err != nil || i.Category != pomodoro.CategoryPomodoro {
+		t.Fatalf("expected a pending pomodoro, got %+v, %v", i, err)
+	}
+	skipped, err := pomodoro.Skip(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !skipped.StartTime.Equal(config.Now()) || skipped.ActualDuration != 0 {
+		t.Errorf("expected it dated now without any time spent, got %+v", skipped)
+	}
+
+	// It counts as skipped, not as work done in no time
+	end := day.AddDate(0, 0, 1)
+	stats, err := pomodoro.CompletionStats(day, end, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := (pomodoro.Stats{Done: 1, Skipped: 1, Ratio: 0.5, HasRatio: true}); stats != exp {
+		t.Errorf("expected %+v, got %+v", exp, stats)
+	}
+	sessions, err := pomodoro.SessionStats(day, end, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sessions.AverageLength != 25*time.Minute || sessions.LongestStreak != 1 ||
+		sessions.States[pomodoro.StateSkipped] != 1 {
+		t.Errorf("expected the skipped pomodoro left out of the average and streak, got %+v", sessions)
+	}
+	ds, err := pomodoro.DailySummary(day, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds[0] != 25*time.Minute {
+		t.Errorf("expected %s of work, got %s", 25*time.Minute, ds[0])
+	}
+}
+
+func TestRunCycleSkip(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config := pomodoro.NewConfig(repo, 5*time.Second, time.Second, time.Second)
+	config.Clock = pomodoro.NewScaledClock(100)
+	config.AutoStart = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	noop := func(pomodoro.Interval) {}
+	periodic := func(i pomodoro.Interval) {
+		if i.ID == 1 {
+			if _, err := pomodoro.Skip(config); err != nil && !errors.Is(err, pomodoro.ErrNothingToSkip) {
+				t.Error(err)
+			}
+		}
+	}
+	completed := 0
+	end := func(pomodoro.Interval) {
+		if completed++; completed == 2 {
+			cancel()
+		}
+	}
+
+	// The cycle moves on past the skipped interval
+	if _, err := pomodoro.RunCycle(ctx, config, nil, noop, periodic, end); err != nil {
+		t.Fatal(err)
+	}
+	for id, exp := range map[int64]int{1: pomodoro.StateSkipped, 2: pomodoro.StateDone, 3: pomodoro.StateDone} {
+		i, err := repo.ByID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i.State != exp {
+			t.Errorf("expected interval %d %s, got %s", id, pomodoro.StateName(exp), pomodoro.StateName(i.State))
+		}
+	}
+}
+
+func TestMicroBreak(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	// The pomodoro ends two ticks after the last micro break, so a late
+	// tick doesn't race the end of the interval
+	config := pomodoro.NewConfig(repo, 11*time.Second, 4*time.Second, 4*time.Second)
+	config.Clock = pomodoro.NewScaledClock(100)
+	config.MicroBreakEvery = 3 * time.Second
+
+	var fired []time.Duration
+	config.OnMicroBreak = func(i pomodoro.Interval) {
+		fired = append(fired, i.ActualDuration)
+	}
+
+	noop := func(pomodoro.Interval) {}
+	pausedOnce := false
+	periodic := func(i pomodoro.Interval) {
+		if i.ActualDuration == 3*time.Second && !pausedOnce {
+			pausedOnce = true
+			if err := i.Pause(config); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	i, err := pomodoro.GetInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := i.Start(context.Background(), config, noop, periodic, noop); err != nil {
+		t.Fatal(err)
+	}
+	// Resume the paused pomodoro until it's done
+	i, err = pomodoro.GetInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.State != pomodoro.StatePaused {
+		t.Fatalf("expected state %d, got %d", pomodoro.StatePaused, i.State)
+	}
+	if err := i.Start(context.Background(), config, noop, periodic, noop); err != nil {
+		t.Fatal(err)
+	}
+
+	expFired := []time.Duration{3 * time.Second, 6 * time.Second, 9 * time.Second}
+	if len(fired) != len(expFired) {
+		t.Fatalf("expected micro breaks at %v, got %v", expFired, fired)
+	}
+	for k := range expFired {
+		if fired[k] != expFired[k] {
+			t.Errorf("expected micro break at %s, got %s", expFired[k], fired[k])
+		}
+	}
+
+	// Breaks never get micro breaks
+	fired = nil
+	i, err = pomodoro.GetInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := i.Start(context.Background(), config, noop, noop, noop); err != nil {
+		t.Fatal(err)
+	}
+	if len(fired) != 0 {
+		t.Errorf("expected no micro breaks during %q, got %v", i.Category, fired)
+	}
+}
+
+func TestSnoozeBreak(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config := pomodoro.NewConfig(repo, 3*time.Second, time.Second, 2*time.Second)
+	config.Clock = pomodoro.NewScaledClock(100)
+	noop := func(pomodoro.Interval) {}
+
+	if _, err := pomodoro.SnoozeBreak(config, time.Second); !errors.Is(err, pomodoro.ErrNothingToSnooze) {
+		t.Fatalf("expected error %q, got %v", pomodoro.ErrNothingToSnooze, err)
+	}
+
+	var work time.Duration
+	for n := 1; n <= 4; n++ {
+		i, err := pomodoro.GetInterval(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i.Category != pomodoro.CategoryPomodoro {
+			t.Fatalf("expected category %q, got %q", pomodoro.CategoryPomodoro, i.Category)
+		}
+		if err := i.Start(context.Background(), config, noop, noop, noop); err != nil {
+			t.Fatal(err)
+		}
+
+		s, err := pomodoro.SnoozeBreak(config, 2*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Category != pomodoro.CategorySnooze {
+			t.Errorf("expected category %q, got %q", pomodoro.CategorySnooze, s.Category)
+		}
+		if err := s.Start(context.Background(), config, noop, noop, noop); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, id := range []int64{i.ID, s.ID} {
+			done, err := repo.ByID(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			work += done.ActualDuration
+		}
+
+		expBreak := pomodoro.CategoryShortBreak
+		if n == 4 {
+			expBreak = pomodoro.CategoryLongBreak
+		}
+		b, err := pomodoro.GetInterval(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.Category != expBreak {
+			t.Fatalf("expected category %q after pomodoro %d, got %q", expBreak, n, b.Category)
+		}
+		if _, err := pomodoro.SnoozeBreak(config, time.Second); !errors.Is(err, pomodoro.ErrNothingToSnooze) {
+			t.Errorf("expected error %q, got %v", pomodoro.ErrNothingToSnooze, err)
+		}
+		if err := b.Start(context.Background(), config, noop, noop, noop); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ds, err := pomodoro.DailySummary(config.Now(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds[0] != work {
+		t.Errorf("expected work summary %s to include snoozes, got %s", work, ds[0])
+	}
+}
+
+func TestExitReason(t *testing.T) {
+	testCases := []struct {
+		name      string
+		threshold float64
+		cycle     bool
+		exp       pomodoro.ExitReason
+	}{
+		{"Cancelled", 0, false, pomodoro.ExitCancelled},
+		{"CompletedEarly", 0.1, false, pomodoro.ExitCompletedEarly},
+		{"CycleCancelled", 0, true, pomodoro.ExitCancelled},
+		{"CycleCompletedEarly", 0.1, true, pomodoro.ExitCompletedEarly},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, cleanup := getRepo(t)
+			defer cleanup()
+
+			config := pomodoro.NewConfig(repo, 5*time.Second, time.Second, time.Second)
+			config.Clock = pomodoro.NewScaledClock(100)
+			config.CompletionThreshold = tt.threshold
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			noop := func(pomodoro.Interval) {}
+			periodic := func(pomodoro.Interval) { cancel() }
+
+			var (
+				reason pomodoro.ExitReason
+				err    error
+			)
+			if tt.cycle {
+				reason, err = pomodoro.RunCycle(ctx, config, nil, noop, periodic, noop)
+			} else {
+				var i pomodoro.Interval
+				if i, err = pomodoro.GetInterval(config); err != nil {
+					t.Fatal(err)
+				}
+				reason, err = i.Run(ctx, config, noop, periodic, noop)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reason != tt.exp {
+				t.Errorf("expected reason %d, got %d", tt.exp, reason)
+			}
+		})
+	}
+}
+
+func TestCompletionThreshold(t *testing.T) {
+	testCases := []struct {
+		name      string
+		threshold float64
+		expState  int
+	}{
+		{name: "Disabled", threshold: 0, expState: pomodoro.StateCancelled},
+		{name: "BelowThreshold", threshold: 0.9, expState: pomodoro.StateCancelled},
+		{name: "AboveThreshold", threshold: 0.5, expState: pomodoro.StateDone},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, cleanup := getRepo(t)
+			defer cleanup()
+
+			config := pomodoro.NewConfig(repo, 10*time.Second, time.Second, time.Second)
+			config.Clock = pomodoro.NewScaledClock(100)
+			config.CompletionThreshold = tt.threshold
+
+			var cancelState = -1
+			config.OnCancel = func(i pomodoro.Interval) {
+				cancelState = i.State
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			noop := func(pomodoro.Interval) {}
+			periodic := func(i pomodoro.Interval) {
+				if i.ActualDuration >= 6*time.Second {
+					cancel()
+				}
+			}
+
+			i, err := pomodoro.GetInterval(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := i.Start(ctx, config, noop, periodic, noop); err != nil {
+				t.Fatal(err)
+			}
+
+			i, err = repo.ByID(i.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if i.State != tt.expState {
+				t.Errorf("expected state %d, got %d", tt.expState, i.State)
+			}
+			if cancelState != tt.expState {
+				t.Errorf("expected OnCancel with state %d, got %d", tt.expState, cancelState)
+			}
+			if i.ActualDuration < 6*time.Second {
+				t.Errorf("expected actual duration to be recorded, got %s", i.ActualDuration)
+			}
+		})
+	}
+}
+
+func TestDailyOrdinal(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config := secondsConfig(repo, time.Second, time.Second, time.Second)
+	noop := func(pomodoro.Interval) {}
+
+	for n := 1; n <= 3; n++ {
+		i, err := pomodoro.GetInterval(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// A pending pomodoro is reused and keeps its ordinal
+		again, err := pomodoro.GetInterval(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again.ID != i.ID || again.DailyOrdinal != n {
+			t.Errorf("expected pending pomodoro %d with ordinal %d, got %d with %d",
+				i.ID, n, again.ID, again.DailyOrdinal)
+		}
+		if i.DailyOrdinal != n {
+			t.Errorf("expected ordinal %d, got %d", n, i.DailyOrdinal)
+		}
+		if err := i.Start(context.Background(), config, noop, noop, noop); err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := pomodoro.GetInterval(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.DailyOrdinal != 0 {
+			t.Errorf("expected no ordinal on breaks, got %d", b.DailyOrdinal)
+		}
+		if err := b.Start(context.Background(), config, noop, noop, noop); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	total, err := pomodoro.TotalCompleted(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 {
+		t.Errorf("expected 3 completed pomodoros, got %d", total)
+	}
+}
+
+func TestScheduleStart(t *testing.T) {
+	noop := func(pomodoro.Interval) {}
+
+	t.Run("Future", func(t *testing.T) {
+		repo, cleanup := getRepo(t)
+		defer cleanup()
+
+		config := pomodoro.NewConfig(repo, time.Second, time.Second, time.Second)
+		config.Clock = pomodoro.NewScaledClock(1000)
+		at := config.Now().Add(100 * time.Second)
+
+		if err := pomodoro.ScheduleStart(context.Background(), config, at, noop, noop, noop); err != nil {
+			t.Fatal(err)
+		}
+		i, err := repo.Last()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i.StartTime.Before(at) {
+			t.Errorf("expected start after %s, got %s", at, i.StartTime)
+		}
+		if i.State != pomodoro.StateDone {
+			t.Errorf("expected state %d, got %d", pomodoro.StateDone, i.State)
+		}
+	})
+
+	t.Run("Past", func(t *testing.T) {
+		repo, cleanup := getRepo(t)
+		defer cleanup()
+
+		config := secondsConfig(repo, time.Second, time.Second, time.Second)
+		at := time.Now().Add(-time.Hour)
+
+		if err := pomodoro.ScheduleStart(context.Background(), config, at, noop, noop, noop); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.Last(); err != nil {
+			t.Errorf("expected interval to be started, got %v", err)
+		}
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		repo, cleanup := getRepo(t)
+		defer cleanup()
+
+		config := pomodoro.NewConfig(repo, time.Second, time.Second, time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		err := pomodoro.ScheduleStart(ctx, config, time.Now().Add(time.Hour), noop, noop, noop)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected error %q, got %v", context.DeadlineExceeded, err)
+		}
+		if _, err := repo.Last(); !errors.Is(err, pomodoro.ErrNoIntervals) {
+			t.Errorf("expected nothing to be stored, got %v", err)
+		}
+	})
+}
+
+func TestRecordInterruption(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config := pomodoro.NewConfig(repo, 5*time.Second, time.Second, time.Second)
+	config.Clock = pomodoro.NewScaledClock(100)
+
+	if err := pomodoro.RecordInterruption(config); !errors.Is(err, pomodoro.ErrIntervalNotRunning) {
+		t.Fatalf("expected error %q, got %v", pomodoro.ErrIntervalNotRunning, err)
+	}
+
+	i, err := pomodoro.GetInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pomodoro.RecordInterruption(config); !errors.Is(err, pomodoro.ErrIntervalNotRunning) {
+		t.Fatalf("expected error %q, got %v", pomodoro.ErrIntervalNotRunning, err)
+	}
+
+	noop := func(pomodoro.Interval) {}
+	periodic := func(i pomodoro.Interval) {
+		if i.ActualDuration <= 2*time.Second {
+			if err := pomodoro.RecordInterruption(config); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+	if err := i.Start(context.Background(), config, noop, periodic, noop); err != nil {
+		t.Fatal(err)
+	}
+
+	i, err = repo.ByID(i.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.Interruptions != 2 {
+		t.Errorf("expected 2 interruptions, got %d", i.Interruptions)
+	}
+
+	n, err := pomodoro.DailyInterruptions(config.Now(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 interruptions today, got %d", n)
+	}
+}
+
+func TestSetNote(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config := secondsConfig(repo, time.Second, time.Second, time.Second)
+	noop := func(pomodoro.Interval) {}
+
+	i, err := pomodoro.GetInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := i.Start(context.Background(), config, noop, noop, noop); err != nil {
+		t.Fatal(err)
+	}
+
+	const note = "finished chapter 3"
+	if err := pomodoro.SetNote(config, i.ID, note); err != nil {
+		t.Fatal(err)
+	}
+
+	done, err := repo.ByID(i.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if done.Note != note {
+		t.Errorf("expected note %q, got %q", note, done.Note)
+	}
+	if done.State != pomodoro.StateDone || done.StartTime.IsZero() {
+		t.Errorf("expected other fields to be kept, got %+v", done)
+	}
+
+	// A later update doesn't clobber the note
+	i.Version = done.Version
+	if err := repo.Update(i); err != nil {
+		t.Fatal(err)
+	}
+	if done, err = repo.ByID(i.ID); err != nil {
+		t.Fatal(err)
+	}
+	if done.Note != note {
+		t.Errorf("expected note %q to survive update, got %q", note, done.Note)
+	}
+
+	if err := pomodoro.SetNote(config, 100, note); !errors.Is(err, pomodoro.ErrInvalidID) {
+		t.Errorf("expected error %q, got %v", pomodoro.ErrInvalidID, err)
+	}
+}
+
+func TestSetLabel(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config := secondsConfig(repo, time.Second, time.Second, time.Second)
+	config.Label = "inbox"
+	noop := func(pomodoro.Interval) {}
+
+	if label, err := pomodoro.LastLabel(config); err != nil || label != "" {
+		t.Errorf("expected no label without pomodoros, got %q, %v", label, err)
+	}
+
+	i, err := pomodoro.GetInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The pending pomodoro hasn't started, so it isn't the last one
+	if label, err := pomodoro.LastLabel(config); err != nil || label != "" {
+		t.Errorf("expected no label before starting, got %q, %v", label, err)
+	}
+
+	if i, err = pomodoro.SetLabel(config, i.ID, "write report"); err != nil {
+		t.Fatal(err)
+	}
+	if i.Label != "write report" {
+		t.Errorf("expected label %q, got %q", "write report", i.Label)
+	}
+	if err := i.Start(context.Background(), config, noop, noop, noop); err != nil {
+		t.Fatal(err)
+	}
+
+	if i, err = repo.ByID(i.ID); err != nil {
+		t.Fatal(err)
+	}
+	if i.Label != "write report" || i.State != pomodoro.StateDone {
+		t.Errorf("expected a done interval labeled %q, got %+v", "write report", i)
+	}
+	if label, err := pomodoro.LastLabel(config); err != nil || label != "write report" {
+		t.Errorf("expected last label %q, got %q, %v", "write report", label, err)
+	}
+
+	if _, err := pomodoro.SetLabel(config, i.ID, "other"); !errors.Is(err, pomodoro.ErrIntervalCompleted) {
+		t.Errorf("expected error %q, got %v", pomodoro.ErrIntervalCompleted, err)
+	}
+	if _, err := pomodoro.SetLabel(config, 100, "other"); !errors.Is(err, pomodoro.ErrInvalidID) {
+		t.Errorf("expected error %q, got %v", pomodoro.ErrInvalidID, err)
+	}
+}
+
+func TestConcurrentStart(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config := pomodoro.NewConfig(repo, 5*time.Second, time.Second, time.Second)
+	config.Clock = pomodoro.NewScaledClock(100)
+
+	i, err := pomodoro.GetInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	noop := func(pomodoro.Interval) {}
+	errs := make(chan error, 2)
+	for k := 0; k < 2; k++ {
+		go func() {
+			errs <- i.Start(context.Background(), config, noop, noop, noop)
+		}()
+	}
+
+	var alreadyRunning int
+	for k := 0; k < 2; k++ {
+		err := <-errs
+		switch {
+		case errors.Is(err, pomodoro.ErrIntervalAlreadyRunning):
+			alreadyRunning++
+		case errors.Is(err, pomodoro.ErrIntervalCompleted):
+			// The second call came after the first one finished
+		case err != nil:
+			t.Fatal(err)
+		}
+	}
+	if alreadyRunning != 1 {
+		t.Errorf("expected exactly one call to fail with %q, got %d", pomodoro.ErrIntervalAlreadyRunning, alreadyRunning)
+	}
+
+	i, err = repo.ByID(i.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.ActualDuration > i.PlannedDuration {
+		t.Errorf("expected a single tick loop, got ActualDuration %s for PlannedDuration %s",
+			i.ActualDuration, i.PlannedDuration)
+	}
+}
+
+func TestResume(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config := pomodoro.NewConfig(repo, 5*time.Second, time.Second, time.Second)
+	config.Clock = pomodoro.NewScaledClock(100)
+	noop := func(pomodoro.Interval) {}
+
+	i, err := pomodoro.GetInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := i.Resume(context.Background(), config, noop, noop, noop); !errors.Is(err, pomodoro.ErrIntervalNotPaused) {
+		t.Fatalf("expected error %q, got %v", pomodoro.ErrIntervalNotPaused, err)
+	}
+
+	pauseAt := 2 * time.Second
+	periodic := func(i pomodoro.Interval) {
+		if i.ActualDuration == pauseAt {
+			if err := i.Pause(config); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+	if err := i.Start(context.Background(), config, noop, periodic, noop); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := i.Resume(context.Background(), config, noop, noop, noop); err != nil {
+		t.Fatal(err)
+	}
+	i, err = repo.ByID(i.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.State != pomodoro.StateDone {
+		t.Errorf("expected state %d, got %d", pomodoro.StateDone, i.State)
+	}
+
+	if err := i.Resume(context.Background(), config, noop, noop, noop); !errors.Is(err, pomodoro.ErrIntervalNotPaused) {
+		t.Errorf("expected error %q, got %v", pomodoro.ErrIntervalNotPaused, err)
+	}
+	if err := i.Start(context.Background(), config, noop, noop, noop); !errors.Is(err, pomodoro.ErrIntervalCompleted) {
+		t.Errorf("expected error %q, got %v", pomodoro.ErrIntervalCompleted, err)
+	}
+	if err := i.Pause(config); !errors.Is(err, pomodoro.ErrIntervalNotRunning) {
+		t.Errorf("expected error %q, got %v", pomodoro.ErrIntervalNotRunning, err)
+	}
+}
+
+func TestPauseResumeKeepsStartTime(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config := pomodoro.NewConfig(repo, 5*time.Second, time.Second, time.Second)
+	config.Clock = pomodoro.NewScaledClock(100)
+
+	i, err := pomodoro.GetInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var started time.Time
+	start := func(i pomodoro.Interval) {
+		if started.IsZero() {
+			started = i.StartTime
+		}
+	}
+	check := func(i pomodoro.Interval) {
+		if !i.StartTime.Equal(started) {
+			t.Errorf("expected start time %s, got %s", started, i.StartTime)
+		}
+	}
+	paused := 0
+	periodic := func(i pomodoro.Interval) {
+		check(i)
+		if paused < 2 && i.ActualDuration == time.Duration(paused+1)*time.Second {
+			paused++
+			if err := i.Pause(config); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+
+	if err := i.Start(context.Background(), config, start, periodic, check); err != nil {
+		t.Fatal(err)
+	}
+	for paused < 3 {
+		i, err = repo.ByID(i.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i.State != pomodoro.StatePaused {
+			break
+		}
+		check(i)
+		if err := i.Resume(context.Background(), config, start, periodic, check); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	i, err = repo.ByID(i.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.State != pomodoro.StateDone {
+		t.Errorf("expected state %d, got %d", pomodoro.StateDone, i.State)
+	}
+	if paused != 2 {
+		t.Errorf("expected 2 pauses, got %d", paused)
+	}
+	check(i)
+}
+
+func TestLongBreakAfter(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config := pomodoro.NewConfig(repo, 5*time.Second, time.Second, time.Second)
+	config.Clock = pomodoro.NewScaledClock(100)
+	config.LongBreakAfter = 12 * time.Second
+	noop := func(pomodoro.Interval) {}
+
+	run := func(expCategory string) {
+		t.Helper()
+		i, err := pomodoro.GetInterval(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i.Category != expCategory {
+			t.Fatalf("expected category %q, got %q", expCategory, i.Category)
+		}
+		if err := i.Start(context.Background(), config, noop, noop, noop); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A regular pomodoro and an extended one cross the threshold
+	run(pomodoro.CategoryPomodoro)
+	run(pomodoro.CategoryShortBreak)
+	config.PomodoroDuration = 10 * time.Second
+	run(pomodoro.CategoryPomodoro)
+	run(pomodoro.CategoryLongBreak)
+
+	// Focus time starts over after the long break
+	config.PomodoroDuration = 5 * time.Second
+	run(pomodoro.CategoryPomodoro)
+	run(pomodoro.CategoryShortBreak)
+}
+
+func addDone(t *testing.T, repo pomodoro.Repository, category string, start time.Time, d time.Duration) {
+	t.Helper()
+	_, err := repo.Create(pomodoro.Interval{
+		StartTime:       start,
+		PlannedDuration: d,
+		ActualDuration:  d,
+		Category:        category,
+		State:           pomodoro.StateDone,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDailySummaryLocation(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	loc, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := pomodoro.NewConfig(repo, 0, 0, 0)
+	config.Location = loc
+
+	// Both fall on May 10th in UTC, but on different days in Kolkata
+	addDone(t, repo, pomodoro.CategoryPomodoro,
+		time.Date(2023, time.May, 10, 23, 0, 0, 0, loc), 10*time.Minute)
+	addDone(t, repo, pomodoro.CategoryPomodoro,
+		time.Date(2023, time.May, 11, 1, 0, 0, 0, loc), 20*time.Minute)
+	addDone(t, repo, pomodoro.CategoryShortBreak,
+		time.Date(2023, time.May, 11, 1, 30, 0, 0, loc), 5*time.Minute)
+
+	testCases := []struct {
+		name     string
+		day      time.Time
+		expPomo  time.Duration
+		expBreak time.Duration
+	}{
+		{"May10", time.Date(2023, time.May, 10, 12, 0, 0, 0, loc), 10 * time.Minute, 0},
+		{"May11", time.Date(2023, time.May, 11, 12, 0, 0, 0, loc), 20 * time.Minute, 5 * time.Minute},
+		// The day is taken from the configured location, not the argument's
+		{"May11FromUTC", time.Date(2023, time.May, 10, 20, 0, 0, 0, time.UTC), 20 * time.Minute, 5 * time.Minute},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ds, err := pomodoro.DailySummary(tc.day, config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ds[0] != tc.expPomo {
+				t.Errorf("expected pomodoro %s, got %s", tc.expPomo, ds[0])
+			}
+			if ds[1] != tc.expBreak {
+				t.Errorf("expected breaks %s, got %s", tc.expBreak, ds[1])
+			}
+		})
+	}
+}
+
+func TestRangeSummaryDST(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := pomodoro.NewConfig(repo, 0, 0, 0)
+	config.Location = loc
+
+	// Daylight saving time starts on March 12th, 2023. One pomodoro late
+	// each evening, with a distinct duration per day.
+	for d := 8; d <= 14; d++ {
+		addDone(t, repo, pomodoro.CategoryPomodoro,
+			time.Date(2023, time.March, d, 23, 30, 0, 0, loc), time.Duration(d)*time.Minute)
+	}
+
+	start := time.Date(2023, time.March, 14, 9, 0, 0, 0, loc)
+	series, err := pomodoro.RangeSummary(start, 7, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pomo := series[0]
+	for i := 0; i < 7; i++ {
+		d := 14 - i
+		expLabel := fmt.Sprintf("%02d/Mar", d)
+		if pomo.Labels[i] != expLabel {
+			t.Errorf("expected label %q at %d, got %q", expLabel, i, pomo.Labels[i])
+		}
+		exp := (time.Duration(d) * time.Minute).Seconds()
+		if pomo.Values[i] != exp {
+			t.Errorf("expected %v seconds on %s, got %v", exp, expLabel, pomo.Values[i])
+		}
+	}
+}
+
+func TestRangeSummaryOpts(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+	config := pomodoro.NewConfig(repo, 0, 0, 0)
+	config.Location = time.UTC
+
+	// A past month boundary, far from now
+	for d := 0; d < 40; d++ {
+		addDone(t, repo, pomodoro.CategoryPomodoro,
+			time.Date(2023, time.January, 10+d, 9, 0, 0, 0, time.UTC), time.Minute)
+	}
+
+	series, err := pomodoro.RangeSummaryOpts(pomodoro.RangeOpts{
+		End:  time.Date(2023, time.February, 3, 23, 0, 0, 0, time.UTC),
+		Days: 30,
+	}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pomo := series[0]
+	if len(pomo.Values) != 30 {
+		t.Fatalf("expected 30 days, got %d", len(pomo.Values))
+	}
+	for k, exp := range map[int]string{0: "03/Feb", 2: "01/Feb", 3: "31/Jan", 29: "05/Jan"} {
+		if pomo.Labels[k] != exp {
+			t.Errorf("expected label %q at %d, got %q", exp, k, pomo.Labels[k])
+		}
+	}
+	// Nothing was done before January 10th
+	if pomo.Values[24] != 60 || pomo.Values[25] != 0 {
+		t.Errorf("expected work from January 10th on, got %v", pomo.Values)
+	}
+
+	for _, n := range []int{0, -1} {
+		_, err := pomodoro.RangeSummaryOpts(pomodoro.RangeOpts{End: time.Now(), Days: n}, config)
+		if !errors.Is(err, pomodoro.ErrInvalidRange) {
+			t.Errorf("%d days: expected error %q, got %v", n, pomodoro.ErrInvalidRange, err)
+		}
+	}
+}
+
+func TestRangeSummaryEmptyDays(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := pomodoro.NewConfig(repo, 0, 0, 0)
+	config.Location = loc
+
+	// Daylight saving time ends on October 29th, 2023. Work on the 27th
+	// and on the 29th at 02:30 after the clocks went back, nothing else
+	addDone(t, repo, pomodoro.CategoryPomodoro, time.Date(2023, time.October, 27, 9, 0, 0, 0, loc), time.Minute)
+	addDone(t, repo, pomodoro.CategoryPomodoro, time.Date(2023, time.October, 29, 1, 30, 0, 0, time.UTC), 2*time.Minute)
+
+	for _, tc := range []struct {
+		name string
+		end  time.Time
+		exp  []float64
+	}{
+		{"DST", time.Date(2023, time.November, 1, 12, 0, 0, 0, loc), []float64{0, 0, 0, 120, 0, 60, 0}},
+		{"Empty", time.Date(2023, time.October, 15, 12, 0, 0, 0, loc), []float64{0, 0, 0, 0, 0, 0, 0}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			series, err := pomodoro.RangeSummary(tc.end, 7, config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range series {
+				if len(s.Values) != 7 || len(s.Labels) != 7 {
+					t.Fatalf("%s: expected 7 values and labels, got %d and %d", s.Name, len(s.Values), len(s.Labels))
+				}
+				for i := range s.Values {
+					day := tc.end.AddDate(0, 0, -i)
+					if exp := fmt.Sprintf("%02d/%s", day.Day(), day.Format("Jan")); s.Labels[i] != exp {
+						t.Errorf("%s: expected label %q at %d, got %q", s.Name, exp, i, s.Labels[i])
+					}
+				}
+			}
+			for i, exp := range tc.exp {
+				if series[0].Values[i] != exp || series[1].Values[i] != 0 {
+					t.Errorf("%s: expected %v seconds of work and no breaks, got %v and %v",
+						series[0].Labels[i], exp, series[0].Values[i], series[1].Values[i])
+				}
+			}
+		})
+	}
+}
+
+// failingTotalsRepo fails to total days
+type failingTotalsRepo struct {
+	pomodoro.Repository
+}
+
+func (failingTotalsRepo) DailyTotals(time.Time, time.Time, string, float64, string) (map[string]time.Duration, error) {
+	return nil, errInjected
+}
+
+func TestRangeSummaryError(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+	config := pomodoro.NewConfig(failingTotalsRepo{repo}, 0, 0, 0)
+	config.Location = time.UTC
+
+	_, err := pomodoro.RangeSummary(time.Date(2023, time.May, 10, 9, 0, 0, 0, time.UTC), 7, config)
+	var serr *pomodoro.SummaryError
+	if !errors.As(err, &serr) {
+		t.Fatalf("expected a summary error, got %v", err)
+	}
+	if serr.First != "2023-05-04" || serr.Last != "2023-05-10" || !errors.Is(err, errInjected) {
+		t.Errorf("expected the days from 2023-05-04 to 2023-05-10 and the repository error, got %+v", serr)
+	}
+}
+
+// failingDayRepo fails to total any range including day
+type failingDayRepo struct {
+	pomodoro.Repository
+	day time.Time
+}
+
+func (r failingDayRepo) DailyTotals(start, end time.Time, filter string, ratio float64, tag string) (map[string]time.Duration, error) {
+	if !r.day.Before(start) && r.day.Before(end) {
+		return nil, errInjected
+	}
+	return r.Repository.DailyTotals(start, end, filter, ratio, tag)
+}
+
+func TestRangeSummaryPartial(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	last := time.Date(2023, time.May, 10, 0, 0, 0, 0, time.UTC)
+	for k := 0; k < 7; k++ {
+		addDone(t, repo, pomodoro.CategoryPomodoro, last.AddDate(0, 0, -k).Add(9*time.Hour), 25*time.Minute)
+	}
+	failing := last.AddDate(0, 0, -2)
+	config := pomodoro.NewConfig(failingDayRepo{repo, failing}, 0, 0, 0)
+	config.Location = time.UTC
+
+	series, err := pomodoro.RangeSummary(last.Add(9*time.Hour), 7, config)
+	var partial *pomodoro.PartialSummaryError
+	if !errors.As(err, &partial) {
+		t.Fatalf("expected a partial summary error, got %v", err)
+	}
+	if partial.Days != 7 || len(partial.Errs) != 1 || partial.Errs[0].First != "2023-05-08" ||
+		!errors.Is(err, errInjected) {
+		t.Errorf("expected 2023-05-08 alone failed with the repository error, got %v", err)
+	}
+
+	if len(series) != 2 || len(series[0].Values) != 7 {
+		t.Fatalf("expected 7 days of each series, got %v", series)
+	}
+	var summarized int
+	for k, v := range series[0].Values {
+		switch {
+		case k == 2 && v != 0:
+			t.Errorf("expected no work on the failed day, got %v", v)
+		case k != 2 && v == (25*time.Minute).Seconds():
+			summarized++
+		}
+	}
+	if summarized != 6 {
+		t.Errorf("expected 6 of 7 days summarized, got %v", series[0].Values)
+	}
+}
+
+func TestSummaryWithTag(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+	config := pomodoro.NewConfig(repo, 0, 0, 0)
+	config.Location = time.UTC
+
+	day := time.Date(2024, time.June, 3, 0, 0, 0, 0, time.UTC)
+	tagged := func(category string, start time.Time, d time.Duration, tags ...string) {
+		t.Helper()
+		id, err := repo.Create(pomodoro.Interval{
+			StartTime:       start,
+			PlannedDuration: d,
+			ActualDuration:  d,
+			Category:        category,
+			State:           pomodoro.StateDone,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := pomodoro.TagInterval(config, id, tags...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tagged(pomodoro.CategoryPomodoro, day.Add(9*time.Hour), 25*time.Minute, "client-a", "deep")
+	tagged(pomodoro.CategoryShortBreak, day.Add(10*time.Hour), 5*time.Minute, "client-a")
+	tagged(pomodoro.CategoryPomodoro, day.Add(11*time.Hour), 20*time.Minute, "client-b")
+	tagged(pomodoro.CategorySnooze, day.Add(12*time.Hour), 10*time.Minute, "client-a", "client-b")
+	tagged(pomodoro.CategoryPomodoro, day.AddDate(0, 0, -1).Add(9*time.Hour), 15*time.Minute, "client-a")
+
+	ds, err := pomodoro.DailySummary(day, config, pomodoro.WithTag("#Client-A"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds[0] != 35*time.Minute || ds[1] != 5*time.Minute {
+		t.Errorf("expected 35m of work and 5m of breaks tagged client-a, got %v", ds)
+	}
+	if ds, err = pomodoro.DailySummary(day, config); err != nil {
+		t.Fatal(err)
+	}
+	if ds[0] != 55*time.Minute {
+		t.Errorf("expected 55m of work without a tag, got %v", ds)
+	}
+
+	series, err := pomodoro.RangeSummary(day, 2, config, pomodoro.WithTag("client-b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := series[0].Values; v[0] != (30*time.Minute).Seconds() || v[1] != 0 {
+		t.Errorf("expected 30m tagged client-b on the last day only, got %v", v)
+	}
+
+	if _, err := pomodoro.DailySummary(day, config, pomodoro.WithTag("two words")); !errors.Is(err, pomodoro.ErrInvalidTag) {
+		t.Errorf("expected error %q, got %v", pomodoro.ErrInvalidTag, err)
+	}
+}
+
+func TestRangeSummaryMatchesDaily(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := pomodoro.NewConfig(repo, 0, 0, 0)
+	config.Location = loc
+	config.SummaryCancelledRatio = 0.5
+
+	// Random intervals around the start of daylight saving time, on March
+	// 12th, 2023
+	rnd := rand.New(rand.NewSource(1))
+	categories := []string{pomodoro.CategoryPomodoro, pomodoro.CategorySnooze,
+		pomodoro.CategoryShortBreak, pomodoro.CategoryLongBreak}
+	states := []int{pomodoro.StateDone, pomodoro.StateCancelled}
+	first := time.Date(2023, time.March, 6, 0, 0, 0, 0, loc)
+	for k := 0; k < 300; k++ {
+		planned := time.Duration(1+rnd.Intn(25)) * time.Minute
+		_, err := repo.Create(pomodoro.Interval{
+			StartTime:       first.Add(time.Duration(rnd.Int63n(int64(12 * 24 * time.Hour)))),
+			PlannedDuration: planned,
+			ActualDuration:  time.Duration(rnd.Int63n(int64(planned) + 1)),
+			Category:        categories[rnd.Intn(len(categories))],
+			State:           states[rnd.Intn(len(states))],
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	start := time.Date(2023, time.March, 16, 9, 0, 0, 0, loc)
+	series, err := pomodoro.RangeSummary(start, 10, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		ds, err := pomodoro.DailySummary(start.AddDate(0, 0, -i), config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if series[0].Values[i] != ds[0].Seconds() || series[1].Values[i] != ds[1].Seconds() {
+			t.Errorf("%s: expected %v, got %v and %v", series[0].Labels[i], ds,
+				series[0].Values[i], series[1].Values[i])
+		}
+	}
+}
+
+func TestMonthlySummary(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := pomodoro.NewConfig(repo, 0, 0, 0)
+	config.Location = loc
+
+	first := time.Date(2024, time.June, 1, 0, 0, 0, 0, loc)
+	for _, i := range []pomodoro.Interval{
+		// Late on May 31st, June 1st in UTC
+		{StartTime: first.Add(-time.Hour), Category: pomodoro.CategoryPomodoro},
+		{StartTime: first.Add(9 * time.Hour), Category: pomodoro.CategoryPomodoro},
+		{StartTime: first.Add(10 * time.Hour), Category: pomodoro.CategoryShortBreak},
+		{StartTime: first.AddDate(0, 0, 14).Add(9 * time.Hour), Category: pomodoro.CategoryPomodoro},
+		{StartTime: first.AddDate(0, 0, 14).Add(10 * time.Hour), Category: pomodoro.CategorySnooze},
+		{StartTime: first.AddDate(0, 0, 29).Add(23 * time.Hour), Category: pomodoro.CategoryPomodoro},
+		{StartTime: first.AddDate(0, 1, 0), Category: pomodoro.CategoryPomodoro},
+	} {
+		i.PlannedDuration = 25 * time.Minute
+		i.ActualDuration = 25 * time.Minute
+		i.State = pomodoro.StateDone
+		if _, err := repo.Create(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := pomodoro.MonthlySummary(first.AddDate(0, 0, 20), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Days) != 30 {
+		t.Fatalf("expected 30 days, got %d", len(s.Days))
+	}
+	if s.Focus != 100*time.Minute || s.Breaks != 25*time.Minute || s.Pomodoros != 3 {
+		t.Errorf("expected 1h40m focus, 25m breaks and 3 pomodoros, got %+v", s)
+	}
+	for k, d := range s.Days {
+		if !d.Day.Equal(first.AddDate(0, 0, k)) {
+			t.Errorf("expected day %d on %s, got %s", k, first.AddDate(0, 0, k), d.Day)
+		}
+		ds, err := pomodoro.DailySummary(d.Day, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Focus != ds[0] || d.Breaks != ds[1] {
+			t.Errorf("%s: expected %v, got %v and %v", d.Day.Format(pomodoro.DayLayout), ds, d.Focus, d.Breaks)
+		}
+	}
+	if d := s.Days[14]; d.Focus != 50*time.Minute || d.Pomodoros != 1 {
+		t.Errorf("expected a pomodoro and a snooze on June 15th, got %+v", d)
+	}
+}
+
+func TestFocusQualitySummary(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+	config := pomodoro.NewConfig(repo, 0, 0, 0)
+	config.Location = time.UTC
+
+	day := time.Date(2024, time.June, 3, 0, 0, 0, 0, time.UTC)
+
+	// A day of intervals without pauses or interruptions
+	addDone(t, repo, pomodoro.CategoryPomodoro, day.Add(9*time.Hour), 25*time.Minute)
+	q, err := pomodoro.FocusQualitySummary(day.Add(12*time.Hour), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q != (pomodoro.FocusQuality{}) {
+		t.Errorf("expected no pauses or interruptions, got %+v", q)
+	}
+
+	for k, p := range []time.Duration{3 * time.Minute, 4 * time.Minute} {
+		i := pomodoro.Interval{
+			StartTime:       day.Add(time.Duration(10+k) * time.Hour),
+			PlannedDuration: 25 * time.Minute,
+			ActualDuration:  25 * time.Minute,
+			Category:        pomodoro.CategoryPomodoro,
+			State:           pomodoro.StateDone,
+		}
+		id, err := repo.Create(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		i.ID, i.PausedDuration = id, p
+		if err := repo.Update(i); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.AddInterruption(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	addDone(t, repo, pomodoro.CategoryPomodoro, day.AddDate(0, 0, 1).Add(9*time.Hour), 25*time.Minute)
+
+	if q, err = pomodoro.FocusQualitySummary(day.Add(12*time.Hour), config); err != nil {
+		t.Fatal(err)
+	}
+	if exp := (pomodoro.FocusQuality{Paused: 7 * time.Minute, Interruptions: 2}); q != exp {
+		t.Errorf("expected %+v, got %+v", exp, q)
+	}
+}
+
+func TestStreak(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	today := time.Date(2024, time.June, 10, 0, 0, 0, 0, time.UTC)
+	config := pomodoro.NewConfig(repo, 0, 0, 0)
+	config.Clock = fixedClock{now: today.Add(15 * time.Hour)}
+
+	streak := func() int {
+		t.Helper()
+		n, err := pomodoro.Streak(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if n := streak(); n != 0 {
+		t.Errorf("expected no streak without pomodoros, got %d", n)
+	}
+
+	// Breaks don't count, and a gap ends the streak
+	addDone(t, repo, pomodoro.CategoryShortBreak, today.AddDate(0, 0, -1).Add(9*time.Hour), 5*time.Minute)
+	addDone(t, repo, pomodoro.CategoryPomodoro, today.AddDate(0, 0, -200).Add(9*time.Hour), 25*time.Minute)
+	if n := streak(); n != 0 {
+		t.Errorf("expected no streak, got %d", n)
+	}
+
+	// Longer than the days counted at a time, without a pomodoro today yet
+	for d := 1; d <= 100; d++ {
+		addDone(t, repo, pomodoro.CategoryPomodoro, today.AddDate(0, 0, -d).Add(9*time.Hour), 25*time.Minute)
+	}
+	if n := streak(); n != 100 {
+		t.Errorf("expected a streak of 100 up to yesterday, got %d", n)
+	}
+
+	addDone(t, repo, pomodoro.CategoryPomodoro, today.Add(9*time.Hour), 25*time.Minute)
+	if n := streak(); n != 101 {
+		t.Errorf("expected a streak of 101 including today, got %d", n)
+	}
+}
+
+func TestSessionStats(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+	config := pomodoro.NewConfig(repo, 0, 0, 0)
+
+	start := time.Date(2024, time.June, 3, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 1)
+
+	s, err := pomodoro.SessionStats(start, end, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.AverageLength != 0 || s.LongestStreak != 0 || len(s.States) != 0 {
+		t.Errorf("expected no stats without intervals, got %+v", s)
+	}
+
+	// Created out of order to check they're taken by start time
+	for _, i := range []struct {
+		hour     int
+		category string
+		state    int
+		actual   time.Duration
+	}{
+		{9, pomodoro.CategoryPomodoro, pomodoro.StateDone, 25 * time.Minute},
+		{10, pomodoro.CategoryShortBreak, pomodoro.StateDone, 5 * time.Minute},
+		{14, pomodoro.CategoryPomodoro, pomodoro.StateDone, 20 * time.Minute},
+		{11, pomodoro.CategoryPomodoro, pomodoro.StateDone, 30 * time.Minute},
+		{12, pomodoro.CategoryPomodoro, pomodoro.StateCancelled, 10 * time.Minute},
+		{13, pomodoro.CategoryPomodoro, pomodoro.StateDone, 25 * time.Minute},
+		{15, pomodoro.CategoryLongBreak, pomodoro.StateSkipped, 0},
+		// The next day
+		{33, pomodoro.CategoryPomodoro, pomodoro.StateDone, 25 * time.Minute},
+	} {
+		_, err := repo.Create(pomodoro.Interval{
+			StartTime:       start.Add(time.Duration(i.hour) * time.Hour),
+			PlannedDuration: 25 * time.Minute,
+			ActualDuration:  i.actual,
+			Category:        i.category,
+			State:           i.state,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if s, err = pomodoro.SessionStats(start, end, config); err != nil {
+		t.Fatal(err)
+	}
+	if s.AverageLength != 25*time.Minute {
+		t.Errorf("expected an average of 25m, got %v", s.AverageLength)
+	}
+	// The cancellation at 12:00 breaks the run of 9:00 and 11:00, the
+	// break between them doesn't
+	if s.LongestStreak != 2 {
+		t.Errorf("expected a longest streak of 2, got %d", s.LongestStreak)
+	}
+	exp := map[int]int{pomodoro.StateDone: 5, pomodoro.StateCancelled: 1, pomodoro.StateSkipped: 1}
+	if len(s.States) != len(exp) {
+		t.Errorf("expected states %v, got %v", exp, s.States)
+	}
+	for state, n := range exp {
+		if s.States[state] != n {
+			t.Errorf("expected %d intervals %s, got %d", n, pomodoro.StateName(state), s.States[state])
+		}
+	}
+}
+
+func TestCompletionStats(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+	config := pomodoro.NewConfig(repo, 0, 0, 0)
+
+	start := time.Date(2024, time.June, 3, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 1)
+
+	// A pending pomodoro alone gives no ratio
+	if _, err := repo.Create(pomodoro.Interval{
+		PlannedDuration: 25 * time.Minute,
+		Category:        pomodoro.CategoryPomodoro,
+		State:           pomodoro.StateNotStarted,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := pomodoro.CompletionStats(start, end, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != (pomodoro.Stats{}) {
+		t.Errorf("expected no stats without started pomodoros, got %+v", s)
+	}
+
+	for k, state := range []int{
+		pomodoro.StateDone, pomodoro.StateDone, pomodoro.StateDone,
+		pomodoro.StateCancelled, pomodoro.StateSkipped,
+	} {
+		_, err := repo.Create(pomodoro.Interval{
+			StartTime:       start.Add(time.Duration(9+k) * time.Hour),
+			PlannedDuration: 25 * time.Minute,
+			Category:        pomodoro.CategoryPomodoro,
+			State:           state,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	addDone(t, repo, pomodoro.CategoryShortBreak, start.Add(15*time.Hour), 5*time.Minute)
+
+	if s, err = pomodoro.CompletionStats(start, end, config); err != nil {
+		t.Fatal(err)
+	}
+	exp := pomodoro.Stats{Done: 3, Cancelled: 1, Skipped: 1, Ratio: 0.6, HasRatio: true}
+	if s != exp {
+		t.Errorf("expected %+v, got %+v", exp, s)
+	}
+}
+
+func TestHourlyHistogram(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	loc, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := pomodoro.NewConfig(repo, 0, 0, 0)
+	config.Location = loc
+
+	day := time.Date(2024, time.June, 3, 0, 0, 0, 0, loc)
+	addDone(t, repo, pomodoro.CategoryPomodoro, day.Add(10*time.Hour), 25*time.Minute)
+	// Split between 10am and 11am
+	addDone(t, repo, pomodoro.CategoryPomodoro, day.Add(10*time.Hour+50*time.Minute), 25*time.Minute)
+	addDone(t, repo, pomodoro.CategorySnooze, day.Add(23*time.Hour+55*time.Minute), 10*time.Minute)
+	// Breaks, unfinished pomodoros and other days don't count
+	addDone(t, repo, pomodoro.CategoryShortBreak, day.Add(9*time.Hour), 5*time.Minute)
+	addDone(t, repo, pomodoro.CategoryPomodoro, day.AddDate(0, 0, 1).Add(9*time.Hour), 25*time.Minute)
+	if _, err := repo.Create(pomodoro.Interval{
+		StartTime:       day.Add(14 * time.Hour),
+		PlannedDuration: 25 * time.Minute,
+		ActualDuration:  10 * time.Minute,
+		Category:        pomodoro.CategoryPomodoro,
+		State:           pomodoro.StateCancelled,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	hours, err := pomodoro.HourlyHistogram(day, day.AddDate(0, 0, 1), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exp [24]time.Duration
+	exp[10] = 35 * time.Minute
+	exp[11] = 15 * time.Minute
+	exp[23] = 5 * time.Minute
+	exp[0] = 5 * time.Minute
+	if hours != exp {
+		t.Errorf("expected %v, got %v", exp, hours)
+	}
+}
+
+func TestWeekdaySummary(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	// Wednesday
+	now := time.Date(2024, time.June, 12, 15, 0, 0, 0, time.UTC)
+	config := pomodoro.NewConfig(repo, 0, 0, 0)
+	config.Clock = fixedClock{now: now}
+	config.Location = time.UTC
+
+	day := func(daysAgo int) time.Time {
+		return time.Date(2024, time.June, 12-daysAgo, 9, 0, 0, 0, time.UTC)
+	}
+	// Two Wednesdays, today included, and a Friday with a snooze
+	addDone(t, repo, pomodoro.CategoryPomodoro, day(0), 25*time.Minute)
+	addDone(t, repo, pomodoro.CategoryPomodoro, day(7), time.Hour)
+	addDone(t, repo, pomodoro.CategoryPomodoro, day(5), 20*time.Minute)
+	addDone(t, repo, pomodoro.CategorySnooze, day(5).Add(time.Hour), 10*time.Minute)
+	addDone(t, repo, pomodoro.CategoryShortBreak, day(4), 5*time.Minute)
+	// The Wednesday before, just out of range
+	addDone(t, repo, pomodoro.CategoryPomodoro, day(14), time.Hour)
+
+	days, err := pomodoro.WeekdaySummary(2, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := map[time.Weekday]time.Duration{
+		time.Wednesday: 42*time.Minute + 30*time.Second,
+		time.Friday:    15 * time.Minute,
+	}
+	if len(days) != 7 {
+		t.Errorf("expected every weekday, got %v", days)
+	}
+	for wd := time.Sunday; wd <= time.Saturday; wd++ {
+		if days[wd] != exp[wd] {
+			t.Errorf("%s: expected %s on average, got %s", wd, exp[wd], days[wd])
+		}
+	}
+
+	config.WeekdayMode = pomodoro.WeekdayTotal
+	if days, err = pomodoro.WeekdaySummary(2, config); err != nil {
+		t.Fatal(err)
+	}
+	if days[time.Wednesday] != 85*time.Minute || days[time.Friday] != 30*time.Minute {
+		t.Errorf("expected totals of 1h25m on Wednesdays and 30m on Fridays, got %v", days)
+	}
+}
+
+func TestYearSummary(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+	config := pomodoro.NewConfig(repo, 0, 0, 0)
+	config.Location = time.UTC
+	config.Clock = fixedClock{now: time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC)}
+
+	at := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2024, month, day, hour, 0, 0, 0, time.UTC)
+	}
+	// A streak of 3 days in January, then 2 days apart in February
+	addDone(t, repo, pomodoro.CategoryPomodoro, at(time.January, 8, 9), 25*time.Minute)
+	addDone(t, repo, pomodoro.CategoryPomodoro, at(time.January, 9, 9), 25*time.Minute)
+	addDone(t, repo, pomodoro.CategoryPomodoro, at(time.January, 10, 9), 25*time.Minute)
+	addDone(t, repo, pomodoro.CategoryPomodoro, at(time.February, 1, 9), time.Hour)
+	addDone(t, repo, pomodoro.CategoryPomodoro, at(time.February, 1, 11), 30*time.Minute)
+	addDone(t, repo, pomodoro.CategoryPomodoro, at(time.February, 3, 9), 25*time.Minute)
+	addDone(t, repo, pomodoro.CategoryShortBreak, at(time.February, 3, 10), 5*time.Minute)
+	// Today and after today, out of the review
+	addDone(t, repo, pomodoro.CategoryPomodoro, at(time.March, 5, 9), 20*time.Minute)
+	addDone(t, repo, pomodoro.CategoryPomodoro, at(time.March, 6, 9), 25*time.Minute)
+	// Last year
+	addDone(t, repo, pomodoro.CategoryPomodoro, time.Date(2023, time.December, 31, 9, 0, 0, 0, time.UTC), 25*time.Minute)
+
+	r, err := pomodoro.YearSummary(2024, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Partial || !r.End.Equal(at(time.March, 6, 0)) {
+		t.Errorf("expected a partial year up to today, got %v partial %v", r.End, r.Partial)
+	}
+	if r.Pomodoros != 7 || r.Focus != 3*time.Hour+30*time.Minute {
+		t.Errorf("expected 7 pomodoros in 3h30m, got %d in %v", r.Pomodoros, r.Focus)
+	}
+	if !r.BusiestDay.Day.Equal(at(time.February, 1, 0)) || r.BusiestDay.Focus != 90*time.Minute {
+		t.Errorf("expected February 1st busiest with 1h30m, got %+v", r.BusiestDay)
+	}
+	if r.BusiestMonth != time.February || r.BusiestMonthFocus != 115*time.Minute {
+		t.Errorf("expected February busiest with 1h55m, got %v with %v", r.BusiestMonth, r.BusiestMonthFocus)
+	}
+	if r.LongestStreak != 3 {
+		t.Errorf("expected a streak of 3 days, got %d", r.LongestStreak)
+	}
+	if r.WorkingDays != 6 || r.AveragePerWorkingDay != 35*time.Minute {
+		t.Errorf("expected 6 working days averaging 35m, got %d averaging %v", r.WorkingDays, r.AveragePerWorkingDay)
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v map[string]any
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v["end"] != "2024-03-05" || v["focus"] != "3h30m0s" || v["longest_streak"] != float64(3) {
+		t.Errorf("unexpected JSON %s", data)
+	}
+
+	// A year without work has nothing busiest, and years to come no review
+	if r, err = pomodoro.YearSummary(2022, config); err != nil {
+		t.Fatal(err)
+	}
+	if r.Partial || r.Pomodoros != 0 || r.BusiestMonth != 0 || !r.BusiestDay.Day.IsZero() {
+		t.Errorf("expected an empty year, got %+v", r)
+	}
+	if data, err = json.Marshal(r); err != nil {
+		t.Fatal(err)
+	}
+	v = nil
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	if day, ok := v["busiest_day"]; !ok || day != nil {
+		t.Errorf("expected a null busiest day in JSON, got %s", data)
+	}
+	if _, err := pomodoro.YearSummary(2025, config); !errors.Is(err, pomodoro.ErrInvalidRange) {
+		t.Errorf("expected error %q, got %v", pomodoro.ErrInvalidRange, err)
+	}
+}
+
+func TestRoundDuration(t *testing.T) {
+	const q = 15 * time.Minute
+	tests := []struct {
+		d                    time.Duration
+		to                   time.Duration
+		nearest, floor, ceil time.Duration
+	}{
+		{4*time.Hour + 58*time.Minute + 12*time.Second, q, 5 * time.Hour, 4*time.Hour + 45*time.Minute, 5 * time.Hour},
+		// Exactly halfway
+		{7*time.Minute + 30*time.Second, q, q, 0, q},
+		{22*time.Minute + 30*time.Second, q, 30 * time.Minute, q, 30 * time.Minute},
+		{-7*time.Minute - 30*time.Second, q, -q, -q, 0},
+		// Already a multiple
+		{0, q, 0, 0, 0},
+		{30 * time.Minute, q, 30 * time.Minute, 30 * time.Minute, 30 * time.Minute},
+		{time.Nanosecond, q, 0, 0, q},
+		{q - time.Nanosecond, q, q, 0, q},
+		// No rounding
+		{4*time.Hour + 58*time.Minute + 12*time.Second, 0,
+			4*time.Hour + 58*time.Minute + 12*time.Second,
+			4*time.Hour + 58*time.Minute + 12*time.Second,
+			4*time.Hour + 58*time.Minute + 12*time.Second},
+	}
+	for _, tc := range tests {
+		for mode, exp := range map[pomodoro.RoundMode]time.Duration{
+			pomodoro.RoundNearest: tc.nearest,
+			pomodoro.RoundFloor:   tc.floor,
+			pomodoro.RoundCeil:    tc.ceil,
+		} {
+			if got := pomodoro.RoundDuration(tc.d, tc.to, mode); got != exp {
+				t.Errorf("%v to %v %s: expected %v, got %v", tc.d, tc.to, mode, exp, got)
+			}
+		}
+	}
+
+	for _, mode := range []pomodoro.RoundMode{pomodoro.RoundNearest, pomodoro.RoundFloor, pomodoro.RoundCeil} {
+		if got, err := pomodoro.ParseRoundMode(mode.String()); err != nil || got != mode {
+			t.Errorf("expected %s parsed, got %s, %v", mode, got, err)
+		}
+	}
+	if _, err := pomodoro.ParseRoundMode("up"); !errors.Is(err, pomodoro.ErrInvalidRoundMode) {
+		t.Errorf("expected error %q, got %v", pomodoro.ErrInvalidRoundMode, err)
+	}
+}
+
+func TestHeatmapData(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+	config := pomodoro.NewConfig(repo, 0, 0, 0)
+	config.Location = time.UTC
+
+	at := func(month time.Month, day int) time.Time {
+		return time.Date(2024, month, day, 9, 0, 0, 0, time.UTC)
+	}
+	addDone(t, repo, pomodoro.CategoryPomodoro, at(time.May, 29), 25*time.Minute)
+	addDone(t, repo, pomodoro.CategoryPomodoro, at(time.May, 30), 25*time.Minute)
+	addDone(t, repo, pomodoro.CategoryPomodoro, at(time.May, 30).Add(time.Hour), 25*time.Minute)
+	addDone(t, repo, pomodoro.CategoryPomodoro, at(time.June, 2), 25*time.Minute)
+	addDone(t, repo, pomodoro.CategoryShortBreak, at(time.June, 4), 5*time.Minute)
+	addDone(t, repo, pomodoro.CategoryPomodoro, at(time.June, 12), 25*time.Minute)
+	addDone(t, repo, pomodoro.CategoryPomodoro, at(time.June, 13), 25*time.Minute)
+
+	// Two weeks up to Wednesday, June 12th start on Thursday, May 30th
+	end := time.Date(2024, time.June, 12, 14, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		sunday bool
+		grid   [][7]int
+		labels []string
+	}{
+		{"Monday", false, [][7]int{
+			{-1, -1, -1, 2, 0, 0, 1},
+			{0, 0, 0, 0, 0, 0, 0},
+			{0, 0, 1, -1, -1, -1, -1},
+		}, []string{"May", "Jun", ""}},
+		{"Sunday", true, [][7]int{
+			{-1, -1, -1, -1, 2, 0, 0},
+			{1, 0, 0, 0, 0, 0, 0},
+			{0, 0, 0, 1, -1, -1, -1},
+		}, []string{"May", "Jun", ""}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			config.WeekStartsSunday = tc.sunday
+			grid, labels, err := pomodoro.HeatmapData(end, 2, config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(grid) != len(tc.grid) || len(labels) != len(tc.labels) {
+				t.Fatalf("expected %v %q, got %v %q", tc.grid, tc.labels, grid, labels)
+			}
+			for k := range grid {
+				if grid[k] != tc.grid[k] || labels[k] != tc.labels[k] {
+					t.Errorf("expected %v %q, got %v %q", tc.grid, tc.labels, grid, labels)
+					break
+				}
+			}
+		})
+	}
+
+	if _, _, err := pomodoro.HeatmapData(end, 0, config); !errors.Is(err, pomodoro.ErrInvalidRange) {
+		t.Errorf("expected error %q, got %v", pomodoro.ErrInvalidRange, err)
+	}
+}
+
+func TestCompareWeeks(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+	config := pomodoro.NewConfig(repo, 0, 0, 0)
+	config.Location = time.UTC
+
+	// Wednesday, June 12th, 2024 at noon
+	asOf := time.Date(2024, time.June, 12, 12, 0, 0, 0, time.UTC)
+	at := func(daysAgo, hour int) time.Time {
+		return time.Date(2024, time.June, 12-daysAgo, hour, 0, 0, 0, time.UTC)
+	}
+
+	// This week so far: 1h15m in 3 pomodoros, one cancelled
+	addDone(t, repo, pomodoro.CategoryPomodoro, at(2, 9), 25*time.Minute)
+	addDone(t, repo, pomodoro.CategoryPomodoro, at(1, 9), 25*time.Minute)
+	addDone(t, repo, pomodoro.CategoryPomodoro, at(0, 9), 25*time.Minute)
+	if _, err := repo.Create(pomodoro.Interval{
+		StartTime:       at(0, 10),
+		PlannedDuration: 25 * time.Minute,
+		ActualDuration:  5 * time.Minute,
+		Category:        pomodoro.CategoryPomodoro,
+		State:           pomodoro.StateCancelled,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// Last week up to Wednesday noon: 50m in 2 pomodoros
+	addDone(t, repo, pomodoro.CategoryPomodoro, at(9, 9), 25*time.Minute)
+	addDone(t, repo, pomodoro.CategoryPomodoro, at(7, 11), 25*time.Minute)
+	// Later that Wednesday and on the Sunday, out of the compared span
+	// unless weeks start on Sunday
+	addDone(t, repo, pomodoro.CategoryPomodoro, at(7, 15), time.Hour)
+	addDone(t, repo, pomodoro.CategoryPomodoro, at(10, 9), 25*time.Minute)
+
+	c, err := pomodoro.CompareWeeks(config, asOf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.ThisWeek.Start.Equal(at(2, 0)) || !c.LastWeek.End.Equal(asOf.AddDate(0, 0, -7)) {
+		t.Errorf("expected weeks from Monday up to Wednesday noon, got %+v and %+v", c.ThisWeek, c.LastWeek)
+	}
+	if c.ThisWeek.Focus != 75*time.Minute || c.ThisWeek.Pomodoros != 3 || c.ThisWeek.Completion.Ratio != 0.75 {
+		t.Errorf("expected 1h15m in 3 of 4 pomodoros this week, got %+v", c.ThisWeek)
+	}
+	if c.LastWeek.Focus != 50*time.Minute || c.LastWeek.Pomodoros != 2 {
+		t.Errorf("expected 50m in 2 pomodoros last week, got %+v", c.LastWeek)
+	}
+	exp := [3]pomodoro.Change{{Percent: 50, Valid: true}, {Percent: 50, Valid: true}, {Percent: -25, Valid: true}}
+	if got := [3]pomodoro.Change{c.Focus, c.Pomodoros, c.Completion}; got != exp {
+		t.Errorf("expected changes %+v, got %+v", exp, got)
+	}
+
+	config.WeekStartsSunday = true
+	if c, err = pomodoro.CompareWeeks(config, asOf); err != nil {
+		t.Fatal(err)
+	}
+	if c.LastWeek.Pomodoros != 3 {
+		t.Errorf("expected last week to include Sunday, got %+v", c.LastWeek)
+	}
+
+	// Nothing to compare with
+	if c, err = pomodoro.CompareWeeks(config, asOf.AddDate(0, 0, -14)); err != nil {
+		t.Fatal(err)
+	}
+	if c.Focus.Valid || c.Pomodoros.Valid || c.Completion.Valid {
+		t.Errorf("expected no changes without work, got %+v", c)
+	}
+}
+
+func TestTaskSummary(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config := pomodoro.NewConfig(repo, 5*time.Second, time.Second, time.Second)
+	config.Clock = pomodoro.NewScaledClock(100)
+	noop := func(pomodoro.Interval) {}
+
+	if _, err := pomodoro.AddTask(config, "write report", 3); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pomodoro.AddTask(config, "write report", 1); !errors.Is(err, pomodoro.ErrTaskExists) {
+		t.Fatalf("expected error %q, got %q", pomodoro.ErrTaskExists, err)
+	}
+
+	t.Run("NotFound", func(t *testing.T) {
+		config.Task = "unknown"
+		if _, err := pomodoro.GetInterval(config); !errors.Is(err, pomodoro.ErrTaskNotFound) {
+			t.Fatalf("expected error %q, got %q", pomodoro.ErrTaskNotFound, err)
+		}
+		if _, err := pomodoro.TaskSummary(config, "unknown"); !errors.Is(err, pomodoro.ErrTaskNotFound) {
+			t.Fatalf("expected error %q, got %q", pomodoro.ErrTaskNotFound, err)
+		}
+	})
+
+	run := func() {
+		t.Helper()
+		for k := 0; k < 2; k++ {
+			i, err := pomodoro.GetInterval(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := i.Start(context.Background(), config, noop, noop, noop); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	config.Task = "write report"
+	run()
+	run()
+
+	config.Task = "review"
+	config.AutoCreateTasks = true
+	run()
+
+	testCases := []struct {
+		name         string
+		expEstimate  int
+		expCompleted int64
+	}{
+		{"write report", 3, 2},
+		{"review", 0, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := pomodoro.TaskSummary(config, tc.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.Estimate != tc.expEstimate {
+				t.Errorf("expected estimate %d, got %d", tc.expEstimate, s.Estimate)
+			}
+			if s.Completed != tc.expCompleted {
+				t.Errorf("expected %d completed, got %d", tc.expCompleted, s.Completed)
+			}
+			expFocus := time.Duration(tc.expCompleted) * config.PomodoroDuration
+			if s.FocusTime != expFocus {
+				t.Errorf("expected focus time %s, got %s", expFocus, s.FocusTime)
+			}
+		})
+	}
+
+	tasks, err := pomodoro.Tasks(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 {
+		t.Errorf("expected 2 tasks, got %d", len(tasks))
+	}
+}
+
+func TestNextCategory(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config := pomodoro.NewConfig(repo, 3*time.Second, time.Second, 2*time.Second)
+	config.Clock = pomodoro.NewScaledClock(100)
+	noop := func(pomodoro.Interval) {}
+
+	expNext := []string{
+		pomodoro.CategoryShortBreak, pomodoro.CategoryPomodoro,
+		pomodoro.CategoryShortBreak, pomodoro.CategoryPomodoro,
+		pomodoro.CategoryShortBreak, pomodoro.CategoryPomodoro,
+		pomodoro.CategoryLongBreak, pomodoro.CategoryPomodoro,
+	}
+
+	for _, exp := range expNext {
+		i, err := pomodoro.GetInterval(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checked := false
+		periodic := func(pomodoro.Interval) {
+			if checked {
+				return
+			}
+			checked = true
+			category, d, err := pomodoro.NextCategory(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if category != exp {
+				t.Errorf("expected next category %q, got %q", exp, category)
+			}
+			var expDuration time.Duration
+			switch category {
+			case pomodoro.CategoryPomodoro:
+				expDuration = config.PomodoroDuration
+			case pomodoro.CategoryShortBreak:
+				expDuration = config.ShortBreakDuration
+			case pomodoro.CategoryLongBreak:
+				expDuration = config.LongBreakDuration
+			}
+			if d != expDuration {
+				t.Errorf("expected next duration %s, got %s", expDuration, d)
+			}
+
+			s, err := pomodoro.GetStatus(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !s.Active || s.NextCategory != exp {
+				t.Errorf("expected active status with next %q, got %+v", exp, s)
+			}
+		}
+		if err := i.Start(context.Background(), config, noop, periodic, noop); err != nil {
+			t.Fatal(err)
+		}
+
+		// Previewing never creates intervals
+		last, err := repo.Last()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last.ID != i.ID {
+			t.Fatalf("expected last interval %d, got %d", i.ID, last.ID)
+		}
+		s, err := pomodoro.GetStatus(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Active || s.Interval.Category != exp || s.NextCategory != exp {
+			t.Errorf("expected idle status with upcoming %q, got %+v", exp, s)
+		}
+	}
+}
+
+func TestNoRepository(t *testing.T) {
+	config := pomodoro.NewConfig(nil, 0, 0, 0)
+	noop := func(pomodoro.Interval) {}
+
+	if _, err := pomodoro.GetInterval(config); !errors.Is(err, pomodoro.ErrNoRepository) {
+		t.Errorf("GetInterval: expected error %q, got %v", pomodoro.ErrNoRepository, err)
+	}
+	i := pomodoro.Interval{ID: 1, State: pomodoro.StateRunning}
+	if err := i.Start(context.Background(), config, noop, noop, noop); !errors.Is(err, pomodoro.ErrNoRepository) {
+		t.Errorf("Start: expected error %q, got %v", pomodoro.ErrNoRepository, err)
+	}
+	if err := i.Pause(config); !errors.Is(err, pomodoro.ErrNoRepository) {
+		t.Errorf("Pause: expected error %q, got %v", pomodoro.ErrNoRepository, err)
+	}
+	if _, err := pomodoro.DailySummary(time.Now(), config); !errors.Is(err, pomodoro.ErrNoRepository) {
+		t.Errorf("DailySummary: expected error %q, got %v", pomodoro.ErrNoRepository, err)
+	}
+	if _, err := pomodoro.RangeSummary(time.Now(), 7, config); !errors.Is(err, pomodoro.ErrNoRepository) {
+		t.Errorf("RangeSummary: expected error %q, got %v", pomodoro.ErrNoRepository, err)
+	}
+}
+
+// failingRepo fails Update and UpdateProgress after a number of successful
+// calls
+type failingRepo struct {
+	pomodoro.Repository
+	updates int
+}
+
+var errInjected = errors.New("disk I/O error")
+
+func (r *failingRepo) Update(i pomodoro.Interval) error {
+	if r.updates == 0 {
+		return errInjected
+	}
+	r.updates--
+	return r.Repository.Update(i)
+}
+
+func (r *failingRepo) UpdateProgress(id, version int64, actual time.Duration, state int) error {
+	if r.updates == 0 {
+		return errInjected
+	}
+	r.updates--
+	return r.Repository.UpdateProgress(id, version, actual, state)
+}
+
+// interleavingRepo lets another writer change an interval right before the
+// next progress update, which then finds it stale
+type interleavingRepo struct {
+	pomodoro.Repository
+	t      *testing.T
+	change func(i *pomodoro.Interval)
+}
+
+func (r *interleavingRepo) UpdateProgress(id, version int64, actual time.Duration, state int) error {
+	if r.change != nil {
+		i, err := r.Repository.ByID(id)
+		if err != nil {
+			r.t.Fatal(err)
+		}
+		r.change(&i)
+		r.change = nil
+		if err := r.Repository.Update(i); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+	return r.Repository.UpdateProgress(id, version, actual, state)
+}
+
+func TestTickConflict(t *testing.T) {
+	testCases := []struct {
+		name     string
+		change   func(i *pomodoro.Interval)
+		expState int
+	}{
+		{name: "Paused", change: func(i *pomodoro.Interval) { i.State = pomodoro.StatePaused },
+			expState: pomodoro.StatePaused},
+		{name: "Cancelled", change: func(i *pomodoro.Interval) { i.State = pomodoro.StateCancelled },
+			expState: pomodoro.StateCancelled},
+		// The other writer left it running, so the tick is counted
+		{name: "Relabelled", change: func(i *pomodoro.Interval) { i.Label = "other" },
+			expState: pomodoro.StateDone},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, cleanup := getRepo(t)
+			defer cleanup()
+
+			interleaving := &interleavingRepo{Repository: repo, t: t, change: tc.change}
+			config := pomodoro.NewConfig(interleaving, 3*time.Second, time.Second, time.Second)
+			config.Clock = pomodoro.NewScaledClock(100)
+			noop := func(pomodoro.Interval) {}
+
+			i, err := pomodoro.GetInterval(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := i.Start(context.Background(), config, noop, noop, noop); err != nil {
+				t.Fatal(err)
+			}
+
+			// The newer state wins over the stale tick
+			i, err = repo.ByID(i.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if i.State != tc.expState {
+				t.Errorf("expected state %d, got %d", tc.expState, i.State)
+			}
+			if tc.expState != pomodoro.StateDone && i.ActualDuration != 0 {
+				t.Errorf("expected no time counted, got %s", i.ActualDuration)
+			}
+		})
+	}
+}
+
+func TestPauseStale(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config := pomodoro.NewConfig(repo, 5*time.Second, time.Second, time.Second)
+	config.Clock = pomodoro.NewScaledClock(100)
+
+	i, err := pomodoro.GetInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := i
+	stale.State = pomodoro.StateRunning
+
+	// Another writer started it, so the copy is stale but still running
+	// when read again
+	i.State = pomodoro.StateRunning
+	if err := repo.Update(i); err != nil {
+		t.Fatal(err)
+	}
+	if err := stale.Pause(config); err != nil {
+		t.Fatal(err)
+	}
+	if i, err = repo.ByID(i.ID); err != nil {
+		t.Fatal(err)
+	}
+	if i.State != pomodoro.StatePaused {
+		t.Errorf("expected state %d, got %d", pomodoro.StatePaused, i.State)
+	}
+
+	// Stopped meanwhile, it's no longer paused
+	i.State = pomodoro.StateCancelled
+	if err := repo.Update(i); err != nil {
+		t.Fatal(err)
+	}
+	if err := stale.Pause(config); !errors.Is(err, pomodoro.ErrIntervalNotRunning) {
+		t.Errorf("expected error %q, got %v", pomodoro.ErrIntervalNotRunning, err)
+	}
+}
+
+func TestRepositoryFailureMidTick(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	// One update to start the interval and three ticks
+	failing := &failingRepo{Repository: repo, updates: 4}
+	config := pomodoro.NewConfig(failing, 10*time.Second, time.Second, time.Second)
+	config.Clock = pomodoro.NewScaledClock(100)
+	noop := func(pomodoro.Interval) {}
+	end := func(pomodoro.Interval) {
+		t.Error("end callback should not be executed")
+	}
+
+	i, err := pomodoro.GetInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = i.Start(context.Background(), config, noop, noop, end)
+	if !errors.Is(err, errInjected) {
+		t.Fatalf("expected error %q, got %v", errInjected, err)
+	}
+
+	// The last successful tick is kept
+	i, err = repo.ByID(i.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.State != pomodoro.StateRunning {
+		t.Errorf("expected state %d, got %d", pomodoro.StateRunning, i.State)
+	}
+	if i.ActualDuration != 3*time.Second {
+		t.Errorf("expected duration %s, got %s", 3*time.Second, i.ActualDuration)
+	}
+
+	// The interval isn't left locked and finishes once the repository recovers
+	failing.updates = 100
+	if err := i.Start(context.Background(), config, noop, noop, noop); err != nil {
+		t.Fatal(err)
+	}
+	i, err = repo.ByID(i.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.State != pomodoro.StateDone {
+		t.Errorf("expected state %d, got %d", pomodoro.StateDone, i.State)
+	}
+}
+
+func TestSummaryCache(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	cache := pomodoro.NewSummaryCache(repo)
+	config := pomodoro.NewConfig(cache, 3*time.Second, time.Second, time.Second)
+	config.Clock = pomodoro.NewScaledClock(100)
+
+	summary := func() time.Duration {
+		t.Helper()
+		ds, err := pomodoro.DailySummary(config.Now(), config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := pomodoro.RangeSummary(config.Now(), 7, config); err != nil {
+			t.Fatal(err)
+		}
+		return ds[0]
+	}
+
+	// Three category summaries and three daily totals, cached the second
+	// time
+	summary()
+	summary()
+	if s := cache.Stats(); s != (pomodoro.CacheStats{Hits: 6, Misses: 6}) {
+		t.Errorf("expected 6 hits and 6 misses, got %+v", s)
+	}
+
+	// A completed interval shows in the next read
+	noop := func(pomodoro.Interval) {}
+	i, err := pomodoro.GetInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := i.Start(context.Background(), config, noop, noop, noop); err != nil {
+		t.Fatal(err)
+	}
+	if d := summary(); d != 3*time.Second {
+		t.Errorf("expected the completed pomodoro summarized, got %s", d)
+	}
+	if s := cache.Stats(); s.Misses != 12 {
+		t.Errorf("expected every summary read again, got %+v", s)
+	}
+
+	// Writes bypassing the cache aren't seen
+	addDone(t, repo, pomodoro.CategoryPomodoro, config.Now(), time.Minute)
+	if d := summary(); d != 3*time.Second {
+		t.Errorf("expected the cached summary, got %s", d)
+	}
+	if err := cache.SetNote(i.ID, "cached"); err != nil {
+		t.Fatal(err)
+	}
+	if d := summary(); d != time.Minute+3*time.Second {
+		t.Errorf("expected the summary read again after a write, got %s", d)
+	}
+}
+
+func TestSummaryCompletedOnly(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	day := time.Now()
+	intervals := []pomodoro.Interval{
+		{Category: pomodoro.CategoryPomodoro, State: pomodoro.StateDone, PlannedDuration: 25 * time.Minute, ActualDuration: 25 * time.Minute},
+		{Category: pomodoro.CategoryPomodoro, State: pomodoro.StateCancelled, PlannedDuration: 25 * time.Minute, ActualDuration: 30 * time.Second},
+		{Category: pomodoro.CategoryPomodoro, State: pomodoro.StateCancelled, PlannedDuration: 25 * time.Minute, ActualDuration: 20 * time.Minute},
+		{Category: pomodoro.CategoryPomodoro, State: pomodoro.StatePaused, PlannedDuration: 25 * time.Minute, ActualDuration: 10 * time.Minute},
+		{Category: pomodoro.CategoryShortBreak, State: pomodoro.StateDone, PlannedDuration: 5 * time.Minute, ActualDuration: 5 * time.Minute},
+		{Category: pomodoro.CategoryShortBreak, State: pomodoro.StateCancelled, PlannedDuration: 5 * time.Minute, ActualDuration: 4 * time.Minute},
+	}
+	for _, i := range intervals {
+		i.StartTime = day
+		if _, err := repo.Create(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		ratio    float64
+		expPomo  time.Duration
+		expBreak time.Duration
+	}{
+		{"DoneOnly", 0, 25 * time.Minute, 5 * time.Minute},
+		{"Ratio80", 0.8, 45 * time.Minute, 9 * time.Minute},
+		{"Ratio90", 0.9, 25 * time.Minute, 5 * time.Minute},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := pomodoro.NewConfig(repo, 0, 0, 0)
+			config.SummaryCancelledRatio = tc.ratio
+
+			ds, err := pomodoro.DailySummary(day, config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ds[0] != tc.expPomo {
+				t.Errorf("expected pomodoro %s, got %s", tc.expPomo, ds[0])
+			}
+			if ds[1] != tc.expBreak {
+				t.Errorf("expected breaks %s, got %s", tc.expBreak, ds[1])
+			}
+
+			series, err := pomodoro.RangeSummary(day, 1, config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if series[0].Values[0] != tc.expPomo.Seconds() {
+				t.Errorf("expected range pomodoro %v, got %v", tc.expPomo.Seconds(), series[0].Values[0])
+			}
+		})
+	}
+}
+
+func TestDayStats(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	loc, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2023, time.June, 1, 9, 0, 0, 0, loc)
+	intervals := []pomodoro.Interval{
+		{StartTime: day, Category: pomodoro.CategoryPomodoro, State: pomodoro.StateDone},
+		{StartTime: day.Add(time.Hour), Category: pomodoro.CategoryPomodoro, State: pomodoro.StateDone},
+		{StartTime: day.Add(2 * time.Hour), Category: pomodoro.CategoryPomodoro, State: pomodoro.StateCancelled},
+		{StartTime: day.Add(3 * time.Hour), Category: pomodoro.CategoryShortBreak, State: pomodoro.StateDone},
+		{StartTime: day.Add(4 * time.Hour), Category: pomodoro.CategoryPomodoro, State: pomodoro.StateRunning},
+		// Still June 1st in UTC, but June 2nd in Kolkata
+		{StartTime: day.Add(16 * time.Hour), Category: pomodoro.CategoryPomodoro, State: pomodoro.StateDone},
+		{Category: pomodoro.CategoryPomodoro, State: pomodoro.StateNotStarted},
+	}
+	for _, i := range intervals {
+		if _, err := repo.Create(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The day is bucketed in Kolkata, whatever the location of the time
+	config := pomodoro.NewConfig(repo, 0, 0, 0)
+	config.Location = loc
+
+	testCases := []struct {
+		category string
+		exp      map[int]int
+	}{
+		{pomodoro.CategoryPomodoro, map[int]int{
+			pomodoro.StateDone:      2,
+			pomodoro.StateCancelled: 1,
+			pomodoro.StateRunning:   1,
+		}},
+		{pomodoro.CategoryShortBreak, map[int]int{pomodoro.StateDone: 1}},
+		{pomodoro.CategoryLongBreak, map[int]int{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.category, func(t *testing.T) {
+			counts, err := pomodoro.DayStats(day.UTC(), tc.category, config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(counts) != len(tc.exp) {
+				t.Errorf("expected %v, got %v", tc.exp, counts)
+			}
+			for state, exp := range tc.exp {
+				if counts[state] != exp {
+					t.Errorf("expected %d intervals in state %s, got %d", exp, pomodoro.StateName(state), counts[state])
+				}
+			}
+		})
+	}
+}
+
+func TestByIDNotFound(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	id, err := repo.Create(pomodoro.Interval{Category: pomodoro.CategoryPomodoro})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, missing := range []int64{-1, 0, id + 1} {
+		if _, err := repo.ByID(missing); !errors.Is(err, pomodoro.ErrInvalidID) {
+			t.Errorf("ID %d: expected error %q, got %v", missing, pomodoro.ErrInvalidID, err)
+		}
+	}
+	if _, err := repo.ByID(id); err != nil {
+		t.Errorf("expected interval %d, got %v", id, err)
+	}
+}
+
+func TestTags(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config := pomodoro.NewConfig(repo, 0, 0, 0)
+	day := time.Date(2023, time.June, 1, 9, 0, 0, 0, time.UTC)
+	intervals := []pomodoro.Interval{
+		{StartTime: day, Category: pomodoro.CategoryPomodoro, State: pomodoro.StateDone, ActualDuration: 25 * time.Minute},
+		{StartTime: day.Add(time.Hour), Category: pomodoro.CategoryPomodoro, State: pomodoro.StateDone, ActualDuration: 20 * time.Minute},
+		{StartTime: day.Add(2 * time.Hour), Category: pomodoro.CategoryPomodoro, State: pomodoro.StateCancelled, ActualDuration: 5 * time.Minute},
+		{StartTime: day.AddDate(0, 0, 1), Category: pomodoro.CategoryPomodoro, State: pomodoro.StateDone, ActualDuration: 25 * time.Minute},
+	}
+	ids := make([]int64, len(intervals))
+	for k, i := range intervals {
+		id, err := repo.Create(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[k] = id
+	}
+
+	tags := [][]string{
+		{"#Deep", "client-a", "deep", " CLIENT-A "},
+		{"client-a"},
+		{"deep"},
+		{"deep"},
+	}
+	for k, id := range ids {
+		if err := pomodoro.TagInterval(config, id, tags[k]...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := repo.TagsFor(ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := []string{"client-a", "deep"}; fmt.Sprint(got) != fmt.Sprint(exp) {
+		t.Errorf("expected tags %v, got %v", exp, got)
+	}
+
+	summary, err := pomodoro.TagSummary(config, day, day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := map[string]time.Duration{"client-a": 45 * time.Minute, "deep": 25 * time.Minute}
+	if fmt.Sprint(summary) != fmt.Sprint(exp) {
+		t.Errorf("expected summary %v, got %v", exp, summary)
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, tag := range []string{"", "#", "two words", "a,b"} {
+			if err := repo.AddTag(ids[0], tag); !errors.Is(err, pomodoro.ErrInvalidTag) {
+				t.Errorf("tag %q: expected error %q, got %v", tag, pomodoro.ErrInvalidTag, err)
+			}
+		}
+		if err := repo.AddTag(ids[len(ids)-1]+1, "deep"); !errors.Is(err, pomodoro.ErrInvalidID) {
+			t.Errorf("expected error %q, got %v", pomodoro.ErrInvalidID, err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		last := ids[len(ids)-1]
+		if err := repo.Delete(last); err != nil {
+			t.Fatal(err)
+		}
+		// Some repositories reuse the ID of the last interval
+		id, err := repo.Create(pomodoro.Interval{StartTime: day, Category: pomodoro.CategoryPomodoro})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := repo.TagsFor(id); err != nil || len(got) != 0 {
+			t.Errorf("expected no tags, got %v, %v", got, err)
+		}
+	})
+}
+
+func TestEvents(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config := pomodoro.NewConfig(repo, 0, 0, 0)
+	i := pomodoro.Interval{Category: pomodoro.CategoryPomodoro, State: pomodoro.StateNotStarted}
+	id, err := repo.Create(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.ID = id
+
+	before := time.Now().Add(-time.Second)
+	for _, state := range []int{pomodoro.StateRunning, pomodoro.StateRunning, pomodoro.StatePaused,
+		pomodoro.StateRunning, pomodoro.StateCancelled} {
+		i.State = state
+		i.ActualDuration += time.Minute
+		if err := repo.Update(i); err != nil {
+			t.Fatal(err)
+		}
+		i.Version++
+	}
+
+	events, err := pomodoro.IntervalEvents(config, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := [][2]int{
+		{pomodoro.StateNotStarted, pomodoro.StateRunning},
+		{pomodoro.StateRunning, pomodoro.StatePaused},
+		{pomodoro.StatePaused, pomodoro.StateRunning},
+		{pomodoro.StateRunning, pomodoro.StateCancelled},
+	}
+	if len(events) != len(exp) {
+		t.Fatalf("expected %d events, got %d: %+v", len(exp), len(events), events)
+	}
+	for k, e := range events {
+		if e.IntervalID != id || e.From != exp[k][0] || e.To != exp[k][1] {
+			t.Errorf("event %d: expected %d -> %d for interval %d, got %+v", k, exp[k][0], exp[k][1], id, e)
+		}
+		if e.Time.Before(before) || (k > 0 && e.Time.Before(events[k-1].Time)) {
+			t.Errorf("event %d: unexpected time %v", k, e.Time)
+		}
+	}
+
+	if _, err := repo.EventsFor(id + 1); !errors.Is(err, pomodoro.ErrInvalidID) {
+		t.Errorf("expected error %q, got %v", pomodoro.ErrInvalidID, err)
+	}
+
+	if err := repo.Delete(id); err != nil {
+		t.Fatal(err)
+	}
+	// Some repositories reuse the ID of the last interval
+	id, err = repo.Create(pomodoro.Interval{Category: pomodoro.CategoryPomodoro})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events, err := repo.EventsFor(id); err != nil || len(events) != 0 {
+		t.Errorf("expected no events, got %v, %v", events, err)
+	}
+}
+
+func TestEventsClock(t *testing.T) {
+	for _, cached := range []bool{false, true} {
+		t.Run(fmt.Sprintf("Cached=%t", cached), func(t *testing.T) {
+			repo, cleanup := getRepo(t)
+			defer cleanup()
+
+			// The cache passes the clock on to the repository it wraps
+			var r pomodoro.Repository = repo
+			if cached {
+				r = pomodoro.NewSummaryCache(repo)
+			}
+			config := pomodoro.NewConfig(r, 0, 0, 0)
+			// Set after NewConfig, like the scaled clock of demos
+			at := time.Date(2020, time.March, 4, 5, 6, 7, 0, time.UTC)
+			config.Clock = fixedClock{now: at}
+
+			i, err := pomodoro.GetInterval(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Cancelled right away, so it's started and cancelled
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			noop := func(pomodoro.Interval) {}
+			if _, err := i.Run(ctx, config, noop, noop, noop); err != nil {
+				t.Fatal(err)
+			}
+
+			events, err := pomodoro.IntervalEvents(config, i.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != 2 {
+				t.Fatalf("expected 2 events, got %+v", events)
+			}
+			for _, e := range events {
+				if !e.Time.Equal(at) {
+					t.Errorf("expected the event at %v, got %v", at, e.Time)
+				}
+			}
+		})
+	}
+}
+
+func TestRunInTransaction(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	tr, ok := repo.(pomodoro.Transactor)
+	if !ok {
+		t.Skip("repository doesn't support transactions")
+	}
+
+	day := time.Date(2023, time.June, 1, 9, 0, 0, 0, time.UTC)
+	write := func(repo pomodoro.Repository) error {
+		id, err := repo.Create(pomodoro.Interval{
+			StartTime: day,
+			Category:  pomodoro.CategoryPomodoro,
+			State:     pomodoro.StateRunning,
+		})
+		if err != nil {
+			return err
+		}
+		if err := repo.AddTag(id, "deep"); err != nil {
+			return err
+		}
+		if _, err := repo.CreateTask(pomodoro.Task{Name: "report"}); err != nil {
+			return err
+		}
+		// Reads see the writes made earlier in the transaction
+		i, err := repo.ByID(id)
+		if err != nil {
+			return err
+		}
+		i.State = pomodoro.StateDone
+		return repo.Update(i)
+	}
+
+	errFail := errors.New("failing on purpose")
+	err := tr.RunInTransaction(func(repo pomodoro.Repository) error {
+		if err := write(repo); err != nil {
+			return err
+		}
+		return errFail
+	})
+	if !errors.Is(err, errFail) {
+		t.Fatalf("expected error %q, got %v", errFail, err)
+	}
+
+	if _, err := repo.Last(); !errors.Is(err, pomodoro.ErrNoIntervals) {
+		t.Errorf("expected error %q, got %v", pomodoro.ErrNoIntervals, err)
+	}
+	if _, err := repo.TaskByName("report"); !errors.Is(err, pomodoro.ErrTaskNotFound) {
+		t.Errorf("expected error %q, got %v", pomodoro.ErrTaskNotFound, err)
+	}
+	if summary, err := repo.TagSummary(day, day.AddDate(0, 0, 1)); err != nil || len(summary) != 0 {
+		t.Errorf("expected no tags, got %v, %v", summary, err)
+	}
+
+	if err := tr.RunInTransaction(write); err != nil {
+		t.Fatal(err)
+	}
+	i, err := repo.Last()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.State != pomodoro.StateDone {
+		t.Errorf("expected state %d, got %d", pomodoro.StateDone, i.State)
+	}
+	if tags, err := repo.TagsFor(i.ID); err != nil || len(tags) != 1 {
+		t.Errorf("expected tag deep, got %v, %v", tags, err)
+	}
+	if _, err := repo.TaskByName("report"); err != nil {
+		t.Error(err)
+	}
+	if events, err := repo.EventsFor(i.ID); err != nil || len(events) != 1 {
+		t.Errorf("expected one event, got %v, %v", events, err)
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	const (
+		workers = 8
+		rounds  = 25
+	)
+	day := time.Date(2023, time.June, 1, 9, 0, 0, 0, time.UTC)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for k := 0; k < rounds; k++ {
+				i := pomodoro.Interval{
+					StartTime:       day.Add(time.Duration(w*rounds+k) * time.Minute),
+					PlannedDuration: 25 * time.Minute,
+					Category:        pomodoro.CategoryPomodoro,
+					State:           pomodoro.StateRunning,
+				}
+				id, err := repo.Create(i)
+				if err != nil {
+					errs <- err
+					return
+				}
+				i.ID = id
+				i.ActualDuration = time.Minute
+				i.State = pomodoro.StateDone
+				if err := repo.Update(i); err != nil {
+					errs <- err
+					return
+				}
+
+				got, err := repo.ByID(id)
+				if err != nil {
+					errs <- err
+					return
+				}
+				// Changing the returned copy doesn't change the stored one
+				got.State = pomodoro.StateCancelled
+				if _, err := repo.Last(); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := repo.CategorySummary(day, "%", 0); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	got, err := repo.CategorySummary(day, pomodoro.CategoryPomodoro, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := workers * rounds * time.Minute; got != exp {
+		t.Errorf("expected %v, got %v", exp, got)
+	}
+	counts, err := repo.StateCounts(day, day.AddDate(0, 0, 1), pomodoro.CategoryPomodoro)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := counts[pomodoro.StateDone]; n != workers*rounds {
+		t.Errorf("expected %d done intervals, got %d", workers*rounds, n)
+	}
+}
+
+// TestRepositoryConformance runs the repository suite against the backend
+// selected by build tags
+func TestRepositoryConformance(t *testing.T) {
+	repotest.TestRepository(t, getRepo)
+}
+
+func TestFind(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config := pomodoro.NewConfig(repo, 0, 0, 0)
+	base := time.Date(2023, time.June, 1, 9, 0, 0, 0, time.UTC)
+	categories := []string{pomodoro.CategoryPomodoro, pomodoro.CategoryShortBreak,
+		pomodoro.CategoryLongBreak, pomodoro.CategorySnooze}
+	labels := []string{"", "write", "review 50%", "o'brien", "a_b"}
+
+	for k := 0; k < 40; k++ {
+		_, err := repo.Create(pomodoro.Interval{
+			StartTime:       base.Add(time.Duration(k) * 37 * time.Minute),
+			PlannedDuration: time.Minute,
+			Category:        categories[k%len(categories)],
+			State:           k % 5,
+			Label:           labels[k%len(labels)],
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all, err := pomodoro.FindIntervals(config, pomodoro.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 40 {
+		t.Fatalf("expected 40 intervals, got %d", len(all))
+	}
+
+	// Arbitrary filter combinations must be valid and agree with Matches
+	rnd := rand.New(rand.NewSource(1))
+	substrings := []string{"", "w", "50%", "%", "_", "'", "review", "x"}
+	for n := 0; n < 300; n++ {
+		var f pomodoro.Filter
+		if rnd.Intn(2) == 0 {
+			f.From = base.Add(time.Duration(rnd.Intn(1500)) * time.Minute)
+		}
+		if rnd.Intn(2) == 0 {
+			f.To = base.Add(time.Duration(rnd.Intn(1500)) * time.Minute)
+		}
+		for _, c := range categories {
+			if rnd.Intn(3) == 0 {
+				f.Categories = append(f.Categories, c)
+			}
+		}
+		for s := 0; s < 5; s++ {
+			if rnd.Intn(3) == 0 {
+				f.States = append(f.States, s)
+			}
+		}
+		f.Label = substrings[rnd.Intn(len(substrings))]
+		f.Text = strings.ToUpper(substrings[rnd.Intn(len(substrings))])
+		f.Limit = rnd.Intn(6)
+		f.Offset = rnd.Intn(4)
+		f.Order = pomodoro.Order(rnd.Intn(3))
+
+		got, err := pomodoro.FindIntervals(config, f)
+		if err != nil {
+			t.Fatalf("filter %+v: %v", f, err)
+		}
+
+		var exp []pomodoro.Interval
+		for k := range all {
+			i := all[k]
+			if f.Order == pomodoro.OrderDesc {
+				i = all[len(all)-1-k]
+			}
+			if f.Matches(i) {
+				exp = append(exp, i)
+			}
+		}
+		if f.Order == pomodoro.OrderLatestFirst {
+			// Start times are all different
+			sort.Slice(exp, func(a, b int) bool { return exp[a].StartTime.After(exp[b].StartTime) })
+		}
+		if f.Offset < len(exp) {
+			exp = exp[f.Offset:]
+		} else {
+			exp = nil
+		}
+		if f.Limit > 0 && len(exp) > f.Limit {
+			exp = exp[:f.Limit]
+		}
+
+		if len(got) != len(exp) {
+			t.Fatalf("filter %+v: expected %d intervals, got %d", f, len(exp), len(got))
+		}
+		for k := range got {
+			if got[k].ID != exp[k].ID {
+				t.Fatalf("filter %+v: expected interval %d at %d, got %d", f, exp[k].ID, k, got[k].ID)
+			}
+		}
+	}
+}
+
+func TestListByCategory(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config := pomodoro.NewConfig(repo, 0, 0, 0)
+	base := time.Date(2023, time.June, 1, 9, 0, 0, 0, time.UTC)
+	for k := 0; k <= pomodoro.DefaultPageSize; k++ {
+		for _, category := range []string{pomodoro.CategoryPomodoro, pomodoro.CategoryLongBreak} {
+			_, err := repo.Create(pomodoro.Interval{
+				StartTime: base.Add(time.Duration(k) * time.Hour),
+				Category:  category,
+				State:     pomodoro.StateDone,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// No limit means the default page size
+	page, err := pomodoro.ListByCategory(config, pomodoro.CategoryLongBreak, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != pomodoro.DefaultPageSize {
+		t.Fatalf("expected %d intervals, got %d", pomodoro.DefaultPageSize, len(page))
+	}
+	for k, i := range page {
+		exp := base.Add(time.Duration(pomodoro.DefaultPageSize-1-k) * time.Hour)
+		if i.Category != pomodoro.CategoryLongBreak || !i.StartTime.Equal(exp) {
+			t.Errorf("expected the %s started at %s at %d, got the %s started at %s",
+				pomodoro.CategoryLongBreak, exp, k, i.Category, i.StartTime)
+		}
+	}
+}
+
+func TestSearchIntervals(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config := pomodoro.NewConfig(repo, 0, 0, 0)
+	base := time.Date(2023, time.June, 1, 9, 0, 0, 0, time.UTC)
+	for k := 0; k <= pomodoro.DefaultPageSize; k++ {
+		_, err := repo.Create(pomodoro.Interval{
+			StartTime: base.Add(time.Duration(k) * time.Hour),
+			Category:  pomodoro.CategoryPomodoro,
+			State:     pomodoro.StateDone,
+			Label:     "Report",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// No limit means the default page size
+	found, err := pomodoro.SearchIntervals(config, "report", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != pomodoro.DefaultPageSize {
+		t.Fatalf("expected %d intervals, got %d", pomodoro.DefaultPageSize, len(found))
+	}
+	if exp := base.Add(time.Duration(pomodoro.DefaultPageSize) * time.Hour); !found[0].StartTime.Equal(exp) {
+		t.Errorf("expected the latest started first, got %s", found[0].StartTime)
+	}
+
+	// A blank query would match everything
+	for _, query := range []string{"", "  "} {
+		if _, err := pomodoro.SearchIntervals(config, query, 0); !errors.Is(err, pomodoro.ErrEmptyQuery) {
+			t.Errorf("query %q: expected error %q, got %v", query, pomodoro.ErrEmptyQuery, err)
+		}
+	}
+}
+
+func TestGetIntervalSinglePending(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config := pomodoro.NewConfig(repo, 0, 0, 0)
+
+	for k := 0; k < 5; k++ {
+		if _, err := pomodoro.GetInterval(config); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Concurrent callers share the pending interval too
+	errCh := make(chan error)
+	for k := 0; k < 5; k++ {
+		go func() {
+			_, err := pomodoro.GetInterval(config)
+			errCh <- err
+		}()
+	}
+	for k := 0; k < 5; k++ {
+		if err := <-errCh; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pending, err := pomodoro.FindIntervals(config, pomodoro.Filter{
+		States: []int{pomodoro.StateNotStarted},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 {
+		t.Fatalf("expected 1 pending interval, got %d", len(pending))
+	}
+
+	// The pending interval has no start time and must not show up in the
+	// summary of any day, including the zero one
+	pending[0].ActualDuration = time.Minute
+	if err := repo.Update(pending[0]); err != nil {
+		t.Fatal(err)
+	}
+	ds, err := pomodoro.DailySummary(time.Time{}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds[0] != 0 || ds[1] != 0 {
+		t.Errorf("expected empty summary, got %v", ds)
+	}
+}
+
+// fakeIdle reports the user as idle once away is set
+type fakeIdle struct {
+	away int32
+}
+
+func (f *fakeIdle) IdleFor() (time.Duration, error) {
+	if atomic.LoadInt32(&f.away) == 1 {
+		return time.Hour, nil
+	}
+	return 0, nil
+}
+
+func TestIdlePause(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	checker := &fakeIdle{}
+	config := pomodoro.NewConfig(repo, 10*time.Second, time.Second, time.Second)
+	config.Clock = pomodoro.NewScaledClock(100)
+	config.IdleChecker = checker
+	config.IdleThreshold = 5 * time.Minute
+
+	var paused []pomodoro.Interval
+	config.OnPause = func(i pomodoro.Interval) {
+		paused = append(paused, i)
+	}
+	noop := func(pomodoro.Interval) {}
+	periodic := func(i pomodoro.Interval) {
+		if i.ActualDuration == 3*time.Second {
+			atomic.StoreInt32(&checker.away, 1)
+		}
+	}
+	end := func(pomodoro.Interval) {
+		t.Error("end callback should not be executed")
+	}
+
+	i, err := pomodoro.GetInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := i.Start(context.Background(), config, noop, periodic, end); err != nil {
+		t.Fatal(err)
+	}
+
+	i, err = repo.ByID(i.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.State != pomodoro.StatePaused || !i.AutoPaused {
+		t.Fatalf("expected interval auto paused, got state %d auto %v", i.State, i.AutoPaused)
+	}
+	if i.ActualDuration != 3*time.Second {
+		t.Errorf("expected duration %s, got %s", 3*time.Second, i.ActualDuration)
+	}
+	if len(paused) != 1 || !paused[0].AutoPaused {
+		t.Errorf("expected one automatic OnPause, got %v", paused)
+	}
+
+	// Starting again explicitly clears the flag
+	atomic.StoreInt32(&checker.away, 0)
+	if err := i.Start(context.Background(), config, noop, noop, noop); err != nil {
+		t.Fatal(err)
+	}
+	i, err = repo.ByID(i.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.State != pomodoro.StateDone || i.AutoPaused {
+		t.Errorf("expected interval done and not auto paused, got state %d auto %v", i.State, i.AutoPaused)
+	}
+
+	// Breaks are never paused
+	atomic.StoreInt32(&checker.away, 1)
+	b, err := pomodoro.GetInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Start(context.Background(), config, noop, noop, noop); err != nil {
+		t.Fatal(err)
+	}
+	b, err = repo.ByID(b.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.State != pomodoro.StateDone {
+		t.Errorf("expected break done, got state %d", b.State)
+	}
+}
+
+func TestDayPlan(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	plan := []pomodoro.PlannedInterval{
+		{Category: pomodoro.CategoryPomodoro},
+		{Category: pomodoro.CategoryShortBreak},
+		{Category: pomodoro.CategoryPomodoro},
+		{Category: pomodoro.CategoryLongBreak, Duration: 6 * time.Second},
+		{Category: pomodoro.CategoryPomodoro, Duration: 2 * time.Second},
+	}
+	newConfig := func() *pomodoro.IntervalConfig {
+		config := pomodoro.NewConfig(repo, 3*time.Second, time.Second, 2*time.Second)
+		config.Clock = pomodoro.NewScaledClock(100)
+		config.Plan = plan
+		return config
+	}
+	noop := func(pomodoro.Interval) {}
+
+	run := func(config *pomodoro.IntervalConfig, expCategory string, expDuration time.Duration, cancel bool) {
+		t.Helper()
+		i, err := pomodoro.GetInterval(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i.Category != expCategory || i.PlannedDuration != expDuration {
+			t.Fatalf("expected %s of %s, got %s of %s",
+				expCategory, expDuration, i.Category, i.PlannedDuration)
+		}
+
+		ctx, stop := context.WithCancel(context.Background())
+		defer stop()
+		periodic := func(pomodoro.Interval) {
+			if cancel {
+				stop()
+			}
+		}
+		if err := i.Start(ctx, config, noop, periodic, noop); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := newConfig()
+	run(config, pomodoro.CategoryPomodoro, 3*time.Second, false)
+	run(config, pomodoro.CategoryShortBreak, time.Second, false)
+
+	// A cancelled interval repeats its slot
+	run(config, pomodoro.CategoryPomodoro, 3*time.Second, true)
+	run(config, pomodoro.CategoryPomodoro, 3*time.Second, false)
+
+	// The position comes from the repository, so a restart picks it up
+	config = newConfig()
+	category, d, err := pomodoro.NextCategory(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if category != pomodoro.CategoryLongBreak || d != 6*time.Second {
+		t.Errorf("expected next %s of %s, got %s of %s",
+			pomodoro.CategoryLongBreak, 6*time.Second, category, d)
+	}
+	run(config, pomodoro.CategoryLongBreak, 6*time.Second, false)
+	run(config, pomodoro.CategoryPomodoro, 2*time.Second, false)
+
+	// Once the plan is exhausted, the regular cycle continues
+	run(config, pomodoro.CategoryShortBreak, time.Second, false)
+	run(config, pomodoro.CategoryPomodoro, 3*time.Second, false)
+}
+
+func TestDayPlanSkip(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config := pomodoro.NewConfig(repo, 3*time.Second, time.Second, 2*time.Second)
+	config.Clock = pomodoro.NewScaledClock(100)
+	config.Plan = []pomodoro.PlannedInterval{
+		{Category: pomodoro.CategoryPomodoro},
+		{Category: pomodoro.CategoryLongBreak, Duration: 6 * time.Second},
+		{Category: pomodoro.CategoryPomodoro, Duration: 2 * time.Second},
+	}
+	noop := func(pomodoro.Interval) {}
+
+	i, err := pomodoro.GetInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := i.Start(context.Background(), config, noop, noop, noop); err != nil {
+		t.Fatal(err)
+	}
+
+	// A skipped slot is filled, whether it never started or was running
+	i, err = pomodoro.GetInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.Category != pomodoro.CategoryLongBreak {
+		t.Fatalf("expected category %q, got %q", pomodoro.CategoryLongBreak, i.Category)
+	}
+	if _, err := pomodoro.Skip(config); err != nil {
+		t.Fatal(err)
+	}
+
+	i, err = pomodoro.GetInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.Category != pomodoro.CategoryPomodoro || i.PlannedDuration != 2*time.Second {
+		t.Fatalf("expected %s of %s, got %s of %s",
+			pomodoro.CategoryPomodoro, 2*time.Second, i.Category, i.PlannedDuration)
+	}
+	skip := func(i pomodoro.Interval) {
+		if _, err := pomodoro.Skip(config); err != nil {
+			t.Error(err)
+		}
+	}
+	if err := i.Start(context.Background(), config, noop, skip, noop); err != nil {
+		t.Fatal(err)
+	}
+
+	// The plan is exhausted
+	i, err = pomodoro.GetInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.Category != pomodoro.CategoryShortBreak || i.PlannedDuration != time.Second {
+		t.Errorf("expected %s of %s, got %s of %s",
+			pomodoro.CategoryShortBreak, time.Second, i.Category, i.PlannedDuration)
+	}
+}
+
+func TestIntervalJSON(t *testing.T) {
+	start := time.Date(2023, time.May, 10, 9, 30, 15, 123456789, time.FixedZone("", 2*60*60))
+	testCases := []struct {
+		name string
+		i    pomodoro.Interval
+	}{
+		{"Zero", pomodoro.Interval{}},
+		{"Pending", pomodoro.Interval{
+			ID: 3, PlannedDuration: 25 * time.Minute,
+			Category: pomodoro.CategoryPomodoro, DailyOrdinal: 2,
+		}},
+		{"Done", pomodoro.Interval{
+			ID: 7, StartTime: start, PlannedDuration: 25 * time.Minute,
+			ActualDuration: 24*time.Minute + 59*time.Second + 250*time.Millisecond,
+			Category:       pomodoro.CategoryPomodoro, State: pomodoro.StateDone,
+			Label: "write", Interruptions: 2, Note: "went \"well\"", TaskID: 4,
+			Device: "desk",
+		}},
+		{"AutoPaused", pomodoro.Interval{
+			ID: 8, StartTime: start.UTC(), PlannedDuration: 5 * time.Minute,
+			ActualDuration: time.Minute, Category: pomodoro.CategoryShortBreak,
+			State: pomodoro.StatePaused, AutoPaused: true,
+		}},
+	}
+
+	equal := func(a, b pomodoro.Interval) bool {
+		if !a.StartTime.Equal(b.StartTime) {
+			return false
+		}
+		a.StartTime, b.StartTime = time.Time{}, time.Time{}
+		return a == b
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := json.Marshal(tc.i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got pomodoro.Interval
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !equal(got, tc.i) {
+				t.Errorf("expected %+v, got %+v from %s", tc.i, got, data)
+			}
+
+			data, err = tc.i.MarshalJSONWith(pomodoro.JSONOptions{DurationSeconds: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = pomodoro.Interval{}
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !equal(got, tc.i) {
+				t.Errorf("expected %+v, got %+v from %s", tc.i, got, data)
+			}
+		})
+	}
+
+	data, err := json.Marshal(testCases[2].i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	exp := map[string]any{
+		"start_time":       "2023-05-10T09:30:15.123456789+02:00",
+		"planned_duration": "25m0s",
+		"actual_duration":  "24m59.25s",
+		"category":         pomodoro.CategoryPomodoro,
+		"state":            "Done",
+	}
+	for k, v := range exp {
+		if raw[k] != v {
+			t.Errorf("expected %s %v, got %v", k, v, raw[k])
+		}
+	}
+}
+
+func TestEndFinalDuration(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	// The last tick races with the end of the interval, which doesn't last
+	// whole seconds. Repositories storing coarser durations plan the
+	// rounded one instead
+	for _, tc := range []struct {
+		name string
+		repo pomodoro.Repository
+	}{
+		{"Repository", repo},
+		{"InMemory", repository.NewInMemoryRepo()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			const duration = 2500 * time.Millisecond
+			planned := duration
+			if u, ok := tc.repo.(pomodoro.DurationUnit); ok {
+				planned = pomodoro.RoundDuration(duration, u.DurationUnit(), pomodoro.RoundNearest)
+			}
+			config := pomodoro.NewConfig(tc.repo, duration, duration, duration)
+			config.Clock = pomodoro.NewScaledClock(10)
+
+			var ended pomodoro.Interval
+			noop := func(pomodoro.Interval) {}
+			end := func(i pomodoro.Interval) {
+				ended = i
+			}
+
+			i, err := pomodoro.GetInterval(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if i.PlannedDuration != planned {
+				t.Fatalf("expected planned duration %s, got %s", planned, i.PlannedDuration)
+			}
+			if err := i.Start(context.Background(), config, noop, noop, end); err != nil {
+				t.Fatal(err)
+			}
+
+			if ended.State != pomodoro.StateDone || ended.ActualDuration != planned {
+				t.Errorf("expected end with state %d and duration %s, got %d and %s",
+					pomodoro.StateDone, planned, ended.State, ended.ActualDuration)
+			}
+			i, err = tc.repo.ByID(i.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if i.ActualDuration != planned {
+				t.Errorf("expected stored duration %s, got %s", planned, i.ActualDuration)
+			}
+		})
+	}
+}
+
+// secondsRepo stores durations in whole seconds
+type secondsRepo struct {
+	pomodoro.Repository
+}
+
+func (secondsRepo) DurationUnit() time.Duration { return time.Second }
+
+// secondsConfig is NewConfig for intervals lasting whole seconds, which
+// sqlite plans them in, run 1000 times faster
+func secondsConfig(repo pomodoro.Repository, p, s, l time.Duration) *pomodoro.IntervalConfig {
+	config := pomodoro.NewConfig(repo, p, s, l)
+	config.Clock = pomodoro.NewScaledClock(1000)
+	return config
+}
+
+func TestPlannedDurationUnit(t *testing.T) {
+	repo := secondsRepo{repository.NewInMemoryRepo()}
+
+	config := pomodoro.NewConfig(repo, 2500*time.Millisecond, 400*time.Millisecond, 90*time.Second)
+	if config.PomodoroDuration != 2500*time.Millisecond {
+		t.Errorf("expected pomodoro duration %s, got %s", 2500*time.Millisecond, config.PomodoroDuration)
+	}
+
+	i, _, err := pomodoro.PeekInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.PlannedDuration != 3*time.Second {
+		t.Errorf("expected planned duration %s, got %s", 3*time.Second, i.PlannedDuration)
+	}
+
+	config.Plan = []pomodoro.PlannedInterval{
+		{Category: pomodoro.CategoryPomodoro, Duration: 1400 * time.Millisecond},
+	}
+	i, _, err = pomodoro.PeekInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.PlannedDuration != time.Second {
+		t.Errorf("expected planned slot duration %s, got %s", time.Second, i.PlannedDuration)
+	}
+
+	done := pomodoro.Interval{
+		Category:        pomodoro.CategoryPomodoro,
+		State:           pomodoro.StateDone,
+		PlannedDuration: time.Second,
+		ActualDuration:  time.Second,
+	}
+	if _, err := repo.Create(done); err != nil {
+		t.Fatal(err)
+	}
+	i, err = pomodoro.SnoozeBreak(config, 1600*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.PlannedDuration != 2*time.Second {
+		t.Errorf("expected snooze duration %s, got %s", 2*time.Second, i.PlannedDuration)
+	}
+}
+
+// fixedClock reports a fixed time and ticks in real time
+type fixedClock struct {
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time                         { return c.now }
+func (c fixedClock) NewTicker(d time.Duration) *time.Ticker { return time.NewTicker(d) }
+func (c fixedClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func TestGoalSummary(t *testing.T) {
+	day := time.Date(2023, time.May, 10, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name      string
+		now       time.Duration
+		done      int
+		expLeft   time.Duration
+		reachable bool
+	}{
+		// 3 pomodoros left take 1h25m with the breaks in between
+		{"OnPace", 13 * time.Hour, 5, 4 * time.Hour, true},
+		{"JustReachable", 15*time.Hour + 35*time.Minute, 5, 85 * time.Minute, true},
+		{"OutOfReach", 15*time.Hour + 36*time.Minute, 5, 84 * time.Minute, false},
+		{"AfterWorkday", 18 * time.Hour, 5, 0, false},
+		{"GoalMet", 18 * time.Hour, 9, 0, true},
+		{"Tomorrow", -6 * time.Hour, 0, 8 * time.Hour, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, cleanup := getRepo(t)
+			defer cleanup()
+
+			config := pomodoro.NewConfig(repo, 0, 0, 0)
+			config.Clock = fixedClock{now: day.Add(tc.now)}
+			config.Location = time.UTC
+
+			if _, err := pomodoro.GoalSummary(config, day); !errors.Is(err, pomodoro.ErrNoGoal) {
+				t.Errorf("expected error %q, got %v", pomodoro.ErrNoGoal, err)
+			}
+			config.DailyGoal = 8
+
+			var last time.Time
+			for k := 0; k < tc.done; k++ {
+				start := day.Add(9*time.Hour + time.Duration(k)*30*time.Minute)
+				addDone(t, repo, pomodoro.CategoryPomodoro, start, 25*time.Minute)
+				last = start.Add(25 * time.Minute)
+			}
+			addDone(t, repo, pomodoro.CategoryShortBreak, day.Add(8*time.Hour), 5*time.Minute)
+
+			p, err := pomodoro.GoalSummary(config, day)
+			if err != nil {
+				t.Fatal(err)
+			}
+			exp := pomodoro.GoalProgress{
+				Completed:     tc.done,
+				Goal:          8,
+				Percent:       float64(tc.done) / 8 * 100,
+				LastCompleted: last,
+				Remaining:     tc.expLeft,
+				Reachable:     tc.reachable,
+			}
+			if p != exp {
+				t.Errorf("expected %+v, got %+v", exp, p)
+			}
+		})
+	}
+}
+
+func TestAdaptiveBreaks(t *testing.T) {
+	day := time.Date(2023, time.May, 10, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name     string
+		goal     int
+		adaptive bool
+		now      time.Duration
+		done     int
+		expBreak time.Duration
+	}{
+		// At 10:00, an eighth of the day is gone and one pomodoro is due
+		{"AheadOfPace", 8, true, 10 * time.Hour, 2, 5 * time.Minute},
+		// At 13:00, half the day is gone and four pomodoros are due
+		{"BehindPace", 8, true, 13 * time.Hour, 1, 2*time.Minute + 30*time.Second},
+		{"BeforeWorkday", 8, true, 7 * time.Hour, 1, 5 * time.Minute},
+		{"NoGoal", 0, true, 13 * time.Hour, 1, 5 * time.Minute},
+		{"Disabled", 8, false, 13 * time.Hour, 1, 5 * time.Minute},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, cleanup := getRepo(t)
+			defer cleanup()
+
+			config := pomodoro.NewConfig(repo, 0, 0, 0)
+			config.Clock = fixedClock{now: day.Add(tc.now)}
+			config.Location = time.UTC
+			config.DailyGoal = tc.goal
+			config.AdaptiveBreaks = tc.adaptive
+
+			for k := 0; k < tc.done; k++ {
+				addDone(t, repo, pomodoro.CategoryPomodoro,
+					day.Add(tc.now).Add(-time.Duration(tc.done-k)*30*time.Minute), 25*time.Minute)
+			}
+
+			i, err := pomodoro.GetInterval(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if i.Category != pomodoro.CategoryShortBreak {
+				t.Fatalf("expected category %q, got %q", pomodoro.CategoryShortBreak, i.Category)
+			}
+			if i.PlannedDuration != tc.expBreak {
+				t.Errorf("expected break of %s, got %s", tc.expBreak, i.PlannedDuration)
+			}
+			stored, err := repo.ByID(i.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.PlannedDuration != tc.expBreak {
+				t.Errorf("expected stored break of %s, got %s", tc.expBreak, stored.PlannedDuration)
+			}
+		})
+	}
+
+	t.Run("Floor", func(t *testing.T) {
+		repo, cleanup := getRepo(t)
+		defer cleanup()
+
+		config := pomodoro.NewConfig(repo, 0, 3*time.Minute, 0)
+		config.Clock = fixedClock{now: day.Add(16 * time.Hour)}
+		config.Location = time.UTC
+		config.DailyGoal = 8
+		config.AdaptiveBreaks = true
+		config.BreakReduction = 0.9
+		addDone(t, repo, pomodoro.CategoryPomodoro, day.Add(15*time.Hour), 25*time.Minute)
+
+		i, err := pomodoro.GetInterval(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i.PlannedDuration != pomodoro.DefaultMinShortBreak {
+			t.Errorf("expected break of %s, got %s", pomodoro.DefaultMinShortBreak, i.PlannedDuration)
+		}
+	})
+}
+
+func TestProjectedEnd(t *testing.T) {
+	start := time.Date(2023, time.May, 10, 14, 0, 0, 0, time.UTC)
+	at := func(m int) time.Time {
+		return start.Add(time.Duration(m) * time.Minute)
+	}
+	base := pomodoro.Interval{StartTime: start, PlannedDuration: 25 * time.Minute}
+
+	// Paused from 14:05 to 14:10 and again from 14:15 to 14:20
+	testCases := []struct {
+		name   string
+		state  int
+		paused time.Duration
+		at     time.Time
+		now    time.Time
+		exp    time.Time
+	}{
+		{"NotStarted", pomodoro.StateNotStarted, 0, time.Time{}, at(3), at(28)},
+		{"Running", pomodoro.StateRunning, 0, time.Time{}, at(3), at(25)},
+		{"FirstPause", pomodoro.StatePaused, 0, at(5), at(8), at(28)},
+		{"AfterFirstPause", pomodoro.StateRunning, 5 * time.Minute, time.Time{}, at(12), at(30)},
+		{"SecondPause", pomodoro.StatePaused, 5 * time.Minute, at(15), at(20), at(35)},
+		{"AfterSecondPause", pomodoro.StateRunning, 10 * time.Minute, time.Time{}, at(21), at(35)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			i := base
+			i.State = tc.state
+			i.PausedDuration = tc.paused
+			i.PausedAt = tc.at
+			if end := i.ProjectedEnd(tc.now); !end.Equal(tc.exp) {
+				t.Errorf("expected end %s, got %s", tc.exp.Format("15:04"), end.Format("15:04"))
+			}
+		})
+	}
+
+	t.Run("PausedTwice", func(t *testing.T) {
+		repo, cleanup := getRepo(t)
+		defer cleanup()
+
+		config := pomodoro.NewConfig(repo, 6*time.Second, time.Second, time.Second)
+		config.Clock = pomodoro.NewScaledClock(20)
+		noop := func(pomodoro.Interval) {}
+		pauseAt := func(d time.Duration) pomodoro.Callback {
+			return func(i pomodoro.Interval) {
+				if i.ActualDuration == d {
+					if err := i.Pause(config); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+		}
+
+		i, err := pomodoro.GetInterval(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, periodic := range []pomodoro.Callback{pauseAt(2 * time.Second), pauseAt(4 * time.Second)} {
+			if err := i.Start(context.Background(), config, noop, periodic, noop); err != nil {
+				t.Fatal(err)
+			}
+			// Three seconds in scaled time
+			time.Sleep(150 * time.Millisecond)
+
+			s, err := pomodoro.GetStatus(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.Interval.State != pomodoro.StatePaused {
+				t.Fatalf("expected paused interval, got state %d", s.Interval.State)
+			}
+			exp := config.Now().Add(s.Interval.PlannedDuration - s.Interval.ActualDuration)
+			if d := s.EndsAt.Sub(exp); d < -time.Second || d > time.Second {
+				t.Errorf("expected end around %s, got %s", exp, s.EndsAt)
+			}
+		}
+		if err := i.Start(context.Background(), config, noop, noop, noop); err != nil {
+			t.Fatal(err)
+		}
+		end := config.Now()
+
+		i, err = repo.ByID(i.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Each pause lasts the sleep plus up to a tick until Start returns
+		if i.PausedDuration < 6*time.Second || i.PausedDuration > 10*time.Second {
+			t.Errorf("expected 6s to 10s paused, got %s", i.PausedDuration)
+		}
+		if d := end.Sub(i.ProjectedEnd(end)); d < 0 || d > time.Second {
+			t.Errorf("expected to end at %s, ended at %s", i.ProjectedEnd(end), end)
+		}
+	})
+}
+
+func TestDeleteInterval(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	config := pomodoro.NewConfig(repo, 0, 0, 0)
+	now := time.Now()
+	addDone(t, repo, pomodoro.CategoryPomodoro, now, 4*time.Hour)
+	addDone(t, repo, pomodoro.CategoryShortBreak, now, 5*time.Minute)
+	addDone(t, repo, pomodoro.CategoryPomodoro, now, 25*time.Minute)
+
+	if err := pomodoro.DeleteInterval(config, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := pomodoro.DeleteInterval(config, 1); !errors.Is(err, pomodoro.ErrInvalidID) {
+		t.Errorf("expected error %q, got %v", pomodoro.ErrInvalidID, err)
+	}
+
+	// Other intervals keep their IDs
+	for _, id := range []int64{2, 3} {
+		i, err := repo.ByID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i.ID != id {
+			t.Errorf("expected interval %d, got %d", id, i.ID)
+		}
+	}
+	ds, err := pomodoro.DailySummary(now, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds[0] != 25*time.Minute {
+		t.Errorf("expected %s of work, got %s", 25*time.Minute, ds[0])
+	}
+
+	// New intervals don't reuse deleted IDs
+	i, err := pomodoro.GetInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.ID != 4 {
+		t.Errorf("expected new interval 4, got %d", i.ID)
+	}
+
+	// A running interval can't be deleted
+	i.State = pomodoro.StateRunning
+	if err := repo.Update(i); err != nil {
+		t.Fatal(err)
+	}
+	if err := pomodoro.DeleteInterval(config, i.ID); !errors.Is(err, pomodoro.ErrIntervalAlreadyRunning) {
+		t.Errorf("expected error %q, got %v", pomodoro.ErrIntervalAlreadyRunning, err)
+	}
+	if _, err := repo.ByID(i.ID); err != nil {
+		t.Errorf("expected running interval to be kept, got %v", err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	now := time.Now()
+	days := func(n int) time.Time {
+		return now.AddDate(0, 0, -n)
+	}
+
+	testCases := []struct {
+		name      string
+		lastState int
+		expPruned int64
+		expLeft   int
+	}{
+		{"LastDone", pomodoro.StateDone, 3, 1},
+		{"LastPaused", pomodoro.StatePaused, 2, 2},
+		{"LastRunning", pomodoro.StateRunning, 2, 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, cleanup := getRepo(t)
+			defer cleanup()
+
+			config := pomodoro.NewConfig(repo, 0, 0, 0)
+			addDone(t, repo, pomodoro.CategoryPomodoro, days(200), 25*time.Minute)
+			addDone(t, repo, pomodoro.CategoryPomodoro, days(10), 25*time.Minute)
+			addDone(t, repo, pomodoro.CategoryShortBreak, days(100), 5*time.Minute)
+			_, err := repo.Create(pomodoro.Interval{
+				StartTime:       days(95),
+				PlannedDuration: 25 * time.Minute,
+				Category:        pomodoro.CategoryPomodoro,
+				State:           tc.lastState,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			n, err := pomodoro.Prune(config, days(90))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != tc.expPruned {
+				t.Errorf("expected %d pruned, got %d", tc.expPruned, n)
+			}
+
+			left, err := pomodoro.FindIntervals(config, pomodoro.Filter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(left) != tc.expLeft {
+				t.Fatalf("expected %d intervals left, got %d", tc.expLeft, len(left))
+			}
+			for _, i := range left {
+				if i.StartTime.Before(days(90)) && i.State != tc.lastState {
+					t.Errorf("expected interval %d to be pruned", i.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestByRange(t *testing.T) {
+	repo, cleanup := getRepo(t)
+	defer cleanup()
+
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := func(d, h, m int) time.Time {
+		return time.Date(2023, time.May, d, h, m, 0, 0, loc)
+	}
+
+	// Created out of order, one right at midnight and one never started
+	starts := []time.Time{
+		day(11, 9, 0), day(10, 23, 59), day(10, 9, 0), day(11, 0, 0),
+		day(12, 18, 0), day(12, 8, 0), {},
+	}
+	for _, s := range starts {
+		state := pomodoro.StateDone
+		if s.IsZero() {
+			state = pomodoro.StateNotStarted
+		}
+		_, err := repo.Create(pomodoro.Interval{
+			StartTime: s, Category: pomodoro.CategoryPomodoro, State: state,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		name string
+		day  int
+		exp  []time.Time
+	}{
+		{"May10", 10, []time.Time{day(10, 9, 0), day(10, 23, 59)}},
+		{"May11", 11, []time.Time{day(11, 0, 0), day(11, 9, 0)}},
+		{"May12", 12, []time.Time{day(12, 8, 0), day(12, 18, 0)}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := repo.ByRange(day(tc.day, 0, 0), day(tc.day+1, 0, 0))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tc.exp) {
+				t.Fatalf("expected %d intervals, got %d", len(tc.exp), len(got))
+			}
+			for k := range got {
+				if !got[k].StartTime.Equal(tc.exp[k]) {
+					t.Errorf("expected start %s at %d, got %s", tc.exp[k], k, got[k].StartTime)
+				}
+			}
+		})
+	}
+
+	all, err := repo.ByRange(time.Time{}, day(13, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 6 {
+		t.Errorf("expected 6 started intervals, got %d", len(all))
+	}
+}
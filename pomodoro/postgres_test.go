@@ -0,0 +1,48 @@
+//go:build postgres && !inmemory
+
+package pomodoro_test
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/snirkop89/pomo/pomodoro"
+	"github.com/snirkop89/pomo/pomodoro/repository"
+)
+
+// getRepo connects to the database in POMO_TEST_POSTGRES_DSN, which is
+// emptied before and after each test
+func getRepo(t *testing.T) (pomodoro.Repository, func()) {
+	t.Helper()
+
+	dsn := os.Getenv("POMO_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("POMO_TEST_POSTGRES_DSN not set")
+	}
+
+	pgRepo, err := repository.NewPostgresRepo(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncate := func() {
+		if _, err := db.Exec(`TRUNCATE "interval", task, tags RESTART IDENTITY CASCADE`); err != nil {
+			t.Fatal(err)
+		}
+	}
+	truncate()
+
+	return pgRepo, func() {
+		truncate()
+		db.Close()
+		if err := pgRepo.Close(); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// writeBackup has write produce a backup at a temporary path next to dest,
+// which is then renamed over dest so it's never left half written. Unless
+// force is set, an existing dest is an error. It returns the backup size
+func writeBackup(dest string, force bool, write func(path string) error) (int64, error) {
+	if !force {
+		_, err := os.Stat(dest)
+		if err == nil {
+			return 0, fmt.Errorf("backing up to %s: %w", dest, fs.ErrExist)
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return 0, err
+		}
+	}
+
+	tf, err := os.CreateTemp(filepath.Dir(dest), filepath.Base(dest)+".*")
+	if err != nil {
+		return 0, err
+	}
+	if err := tf.Close(); err != nil {
+		return 0, err
+	}
+	defer os.Remove(tf.Name())
+
+	if err := write(tf.Name()); err != nil {
+		return 0, fmt.Errorf("backing up to %s: %w", dest, err)
+	}
+	if err := os.Rename(tf.Name(), dest); err != nil {
+		return 0, err
+	}
+
+	fi, err := os.Stat(dest)
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
@@ -0,0 +1,873 @@
+//go:build bolt
+
+package repository
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	intervalBucket = []byte("interval")
+	taskBucket     = []byte("task")
+	// tagBucket holds the sorted tags of each interval as JSON, keyed
+	// like the interval
+	tagBucket = []byte("interval_tags")
+	// eventBucket holds the state changes of each interval as a JSON
+	// list, keyed like the interval
+	eventBucket = []byte("interval_events")
+	// archiveBucket holds the intervals moved by Archive, keyed like the
+	// interval bucket
+	archiveBucket = []byte("interval_archive")
+)
+
+// itob encodes an ID as a big endian key, so keys sort by ID
+func itob(id int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(id))
+	return b
+}
+
+func init() {
+	Register("bolt", func(path string) (pomodoro.Repository, error) {
+		r, err := NewBoltRepo(path)
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	})
+}
+
+type boltRepo struct {
+	db *bolt.DB
+	// tx is set on the repository given to RunInTransaction functions,
+	// which runs every operation in it
+	tx *bolt.Tx
+	// now stamps the state events
+	now func() time.Time
+}
+
+// NewBoltRepo opens the bbolt database at path, creating it if needed.
+// Intervals are stored as JSON keyed by ID
+func NewBoltRepo(path string) (*boltRepo, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{intervalBucket, taskBucket, tagBucket, eventBucket, archiveBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &boltRepo{
+		db:  db,
+		now: time.Now,
+	}, nil
+}
+
+// SetNow sets the time the state events are stamped with
+func (r *boltRepo) SetNow(now func() time.Time) {
+	r.now = now
+}
+
+// Close releases the database file, which bbolt keeps locked while open
+func (r *boltRepo) Close() error {
+	return r.db.Close()
+}
+
+// Backup copies the database to dest from a read-only transaction, which
+// sees a consistent snapshot while writes go on
+func (r *boltRepo) Backup(dest string, force bool) (int64, error) {
+	return writeBackup(dest, force, func(path string) error {
+		return r.readTx(func(tx *bolt.Tx) error {
+			return tx.CopyFile(path, 0o600)
+		})
+	})
+}
+
+// readTx runs fn in a read-only transaction, or in the one the repository
+// is bound to
+func (r *boltRepo) readTx(fn func(tx *bolt.Tx) error) error {
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+	return r.db.View(fn)
+}
+
+// writeTx runs fn in a read-write transaction committed if fn succeeds,
+// or in the one the repository is bound to, leaving the commit to its
+// owner
+func (r *boltRepo) writeTx(fn func(tx *bolt.Tx) error) error {
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+	return r.db.Update(fn)
+}
+
+// RunInTransaction calls fn with a repository running every operation in
+// one read-write transaction, rolled back if fn fails
+func (r *boltRepo) RunInTransaction(fn func(pomodoro.Repository) error) error {
+	return r.writeTx(func(tx *bolt.Tx) error {
+		return fn(&boltRepo{db: r.db, tx: tx, now: r.now})
+	})
+}
+
+// boltVersion holds the version stored next to the JSON representation of
+// an interval, which leaves it out
+type boltVersion struct {
+	Version int64 `json:"version"`
+}
+
+func encodeInterval(i pomodoro.Interval) ([]byte, error) {
+	data, err := json.Marshal(i)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if fields["version"], err = json.Marshal(i.Version); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+func decodeInterval(data []byte) (pomodoro.Interval, error) {
+	var (
+		i pomodoro.Interval
+		v boltVersion
+	)
+	if err := json.Unmarshal(data, &i); err != nil {
+		return i, err
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return i, err
+	}
+	i.Version = v.Version
+	return i, nil
+}
+
+func getInterval(b *bolt.Bucket, id int64) (pomodoro.Interval, error) {
+	v := b.Get(itob(id))
+	if v == nil {
+		return pomodoro.Interval{}, fmt.Errorf("%w: %d", pomodoro.ErrInvalidID, id)
+	}
+	return decodeInterval(v)
+}
+
+func putInterval(b *bolt.Bucket, i pomodoro.Interval) error {
+	v, err := encodeInterval(i)
+	if err != nil {
+		return err
+	}
+	return b.Put(itob(i.ID), v)
+}
+
+// each calls fn with the intervals in ID order, or reverse ID order when
+// desc is set, until fn returns false or an error
+func each(b *bolt.Bucket, desc bool, fn func(i pomodoro.Interval) (bool, error)) error {
+	c := b.Cursor()
+	first, next := c.First, c.Next
+	if desc {
+		first, next = c.Last, c.Prev
+	}
+
+	for k, v := first(); k != nil; k, v = next() {
+		i, err := decodeInterval(v)
+		if err != nil {
+			return err
+		}
+		more, err := fn(i)
+		if err != nil || !more {
+			return err
+		}
+	}
+	return nil
+}
+
+// view calls fn with the intervals in ID order
+func (r *boltRepo) view(desc bool, fn func(i pomodoro.Interval) (bool, error)) error {
+	return r.readTx(func(tx *bolt.Tx) error {
+		return each(tx.Bucket(intervalBucket), desc, fn)
+	})
+}
+
+func (r *boltRepo) Create(i pomodoro.Interval) (int64, error) {
+	err := r.writeTx(func(tx *bolt.Tx) error {
+		b := tx.Bucket(intervalBucket)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		i.ID = int64(seq)
+		i.Version = 0
+		return putInterval(b, i)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return i.ID, nil
+}
+
+// CreateBatch stores intervals in a single transaction
+func (r *boltRepo) CreateBatch(is []pomodoro.Interval) ([]int64, error) {
+	ids := make([]int64, 0, len(is))
+	err := r.writeTx(func(tx *bolt.Tx) error {
+		b := tx.Bucket(intervalBucket)
+		for _, i := range is {
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			i.ID = int64(seq)
+			i.Version = 0
+			if err := putInterval(b, i); err != nil {
+				return err
+			}
+			ids = append(ids, i.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *boltRepo) Update(i pomodoro.Interval) error {
+	return r.writeTx(func(tx *bolt.Tx) error {
+		b := tx.Bucket(intervalBucket)
+		stored, err := getInterval(b, i.ID)
+		if err != nil {
+			return err
+		}
+		// Interruptions and notes have their own methods
+		i.Interruptions = stored.Interruptions
+		i.Note = stored.Note
+		return replaceInterval(tx, stored, i, r.now())
+	})
+}
+
+// UpdateProgress stores the elapsed time and state of an interval
+func (r *boltRepo) UpdateProgress(id, version int64, actual time.Duration, state int) error {
+	return r.writeTx(func(tx *bolt.Tx) error {
+		stored, err := getInterval(tx.Bucket(intervalBucket), id)
+		if err != nil {
+			return err
+		}
+		i := stored
+		i.ActualDuration = actual
+		i.State = state
+		i.Version = version
+		return replaceInterval(tx, stored, i, r.now())
+	})
+}
+
+// replaceInterval stores i over stored, recording the change of state at
+// now if there's one. It fails with ErrConflict unless i has the stored
+// version
+func replaceInterval(tx *bolt.Tx, stored, i pomodoro.Interval, now time.Time) error {
+	if i.Version != stored.Version {
+		return fmt.Errorf("%w: interval %d", pomodoro.ErrConflict, i.ID)
+	}
+	i.Version++
+	if i.State != stored.State {
+		err := addEvent(tx.Bucket(eventBucket), pomodoro.StateEvent{
+			IntervalID: i.ID,
+			From:       stored.State,
+			To:         i.State,
+			Time:       now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return putInterval(tx.Bucket(intervalBucket), i)
+}
+
+func (r *boltRepo) ByID(id int64) (pomodoro.Interval, error) {
+	if id <= 0 {
+		return pomodoro.Interval{}, fmt.Errorf("%w: %d", pomodoro.ErrInvalidID, id)
+	}
+
+	var i pomodoro.Interval
+	err := r.readTx(func(tx *bolt.Tx) error {
+		var err error
+		i, err = getInterval(tx.Bucket(intervalBucket), id)
+		return err
+	})
+	return i, err
+}
+
+func (r *boltRepo) Last() (pomodoro.Interval, error) {
+	found, err := r.Find(pomodoro.Filter{Order: pomodoro.OrderDesc, Limit: 1})
+	if err != nil {
+		return pomodoro.Interval{}, err
+	}
+	if len(found) == 0 {
+		return pomodoro.Interval{}, pomodoro.ErrNoIntervals
+	}
+	return found[0], nil
+}
+
+func (r *boltRepo) Breaks(n int) ([]pomodoro.Interval, error) {
+	return r.Find(pomodoro.Filter{
+		Categories: []string{pomodoro.CategoryShortBreak, pomodoro.CategoryLongBreak},
+		Order:      pomodoro.OrderDesc,
+		Limit:      n,
+	})
+}
+
+func (r *boltRepo) Find(f pomodoro.Filter) ([]pomodoro.Interval, error) {
+	if f.IncludeArchived || f.Order == pomodoro.OrderLatestFirst {
+		return r.findAll(f)
+	}
+
+	end := f.Limit
+	if f.Offset > 0 {
+		end += f.Offset
+	}
+	var data []pomodoro.Interval
+	err := r.view(f.Order == pomodoro.OrderDesc, func(i pomodoro.Interval) (bool, error) {
+		if f.Matches(i) {
+			data = append(data, i)
+		}
+		return f.Limit <= 0 || len(data) < end, nil
+	})
+	return f.Page(data), err
+}
+
+// findAll applies f to the intervals of both buckets, which are read whole
+// to merge them in ID order and sort them
+func (r *boltRepo) findAll(f pomodoro.Filter) ([]pomodoro.Interval, error) {
+	var live, archived []pomodoro.Interval
+	err := r.readTx(func(tx *bolt.Tx) error {
+		err := each(tx.Bucket(intervalBucket), false, func(i pomodoro.Interval) (bool, error) {
+			live = append(live, i)
+			return true, nil
+		})
+		if err != nil {
+			return err
+		}
+		return each(tx.Bucket(archiveBucket), false, func(i pomodoro.Interval) (bool, error) {
+			archived = append(archived, i)
+			return true, nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return f.Select(pomodoro.WithArchived(f, live, archived)), nil
+}
+
+func (r *boltRepo) CategorySummary(day time.Time, filter string, cancelledRatio float64) (time.Duration, error) {
+	var d time.Duration
+	filter = strings.Trim(filter, "%")
+
+	err := r.view(false, func(i pomodoro.Interval) (bool, error) {
+		if onDay(i.StartTime, day) && i.Completed(cancelledRatio) &&
+			strings.Contains(i.Category, filter) {
+			d += i.ActualDuration
+		}
+		return true, nil
+	})
+	return d, err
+}
+
+func (r *boltRepo) DailyTotals(start, end time.Time, filter string, cancelledRatio float64, tag string) (map[string]time.Duration, error) {
+	totals := make(map[string]time.Duration)
+	filter = strings.Trim(filter, "%")
+
+	err := r.readTx(func(tx *bolt.Tx) error {
+		b := tx.Bucket(tagBucket)
+		return each(tx.Bucket(intervalBucket), false, func(i pomodoro.Interval) (bool, error) {
+			if i.StartTime.Before(start) || !i.StartTime.Before(end) ||
+				!i.Completed(cancelledRatio) || !strings.Contains(i.Category, filter) {
+				return true, nil
+			}
+			if tag != "" {
+				tags, err := getTags(b, i.ID)
+				if err != nil || !pomodoro.HasTag(tags, tag) {
+					return err == nil, err
+				}
+			}
+			totals[i.StartTime.In(start.Location()).Format(pomodoro.DayLayout)] += i.ActualDuration
+			return true, nil
+		})
+	})
+	return totals, err
+}
+
+func (r *boltRepo) CompletedPerDay(start, end time.Time) (map[string]int, error) {
+	counts := make(map[string]int)
+	err := r.view(false, func(i pomodoro.Interval) (bool, error) {
+		if i.StartTime.Before(start) || !i.StartTime.Before(end) {
+			return true, nil
+		}
+		if i.Category == pomodoro.CategoryPomodoro && i.State == pomodoro.StateDone {
+			counts[i.StartTime.In(start.Location()).Format(pomodoro.DayLayout)]++
+		}
+		return true, nil
+	})
+	return counts, err
+}
+
+func (r *boltRepo) StateCounts(start, end time.Time, category string) (map[int]int, error) {
+	var intervals []pomodoro.Interval
+	err := r.view(false, func(i pomodoro.Interval) (bool, error) {
+		if i.Category == category {
+			intervals = append(intervals, i)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pomodoro.CountStates(intervals, start, end, category), nil
+}
+
+func getTags(b *bolt.Bucket, id int64) ([]string, error) {
+	var tags []string
+	if v := b.Get(itob(id)); v != nil {
+		if err := json.Unmarshal(v, &tags); err != nil {
+			return nil, err
+		}
+	}
+	return tags, nil
+}
+
+func (r *boltRepo) AddTag(intervalID int64, tag string) error {
+	t, err := pomodoro.NormalizeTag(tag)
+	if err != nil {
+		return err
+	}
+
+	return r.writeTx(func(tx *bolt.Tx) error {
+		if _, err := getInterval(tx.Bucket(intervalBucket), intervalID); err != nil {
+			return err
+		}
+
+		b := tx.Bucket(tagBucket)
+		tags, err := getTags(b, intervalID)
+		if err != nil {
+			return err
+		}
+		k := sort.SearchStrings(tags, t)
+		if k < len(tags) && tags[k] == t {
+			return nil
+		}
+		tags = append(tags, "")
+		copy(tags[k+1:], tags[k:])
+		tags[k] = t
+
+		v, err := json.Marshal(tags)
+		if err != nil {
+			return err
+		}
+		return b.Put(itob(intervalID), v)
+	})
+}
+
+func (r *boltRepo) TagsFor(intervalID int64) ([]string, error) {
+	var tags []string
+	err := r.readTx(func(tx *bolt.Tx) error {
+		if _, err := getInterval(tx.Bucket(intervalBucket), intervalID); err != nil {
+			if tx.Bucket(archiveBucket).Get(itob(intervalID)) == nil {
+				return err
+			}
+		}
+		var err error
+		tags, err = getTags(tx.Bucket(tagBucket), intervalID)
+		return err
+	})
+	return tags, err
+}
+
+func (r *boltRepo) TagSummary(start, end time.Time) (map[string]time.Duration, error) {
+	totals := make(map[string]time.Duration)
+	err := r.readTx(func(tx *bolt.Tx) error {
+		b := tx.Bucket(tagBucket)
+		return each(tx.Bucket(intervalBucket), false, func(i pomodoro.Interval) (bool, error) {
+			if i.State != pomodoro.StateDone || i.StartTime.Before(start) || !i.StartTime.Before(end) {
+				return true, nil
+			}
+			tags, err := getTags(b, i.ID)
+			for _, t := range tags {
+				totals[t] += i.ActualDuration
+			}
+			return true, err
+		})
+	})
+	return totals, err
+}
+
+func (r *boltRepo) LabelSummary(start, end time.Time) ([]pomodoro.LabelTotal, error) {
+	var pomodoros []pomodoro.Interval
+	err := r.view(false, func(i pomodoro.Interval) (bool, error) {
+		if i.Category == pomodoro.CategoryPomodoro && i.State == pomodoro.StateDone {
+			pomodoros = append(pomodoros, i)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pomodoro.SumByLabel(pomodoros, start, end), nil
+}
+
+func (r *boltRepo) DeviceSummary(start, end time.Time) (map[string]time.Duration, error) {
+	var pomodoros []pomodoro.Interval
+	err := r.view(false, func(i pomodoro.Interval) (bool, error) {
+		if i.Category == pomodoro.CategoryPomodoro && i.State == pomodoro.StateDone {
+			pomodoros = append(pomodoros, i)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pomodoro.SumByDevice(pomodoros, start, end), nil
+}
+
+func getEvents(b *bolt.Bucket, id int64) ([]pomodoro.StateEvent, error) {
+	var events []pomodoro.StateEvent
+	if v := b.Get(itob(id)); v != nil {
+		if err := json.Unmarshal(v, &events); err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+func addEvent(b *bolt.Bucket, e pomodoro.StateEvent) error {
+	events, err := getEvents(b, e.IntervalID)
+	if err != nil {
+		return err
+	}
+	v, err := json.Marshal(append(events, e))
+	if err != nil {
+		return err
+	}
+	return b.Put(itob(e.IntervalID), v)
+}
+
+func (r *boltRepo) EventsFor(id int64) ([]pomodoro.StateEvent, error) {
+	var events []pomodoro.StateEvent
+	err := r.readTx(func(tx *bolt.Tx) error {
+		if _, err := getInterval(tx.Bucket(intervalBucket), id); err != nil {
+			return err
+		}
+		var err error
+		events, err = getEvents(tx.Bucket(eventBucket), id)
+		return err
+	})
+	return events, err
+}
+
+func (r *boltRepo) TotalCompleted() (int64, error) {
+	var n int64
+	err := r.view(false, func(i pomodoro.Interval) (bool, error) {
+		if i.Category == pomodoro.CategoryPomodoro && i.State == pomodoro.StateDone {
+			n++
+		}
+		return true, nil
+	})
+	return n, err
+}
+
+// change applies fn to the stored interval id
+func (r *boltRepo) change(id int64, fn func(i *pomodoro.Interval)) error {
+	return r.writeTx(func(tx *bolt.Tx) error {
+		b := tx.Bucket(intervalBucket)
+		i, err := getInterval(b, id)
+		if err != nil {
+			return err
+		}
+		fn(&i)
+		return putInterval(b, i)
+	})
+}
+
+func (r *boltRepo) AddInterruption(id int64) error {
+	return r.change(id, func(i *pomodoro.Interval) {
+		i.Interruptions++
+	})
+}
+
+func (r *boltRepo) SetNote(id int64, note string) error {
+	return r.change(id, func(i *pomodoro.Interval) {
+		i.Note = note
+	})
+}
+
+func (r *boltRepo) WorkSinceLongBreak(day time.Time) (time.Duration, error) {
+	var d time.Duration
+	err := r.view(true, func(i pomodoro.Interval) (bool, error) {
+		if i.Category == pomodoro.CategoryLongBreak {
+			return false, nil
+		}
+		if (i.Category == pomodoro.CategoryPomodoro || i.Category == pomodoro.CategorySnooze) &&
+			onDay(i.StartTime, day) {
+			d += i.ActualDuration
+		}
+		return true, nil
+	})
+	return d, err
+}
+
+func (r *boltRepo) CreateTask(t pomodoro.Task) (int64, error) {
+	err := r.writeTx(func(tx *bolt.Tx) error {
+		b := tx.Bucket(taskBucket)
+		err := b.ForEach(func(k, v []byte) error {
+			var existing pomodoro.Task
+			if err := json.Unmarshal(v, &existing); err != nil {
+				return err
+			}
+			if existing.Name == t.Name {
+				return fmt.Errorf("%w: %q", pomodoro.ErrTaskExists, t.Name)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		t.ID = int64(seq)
+		v, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+		return b.Put(itob(t.ID), v)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return t.ID, nil
+}
+
+func (r *boltRepo) TaskByName(name string) (pomodoro.Task, error) {
+	tasks, err := r.Tasks()
+	if err != nil {
+		return pomodoro.Task{}, err
+	}
+	for _, t := range tasks {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+	return pomodoro.Task{}, fmt.Errorf("%w: %q", pomodoro.ErrTaskNotFound, name)
+}
+
+func (r *boltRepo) Tasks() ([]pomodoro.Task, error) {
+	var data []pomodoro.Task
+	err := r.readTx(func(tx *bolt.Tx) error {
+		return tx.Bucket(taskBucket).ForEach(func(k, v []byte) error {
+			var t pomodoro.Task
+			if err := json.Unmarshal(v, &t); err != nil {
+				return err
+			}
+			data = append(data, t)
+			return nil
+		})
+	})
+	return data, err
+}
+
+func (r *boltRepo) TaskTotals(taskID int64) (int64, time.Duration, error) {
+	var (
+		n int64
+		d time.Duration
+	)
+	err := r.view(false, func(i pomodoro.Interval) (bool, error) {
+		if i.Category != pomodoro.CategoryPomodoro || i.TaskID != taskID {
+			return true, nil
+		}
+		if i.State == pomodoro.StateDone {
+			n++
+		}
+		d += i.ActualDuration
+		return true, nil
+	})
+	return n, d, err
+}
+
+func (r *boltRepo) Delete(id int64) error {
+	return r.writeTx(func(tx *bolt.Tx) error {
+		b := tx.Bucket(intervalBucket)
+		i, err := getInterval(b, id)
+		if err != nil {
+			return err
+		}
+		if i.State == pomodoro.StateRunning {
+			return fmt.Errorf("%w: cannot delete interval %d", pomodoro.ErrIntervalAlreadyRunning, id)
+		}
+		if err := tx.Bucket(tagBucket).Delete(itob(id)); err != nil {
+			return err
+		}
+		if err := tx.Bucket(eventBucket).Delete(itob(id)); err != nil {
+			return err
+		}
+		return b.Delete(itob(id))
+	})
+}
+
+func (r *boltRepo) DeleteOlderThan(t time.Time) (int64, error) {
+	var n int64
+	err := r.writeTx(func(tx *bolt.Tx) error {
+		b := tx.Bucket(intervalBucket)
+		lastKey, _ := b.Cursor().Last()
+
+		// Keys are collected first, as deleting moves the cursor
+		var old [][]byte
+		err := each(b, false, func(i pomodoro.Interval) (bool, error) {
+			last := string(itob(i.ID)) == string(lastKey)
+			unfinished := i.State != pomodoro.StateDone && i.State != pomodoro.StateCancelled &&
+				i.State != pomodoro.StateSkipped
+			if i.StartTime.Before(t) && !(last && unfinished) {
+				old = append(old, itob(i.ID))
+			}
+			return true, nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range old {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+			if err := tx.Bucket(tagBucket).Delete(k); err != nil {
+				return err
+			}
+			if err := tx.Bucket(eventBucket).Delete(k); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Archive moves intervals to the archive bucket, keeping their tags and
+// events. IDs come from the sequence of the interval bucket, so they're
+// never reused
+func (r *boltRepo) Archive(olderThan time.Time) (int64, error) {
+	var n int64
+	err := r.writeTx(func(tx *bolt.Tx) error {
+		b := tx.Bucket(intervalBucket)
+		lastKey, _ := b.Cursor().Last()
+
+		// Intervals are collected first, as deleting moves the cursor
+		var old []pomodoro.Interval
+		err := each(b, false, func(i pomodoro.Interval) (bool, error) {
+			if pomodoro.Archivable(i, olderThan, string(itob(i.ID)) == string(lastKey)) {
+				old = append(old, i)
+			}
+			return true, nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, i := range old {
+			if err := putInterval(tx.Bucket(archiveBucket), i); err != nil {
+				return err
+			}
+			if err := b.Delete(itob(i.ID)); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (r *boltRepo) ByRange(start, end time.Time) ([]pomodoro.Interval, error) {
+	var data []pomodoro.Interval
+	err := r.view(false, func(i pomodoro.Interval) (bool, error) {
+		if !i.StartTime.IsZero() && !i.StartTime.Before(start) && i.StartTime.Before(end) {
+			data = append(data, i)
+		}
+		return true, nil
+	})
+	sort.SliceStable(data, func(a, b int) bool {
+		return data[a].StartTime.Before(data[b].StartTime)
+	})
+	return data, err
+}
+
+// Import stores intervals in a single transaction, skipping duplicates
+func (r *boltRepo) Import(intervals []pomodoro.Interval) (int, error) {
+	n := 0
+	err := r.writeTx(func(tx *bolt.Tx) error {
+		b := tx.Bucket(intervalBucket)
+
+		type key struct {
+			start    int64
+			category string
+		}
+		seen := make(map[key]bool)
+		for _, bucket := range []*bolt.Bucket{b, tx.Bucket(archiveBucket)} {
+			err := each(bucket, false, func(i pomodoro.Interval) (bool, error) {
+				seen[key{i.StartTime.UnixNano(), i.Category}] = true
+				return true, nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, i := range intervals {
+			k := key{i.StartTime.UnixNano(), i.Category}
+			if i.StartTime.IsZero() || seen[k] {
+				continue
+			}
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			i.ID = int64(seq)
+			i.Version = 0
+			if err := putInterval(b, i); err != nil {
+				return fmt.Errorf("importing interval %d: %w", i.ID, err)
+			}
+			seen[k] = true
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro"
+)
+
+// dayBounds returns the half-open range [start, end) in UTC covering the
+// calendar day of t in t's own location. Callers pick the location used to
+// bucket intervals into days by passing t in it.
+func dayBounds(t time.Time) (time.Time, time.Time) {
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	end := start.AddDate(0, 0, 1)
+	return start.UTC(), end.UTC()
+}
+
+// onDay reports whether t falls on the calendar day of day
+func onDay(t, day time.Time) bool {
+	start, end := dayBounds(day)
+	return !t.Before(start) && t.Before(end)
+}
+
+// dayBuckets splits [start, end) at every midnight in the location of
+// start. Bucket k is [bounds[k], bounds[k+1]) and falls on days[k]
+func dayBuckets(start, end time.Time) (bounds []time.Time, days []string) {
+	for t := start; t.Before(end); {
+		bounds = append(bounds, t)
+		days = append(days, t.Format(pomodoro.DayLayout))
+		t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+	}
+	return append(bounds, end), days
+}
+
+// dayCase builds a CASE expression giving the index in days of the day
+// bucket of start_time, for the buckets of dayBuckets. Its arguments come
+// first in the statement
+func dayCase(start, end time.Time) (string, []any, []string) {
+	bounds, days := dayBuckets(start, end)
+
+	var (
+		cases strings.Builder
+		args  []any
+	)
+	cases.WriteString("CASE")
+	for _, b := range bounds[1:] {
+		cases.WriteString(" WHEN start_time < ? THEN ")
+		cases.WriteString(strconv.Itoa(len(args)))
+		args = append(args, b.UTC())
+	}
+	cases.WriteString(" END")
+	return cases.String(), args, days
+}
+
+// dailyTotalsQuery builds the statement summing intervals by day for
+// DailyTotals. Rows are the index of the day in days and the total. The
+// tag is matched in a subquery rather than joined, so intervals with many
+// tags are summed once
+func dailyTotalsQuery(start, end time.Time, filter string, cancelledRatio float64, tag string) (string, []any, []string) {
+	cases, args, days := dayCase(start, end)
+
+	stmt := `SELECT ` + cases + ` AS day, sum(actual_duration)
+		FROM "interval"
+		WHERE category LIKE ? AND
+		start_time IS NOT NULL AND start_time >= ? AND start_time < ? AND
+		(state=? OR
+		(CAST(? AS DOUBLE PRECISION) > 0 AND state=? AND
+		actual_duration >= planned_duration * CAST(? AS DOUBLE PRECISION)))`
+	args = append(args, filter, start.UTC(), end.UTC(), pomodoro.StateDone,
+		cancelledRatio, pomodoro.StateCancelled, cancelledRatio)
+	if tag != "" {
+		stmt += ` AND id IN (SELECT it.interval_id FROM interval_tags it
+			JOIN tags t ON t.id=it.tag_id WHERE t.name=?)`
+		args = append(args, tag)
+	}
+	return stmt + " GROUP BY day", args, days
+}
+
+// completedPerDayQuery builds the statement counting the pomodoros done by
+// day for CompletedPerDay. Rows are the index of the day in days and the
+// count
+func completedPerDayQuery(start, end time.Time) (string, []any, []string) {
+	cases, args, days := dayCase(start, end)
+
+	stmt := `SELECT ` + cases + ` AS day, count(*)
+		FROM "interval"
+		WHERE category=? AND state=? AND
+		start_time IS NOT NULL AND start_time >= ? AND start_time < ?
+		GROUP BY day`
+	args = append(args, pomodoro.CategoryPomodoro, pomodoro.StateDone, start.UTC(), end.UTC())
+	return stmt, args, days
+}
+
+// scanCounts reads the rows of a completedPerDayQuery
+func scanCounts(rows *sql.Rows, days []string) (map[string]int, error) {
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var day, n int
+		if err := rows.Scan(&day, &n); err != nil {
+			return nil, err
+		}
+		counts[days[day]] = n
+	}
+	return counts, rows.Err()
+}
+
+// scanDailyTotals reads the rows of a dailyTotalsQuery
+func scanDailyTotals(rows *sql.Rows, days []string, unit time.Duration) (map[string]time.Duration, error) {
+	defer rows.Close()
+
+	totals := make(map[string]time.Duration)
+	for rows.Next() {
+		var (
+			day int
+			d   int64
+		)
+		if err := rows.Scan(&day, &d); err != nil {
+			return nil, err
+		}
+		totals[days[day]] = time.Duration(d) * unit
+	}
+	return totals, rows.Err()
+}
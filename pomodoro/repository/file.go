@@ -0,0 +1,264 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro"
+)
+
+// fileData is the content of the file backing a fileRepo
+type fileData struct {
+	LastID    int64               `json:"last_id"`
+	Intervals []pomodoro.Interval `json:"intervals"`
+	Tasks     []fileTask          `json:"tasks"`
+	// Archived holds the intervals moved out of Intervals by Archive
+	Archived []pomodoro.Interval `json:"archived,omitempty"`
+	// Tags holds the tags of each interval by ID
+	Tags map[int64][]string `json:"tags,omitempty"`
+	// Events holds the state changes of all intervals
+	Events []pomodoro.StateEvent `json:"events,omitempty"`
+}
+
+type fileTask struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Estimate int    `json:"estimate"`
+}
+
+func init() {
+	Register("file", func(path string) (pomodoro.Repository, error) {
+		r, err := NewFileRepo(path)
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	})
+}
+
+// fileRepo keeps the intervals in memory like inMemoryRepo, writing them
+// to a JSON file after every change
+type fileRepo struct {
+	*inMemoryRepo
+	path string
+	// mu serializes changes with the writes saving them, so the file
+	// always holds the latest state
+	mu sync.Mutex
+}
+
+// NewFileRepo loads the intervals stored in the JSON file at path. A
+// missing or empty file starts an empty repository, while a file that
+// can't be parsed is an error
+func NewFileRepo(path string) (*fileRepo, error) {
+	r := &fileRepo{
+		inMemoryRepo: NewInMemoryRepo(),
+		path:         path,
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return r, nil
+	}
+
+	var fd fileData
+	if err := json.Unmarshal(data, &fd); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if fd.Intervals != nil {
+		r.intervals = fd.Intervals
+	}
+	r.archived = fd.Archived
+	r.lastID = fd.LastID
+	if fd.Tags != nil {
+		r.tags = fd.Tags
+	}
+	r.events = fd.Events
+	for _, t := range fd.Tasks {
+		r.tasks = append(r.tasks, pomodoro.Task{ID: t.ID, Name: t.Name, Estimate: t.Estimate})
+	}
+	return r, nil
+}
+
+// marshal encodes the repository in the format read by NewFileRepo
+func (r *inMemoryRepo) marshal() ([]byte, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	fd := fileData{
+		LastID:    r.lastID,
+		Intervals: r.intervals,
+		Archived:  r.archived,
+		Tags:      r.tags,
+		Events:    r.events,
+	}
+	for _, t := range r.tasks {
+		fd.Tasks = append(fd.Tasks, fileTask{ID: t.ID, Name: t.Name, Estimate: t.Estimate})
+	}
+	return json.MarshalIndent(fd, "", "  ")
+}
+
+// save writes the repository to a temporary file renamed over the
+// original, so a crash never leaves a partial file. Callers hold mu
+func (r *fileRepo) save() error {
+	data, err := r.marshal()
+	if err != nil {
+		return err
+	}
+
+	tf, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tf.Name())
+
+	if _, err := tf.Write(data); err != nil {
+		tf.Close()
+		return err
+	}
+	if err := tf.Sync(); err != nil {
+		tf.Close()
+		return err
+	}
+	if err := tf.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tf.Name(), r.path); err != nil {
+		return fmt.Errorf("saving %s: %w", r.path, err)
+	}
+	return nil
+}
+
+// Close does nothing, as every change is saved when it's made and the
+// file isn't kept open
+func (r *fileRepo) Close() error {
+	return nil
+}
+
+// change applies fn to the repository and saves it if fn succeeds
+func (r *fileRepo) change(fn func() error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := fn(); err != nil {
+		return err
+	}
+	return r.save()
+}
+
+func (r *fileRepo) Create(i pomodoro.Interval) (int64, error) {
+	var id int64
+	err := r.change(func() error {
+		var err error
+		id, err = r.inMemoryRepo.Create(i)
+		return err
+	})
+	return id, err
+}
+
+// CreateBatch stores intervals saving the file once
+func (r *fileRepo) CreateBatch(is []pomodoro.Interval) ([]int64, error) {
+	var ids []int64
+	err := r.change(func() error {
+		var err error
+		ids, err = r.inMemoryRepo.CreateBatch(is)
+		return err
+	})
+	return ids, err
+}
+
+func (r *fileRepo) Update(i pomodoro.Interval) error {
+	return r.change(func() error {
+		return r.inMemoryRepo.Update(i)
+	})
+}
+
+func (r *fileRepo) UpdateProgress(id, version int64, actual time.Duration, state int) error {
+	return r.change(func() error {
+		return r.inMemoryRepo.UpdateProgress(id, version, actual, state)
+	})
+}
+
+func (r *fileRepo) AddInterruption(id int64) error {
+	return r.change(func() error {
+		return r.inMemoryRepo.AddInterruption(id)
+	})
+}
+
+func (r *fileRepo) SetNote(id int64, note string) error {
+	return r.change(func() error {
+		return r.inMemoryRepo.SetNote(id, note)
+	})
+}
+
+func (r *fileRepo) CreateTask(t pomodoro.Task) (int64, error) {
+	var id int64
+	err := r.change(func() error {
+		var err error
+		id, err = r.inMemoryRepo.CreateTask(t)
+		return err
+	})
+	return id, err
+}
+
+func (r *fileRepo) AddTag(intervalID int64, tag string) error {
+	return r.change(func() error {
+		return r.inMemoryRepo.AddTag(intervalID, tag)
+	})
+}
+
+func (r *fileRepo) Delete(id int64) error {
+	return r.change(func() error {
+		return r.inMemoryRepo.Delete(id)
+	})
+}
+
+func (r *fileRepo) DeleteOlderThan(t time.Time) (int64, error) {
+	var n int64
+	err := r.change(func() error {
+		var err error
+		n, err = r.inMemoryRepo.DeleteOlderThan(t)
+		return err
+	})
+	return n, err
+}
+
+func (r *fileRepo) Archive(olderThan time.Time) (int64, error) {
+	var n int64
+	err := r.change(func() error {
+		var err error
+		n, err = r.inMemoryRepo.Archive(olderThan)
+		return err
+	})
+	return n, err
+}
+
+func (r *fileRepo) Import(intervals []pomodoro.Interval) (int, error) {
+	var n int
+	err := r.change(func() error {
+		var err error
+		n, err = r.inMemoryRepo.Import(intervals)
+		return err
+	})
+	return n, err
+}
+
+// RunInTransaction runs fn like the in-memory repository does, saving the
+// file once if fn succeeds
+func (r *fileRepo) RunInTransaction(fn func(pomodoro.Repository) error) error {
+	return r.change(func() error {
+		return r.inMemoryRepo.RunInTransaction(fn)
+	})
+}
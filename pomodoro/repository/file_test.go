@@ -0,0 +1,188 @@
+package repository_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro"
+	"github.com/snirkop89/pomo/pomodoro/repository"
+)
+
+func TestFileRepo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pomo.json")
+	start := time.Date(2023, time.May, 10, 9, 30, 0, 0, time.Local)
+
+	repo, err := repository.NewFileRepo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Last(); err != pomodoro.ErrNoIntervals {
+		t.Fatalf("expected error %q, got %v", pomodoro.ErrNoIntervals, err)
+	}
+
+	id, err := repo.Create(pomodoro.Interval{
+		StartTime: start, PlannedDuration: 25 * time.Minute,
+		Category: pomodoro.CategoryPomodoro, State: pomodoro.StateRunning,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := pomodoro.Interval{
+		ID: id, StartTime: start, PlannedDuration: 25 * time.Minute, ActualDuration: 25 * time.Minute,
+		Category: pomodoro.CategoryPomodoro, State: pomodoro.StateDone, Label: "write",
+	}
+	if err := repo.Update(exp); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SetNote(id, "draft"); err != nil {
+		t.Fatal(err)
+	}
+	exp.Note = "draft"
+	if _, err := repo.Create(pomodoro.Interval{
+		StartTime: start.Add(25 * time.Minute), PlannedDuration: 5 * time.Minute,
+		Category: pomodoro.CategoryShortBreak,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateTask(pomodoro.Task{Name: "report", Estimate: 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Drop the repository without closing anything, as a killed process
+	// would, and load the file again
+	repo, err = repository.NewFileRepo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := repo.ByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.StartTime.Equal(exp.StartTime) || got.ActualDuration != exp.ActualDuration ||
+		got.State != exp.State || got.Label != exp.Label || got.Note != exp.Note {
+		t.Errorf("expected interval %+v, got %+v", exp, got)
+	}
+	breaks, err := repo.Breaks(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(breaks) != 1 || breaks[0].Category != pomodoro.CategoryShortBreak {
+		t.Errorf("expected a short break, got %+v", breaks)
+	}
+	d, err := repo.CategorySummary(start, "%", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != 25*time.Minute {
+		t.Errorf("expected summary %s, got %s", 25*time.Minute, d)
+	}
+	if task, err := repo.TaskByName("report"); err != nil || task.Estimate != 4 {
+		t.Errorf("expected task report with estimate 4, got %+v, %v", task, err)
+	}
+
+	// IDs aren't reused after the last interval is deleted and reloaded
+	last, err := repo.Last()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete(last.ID); err != nil {
+		t.Fatal(err)
+	}
+	if repo, err = repository.NewFileRepo(path); err != nil {
+		t.Fatal(err)
+	}
+	next, err := repo.Create(pomodoro.Interval{StartTime: start, Category: pomodoro.CategoryPomodoro})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next <= last.ID {
+		t.Errorf("expected an ID after %d, got %d", last.ID, next)
+	}
+}
+
+func TestFileRepoLoad(t *testing.T) {
+	testCases := []struct {
+		name    string
+		missing bool
+		content string
+		expErr  bool
+	}{
+		{name: "Missing", missing: true},
+		{name: "Empty"},
+		{name: "Blank", content: " \n"},
+		{name: "Corrupt", content: `{"intervals": [`, expErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "pomo.json")
+			if !tc.missing {
+				if err := os.WriteFile(path, []byte(tc.content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			repo, err := repository.NewFileRepo(path)
+			if tc.expErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(data) != tc.content {
+					t.Errorf("expected corrupt file untouched, got %q", data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := repo.Create(pomodoro.Interval{Category: pomodoro.CategoryPomodoro}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(path); err != nil {
+				t.Errorf("expected file saved: %v", err)
+			}
+		})
+	}
+}
+
+func TestInMemoryBackup(t *testing.T) {
+	repo := repository.NewInMemoryRepo()
+	start := time.Date(2023, time.May, 10, 9, 30, 0, 0, time.UTC)
+	id, err := repo.Create(pomodoro.Interval{
+		StartTime: start, PlannedDuration: 25 * time.Minute, ActualDuration: 25 * time.Minute,
+		Category: pomodoro.CategoryPomodoro, State: pomodoro.StateDone,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dest := filepath.Join(t.TempDir(), "backup.json")
+	if _, err := repo.Backup(dest, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Backup(dest, false); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("expected error %q, got %v", fs.ErrExist, err)
+	}
+
+	// The backup is a file repository
+	backup, err := repository.NewFileRepo(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := backup.ByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.StartTime.Equal(start) || got.State != pomodoro.StateDone {
+		t.Errorf("expected the stored interval, got %+v", got)
+	}
+}
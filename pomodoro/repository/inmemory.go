@@ -1,9 +1,9 @@
-//go:build inmemory
-
 package repository
 
 import (
 	"fmt"
+	"os"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -11,75 +11,240 @@ import (
 	"github.com/snirkop89/pomo/pomodoro"
 )
 
+func init() {
+	// The path is ignored, as nothing is stored
+	Register("memory", func(string) (pomodoro.Repository, error) {
+		return NewInMemoryRepo(), nil
+	})
+	pomodoro.EphemeralRepository = func() pomodoro.Repository {
+		return NewInMemoryRepo()
+	}
+}
+
+// inMemoryRepo keeps intervals in a slice guarded by its mutex. Intervals
+// are stored and returned by value, never by pointer into the slice, so
+// callers can change what they get without racing with the repository
 type inMemoryRepo struct {
 	sync.RWMutex
 	intervals []pomodoro.Interval
+	// archived holds the intervals moved out of intervals by Archive
+	archived []pomodoro.Interval
+	tasks    []pomodoro.Task
+	// tags holds the sorted tags of each interval by ID
+	tags map[int64][]string
+	// events holds the state changes of all intervals in the order they
+	// happened
+	events []pomodoro.StateEvent
+	// lastID keeps IDs unique after deletes
+	lastID int64
+	// now stamps the state events
+	now func() time.Time
 }
 
 func NewInMemoryRepo() *inMemoryRepo {
 	return &inMemoryRepo{
 		intervals: []pomodoro.Interval{},
+		tags:      make(map[int64][]string),
+		now:       time.Now,
 	}
 }
 
+// SetNow sets the time the state events are stamped with
+func (r *inMemoryRepo) SetNow(now func() time.Time) {
+	r.Lock()
+	defer r.Unlock()
+	r.now = now
+}
+
+// Close does nothing, as there's nothing to release
+func (r *inMemoryRepo) Close() error {
+	return nil
+}
+
+// Backup writes the repository as JSON in the format of the file
+// repository, so the backup can be opened with NewFileRepo
+func (r *inMemoryRepo) Backup(dest string, force bool) (int64, error) {
+	data, err := r.marshal()
+	if err != nil {
+		return 0, err
+	}
+	return writeBackup(dest, force, func(path string) error {
+		return os.WriteFile(path, data, 0o600)
+	})
+}
+
+// snapshot returns a copy of the repository whose changes don't affect r.
+// Callers hold the lock
+func (r *inMemoryRepo) snapshot() *inMemoryRepo {
+	tags := make(map[int64][]string, len(r.tags))
+	for id, t := range r.tags {
+		tags[id] = append([]string(nil), t...)
+	}
+	return &inMemoryRepo{
+		intervals: append([]pomodoro.Interval{}, r.intervals...),
+		archived:  append([]pomodoro.Interval(nil), r.archived...),
+		tasks:     append([]pomodoro.Task(nil), r.tasks...),
+		tags:      tags,
+		events:    append([]pomodoro.StateEvent(nil), r.events...),
+		lastID:    r.lastID,
+		now:       r.now,
+	}
+}
+
+// RunInTransaction calls fn with a snapshot of the repository, whose
+// changes replace the repository's content if fn succeeds. Other callers
+// wait until it's done
+func (r *inMemoryRepo) RunInTransaction(fn func(pomodoro.Repository) error) error {
+	r.Lock()
+	defer r.Unlock()
+
+	tx := r.snapshot()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	r.intervals = tx.intervals
+	r.archived = tx.archived
+	r.tasks = tx.tasks
+	r.tags = tx.tags
+	r.events = tx.events
+	r.lastID = tx.lastID
+	return nil
+}
+
 func (r *inMemoryRepo) Create(i pomodoro.Interval) (int64, error) {
 	r.Lock()
 	defer r.Unlock()
 
-	i.ID = int64(len(r.intervals)) + 1
+	r.lastID++
+	i.ID = r.lastID
+	// New intervals start at the first version, as they do in databases
+	i.Version = 0
 	r.intervals = append(r.intervals, i)
 	return i.ID, nil
 }
 
+// CreateBatch appends intervals with a single acquisition of the lock
+func (r *inMemoryRepo) CreateBatch(is []pomodoro.Interval) ([]int64, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	ids := make([]int64, 0, len(is))
+	for _, i := range is {
+		r.lastID++
+		i.ID = r.lastID
+		i.Version = 0
+		r.intervals = append(r.intervals, i)
+		ids = append(ids, i.ID)
+	}
+	return ids, nil
+}
+
+// index returns the position of the interval with the given id. Callers
+// hold the lock
+func (r *inMemoryRepo) index(id int64) (int, error) {
+	for k, i := range r.intervals {
+		if i.ID == id {
+			return k, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %d", pomodoro.ErrInvalidID, id)
+}
+
 func (r *inMemoryRepo) Update(i pomodoro.Interval) error {
 	r.Lock()
 	defer r.Unlock()
 
-	if i.ID == 0 {
-		return fmt.Errorf("%w: %d", pomodoro.ErrInvalidID, i.ID)
+	k, err := r.index(i.ID)
+	if err != nil {
+		return err
+	}
+	// Interruptions and notes have their own methods
+	i.Interruptions = r.intervals[k].Interruptions
+	i.Note = r.intervals[k].Note
+	return r.replace(k, i)
+}
+
+func (r *inMemoryRepo) UpdateProgress(id, version int64, actual time.Duration, state int) error {
+	r.Lock()
+	defer r.Unlock()
+
+	k, err := r.index(id)
+	if err != nil {
+		return err
+	}
+	i := r.intervals[k]
+	i.ActualDuration = actual
+	i.State = state
+	i.Version = version
+	return r.replace(k, i)
+}
+
+// replace stores i at position k, recording the change of state if there's
+// one. It fails with ErrConflict unless i has the stored version. Callers
+// hold the lock
+func (r *inMemoryRepo) replace(k int, i pomodoro.Interval) error {
+	if i.Version != r.intervals[k].Version {
+		return fmt.Errorf("%w: interval %d", pomodoro.ErrConflict, i.ID)
 	}
-	r.intervals[i.ID-1] = i
+	i.Version++
+	if i.State != r.intervals[k].State {
+		r.events = append(r.events, pomodoro.StateEvent{
+			IntervalID: i.ID,
+			From:       r.intervals[k].State,
+			To:         i.State,
+			Time:       r.now(),
+		})
+	}
+	r.intervals[k] = i
 	return nil
 }
 
 func (r *inMemoryRepo) ByID(id int64) (pomodoro.Interval, error) {
 	r.RLock()
 	defer r.RUnlock()
-	var i pomodoro.Interval
-	if id == 0 {
-		return i, fmt.Errorf("%w: %d", pomodoro.ErrInvalidID, id)
+
+	k, err := r.index(id)
+	if err != nil {
+		return pomodoro.Interval{}, err
 	}
-	i = r.intervals[id-1]
-	return i, nil
+	return r.intervals[k], nil
 }
 
 func (r *inMemoryRepo) Last() (pomodoro.Interval, error) {
 	r.RLock()
 	defer r.RUnlock()
-	var i pomodoro.Interval
-	if len(r.intervals) == 0 {
-		return i, pomodoro.ErrNoIntervals
+
+	found := r.find(pomodoro.Filter{Order: pomodoro.OrderDesc, Limit: 1})
+	if len(found) == 0 {
+		return pomodoro.Interval{}, pomodoro.ErrNoIntervals
 	}
-	return r.intervals[len(r.intervals)-1], nil
+	return found[0], nil
 }
 
 func (r *inMemoryRepo) Breaks(n int) ([]pomodoro.Interval, error) {
 	r.RLock()
 	defer r.RUnlock()
-	var data []pomodoro.Interval
-	for k := len(r.intervals) - 1; k >= 0; k-- {
-		if r.intervals[k].Category == pomodoro.CategoryPomodoro {
-			continue
-		}
-		data = append(data, r.intervals[k])
-		if len(data) == n {
-			return data, nil
-		}
-	}
-	return data, nil
+
+	return r.find(pomodoro.Filter{
+		Categories: []string{pomodoro.CategoryShortBreak, pomodoro.CategoryLongBreak},
+		Order:      pomodoro.OrderDesc,
+		Limit:      n,
+	}), nil
+}
+
+func (r *inMemoryRepo) Find(f pomodoro.Filter) ([]pomodoro.Interval, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	return r.find(f), nil
 }
 
-func (r *inMemoryRepo) CategorySummary(day time.Time, filter string) (time.Duration, error) {
+// find applies f to the intervals. Callers hold the lock
+func (r *inMemoryRepo) find(f pomodoro.Filter) []pomodoro.Interval {
+	return f.Select(pomodoro.WithArchived(f, r.intervals, r.archived))
+}
+
+func (r *inMemoryRepo) CategorySummary(day time.Time, filter string, cancelledRatio float64) (time.Duration, error) {
 	r.RLock()
 	defer r.RUnlock()
 
@@ -87,8 +252,7 @@ func (r *inMemoryRepo) CategorySummary(day time.Time, filter string) (time.Durat
 	filter = strings.Trim(filter, "%")
 
 	for _, i := range r.intervals {
-		if i.StartTime.Year() == day.Year() &&
-			i.StartTime.YearDay() == day.YearDay() {
+		if onDay(i.StartTime, day) && i.Completed(cancelledRatio) {
 			if strings.Contains(i.Category, filter) {
 				d += i.ActualDuration
 			}
@@ -96,3 +260,377 @@ func (r *inMemoryRepo) CategorySummary(day time.Time, filter string) (time.Durat
 	}
 	return d, nil
 }
+
+func (r *inMemoryRepo) DailyTotals(start, end time.Time, filter string, cancelledRatio float64, tag string) (map[string]time.Duration, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	totals := make(map[string]time.Duration)
+	filter = strings.Trim(filter, "%")
+
+	for _, i := range r.intervals {
+		if i.StartTime.Before(start) || !i.StartTime.Before(end) {
+			continue
+		}
+		if tag != "" && !pomodoro.HasTag(r.tags[i.ID], tag) {
+			continue
+		}
+		if i.Completed(cancelledRatio) && strings.Contains(i.Category, filter) {
+			totals[i.StartTime.In(start.Location()).Format(pomodoro.DayLayout)] += i.ActualDuration
+		}
+	}
+	return totals, nil
+}
+
+func (r *inMemoryRepo) CompletedPerDay(start, end time.Time) (map[string]int, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	counts := make(map[string]int)
+	for _, i := range r.intervals {
+		if i.StartTime.Before(start) || !i.StartTime.Before(end) {
+			continue
+		}
+		if i.Category == pomodoro.CategoryPomodoro && i.State == pomodoro.StateDone {
+			counts[i.StartTime.In(start.Location()).Format(pomodoro.DayLayout)]++
+		}
+	}
+	return counts, nil
+}
+
+func (r *inMemoryRepo) StateCounts(start, end time.Time, category string) (map[int]int, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	return pomodoro.CountStates(r.intervals, start, end, category), nil
+}
+
+func (r *inMemoryRepo) AddTag(intervalID int64, tag string) error {
+	t, err := pomodoro.NormalizeTag(tag)
+	if err != nil {
+		return err
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	if _, err := r.index(intervalID); err != nil {
+		return err
+	}
+	tags := r.tags[intervalID]
+	k := sort.SearchStrings(tags, t)
+	if k < len(tags) && tags[k] == t {
+		return nil
+	}
+	tags = append(tags, "")
+	copy(tags[k+1:], tags[k:])
+	tags[k] = t
+	r.tags[intervalID] = tags
+	return nil
+}
+
+func (r *inMemoryRepo) TagsFor(intervalID int64) ([]string, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	if _, err := r.index(intervalID); err != nil && !r.isArchived(intervalID) {
+		return nil, err
+	}
+	return append([]string(nil), r.tags[intervalID]...), nil
+}
+
+func (r *inMemoryRepo) TagSummary(start, end time.Time) (map[string]time.Duration, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	totals := make(map[string]time.Duration)
+	for _, i := range r.intervals {
+		if i.State != pomodoro.StateDone || i.StartTime.Before(start) || !i.StartTime.Before(end) {
+			continue
+		}
+		for _, t := range r.tags[i.ID] {
+			totals[t] += i.ActualDuration
+		}
+	}
+	return totals, nil
+}
+
+func (r *inMemoryRepo) LabelSummary(start, end time.Time) ([]pomodoro.LabelTotal, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	return pomodoro.SumByLabel(r.intervals, start, end), nil
+}
+
+func (r *inMemoryRepo) DeviceSummary(start, end time.Time) (map[string]time.Duration, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	return pomodoro.SumByDevice(r.intervals, start, end), nil
+}
+
+func (r *inMemoryRepo) EventsFor(id int64) ([]pomodoro.StateEvent, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	if _, err := r.index(id); err != nil {
+		return nil, err
+	}
+	var events []pomodoro.StateEvent
+	for _, e := range r.events {
+		if e.IntervalID == id {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func (r *inMemoryRepo) TotalCompleted() (int64, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	var n int64
+	for _, i := range r.intervals {
+		if i.Category == pomodoro.CategoryPomodoro && i.State == pomodoro.StateDone {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *inMemoryRepo) AddInterruption(id int64) error {
+	r.Lock()
+	defer r.Unlock()
+
+	k, err := r.index(id)
+	if err != nil {
+		return err
+	}
+	r.intervals[k].Interruptions++
+	return nil
+}
+
+func (r *inMemoryRepo) SetNote(id int64, note string) error {
+	r.Lock()
+	defer r.Unlock()
+
+	k, err := r.index(id)
+	if err != nil {
+		return err
+	}
+	r.intervals[k].Note = note
+	return nil
+}
+
+func (r *inMemoryRepo) WorkSinceLongBreak(day time.Time) (time.Duration, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	var d time.Duration
+	for k := len(r.intervals) - 1; k >= 0; k-- {
+		i := r.intervals[k]
+		if i.Category == pomodoro.CategoryLongBreak {
+			break
+		}
+		if i.Category != pomodoro.CategoryPomodoro && i.Category != pomodoro.CategorySnooze {
+			continue
+		}
+		if onDay(i.StartTime, day) {
+			d += i.ActualDuration
+		}
+	}
+	return d, nil
+}
+
+func (r *inMemoryRepo) CreateTask(t pomodoro.Task) (int64, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	for _, existing := range r.tasks {
+		if existing.Name == t.Name {
+			return 0, fmt.Errorf("%w: %q", pomodoro.ErrTaskExists, t.Name)
+		}
+	}
+	t.ID = int64(len(r.tasks)) + 1
+	r.tasks = append(r.tasks, t)
+	return t.ID, nil
+}
+
+func (r *inMemoryRepo) TaskByName(name string) (pomodoro.Task, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	for _, t := range r.tasks {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+	return pomodoro.Task{}, fmt.Errorf("%w: %q", pomodoro.ErrTaskNotFound, name)
+}
+
+func (r *inMemoryRepo) Tasks() ([]pomodoro.Task, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	return append([]pomodoro.Task(nil), r.tasks...), nil
+}
+
+func (r *inMemoryRepo) TaskTotals(taskID int64) (int64, time.Duration, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	var (
+		n int64
+		d time.Duration
+	)
+	for _, i := range r.intervals {
+		if i.Category != pomodoro.CategoryPomodoro || i.TaskID != taskID {
+			continue
+		}
+		if i.State == pomodoro.StateDone {
+			n++
+		}
+		d += i.ActualDuration
+	}
+	return n, d, nil
+}
+
+func (r *inMemoryRepo) Delete(id int64) error {
+	r.Lock()
+	defer r.Unlock()
+
+	k, err := r.index(id)
+	if err != nil {
+		return err
+	}
+	if r.intervals[k].State == pomodoro.StateRunning {
+		return fmt.Errorf("%w: cannot delete interval %d", pomodoro.ErrIntervalAlreadyRunning, id)
+	}
+	r.intervals = append(r.intervals[:k], r.intervals[k+1:]...)
+	delete(r.tags, id)
+	r.pruneEvents()
+	return nil
+}
+
+// isArchived reports whether the interval with the given id was archived.
+// Callers hold the lock
+func (r *inMemoryRepo) isArchived(id int64) bool {
+	for _, i := range r.archived {
+		if i.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneEvents drops the events of deleted intervals. Callers hold the lock
+func (r *inMemoryRepo) pruneEvents() {
+	ids := make(map[int64]bool, len(r.intervals)+len(r.archived))
+	for _, i := range r.intervals {
+		ids[i.ID] = true
+	}
+	for _, i := range r.archived {
+		ids[i.ID] = true
+	}
+	kept := r.events[:0]
+	for _, e := range r.events {
+		if ids[e.IntervalID] {
+			kept = append(kept, e)
+		}
+	}
+	r.events = kept
+}
+
+func (r *inMemoryRepo) DeleteOlderThan(t time.Time) (int64, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	var (
+		kept []pomodoro.Interval
+		n    int64
+	)
+	for k, i := range r.intervals {
+		last := k == len(r.intervals)-1
+		unfinished := i.State != pomodoro.StateDone && i.State != pomodoro.StateCancelled &&
+			i.State != pomodoro.StateSkipped
+		if i.StartTime.Before(t) && !(last && unfinished) {
+			delete(r.tags, i.ID)
+			n++
+			continue
+		}
+		kept = append(kept, i)
+	}
+	r.intervals = kept
+	r.pruneEvents()
+	return n, nil
+}
+
+// Archive moves intervals to a second slice, which only Find and TagsFor
+// look at
+func (r *inMemoryRepo) Archive(olderThan time.Time) (int64, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	var (
+		kept []pomodoro.Interval
+		n    int64
+	)
+	for k, i := range r.intervals {
+		if pomodoro.Archivable(i, olderThan, k == len(r.intervals)-1) {
+			r.archived = append(r.archived, i)
+			n++
+			continue
+		}
+		kept = append(kept, i)
+	}
+	r.intervals = kept
+	return n, nil
+}
+
+func (r *inMemoryRepo) ByRange(start, end time.Time) ([]pomodoro.Interval, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	var data []pomodoro.Interval
+	for _, i := range r.intervals {
+		if i.StartTime.IsZero() || i.StartTime.Before(start) || !i.StartTime.Before(end) {
+			continue
+		}
+		data = append(data, i)
+	}
+	sort.SliceStable(data, func(a, b int) bool {
+		return data[a].StartTime.Before(data[b].StartTime)
+	})
+	return data, nil
+}
+
+func (r *inMemoryRepo) Import(intervals []pomodoro.Interval) (int, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	n := 0
+	for _, i := range intervals {
+		if i.StartTime.IsZero() || r.has(i.StartTime, i.Category) {
+			continue
+		}
+		r.lastID++
+		i.ID = r.lastID
+		i.Version = 0
+		r.intervals = append(r.intervals, i)
+		n++
+	}
+	return n, nil
+}
+
+// has reports whether an interval with the same start time and category
+// exists, archived or not. Callers hold the lock
+func (r *inMemoryRepo) has(start time.Time, category string) bool {
+	for _, is := range [][]pomodoro.Interval{r.intervals, r.archived} {
+		for _, i := range is {
+			if i.StartTime.Equal(start) && i.Category == category {
+				return true
+			}
+		}
+	}
+	return false
+}
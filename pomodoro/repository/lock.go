@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrDatabaseInUse is returned when opening a database locked by another
+// pomo instance
+var ErrDatabaseInUse = errors.New("database in use by another pomo instance")
+
+// errLocked is returned by tryLock when another process holds the lock
+var errLocked = errors.New("locked")
+
+// fileLock is an advisory lock on a database, held through a sibling file
+// ending in .lock. The file is left behind when the lock is released, as
+// removing it would race with another instance taking it
+type fileLock struct {
+	f *os.File
+}
+
+// lockDatabase takes the lock of the database at path without waiting. It
+// records the PID of the process in the lock file, so others fail with an
+// error naming it
+func lockDatabase(path string) (*fileLock, error) {
+	lockPath := path + ".lock"
+	f, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tryLock(f); err != nil {
+		defer f.Close()
+		if !errors.Is(err, errLocked) {
+			return nil, fmt.Errorf("locking %s: %w", lockPath, err)
+		}
+		if pid, ok := lockHolder(f); ok {
+			return nil, fmt.Errorf("%w: %s is locked by process %d", ErrDatabaseInUse, path, pid)
+		}
+		return nil, fmt.Errorf("%w: %s is locked", ErrDatabaseInUse, path)
+	}
+
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &fileLock{f: f}, nil
+}
+
+// lockHolder reads the PID written to the lock file by its holder
+func lockHolder(f *os.File) (int, bool) {
+	data, err := io.ReadAll(io.NewSectionReader(f, 0, 32))
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid, err == nil
+}
+
+// release gives up the lock, which closing the file does
+func (l *fileLock) release() error {
+	if l == nil {
+		return nil
+	}
+	return l.f.Close()
+}
+
+// lockable reports whether the sqlite database named by dbfile is a plain
+// file that can be locked. In-memory databases and URIs aren't
+func lockable(dbfile string) bool {
+	return dbfile != "" && dbfile != ":memory:" && !strings.HasPrefix(dbfile, "file:")
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package repository
+
+import "os"
+
+// tryLock does nothing where flock isn't available, so databases aren't
+// locked there
+func tryLock(f *os.File) error {
+	return nil
+}
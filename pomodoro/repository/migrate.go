@@ -0,0 +1,309 @@
+//go:build !bolt
+
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrSchemaTooNew is returned when opening a database migrated by a newer
+// version of pomo
+var ErrSchemaTooNew = errors.New("database schema is newer than this version of pomo")
+
+// ErrSchemaTooOld is returned when opening read-only a database that
+// needs migrating, which happens when pomo opens it for writing
+var ErrSchemaTooOld = errors.New("database schema is older than this version of pomo")
+
+const createTableMigrations string = `CREATE TABLE IF NOT EXISTS "schema_migrations" (
+	"version" INTEGER NOT NULL,
+	"applied_at" DATETIME NOT NULL,
+	PRIMARY KEY("version")
+	);`
+
+// A migration changes the schema from the previous version to the next
+type migration func(tx *sql.Tx) error
+
+// migrations upgrade the schema one version at a time. Version n is
+// reached by applying migrations[n-1]. New migrations are appended, never
+// edited or reordered, as databases record the last one they applied.
+//
+// Databases created before the version table existed may have any of the
+// early changes already, so those migrations tolerate it.
+var migrations = []migration{
+	execStmt(createTableInterval),
+	addColumn("label", `TEXT NOT NULL DEFAULT ''`),
+	addColumn("daily_ordinal", `INTEGER NOT NULL DEFAULT 0`),
+	addColumn("interruptions", `INTEGER NOT NULL DEFAULT 0`),
+	addColumn("note", `TEXT NOT NULL DEFAULT ''`),
+	execStmt(createTableTask),
+	addColumn("task_id", `INTEGER NOT NULL DEFAULT 0`),
+	execStmt(createIndexStartTime),
+	normalizeStartTimes,
+	addColumn("auto_paused", `INTEGER NOT NULL DEFAULT 0`),
+	addColumn("paused_duration", `INTEGER NOT NULL DEFAULT 0`),
+	addColumn("paused_at", `DATETIME NOT NULL DEFAULT '0001-01-01 00:00:00+00:00'`),
+	execStmt(`CREATE INDEX IF NOT EXISTS "interval_start_time_category"
+		ON "interval" ("start_time", "category");`),
+	execStmt(`CREATE INDEX IF NOT EXISTS "interval_category_id"
+		ON "interval" ("category", "id" DESC);`),
+	execStmt(`CREATE TABLE IF NOT EXISTS "tags" (
+		"id" INTEGER,
+		"name" TEXT NOT NULL UNIQUE,
+		PRIMARY KEY("id")
+		);`),
+	execStmt(`CREATE TABLE IF NOT EXISTS "interval_tags" (
+		"interval_id" INTEGER NOT NULL,
+		"tag_id" INTEGER NOT NULL,
+		PRIMARY KEY("interval_id", "tag_id")
+		);`),
+	execStmt(`CREATE TABLE IF NOT EXISTS "interval_events" (
+		"id" INTEGER,
+		"interval_id" INTEGER NOT NULL,
+		"from_state" INTEGER NOT NULL,
+		"to_state" INTEGER NOT NULL,
+		"time" DATETIME NOT NULL,
+		PRIMARY KEY("id")
+		);`),
+	execStmt(`CREATE INDEX IF NOT EXISTS "interval_events_interval_id"
+		ON "interval_events" ("interval_id");`),
+	addColumn("version", `INTEGER NOT NULL DEFAULT 0`),
+	execStmt(`CREATE INDEX IF NOT EXISTS "interval_category_start_time"
+		ON "interval" ("category", "start_time" DESC);`),
+	durationsToSeconds,
+	execStmt(createTableIntervalArchive),
+	execStmt(`CREATE INDEX IF NOT EXISTS "interval_archive_start_time"
+		ON "interval_archive" ("start_time");`),
+	autoincrementIDs,
+	addColumn("device", `TEXT NOT NULL DEFAULT ''`),
+	execStmt(`ALTER TABLE "interval_archive" ADD COLUMN "device" TEXT NOT NULL DEFAULT ''`),
+	nullStartTimes,
+}
+
+// schemaVersion is the version of the schema created by this version of pomo
+var schemaVersion = len(migrations)
+
+func execStmt(stmt string) migration {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(stmt)
+		return err
+	}
+}
+
+// addColumn adds a column to the interval table unless it exists
+func addColumn(name, definition string) migration {
+	return func(tx *sql.Tx) error {
+		var n int
+		err := tx.QueryRow(`SELECT count(*) FROM pragma_table_info('interval') WHERE name=?`, name).Scan(&n)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return nil
+		}
+		_, err = tx.Exec(fmt.Sprintf(`ALTER TABLE interval ADD COLUMN %q %s`, name, definition))
+		return err
+	}
+}
+
+// normalizeStartTimes rewrites start times stored with a local offset to
+// UTC, so they compare correctly as text when bucketing by day. Older
+// versions stored times in the location of the process.
+func normalizeStartTimes(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT id, start_time FROM interval
+		WHERE start_time NOT LIKE '%+00:00'`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	starts := make(map[int64]time.Time)
+	for rows.Next() {
+		var (
+			id int64
+			t  time.Time
+		)
+		if err := rows.Scan(&id, &t); err != nil {
+			return err
+		}
+		starts[id] = t
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for id, t := range starts {
+		if _, err := tx.Exec("UPDATE interval SET start_time=? WHERE id=?", t.UTC(), id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// durationsToSeconds converts durations stored as Go nanosecond counts to
+// whole seconds, rounding. Values written in seconds by other tools are
+// told apart by magnitude: no interval lasts a million seconds, while
+// nanosecond counts reach that at a millisecond
+func durationsToSeconds(tx *sql.Tx) error {
+	for _, c := range []string{"planned_duration", "actual_duration", "paused_duration"} {
+		_, err := tx.Exec(fmt.Sprintf(`UPDATE interval SET %[1]q = (%[1]q + 500000000) / 1000000000
+			WHERE %[1]q >= 1000000`, c))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// autoincrementIDs keeps the IDs of deleted intervals from being reused,
+// which sqlite does for the highest one otherwise. An interval archived
+// and then pruned from interval would hand its ID, tags and events to the
+// next one created. The table is rebuilt, as AUTOINCREMENT can't be added
+// to an existing one, and the sequence starts past the archived IDs
+func autoincrementIDs(tx *sql.Tx) error {
+	create, err := tableSQL(tx, "interval")
+	if err != nil {
+		return err
+	}
+	autoinc := strings.Replace(create, `PRIMARY KEY("id")`, `PRIMARY KEY("id" AUTOINCREMENT)`, 1)
+	if autoinc == create {
+		return errors.New("no id primary key to change in interval")
+	}
+	return rebuildTable(tx, "interval", autoinc,
+		`DELETE FROM sqlite_sequence WHERE name='interval'`,
+		`INSERT INTO sqlite_sequence (name, seq) SELECT 'interval', max(
+			(SELECT coalesce(max(id), 0) FROM "interval"),
+			(SELECT coalesce(max(id), 0) FROM "interval_archive"))`)
+}
+
+// nullStartTimes lets start_time be NULL, which intervals that never
+// started now store instead of the zero time. sqlite can't drop a NOT NULL
+// constraint, so each table is rebuilt with the change
+func nullStartTimes(tx *sql.Tx) error {
+	for _, table := range []string{"interval", "interval_archive"} {
+		create, err := tableSQL(tx, table)
+		if err != nil {
+			return err
+		}
+		nullable := strings.Replace(create, `"start_time" DATETIME NOT NULL`, `"start_time" DATETIME`, 1)
+		if nullable == create {
+			return fmt.Errorf("no start_time column to change in %s", table)
+		}
+		err = rebuildTable(tx, table, nullable,
+			fmt.Sprintf(`UPDATE %q SET start_time=NULL WHERE start_time < '0001-01-02'`, table))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tableSQL returns the statement table was created with, including the
+// columns added over time
+func tableSQL(tx *sql.Tx, table string) (string, error) {
+	var create string
+	err := tx.QueryRow(`SELECT sql FROM sqlite_master WHERE type='table' AND name=?`, table).Scan(&create)
+	return create, err
+}
+
+// rebuildTable recreates table with the statement create, a changed copy
+// of its own, as the sqlite documentation describes for changes ALTER
+// TABLE can't make. The rows and indexes are kept, and the statements in
+// after run once the rows are copied
+func rebuildTable(tx *sql.Tx, table, create string, after ...string) error {
+	indexes, err := tableIndexes(tx, table)
+	if err != nil {
+		return err
+	}
+
+	old := table + "_old"
+	stmts := []string{
+		fmt.Sprintf(`ALTER TABLE %q RENAME TO %q`, table, old),
+		create,
+		fmt.Sprintf(`INSERT INTO %q SELECT * FROM %q`, table, old),
+		fmt.Sprintf(`DROP TABLE %q`, old),
+	}
+	stmts = append(stmts, after...)
+	for _, stmt := range append(stmts, indexes...) {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tableIndexes returns the statements creating the indexes of table
+func tableIndexes(tx *sql.Tx, table string) ([]string, error) {
+	rows, err := tx.Query(`SELECT sql FROM sqlite_master
+		WHERE type='index' AND tbl_name=? AND sql IS NOT NULL`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stmts []string
+	for rows.Next() {
+		var stmt string
+		if err := rows.Scan(&stmt); err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, stmt)
+	}
+	return stmts, rows.Err()
+}
+
+// migrate applies the pending migrations in a single transaction, so a
+// failed upgrade leaves the database as it was
+func migrate(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(createTableMigrations); err != nil {
+		return err
+	}
+
+	var version int
+	if err := tx.QueryRow(`SELECT coalesce(max(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return err
+	}
+	if version > schemaVersion {
+		return fmt.Errorf("%w: version %d, expected at most %d", ErrSchemaTooNew, version, schemaVersion)
+	}
+
+	for v := version + 1; v <= schemaVersion; v++ {
+		if err := migrations[v-1](tx); err != nil {
+			return fmt.Errorf("migrating database to version %d: %w", v, err)
+		}
+		_, err := tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES(?, ?)`,
+			v, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// checkSchema fails unless the schema of db is the one created by this
+// version of pomo, without changing it
+func checkSchema(db *sql.DB) error {
+	var version int
+	err := db.QueryRow(`SELECT coalesce(max(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return fmt.Errorf("%w: no schema version: %v", ErrSchemaTooOld, err)
+	}
+	switch {
+	case version > schemaVersion:
+		return fmt.Errorf("%w: version %d, expected at most %d", ErrSchemaTooNew, version, schemaVersion)
+	case version < schemaVersion:
+		return fmt.Errorf("%w: version %d, expected %d", ErrSchemaTooOld, version, schemaVersion)
+	}
+	return nil
+}
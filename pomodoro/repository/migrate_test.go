@@ -0,0 +1,296 @@
+//go:build !bolt
+
+package repository_test
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/snirkop89/pomo/pomodoro"
+	"github.com/snirkop89/pomo/pomodoro/repository"
+)
+
+// createV1 writes a database in the format of the first release, with
+// start times in the local offset of the process
+func createV1(t *testing.T, path string, start time.Time) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE "interval" (
+		"id" INTEGER,
+		"start_time" DATETIME NOT NULL,
+		"planned_duration" INTEGER DEFAULT 0,
+		"actual_duration" INTEGER DEFAULT 0,
+		"category" TEXT NOT NULL,
+		"state" INTEGER DEFAULT 1,
+		PRIMARY KEY("id")
+		);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`INSERT INTO interval (start_time, planned_duration, actual_duration, category, state)
+		VALUES(?, ?, ?, ?, ?)`,
+		start, 25*time.Minute, 25*time.Minute, pomodoro.CategoryPomodoro, pomodoro.StateDone)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func schemaVersion(t *testing.T, path string) int {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var v int
+	if err := db.QueryRow("SELECT max(version) FROM schema_migrations").Scan(&v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestMigrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pomo.db")
+	start := time.Date(2023, time.May, 10, 9, 30, 0, 0, time.FixedZone("", 2*60*60))
+	createV1(t, path, start)
+
+	repo, err := repository.NewSQLite3Repo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i, err := repo.ByID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !i.StartTime.Equal(start) || i.StartTime.Location() != time.UTC {
+		t.Errorf("expected start time %s in UTC, got %s", start.UTC(), i.StartTime)
+	}
+	d, err := repo.CategorySummary(start, "%", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != 25*time.Minute {
+		t.Errorf("expected summary %s, got %s", 25*time.Minute, d)
+	}
+	if i.Device != "" {
+		t.Errorf("expected no device, got %q", i.Device)
+	}
+	devices, err := repo.DeviceSummary(start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 1 || devices[""] != 25*time.Minute {
+		t.Errorf("expected %s without a device, got %v", 25*time.Minute, devices)
+	}
+
+	// Rows from before paused time and interruptions were recorded count
+	// as having none
+	config := pomodoro.NewConfig(repo, 0, 0, 0)
+	if q, err := pomodoro.FocusQualitySummary(start, config); err != nil || q != (pomodoro.FocusQuality{}) {
+		t.Errorf("expected no paused time or interruptions, got %+v, %v", q, err)
+	}
+
+	// Columns added by migrations are usable
+	if err := repo.SetNote(1, "migrated"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateTask(pomodoro.Task{Name: "report"}); err != nil {
+		t.Fatal(err)
+	}
+
+	v := schemaVersion(t, path)
+	if v == 0 {
+		t.Fatal("expected schema version recorded")
+	}
+
+	// Opening again applies nothing
+	repo.Close()
+	if repo, err = repository.NewSQLite3Repo(path); err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	if got := schemaVersion(t, path); got != v {
+		t.Errorf("expected version %d, got %d", v, got)
+	}
+	if i, err := repo.ByID(1); err != nil || i.Note != "migrated" {
+		t.Errorf("expected note kept, got %q, %v", i.Note, err)
+	}
+}
+
+func TestMigrateNullStartTimes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pomo.db")
+	start := time.Date(2023, time.May, 10, 9, 30, 0, 0, time.UTC)
+	createV1(t, path, start)
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.Exec(`INSERT INTO interval (start_time, planned_duration, category, state)
+		VALUES(?, ?, ?, ?)`, time.Time{}, 25*time.Minute, pomodoro.CategoryPomodoro, pomodoro.StateNotStarted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := repository.NewSQLite3Repo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	nulls := func() int {
+		t.Helper()
+		var n int
+		if err := db.QueryRow("SELECT count(*) FROM interval WHERE start_time IS NULL").Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if n := nulls(); n != 1 {
+		t.Errorf("expected the pending interval migrated to NULL, got %d NULL start times", n)
+	}
+	if i, err := repo.ByID(2); err != nil || !i.StartTime.IsZero() {
+		t.Errorf("expected no start time, got %s, %v", i.StartTime, err)
+	}
+	if i, err := repo.ByID(1); err != nil || !i.StartTime.Equal(start) {
+		t.Errorf("expected start time %s kept, got %s, %v", start, i.StartTime, err)
+	}
+
+	// The indexes survive rebuilding the table
+	for _, index := range []string{"interval_start_time", "interval_category_start_time"} {
+		var n int
+		err := db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type='index' AND name=?", index).Scan(&n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 1 {
+			t.Errorf("expected index %s", index)
+		}
+	}
+
+	if _, err := repo.Create(pomodoro.Interval{Category: pomodoro.CategoryShortBreak}); err != nil {
+		t.Fatal(err)
+	}
+	if n := nulls(); n != 2 {
+		t.Errorf("expected new pending interval stored with NULL, got %d NULL start times", n)
+	}
+}
+
+func TestMigrateTooNew(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pomo.db")
+	repo, err := repository.NewSQLite3Repo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.Close()
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec("INSERT INTO schema_migrations (version, applied_at) VALUES(?, ?)", 1000, time.Now())
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repository.NewSQLite3Repo(path); !errors.Is(err, repository.ErrSchemaTooNew) {
+		t.Errorf("expected error %q, got %v", repository.ErrSchemaTooNew, err)
+	}
+}
+
+func TestMigrateDurationsToSeconds(t *testing.T) {
+	fixture, err := os.ReadFile(filepath.Join("testdata", "nanoseconds.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "pomo.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(string(fixture)); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := repository.NewSQLite3Repo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	// Summaries are what they were with nanoseconds
+	day := time.Date(2023, time.May, 10, 0, 0, 0, 0, time.UTC)
+	summaries := []struct {
+		filter string
+		ratio  float64
+		exp    time.Duration
+	}{
+		{pomodoro.CategoryPomodoro, 0, 50 * time.Minute},
+		{pomodoro.CategoryPomodoro, 0.8, 70 * time.Minute},
+		{"%Break", 0, 20 * time.Minute},
+	}
+	for _, s := range summaries {
+		got, err := repo.CategorySummary(day, s.filter, s.ratio)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != s.exp {
+			t.Errorf("%s with ratio %g: expected %s, got %s", s.filter, s.ratio, s.exp, got)
+		}
+	}
+	totals, err := repo.DailyTotals(day, day.AddDate(0, 0, 2), pomodoro.CategoryPomodoro, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totals["2023-05-10"] != 50*time.Minute || totals["2023-05-11"] != 25*time.Minute {
+		t.Errorf("expected daily totals of 50m and 25m, got %v", totals)
+	}
+	tags, err := repo.TagSummary(day, day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags["deep"] != 25*time.Minute {
+		t.Errorf("expected 25m tagged deep, got %v", tags)
+	}
+
+	// The interval written in seconds isn't converted again
+	for id, exp := range map[int64]time.Duration{4: 5 * time.Minute, 5: 15 * time.Minute} {
+		i, err := repo.ByID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i.PlannedDuration != exp || i.ActualDuration != exp {
+			t.Errorf("interval %d: expected %s, got planned %s and actual %s",
+				id, exp, i.PlannedDuration, i.ActualDuration)
+		}
+	}
+	if i, err := repo.ByID(4); err != nil || i.PausedDuration != 90*time.Second {
+		t.Errorf("expected 90s paused, got %s, %v", i.PausedDuration, err)
+	}
+
+	// Plain SQL reads seconds
+	var actual int64
+	if err := db.QueryRow("SELECT actual_duration FROM interval WHERE id=1").Scan(&actual); err != nil {
+		t.Fatal(err)
+	}
+	if actual != 1500 {
+		t.Errorf("expected 1500 seconds stored, got %d", actual)
+	}
+}
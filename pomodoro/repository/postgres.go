@@ -0,0 +1,746 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro"
+)
+
+// The postgres driver isn't linked by this package. Programs using
+// NewPostgresRepo register one under the name "postgres", for example by
+// importing github.com/lib/pq.
+const postgresDriver = "postgres"
+
+const (
+	// interval is a reserved word in postgres, so the table name is
+	// always quoted
+	pgCreateTableInterval string = `CREATE TABLE IF NOT EXISTS "interval" (
+		"id" BIGSERIAL PRIMARY KEY,
+		"start_time" TIMESTAMPTZ,
+		"planned_duration" BIGINT NOT NULL DEFAULT 0,
+		"actual_duration" BIGINT NOT NULL DEFAULT 0,
+		"category" TEXT NOT NULL,
+		"state" INTEGER NOT NULL DEFAULT 1
+		);`
+
+	// pgCreateTableIntervalArchive holds the intervals moved by Archive.
+	// It gets the columns added to interval too
+	pgCreateTableIntervalArchive string = `CREATE TABLE IF NOT EXISTS "interval_archive" (
+		"id" BIGINT PRIMARY KEY,
+		"start_time" TIMESTAMPTZ,
+		"planned_duration" BIGINT NOT NULL DEFAULT 0,
+		"actual_duration" BIGINT NOT NULL DEFAULT 0,
+		"category" TEXT NOT NULL,
+		"state" INTEGER NOT NULL DEFAULT 1
+		);`
+
+	pgCreateIndexArchiveStartTime string = `CREATE INDEX IF NOT EXISTS "interval_archive_start_time"
+		ON "interval_archive" ("start_time");`
+
+	pgCreateIndexStartTime string = `CREATE INDEX IF NOT EXISTS "interval_start_time"
+		ON "interval" ("start_time");`
+
+	pgCreateTableTags string = `CREATE TABLE IF NOT EXISTS "tags" (
+		"id" BIGSERIAL PRIMARY KEY,
+		"name" TEXT NOT NULL UNIQUE
+		);`
+
+	pgCreateTableIntervalTags string = `CREATE TABLE IF NOT EXISTS "interval_tags" (
+		"interval_id" BIGINT NOT NULL REFERENCES "interval" ("id") ON DELETE CASCADE,
+		"tag_id" BIGINT NOT NULL REFERENCES "tags" ("id"),
+		PRIMARY KEY ("interval_id", "tag_id")
+		);`
+
+	pgCreateTableIntervalEvents string = `CREATE TABLE IF NOT EXISTS "interval_events" (
+		"id" BIGSERIAL PRIMARY KEY,
+		"interval_id" BIGINT NOT NULL REFERENCES "interval" ("id") ON DELETE CASCADE,
+		"from_state" INTEGER NOT NULL,
+		"to_state" INTEGER NOT NULL,
+		"time" TIMESTAMPTZ NOT NULL
+		);`
+
+	pgCreateIndexIntervalEvents string = `CREATE INDEX IF NOT EXISTS "interval_events_interval_id"
+		ON "interval_events" ("interval_id");`
+
+	pgCreateIndexCategoryStartTime string = `CREATE INDEX IF NOT EXISTS "interval_category_start_time"
+		ON "interval" ("category", "start_time" DESC);`
+
+	pgCreateTableTask string = `CREATE TABLE IF NOT EXISTS "task" (
+		"id" BIGSERIAL PRIMARY KEY,
+		"name" TEXT NOT NULL UNIQUE,
+		"estimate" INTEGER NOT NULL DEFAULT 0
+		);`
+)
+
+const pgInsertInterval string = `INSERT INTO "interval"
+	(start_time, planned_duration, actual_duration, category, state, label,
+	daily_ordinal, task_id, device)
+	VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`
+
+// pgAddedColumns are the columns added by the sqlite migrations, with
+// postgres types
+var pgAddedColumns = []struct {
+	name       string
+	definition string
+}{
+	{"label", `TEXT NOT NULL DEFAULT ''`},
+	{"daily_ordinal", `INTEGER NOT NULL DEFAULT 0`},
+	{"interruptions", `INTEGER NOT NULL DEFAULT 0`},
+	{"note", `TEXT NOT NULL DEFAULT ''`},
+	{"task_id", `BIGINT NOT NULL DEFAULT 0`},
+	{"auto_paused", `BOOLEAN NOT NULL DEFAULT FALSE`},
+	{"paused_duration", `BIGINT NOT NULL DEFAULT 0`},
+	{"paused_at", `TIMESTAMPTZ NOT NULL DEFAULT '0001-01-01 00:00:00+00'`},
+	{"version", `BIGINT NOT NULL DEFAULT 0`},
+	{"device", `TEXT NOT NULL DEFAULT ''`},
+}
+
+// rebind replaces the ? placeholders of a query with postgres' numbered
+// ones
+func rebind(stmt string) string {
+	var b strings.Builder
+	n := 0
+	for _, c := range stmt {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func init() {
+	Register("postgres", func(path string) (pomodoro.Repository, error) {
+		r, err := NewPostgresRepo(path)
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	})
+}
+
+type pgRepo struct {
+	db *sql.DB
+	// tx is set on the repository given to RunInTransaction functions,
+	// which runs every statement in it
+	tx *sql.Tx
+	// now stamps the state events
+	now func() time.Time
+}
+
+// NewPostgresRepo connects to the postgres database at dsn, creating or
+// migrating its schema
+func NewPostgresRepo(dsn string) (*pgRepo, error) {
+	db, err := sql.Open(postgresDriver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("opening postgres database: %w", err)
+	}
+	db.SetConnMaxLifetime(30 * time.Minute)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	for _, stmt := range []string{pgCreateTableInterval, pgCreateTableTask, pgCreateIndexStartTime,
+		pgCreateTableTags, pgCreateTableIntervalTags, pgCreateTableIntervalEvents,
+		pgCreateIndexIntervalEvents, pgCreateIndexCategoryStartTime, pgCreateTableIntervalArchive,
+		pgCreateIndexArchiveStartTime} {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	for _, table := range []string{"interval", "interval_archive"} {
+		var stmts []string
+		for _, c := range pgAddedColumns {
+			stmts = append(stmts, fmt.Sprintf(`ALTER TABLE %q ADD COLUMN IF NOT EXISTS %q %s`,
+				table, c.name, c.definition))
+		}
+		// Intervals that never started used to store the zero time
+		stmts = append(stmts,
+			fmt.Sprintf(`ALTER TABLE %q ALTER COLUMN "start_time" DROP NOT NULL`, table),
+			fmt.Sprintf(`UPDATE %q SET start_time=NULL WHERE start_time < '0001-01-02'`, table))
+		for _, stmt := range stmts {
+			if _, err := db.Exec(stmt); err != nil {
+				db.Close()
+				return nil, err
+			}
+		}
+	}
+
+	return &pgRepo{
+		db:  db,
+		now: time.Now,
+	}, nil
+}
+
+// SetNow sets the time the state events are stamped with
+func (r *pgRepo) SetNow(now func() time.Time) {
+	r.now = now
+}
+
+// Close closes the connections to the database
+func (r *pgRepo) Close() error {
+	return r.db.Close()
+}
+
+// conn returns the transaction the repository is bound to, if any, or the
+// database
+func (r *pgRepo) conn() querier {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.db
+}
+
+// withTx runs fn in a transaction committed if fn succeeds. Repositories
+// bound to a transaction run fn in it, leaving the commit to its owner
+func (r *pgRepo) withTx(fn func(tx *sql.Tx) error) error {
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RunInTransaction calls fn with a repository running every statement in
+// one transaction, rolled back if fn fails
+func (r *pgRepo) RunInTransaction(fn func(pomodoro.Repository) error) error {
+	return r.withTx(func(tx *sql.Tx) error {
+		return fn(&pgRepo{db: r.db, tx: tx, now: r.now})
+	})
+}
+
+func (r *pgRepo) Create(i pomodoro.Interval) (int64, error) {
+	var id int64
+	err := r.conn().QueryRow(pgInsertInterval,
+		startTime(i.StartTime), i.PlannedDuration, i.ActualDuration,
+		i.Category, i.State, i.Label, i.DailyOrdinal, i.TaskID, i.Device).Scan(&id)
+	return id, err
+}
+
+// CreateBatch stores intervals in a single transaction with the insert
+// statement prepared once
+func (r *pgRepo) CreateBatch(is []pomodoro.Interval) ([]int64, error) {
+	ids := make([]int64, 0, len(is))
+	err := r.withTx(func(tx *sql.Tx) error {
+		ins, err := tx.Prepare(pgInsertInterval)
+		if err != nil {
+			return err
+		}
+		defer ins.Close()
+
+		for _, i := range is {
+			var id int64
+			err := ins.QueryRow(startTime(i.StartTime), i.PlannedDuration, i.ActualDuration,
+				i.Category, i.State, i.Label, i.DailyOrdinal, i.TaskID, i.Device).Scan(&id)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *pgRepo) Update(i pomodoro.Interval) error {
+	return r.update(i.ID, i.State,
+		`UPDATE "interval" SET start_time=$1, actual_duration=$2, state=$3, label=$4,
+		auto_paused=$5, paused_duration=$6, paused_at=$7, version=version+1
+		WHERE id=$8 AND version=$9`,
+		startTime(i.StartTime), i.ActualDuration, i.State, i.Label,
+		i.AutoPaused, i.PausedDuration, i.PausedAt.UTC(), i.ID, i.Version)
+}
+
+// UpdateProgress stores the elapsed time and state of an interval
+func (r *pgRepo) UpdateProgress(id, version int64, actual time.Duration, state int) error {
+	return r.update(id, state, `UPDATE "interval" SET actual_duration=$1, state=$2,
+		version=version+1 WHERE id=$3 AND version=$4`,
+		actual, state, id, version)
+}
+
+// update runs stmt, which sets the state of interval id if it has the
+// expected version, and records the change of state if there's one
+func (r *pgRepo) update(id int64, state int, stmt string, args ...any) error {
+	return r.withTx(func(tx *sql.Tx) error {
+		// Locking the row keeps concurrent updates from recording the same
+		// previous state
+		var prev int
+		err := tx.QueryRow(`SELECT state FROM "interval" WHERE id=$1 FOR UPDATE`, id).Scan(&prev)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: %d", pomodoro.ErrInvalidID, id)
+		}
+		if err != nil {
+			return err
+		}
+
+		res, err := tx.Exec(stmt, args...)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion(res, id); err != nil {
+			return err
+		}
+		if prev == state {
+			return nil
+		}
+		_, err = tx.Exec(`INSERT INTO interval_events (interval_id, from_state, to_state, time)
+			VALUES ($1, $2, $3, $4)`, id, prev, state, r.now().UTC())
+		return err
+	})
+}
+
+func (r *pgRepo) ByID(id int64) (pomodoro.Interval, error) {
+	if id <= 0 {
+		return pomodoro.Interval{}, fmt.Errorf("%w: %d", pomodoro.ErrInvalidID, id)
+	}
+
+	i, err := scanInterval(r.conn().QueryRow(`SELECT `+intervalColumns+` FROM "interval" WHERE id=$1`, id), postgresUnit)
+	if err == sql.ErrNoRows {
+		return i, fmt.Errorf("%w: %d", pomodoro.ErrInvalidID, id)
+	}
+	return i, err
+}
+
+// Last searchs for the last item in the repository
+func (r *pgRepo) Last() (pomodoro.Interval, error) {
+	found, err := r.Find(pomodoro.Filter{Order: pomodoro.OrderDesc, Limit: 1})
+	if err != nil {
+		return pomodoro.Interval{}, err
+	}
+	if len(found) == 0 {
+		return pomodoro.Interval{}, pomodoro.ErrNoIntervals
+	}
+	return found[0], nil
+}
+
+func (r *pgRepo) Breaks(n int) ([]pomodoro.Interval, error) {
+	return r.Find(pomodoro.Filter{
+		Categories: []string{pomodoro.CategoryShortBreak, pomodoro.CategoryLongBreak},
+		Order:      pomodoro.OrderDesc,
+		Limit:      n,
+	})
+}
+
+// Find returns the intervals matched by the filter
+func (r *pgRepo) Find(f pomodoro.Filter) ([]pomodoro.Interval, error) {
+	stmt, args := findQuery(f, "strpos", "lower")
+	return r.query(rebind(stmt), args...)
+}
+
+// query runs a statement selecting intervalColumns
+func (r *pgRepo) query(stmt string, args ...any) ([]pomodoro.Interval, error) {
+	rows, err := r.conn().Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var data []pomodoro.Interval
+	for rows.Next() {
+		i, err := scanInterval(rows, postgresUnit)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, i)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// CategorySummary returns a daily summary of completed intervals. Days are
+// bucketed by the location of day, as in the sqlite repository, rather than
+// by the session time zone date_trunc would use
+func (r *pgRepo) CategorySummary(day time.Time, filter string, cancelledRatio float64) (time.Duration, error) {
+	stmt := `SELECT sum(actual_duration) FROM "interval"
+		WHERE category LIKE $1 AND
+		start_time IS NOT NULL AND start_time >= $2 AND start_time < $3 AND
+		(state=$4 OR
+		($5::float8 > 0 AND state=$6 AND actual_duration >= planned_duration * $5::float8))`
+
+	start, end := dayBounds(day)
+	var ds sql.NullInt64
+	err := r.conn().QueryRow(stmt, filter, start, end, pomodoro.StateDone,
+		cancelledRatio, pomodoro.StateCancelled).Scan(&ds)
+	if err != nil {
+		return 0, err
+	}
+
+	var d time.Duration
+	if ds.Valid {
+		d = time.Duration(ds.Int64)
+	}
+	return d, nil
+}
+
+// DailyTotals sums the completed intervals of each day in a single query
+func (r *pgRepo) DailyTotals(start, end time.Time, filter string, cancelledRatio float64, tag string) (map[string]time.Duration, error) {
+	stmt, args, days := dailyTotalsQuery(start, end, filter, cancelledRatio, tag)
+	rows, err := r.conn().Query(rebind(stmt), args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanDailyTotals(rows, days, postgresUnit)
+}
+
+func (r *pgRepo) CompletedPerDay(start, end time.Time) (map[string]int, error) {
+	if !start.Before(end) {
+		return map[string]int{}, nil
+	}
+	stmt, args, days := completedPerDayQuery(start, end)
+	rows, err := r.conn().Query(rebind(stmt), args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanCounts(rows, days)
+}
+
+// StateCounts counts the intervals of category started in [start, end)
+// by state
+func (r *pgRepo) StateCounts(start, end time.Time, category string) (map[int]int, error) {
+	rows, err := r.conn().Query(`SELECT state, count(*) FROM "interval"
+		WHERE category=$1 AND
+		start_time IS NOT NULL AND start_time >= $2 AND start_time < $3
+		GROUP BY state`, category, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, err
+	}
+	return scanStateCounts(rows)
+}
+
+// AddTag links a tag to an interval, creating the tag if it's new
+func (r *pgRepo) AddTag(intervalID int64, tag string) error {
+	t, err := pomodoro.NormalizeTag(tag)
+	if err != nil {
+		return err
+	}
+
+	return r.withTx(func(tx *sql.Tx) error {
+		var exists bool
+		if err := tx.QueryRow(`SELECT exists(SELECT 1 FROM "interval" WHERE id=$1)`, intervalID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%w: %d", pomodoro.ErrInvalidID, intervalID)
+		}
+
+		if _, err := tx.Exec(`INSERT INTO tags (name) VALUES($1) ON CONFLICT (name) DO NOTHING`, t); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`INSERT INTO interval_tags (interval_id, tag_id)
+			SELECT $1, id FROM tags WHERE name=$2 ON CONFLICT DO NOTHING`, intervalID, t)
+		return err
+	})
+}
+
+// TagsFor returns the tags of an interval in alphabetical order
+func (r *pgRepo) TagsFor(intervalID int64) ([]string, error) {
+	var exists bool
+	err := r.conn().QueryRow(`SELECT exists(SELECT 1 FROM "interval" WHERE id=$1)
+		OR exists(SELECT 1 FROM "interval_archive" WHERE id=$1)`, intervalID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %d", pomodoro.ErrInvalidID, intervalID)
+	}
+
+	rows, err := r.conn().Query(`SELECT t.name FROM tags t
+		JOIN interval_tags it ON it.tag_id=t.id
+		WHERE it.interval_id=$1 ORDER BY t.name`, intervalID)
+	if err != nil {
+		return nil, err
+	}
+	return scanTags(rows)
+}
+
+// TagSummary sums the time of the done intervals started in [start, end)
+// by tag
+func (r *pgRepo) TagSummary(start, end time.Time) (map[string]time.Duration, error) {
+	rows, err := r.conn().Query(`SELECT t.name, sum(i.actual_duration) FROM "interval" i
+		JOIN interval_tags it ON it.interval_id=i.id
+		JOIN tags t ON t.id=it.tag_id
+		WHERE i.state=$1 AND
+		i.start_time IS NOT NULL AND i.start_time >= $2 AND i.start_time < $3
+		GROUP BY t.name`, pomodoro.StateDone, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, err
+	}
+	return scanTotals(rows, postgresUnit)
+}
+
+// DeviceSummary sums the time of the done pomodoros started in
+// [start, end) by device
+func (r *pgRepo) DeviceSummary(start, end time.Time) (map[string]time.Duration, error) {
+	rows, err := r.conn().Query(`SELECT device, sum(actual_duration) FROM "interval"
+		WHERE category=$1 AND state=$2 AND
+		start_time IS NOT NULL AND start_time >= $3 AND start_time < $4
+		GROUP BY device`, pomodoro.CategoryPomodoro, pomodoro.StateDone, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, err
+	}
+	return scanTotals(rows, postgresUnit)
+}
+
+// LabelSummary totals the done pomodoros started in [start, end) by label
+func (r *pgRepo) LabelSummary(start, end time.Time) ([]pomodoro.LabelTotal, error) {
+	rows, err := r.conn().Query(`SELECT CASE WHEN label='' THEN $1 ELSE label END,
+		count(*), sum(actual_duration) FROM "interval"
+		WHERE category=$2 AND state=$3 AND
+		start_time IS NOT NULL AND start_time >= $4 AND start_time < $5
+		GROUP BY 1 ORDER BY 3 DESC, 1`,
+		pomodoro.NoLabel, pomodoro.CategoryPomodoro, pomodoro.StateDone, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, err
+	}
+	return scanLabelTotals(rows, postgresUnit)
+}
+
+// EventsFor returns the state changes of an interval, oldest first
+func (r *pgRepo) EventsFor(id int64) ([]pomodoro.StateEvent, error) {
+	var exists bool
+	if err := r.conn().QueryRow(`SELECT exists(SELECT 1 FROM "interval" WHERE id=$1)`, id).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %d", pomodoro.ErrInvalidID, id)
+	}
+
+	rows, err := r.conn().Query(`SELECT interval_id, from_state, to_state, time
+		FROM interval_events WHERE interval_id=$1 ORDER BY id`, id)
+	if err != nil {
+		return nil, err
+	}
+	return scanEvents(rows)
+}
+
+// TotalCompleted counts all the pomodoros ever completed
+func (r *pgRepo) TotalCompleted() (int64, error) {
+	var n int64
+	err := r.conn().QueryRow(`SELECT count(*) FROM "interval" WHERE category=$1 AND state=$2`,
+		pomodoro.CategoryPomodoro, pomodoro.StateDone).Scan(&n)
+	return n, err
+}
+
+// exec runs a statement changing the interval id, failing if it
+// doesn't exist
+func (r *pgRepo) exec(id int64, stmt string, args ...any) error {
+	res, err := r.conn().Exec(stmt, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: %d", pomodoro.ErrInvalidID, id)
+	}
+	return nil
+}
+
+// AddInterruption increments the interruptions of an interval in place
+func (r *pgRepo) AddInterruption(id int64) error {
+	return r.exec(id, `UPDATE "interval" SET interruptions=interruptions+1 WHERE id=$1`, id)
+}
+
+// SetNote replaces the note of an interval
+func (r *pgRepo) SetNote(id int64, note string) error {
+	return r.exec(id, `UPDATE "interval" SET note=$1 WHERE id=$2`, note, id)
+}
+
+// WorkSinceLongBreak sums the work done on day after the last long break
+func (r *pgRepo) WorkSinceLongBreak(day time.Time) (time.Duration, error) {
+	stmt := `SELECT coalesce(sum(actual_duration), 0) FROM "interval"
+		WHERE category IN ($1, $2) AND
+		id > coalesce((SELECT max(id) FROM "interval" WHERE category=$3), 0) AND
+		start_time IS NOT NULL AND start_time >= $4 AND start_time < $5`
+
+	start, end := dayBounds(day)
+	var d int64
+	err := r.conn().QueryRow(stmt, pomodoro.CategoryPomodoro, pomodoro.CategorySnooze,
+		pomodoro.CategoryLongBreak, start, end).Scan(&d)
+	return time.Duration(d), err
+}
+
+// CreateTask stores a task, failing if its name is taken
+func (r *pgRepo) CreateTask(t pomodoro.Task) (int64, error) {
+	var id int64
+	err := r.conn().QueryRow(`INSERT INTO task (name, estimate) VALUES($1, $2)
+		ON CONFLICT (name) DO NOTHING RETURNING id`, t.Name, t.Estimate).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("%w: %q", pomodoro.ErrTaskExists, t.Name)
+	}
+	return id, err
+}
+
+// TaskByName returns the task with the given name
+func (r *pgRepo) TaskByName(name string) (pomodoro.Task, error) {
+	var t pomodoro.Task
+	err := r.conn().QueryRow("SELECT id, name, estimate FROM task WHERE name=$1", name).
+		Scan(&t.ID, &t.Name, &t.Estimate)
+	if err == sql.ErrNoRows {
+		return t, fmt.Errorf("%w: %q", pomodoro.ErrTaskNotFound, name)
+	}
+	return t, err
+}
+
+// Tasks returns all the tasks
+func (r *pgRepo) Tasks() ([]pomodoro.Task, error) {
+	rows, err := r.conn().Query("SELECT id, name, estimate FROM task ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var data []pomodoro.Task
+	for rows.Next() {
+		var t pomodoro.Task
+		if err := rows.Scan(&t.ID, &t.Name, &t.Estimate); err != nil {
+			return nil, err
+		}
+		data = append(data, t)
+	}
+	return data, rows.Err()
+}
+
+// TaskTotals sums the pomodoros linked to a task
+func (r *pgRepo) TaskTotals(taskID int64) (int64, time.Duration, error) {
+	stmt := `SELECT count(*) FILTER (WHERE state=$1), coalesce(sum(actual_duration), 0)
+		FROM "interval" WHERE category=$2 AND task_id=$3`
+
+	var (
+		n int64
+		d int64
+	)
+	err := r.conn().QueryRow(stmt, pomodoro.StateDone, pomodoro.CategoryPomodoro, taskID).Scan(&n, &d)
+	return n, time.Duration(d), err
+}
+
+// Delete removes an interval unless it's running
+func (r *pgRepo) Delete(id int64) error {
+	res, err := r.conn().Exec(`DELETE FROM "interval" WHERE id=$1 AND state<>$2`, id, pomodoro.StateRunning)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	var exists bool
+	if err := r.conn().QueryRow(`SELECT exists(SELECT 1 FROM "interval" WHERE id=$1)`, id).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%w: cannot delete interval %d", pomodoro.ErrIntervalAlreadyRunning, id)
+	}
+	return fmt.Errorf("%w: %d", pomodoro.ErrInvalidID, id)
+}
+
+// DeleteOlderThan removes the intervals started before t, except for the
+// last one if it's unfinished. Intervals that never started count as
+// older than any time
+func (r *pgRepo) DeleteOlderThan(t time.Time) (int64, error) {
+	res, err := r.conn().Exec(`DELETE FROM "interval" WHERE (start_time IS NULL OR start_time < $1) AND
+		NOT (id = (SELECT max(id) FROM "interval") AND state IN ($2, $3, $4))`,
+		t.UTC(), pomodoro.StateNotStarted, pomodoro.StateRunning, pomodoro.StatePaused)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Archive moves the intervals to interval_archive in one statement. They
+// keep their IDs, but their tags and events are dropped, as the foreign
+// keys of those tables cascade deletes from interval
+func (r *pgRepo) Archive(olderThan time.Time) (int64, error) {
+	res, err := r.conn().Exec(`WITH moved AS (
+			DELETE FROM "interval"
+			WHERE (start_time IS NULL OR start_time < $1) AND state IN ($2, $3, $4) AND
+			id < (SELECT max(id) FROM "interval")
+			RETURNING `+intervalColumns+`
+		)
+		INSERT INTO "interval_archive" (`+intervalColumns+`) SELECT `+intervalColumns+` FROM moved`,
+		olderThan.UTC(), pomodoro.StateDone, pomodoro.StateCancelled, pomodoro.StateSkipped)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ByRange returns the intervals started in [start, end) by start time
+func (r *pgRepo) ByRange(start, end time.Time) ([]pomodoro.Interval, error) {
+	stmt := `SELECT ` + intervalColumns + ` FROM "interval"
+		WHERE start_time IS NOT NULL AND start_time >= $1 AND start_time < $2
+		ORDER BY start_time`
+
+	return r.query(stmt, start.UTC(), end.UTC())
+}
+
+// Import stores intervals in a single transaction, skipping duplicates
+func (r *pgRepo) Import(intervals []pomodoro.Interval) (int, error) {
+	n := 0
+	err := r.withTx(func(tx *sql.Tx) error {
+		for _, i := range intervals {
+			if i.StartTime.IsZero() {
+				continue
+			}
+
+			var dup bool
+			err := tx.QueryRow(`SELECT exists(SELECT 1 FROM "interval" WHERE start_time=$1 AND category=$2)
+				OR exists(SELECT 1 FROM "interval_archive" WHERE start_time=$1 AND category=$2)`,
+				i.StartTime.UTC(), i.Category).Scan(&dup)
+			if err != nil {
+				return err
+			}
+			if dup {
+				continue
+			}
+
+			_, err = tx.Exec(`INSERT INTO "interval"
+				(start_time, planned_duration, actual_duration, category, state, label,
+				daily_ordinal, interruptions, note, task_id, auto_paused, paused_duration, paused_at,
+				device)
+				VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`,
+				i.StartTime.UTC(), i.PlannedDuration, i.ActualDuration, i.Category, i.State, i.Label,
+				i.DailyOrdinal, i.Interruptions, i.Note, i.TaskID, i.AutoPaused, i.PausedDuration,
+				i.PausedAt.UTC(), i.Device)
+			if err != nil {
+				return fmt.Errorf("importing interval %d: %w", i.ID, err)
+			}
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro"
+)
+
+// readOnlyRepo lets the reads through to the repository it wraps and fails
+// every write with pomodoro.ErrReadOnly. Optional interfaces such as
+// Importer aren't passed through, as they all write
+type readOnlyRepo struct {
+	pomodoro.Repository
+}
+
+// readOnly wraps r so it can't be changed
+func readOnly(r pomodoro.Repository) *readOnlyRepo {
+	return &readOnlyRepo{Repository: r}
+}
+
+func (r *readOnlyRepo) Create(i pomodoro.Interval) (int64, error) {
+	return 0, pomodoro.ErrReadOnly
+}
+
+func (r *readOnlyRepo) Update(i pomodoro.Interval) error {
+	return pomodoro.ErrReadOnly
+}
+
+func (r *readOnlyRepo) UpdateProgress(id, version int64, actual time.Duration, state int) error {
+	return pomodoro.ErrReadOnly
+}
+
+func (r *readOnlyRepo) Delete(id int64) error {
+	return pomodoro.ErrReadOnly
+}
+
+func (r *readOnlyRepo) DeleteOlderThan(t time.Time) (int64, error) {
+	return 0, pomodoro.ErrReadOnly
+}
+
+func (r *readOnlyRepo) Archive(olderThan time.Time) (int64, error) {
+	return 0, pomodoro.ErrReadOnly
+}
+
+func (r *readOnlyRepo) AddTag(intervalID int64, tag string) error {
+	return pomodoro.ErrReadOnly
+}
+
+func (r *readOnlyRepo) AddInterruption(id int64) error {
+	return pomodoro.ErrReadOnly
+}
+
+func (r *readOnlyRepo) SetNote(id int64, note string) error {
+	return pomodoro.ErrReadOnly
+}
+
+func (r *readOnlyRepo) CreateTask(t pomodoro.Task) (int64, error) {
+	return 0, pomodoro.ErrReadOnly
+}
+
+// Close closes the wrapped repository if it needs closing
+func (r *readOnlyRepo) Close() error {
+	if c, ok := r.Repository.(interface{ Close() error }); ok {
+		return c.Close()
+	}
+	return nil
+}
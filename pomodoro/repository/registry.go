@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/snirkop89/pomo/pomodoro"
+)
+
+// ErrUnknownBackend is returned by Open for backends that weren't
+// registered, e.g. because they're left out of the build
+var ErrUnknownBackend = errors.New("unknown repository backend")
+
+// Opener opens a repository at a backend specific location, such as a file
+// path or a connection URL
+type Opener func(path string) (pomodoro.Repository, error)
+
+var (
+	openersMu sync.RWMutex
+	openers   = make(map[string]Opener)
+	// unlocked holds the openers of backends locking their database that
+	// can also open it without the lock
+	unlocked = make(map[string]Opener)
+	// readOnlyOpeners holds the openers of backends able to open their
+	// database read-only
+	readOnlyOpeners = make(map[string]Opener)
+)
+
+// Register makes a backend available to Open by name. Backends register
+// themselves from init. Registering a name twice panics
+func Register(name string, open Opener) {
+	openersMu.Lock()
+	defer openersMu.Unlock()
+
+	if _, dup := openers[name]; dup {
+		panic("repository: Register called twice for backend " + name)
+	}
+	openers[name] = open
+}
+
+// Open opens the repository of the named backend at path
+func Open(backend, path string) (pomodoro.Repository, error) {
+	openersMu.RLock()
+	open, ok := openers[backend]
+	openersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q, available backends are %v", ErrUnknownBackend, backend, Backends())
+	}
+	return open(path)
+}
+
+// RegisterUnlocked makes a backend that locks its database available to
+// OpenUnlocked. Registering a name twice panics
+func RegisterUnlocked(name string, open Opener) {
+	openersMu.Lock()
+	defer openersMu.Unlock()
+
+	if _, dup := unlocked[name]; dup {
+		panic("repository: RegisterUnlocked called twice for backend " + name)
+	}
+	unlocked[name] = open
+}
+
+// OpenUnlocked opens the repository like Open, but without taking the lock
+// that keeps other pomo instances from changing it. It's meant for
+// commands that only read the repository
+func OpenUnlocked(backend, path string) (pomodoro.Repository, error) {
+	openersMu.RLock()
+	open, ok := unlocked[backend]
+	openersMu.RUnlock()
+	if !ok {
+		return Open(backend, path)
+	}
+	return open(path)
+}
+
+// RegisterReadOnly makes a backend able to open its database read-only
+// available to OpenReadOnly. Registering a name twice panics
+func RegisterReadOnly(name string, open Opener) {
+	openersMu.Lock()
+	defer openersMu.Unlock()
+
+	if _, dup := readOnlyOpeners[name]; dup {
+		panic("repository: RegisterReadOnly called twice for backend " + name)
+	}
+	readOnlyOpeners[name] = open
+}
+
+// OpenReadOnly opens the repository for commands that only report on it.
+// Its writes fail with pomodoro.ErrReadOnly. Backends without a read-only
+// mode are opened like OpenUnlocked and guarded the same way
+func OpenReadOnly(backend, path string) (pomodoro.Repository, error) {
+	openersMu.RLock()
+	open, ok := readOnlyOpeners[backend]
+	openersMu.RUnlock()
+	if ok {
+		return open(path)
+	}
+
+	r, err := OpenUnlocked(backend, path)
+	if err != nil {
+		return nil, err
+	}
+	return readOnly(r), nil
+}
+
+// Backends returns the names of the registered backends in order
+func Backends() []string {
+	openersMu.RLock()
+	defer openersMu.RUnlock()
+
+	var names []string
+	for name := range openers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,56 @@
+package repository_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/snirkop89/pomo/pomodoro"
+	"github.com/snirkop89/pomo/pomodoro/repository"
+)
+
+func TestOpen(t *testing.T) {
+	// Backends depend on build tags, postgres needs a server
+	for _, backend := range repository.Backends() {
+		if backend == "postgres" {
+			continue
+		}
+		t.Run(backend, func(t *testing.T) {
+			repo, err := repository.Open(backend, filepath.Join(t.TempDir(), "pomo"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer repo.(interface{ Close() error }).Close()
+
+			if _, err := repo.Last(); !errors.Is(err, pomodoro.ErrNoIntervals) {
+				t.Errorf("expected error %q, got %v", pomodoro.ErrNoIntervals, err)
+			}
+		})
+	}
+
+	t.Run("Unknown", func(t *testing.T) {
+		repo, err := repository.Open("nope", "pomo.db")
+		if !errors.Is(err, repository.ErrUnknownBackend) {
+			t.Errorf("expected error %q, got %v", repository.ErrUnknownBackend, err)
+		}
+		if repo != nil {
+			t.Errorf("expected no repository, got %T", repo)
+		}
+	})
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	// The memory backend has no read-only mode, so it's guarded instead
+	repo, err := repository.OpenReadOnly("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.(interface{ Close() error }).Close()
+
+	if _, err := repo.Create(pomodoro.Interval{}); !errors.Is(err, pomodoro.ErrReadOnly) {
+		t.Errorf("expected error %q, got %v", pomodoro.ErrReadOnly, err)
+	}
+	if _, err := repo.Last(); !errors.Is(err, pomodoro.ErrNoIntervals) {
+		t.Errorf("expected error %q, got %v", pomodoro.ErrNoIntervals, err)
+	}
+}
@@ -0,0 +1,1185 @@
+// Package repotest checks that a pomodoro.Repository behaves like the
+// others. Every backend runs the same suite, so a new backend is done
+// once it passes TestRepository.
+package repotest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro"
+)
+
+// TestRepository runs the conformance suite. newRepo returns an empty
+// repository and a function releasing it, and is called for each subtest
+func TestRepository(t *testing.T, newRepo func(t *testing.T) (pomodoro.Repository, func())) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, repo pomodoro.Repository)
+	}{
+		{"CreateAndByID", testCreateAndByID},
+		{"CreateBatch", testCreateBatch},
+		{"Update", testUpdate},
+		{"UpdateProgress", testUpdateProgress},
+		{"Conflict", testConflict},
+		{"Last", testLast},
+		{"Breaks", testBreaks},
+		{"Find", testFind},
+		{"ByRange", testByRange},
+		{"NeverStarted", testNeverStarted},
+		{"Pages", testPages},
+		{"Search", testSearch},
+		{"Summaries", testSummaries},
+		{"PausedDuration", testPausedDuration},
+		{"TaggedTotals", testTaggedTotals},
+		{"LabelSummary", testLabelSummary},
+		{"DeviceSummary", testDeviceSummary},
+		{"CompletedPerDay", testCompletedPerDay},
+		{"StateCounts", testStateCounts},
+		{"Delete", testDelete},
+		{"DeleteOlderThan", testDeleteOlderThan},
+		{"Archive", testArchive},
+		{"ArchivedIDs", testArchivedIDs},
+		{"Tasks", testTasks},
+		{"Errors", testErrors},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			repo, cleanup := newRepo(t)
+			defer cleanup()
+			tc.fn(t, repo)
+		})
+	}
+}
+
+// day is the day most intervals of the suite start on. It's in a zone
+// ahead of UTC, so bucketing by day in UTC gives different results
+var day = time.Date(2023, time.March, 14, 0, 0, 0, 0, time.FixedZone("UTC+5", 5*60*60))
+
+func create(t *testing.T, repo pomodoro.Repository, i pomodoro.Interval) pomodoro.Interval {
+	t.Helper()
+
+	id, err := repo.Create(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.ID = id
+	return i
+}
+
+func done(start time.Time, category string, d time.Duration) pomodoro.Interval {
+	return pomodoro.Interval{
+		StartTime:       start,
+		PlannedDuration: d,
+		ActualDuration:  d,
+		Category:        category,
+		State:           pomodoro.StateDone,
+	}
+}
+
+// sameInterval reports whether a and b are equal, comparing times as
+// instants as repositories may return them in another location
+func sameInterval(a, b pomodoro.Interval) bool {
+	if !a.StartTime.Equal(b.StartTime) || !a.PausedAt.Equal(b.PausedAt) {
+		return false
+	}
+	a.StartTime, b.StartTime = time.Time{}, time.Time{}
+	a.PausedAt, b.PausedAt = time.Time{}, time.Time{}
+	return a == b
+}
+
+func ids(intervals []pomodoro.Interval) []int64 {
+	var ids []int64
+	for _, i := range intervals {
+		ids = append(ids, i.ID)
+	}
+	return ids
+}
+
+func sameIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k := range a {
+		if a[k] != b[k] {
+			return false
+		}
+	}
+	return true
+}
+
+func testCreateAndByID(t *testing.T, repo pomodoro.Repository) {
+	exp := create(t, repo, pomodoro.Interval{
+		StartTime:       day.Add(9 * time.Hour),
+		PlannedDuration: 25 * time.Minute,
+		ActualDuration:  10 * time.Minute,
+		Category:        pomodoro.CategoryPomodoro,
+		State:           pomodoro.StateRunning,
+		Label:           "write report",
+		DailyOrdinal:    3,
+		Device:          "desk",
+	})
+	other := create(t, repo, pomodoro.Interval{Category: pomodoro.CategoryShortBreak})
+	if other.ID <= exp.ID {
+		t.Errorf("expected IDs to increase, got %d after %d", other.ID, exp.ID)
+	}
+
+	got, err := repo.ByID(exp.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameInterval(exp, got) {
+		t.Errorf("expected %+v, got %+v", exp, got)
+	}
+}
+
+func testCreateBatch(t *testing.T, repo pomodoro.Repository) {
+	bc, ok := repo.(pomodoro.BatchCreator)
+	if !ok {
+		t.Skip("repository doesn't create batches")
+	}
+	first := create(t, repo, done(day.Add(8*time.Hour), pomodoro.CategoryPomodoro, 25*time.Minute))
+
+	batch := []pomodoro.Interval{
+		done(day.Add(11*time.Hour), pomodoro.CategoryPomodoro, 25*time.Minute),
+		done(day.Add(9*time.Hour), pomodoro.CategoryShortBreak, 5*time.Minute),
+		done(day.Add(10*time.Hour), pomodoro.CategoryLongBreak, 15*time.Minute),
+	}
+	batch[0].Label = "report"
+	ids, err := bc.CreateBatch(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != len(batch) {
+		t.Fatalf("expected %d IDs, got %v", len(batch), ids)
+	}
+
+	// IDs follow the input order
+	for k, id := range ids {
+		if id <= first.ID || (k > 0 && id <= ids[k-1]) {
+			t.Errorf("expected increasing IDs after %d, got %v", first.ID, ids)
+		}
+		got, err := repo.ByID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		batch[k].ID = id
+		if !sameInterval(batch[k], got) {
+			t.Errorf("expected %+v, got %+v", batch[k], got)
+		}
+	}
+
+	if ids, err := bc.CreateBatch(nil); err != nil || len(ids) != 0 {
+		t.Errorf("expected no IDs for an empty batch, got %v, %v", ids, err)
+	}
+}
+
+func testUpdate(t *testing.T, repo pomodoro.Repository) {
+	i := create(t, repo, pomodoro.Interval{
+		PlannedDuration: 25 * time.Minute,
+		Category:        pomodoro.CategoryPomodoro,
+		State:           pomodoro.StateNotStarted,
+	})
+	if err := repo.AddInterruption(i.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SetNote(i.ID, "phone call"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Interruptions and notes are only changed by their own methods
+	i.StartTime = day.Add(10 * time.Hour)
+	i.ActualDuration = 5 * time.Minute
+	i.State = pomodoro.StatePaused
+	i.Label = "review"
+	i.AutoPaused = true
+	i.PausedDuration = time.Minute
+	i.PausedAt = day.Add(10*time.Hour + 6*time.Minute)
+	i.Interruptions = 7
+	i.Note = "overwritten"
+	if err := repo.Update(i); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := repo.ByID(i.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.Interruptions = 1
+	i.Note = "phone call"
+	i.Version = 1
+	if !sameInterval(i, got) {
+		t.Errorf("expected %+v, got %+v", i, got)
+	}
+}
+
+func testUpdateProgress(t *testing.T, repo pomodoro.Repository) {
+	i := create(t, repo, pomodoro.Interval{
+		StartTime:       day.Add(10 * time.Hour),
+		PlannedDuration: 25 * time.Minute,
+		Category:        pomodoro.CategoryPomodoro,
+		State:           pomodoro.StateRunning,
+		Label:           "review",
+	})
+
+	// Only the elapsed time and state change
+	if err := repo.UpdateProgress(i.ID, i.Version, 25*time.Minute, pomodoro.StateDone); err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.ByID(i.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.ActualDuration = 25 * time.Minute
+	i.State = pomodoro.StateDone
+	i.Version = 1
+	if !sameInterval(i, got) {
+		t.Errorf("expected %+v, got %+v", i, got)
+	}
+
+	events, err := repo.EventsFor(i.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].From != pomodoro.StateRunning || events[0].To != pomodoro.StateDone {
+		t.Errorf("expected a single event from running to done, got %+v", events)
+	}
+}
+
+// testConflict interleaves two writers updating the same interval, like
+// the TUI ticking while a CLI command pauses it
+func testConflict(t *testing.T, repo pomodoro.Repository) {
+	i := create(t, repo, pomodoro.Interval{
+		StartTime:       day.Add(10 * time.Hour),
+		PlannedDuration: 25 * time.Minute,
+		Category:        pomodoro.CategoryPomodoro,
+		State:           pomodoro.StateRunning,
+	})
+	ticker, err := repo.ByID(i.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pauser, err := repo.ByID(i.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pauser.State = pomodoro.StatePaused
+	if err := repo.Update(pauser); err != nil {
+		t.Fatal(err)
+	}
+
+	// The ticker's copy is stale, so neither kind of update may resurrect
+	// the running state
+	ticker.ActualDuration = time.Minute
+	if err := repo.Update(ticker); !errors.Is(err, pomodoro.ErrConflict) {
+		t.Errorf("Update: expected error %q, got %v", pomodoro.ErrConflict, err)
+	}
+	err = repo.UpdateProgress(ticker.ID, ticker.Version, time.Minute, pomodoro.StateRunning)
+	if !errors.Is(err, pomodoro.ErrConflict) {
+		t.Errorf("UpdateProgress: expected error %q, got %v", pomodoro.ErrConflict, err)
+	}
+
+	got, err := repo.ByID(i.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pauser.Version++
+	if !sameInterval(pauser, got) {
+		t.Errorf("expected %+v, got %+v", pauser, got)
+	}
+
+	// Reading it again gets the current version
+	if err := repo.UpdateProgress(got.ID, got.Version, time.Minute, got.State); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = repo.ByID(i.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != pauser.Version+1 {
+		t.Errorf("expected version %d, got %d", pauser.Version+1, got.Version)
+	}
+}
+
+func testLast(t *testing.T, repo pomodoro.Repository) {
+	if _, err := repo.Last(); !errors.Is(err, pomodoro.ErrNoIntervals) {
+		t.Errorf("expected error %q, got %v", pomodoro.ErrNoIntervals, err)
+	}
+
+	// The last interval is the last created, whatever its start time
+	create(t, repo, done(day.Add(11*time.Hour), pomodoro.CategoryPomodoro, 25*time.Minute))
+	exp := create(t, repo, done(day.Add(9*time.Hour), pomodoro.CategoryShortBreak, 5*time.Minute))
+
+	got, err := repo.Last()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != exp.ID {
+		t.Errorf("expected interval %d, got %d", exp.ID, got.ID)
+	}
+}
+
+func testBreaks(t *testing.T, repo pomodoro.Repository) {
+	if breaks, err := repo.Breaks(3); err != nil || len(breaks) != 0 {
+		t.Errorf("expected no breaks, got %v, %v", breaks, err)
+	}
+
+	var exp []int64
+	for k, c := range []string{pomodoro.CategoryPomodoro, pomodoro.CategoryShortBreak,
+		pomodoro.CategoryPomodoro, pomodoro.CategoryLongBreak, pomodoro.CategorySnooze,
+		pomodoro.CategoryShortBreak} {
+		i := create(t, repo, done(day.Add(time.Duration(k)*time.Hour), c, 5*time.Minute))
+		if c == pomodoro.CategoryShortBreak || c == pomodoro.CategoryLongBreak {
+			exp = append([]int64{i.ID}, exp...)
+		}
+	}
+
+	testCases := []struct {
+		n   int
+		exp []int64
+	}{
+		{1, exp[:1]},
+		{2, exp[:2]},
+		{10, exp},
+	}
+	for _, tc := range testCases {
+		breaks, err := repo.Breaks(tc.n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(breaks); !sameIDs(got, tc.exp) {
+			t.Errorf("Breaks(%d): expected %v, got %v", tc.n, tc.exp, got)
+		}
+	}
+}
+
+func testFind(t *testing.T, repo pomodoro.Repository) {
+	p1 := create(t, repo, done(day.Add(9*time.Hour), pomodoro.CategoryPomodoro, 25*time.Minute))
+	b := create(t, repo, done(day.Add(10*time.Hour), pomodoro.CategoryShortBreak, 5*time.Minute))
+	p2 := create(t, repo, pomodoro.Interval{
+		StartTime:       day.Add(11 * time.Hour),
+		PlannedDuration: 25 * time.Minute,
+		Category:        pomodoro.CategoryPomodoro,
+		State:           pomodoro.StateCancelled,
+		Label:           "Write Report",
+	})
+
+	testCases := []struct {
+		name string
+		f    pomodoro.Filter
+		exp  []int64
+	}{
+		{"All", pomodoro.Filter{}, []int64{p1.ID, b.ID, p2.ID}},
+		{"Desc", pomodoro.Filter{Order: pomodoro.OrderDesc}, []int64{p2.ID, b.ID, p1.ID}},
+		{"Limit", pomodoro.Filter{Order: pomodoro.OrderDesc, Limit: 2}, []int64{p2.ID, b.ID}},
+		{"Category", pomodoro.Filter{Categories: []string{pomodoro.CategoryPomodoro}}, []int64{p1.ID, p2.ID}},
+		{"State", pomodoro.Filter{States: []int{pomodoro.StateCancelled}}, []int64{p2.ID}},
+		{"Label", pomodoro.Filter{Label: "Report"}, []int64{p2.ID}},
+		{"Range", pomodoro.Filter{From: day.Add(10 * time.Hour), To: day.Add(11 * time.Hour)}, []int64{b.ID}},
+	}
+	for _, tc := range testCases {
+		found, err := repo.Find(tc.f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(found); !sameIDs(got, tc.exp) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.exp, got)
+		}
+	}
+}
+
+func testByRange(t *testing.T, repo pomodoro.Repository) {
+	late := create(t, repo, done(day.Add(15*time.Hour), pomodoro.CategoryPomodoro, 25*time.Minute))
+	early := create(t, repo, done(day.Add(9*time.Hour), pomodoro.CategoryPomodoro, 25*time.Minute))
+	create(t, repo, pomodoro.Interval{Category: pomodoro.CategoryShortBreak})
+	create(t, repo, done(day.AddDate(0, 0, 1), pomodoro.CategoryPomodoro, 25*time.Minute))
+
+	found, err := repo.ByRange(day, day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []int64{early.ID, late.ID}
+	if got := ids(found); !sameIDs(got, exp) {
+		t.Errorf("expected %v, got %v", exp, got)
+	}
+}
+
+// testNeverStarted checks an interval created before it starts is left out
+// of every report until it does
+func testNeverStarted(t *testing.T, repo pomodoro.Repository) {
+	create(t, repo, done(day.Add(9*time.Hour), pomodoro.CategoryPomodoro, 25*time.Minute))
+	pending := create(t, repo, pomodoro.Interval{
+		PlannedDuration: 25 * time.Minute,
+		Category:        pomodoro.CategoryPomodoro,
+	})
+	if err := repo.AddInterruption(pending.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := repo.ByID(pending.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.StartTime.IsZero() {
+		t.Errorf("expected no start time, got %s", got.StartTime)
+	}
+
+	end := day.AddDate(0, 0, 1)
+	found, err := repo.ByRange(day, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 {
+		t.Errorf("expected 1 interval in range, got %d", len(found))
+	}
+	if d, err := repo.CategorySummary(day, "%", 0); err != nil || d != 25*time.Minute {
+		t.Errorf("expected summary %v, got %v, %v", 25*time.Minute, d, err)
+	}
+	totals, err := repo.DailyTotals(day, end, "%", 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(totals) != 1 || totals[day.Format(pomodoro.DayLayout)] != 25*time.Minute {
+		t.Errorf("expected 25m on %s only, got %v", day.Format(pomodoro.DayLayout), totals)
+	}
+	if counts, err := repo.StateCounts(day, end, pomodoro.CategoryPomodoro); err != nil || counts[pomodoro.StateNotStarted] != 0 {
+		t.Errorf("expected no pending pomodoros on the day, got %v, %v", counts, err)
+	}
+	if found, err := repo.Find(pomodoro.Filter{From: day, To: end}); err != nil || len(found) != 1 {
+		t.Errorf("expected only the done pomodoro found on the day, got %d, %v", len(found), err)
+	}
+
+	// Starting it brings it into the reports
+	got.StartTime = day.Add(10 * time.Hour)
+	got.State = pomodoro.StateRunning
+	if err := repo.Update(got); err != nil {
+		t.Fatal(err)
+	}
+	counts, err := repo.StateCounts(day, end, pomodoro.CategoryPomodoro)
+	if err != nil || counts[pomodoro.StateRunning] != 1 {
+		t.Errorf("expected the started pomodoro counted, got %v, %v", counts, err)
+	}
+	if found, err := repo.Find(pomodoro.Filter{From: day, To: end}); err != nil || len(found) != 2 {
+		t.Errorf("expected the started pomodoro found, got %d, %v", len(found), err)
+	}
+}
+
+// testPages checks paging the intervals of a category with Find, the
+// latest started first
+func testPages(t *testing.T, repo pomodoro.Repository) {
+	long := func(h float64) pomodoro.Interval {
+		start := day.Add(time.Duration(h * float64(time.Hour)))
+		return create(t, repo, done(start, pomodoro.CategoryLongBreak, 15*time.Minute))
+	}
+	a, b, c, d, e := long(1), long(2), long(3), long(4), long(5)
+	create(t, repo, done(day.Add(6*time.Hour), pomodoro.CategoryPomodoro, 25*time.Minute))
+	// Started with c, it's listed after it
+	tie := long(3)
+
+	list := func(limit, offset int) []int64 {
+		t.Helper()
+		page, err := repo.Find(pomodoro.Filter{
+			Categories: []string{pomodoro.CategoryLongBreak},
+			Order:      pomodoro.OrderLatestFirst,
+			Limit:      limit,
+			Offset:     offset,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ids(page)
+	}
+
+	first := list(3, 0)
+	if exp := []int64{e.ID, d.ID, c.ID}; !sameIDs(exp, first) {
+		t.Errorf("first page: expected %v, got %v", exp, first)
+	}
+
+	// Intervals written between pages that don't start later don't shift
+	// the first one
+	newTie := long(3)
+	older := long(0.5)
+	create(t, repo, done(day.Add(10*time.Hour), pomodoro.CategoryPomodoro, 25*time.Minute))
+
+	second := list(3, 3)
+	if exp := []int64{tie.ID, newTie.ID, b.ID}; !sameIDs(exp, second) {
+		t.Errorf("second page: expected %v, got %v", exp, second)
+	}
+	for _, id := range second {
+		for _, seen := range first {
+			if id == seen {
+				t.Errorf("interval %d is on both pages", id)
+			}
+		}
+	}
+	if exp := []int64{a.ID, older.ID}; !sameIDs(exp, list(3, 6)) {
+		t.Errorf("last page: expected %v, got %v", exp, list(3, 6))
+	}
+	if got := list(3, 8); len(got) != 0 {
+		t.Errorf("expected no intervals past the end, got %v", got)
+	}
+
+	// No limit means every interval past the offset
+	if got := list(0, 0); len(got) != 8 {
+		t.Errorf("expected all 8 long breaks, got %v", got)
+	}
+	if exp := []int64{a.ID, older.ID}; !sameIDs(exp, list(0, 6)) {
+		t.Errorf("expected the last %v, got %v", exp, list(0, 6))
+	}
+}
+
+func testSearch(t *testing.T, repo pomodoro.Repository) {
+	labelled := func(hour int, label, note string) pomodoro.Interval {
+		i := done(day.Add(time.Duration(hour)*time.Hour), pomodoro.CategoryPomodoro, 25*time.Minute)
+		i.Label = label
+		i = create(t, repo, i)
+		// Create leaves notes out, like Update
+		if note != "" {
+			if err := repo.SetNote(i.ID, note); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return i
+	}
+	report := labelled(9, "Report", "")
+	draft := labelled(10, "", "draft the REPORT")
+	percent := labelled(11, "100% done", "")
+	underscore := labelled(12, "snake_case", "")
+	labelled(13, "snakeXcase", "")
+	backslash := labelled(14, "", `back\slash`)
+	umlaut := labelled(15, "Über", "")
+
+	tests := []struct {
+		name  string
+		query string
+		limit int
+		exp   []int64
+	}{
+		{"IgnoresCase", "report", 0, []int64{draft.ID, report.ID}},
+		{"Limit", "report", 1, []int64{draft.ID}},
+		{"Percent", "%", 0, []int64{percent.ID}},
+		{"Underscore", "_", 0, []int64{underscore.ID}},
+		{"UnderscoreInWord", "snake_case", 0, []int64{underscore.ID}},
+		{"Backslash", `\`, 0, []int64{backslash.ID}},
+		{"IgnoresCaseBeyondASCII", "üBER", 0, []int64{umlaut.ID}},
+		{"NoMatch", "slides", 0, nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			found, err := repo.Find(pomodoro.Filter{
+				Text:  tc.query,
+				Order: pomodoro.OrderLatestFirst,
+				Limit: tc.limit,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(found); !sameIDs(got, tc.exp) {
+				t.Errorf("expected %v, got %v", tc.exp, got)
+			}
+		})
+	}
+}
+
+func testSummaries(t *testing.T, repo pomodoro.Repository) {
+	next := day.AddDate(0, 0, 1)
+	for _, i := range []pomodoro.Interval{
+		// Late on the day before in day's zone, although it's day in UTC
+		done(day.Add(-time.Hour), pomodoro.CategoryPomodoro, 25*time.Minute),
+		done(day.Add(9*time.Hour), pomodoro.CategoryPomodoro, 25*time.Minute),
+		done(day.Add(10*time.Hour), pomodoro.CategoryShortBreak, 5*time.Minute),
+		done(day.Add(11*time.Hour), pomodoro.CategoryPomodoro, 20*time.Minute),
+		done(day.Add(12*time.Hour), pomodoro.CategoryLongBreak, 15*time.Minute),
+		{
+			StartTime:       day.Add(13 * time.Hour),
+			PlannedDuration: 25 * time.Minute,
+			ActualDuration:  22 * time.Minute,
+			Category:        pomodoro.CategoryPomodoro,
+			State:           pomodoro.StateCancelled,
+		},
+		// Early on the next day in day's zone, although it's day in UTC
+		done(next.Add(time.Hour), pomodoro.CategoryPomodoro, 25*time.Minute),
+		{Category: pomodoro.CategoryPomodoro},
+	} {
+		create(t, repo, i)
+	}
+
+	summaries := []struct {
+		name   string
+		filter string
+		ratio  float64
+		exp    time.Duration
+	}{
+		{"Pomodoro", pomodoro.CategoryPomodoro, 0, 45 * time.Minute},
+		{"Breaks", "%Break", 0, 20 * time.Minute},
+		{"CancelledRatio", pomodoro.CategoryPomodoro, 0.8, 67 * time.Minute},
+		{"CancelledBelowRatio", pomodoro.CategoryPomodoro, 0.9, 45 * time.Minute},
+	}
+	for _, s := range summaries {
+		got, err := repo.CategorySummary(day, s.filter, s.ratio)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != s.exp {
+			t.Errorf("CategorySummary %s: expected %v, got %v", s.name, s.exp, got)
+		}
+	}
+
+	totals, err := repo.DailyTotals(day.AddDate(0, 0, -1), next.AddDate(0, 0, 1), pomodoro.CategoryPomodoro, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expTotals := map[string]time.Duration{
+		day.AddDate(0, 0, -1).Format(pomodoro.DayLayout): 25 * time.Minute,
+		day.Format(pomodoro.DayLayout):                   45 * time.Minute,
+		next.Format(pomodoro.DayLayout):                  25 * time.Minute,
+	}
+	if len(totals) != len(expTotals) {
+		t.Errorf("DailyTotals: expected %v, got %v", expTotals, totals)
+	}
+	for d, exp := range expTotals {
+		if totals[d] != exp {
+			t.Errorf("DailyTotals %s: expected %v, got %v", d, exp, totals[d])
+		}
+	}
+
+	total, err := repo.TotalCompleted()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 4 {
+		t.Errorf("TotalCompleted: expected 4, got %d", total)
+	}
+	counts, err := repo.StateCounts(day, day.AddDate(0, 0, 1), pomodoro.CategoryPomodoro)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts[pomodoro.StateCancelled] != 1 {
+		t.Errorf("StateCounts: expected 1 cancelled, got %d", counts[pomodoro.StateCancelled])
+	}
+	// Work since the long break is the cancelled pomodoro, then the one
+	// on the next day which isn't counted for day
+	work, err := repo.WorkSinceLongBreak(day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if work != 22*time.Minute {
+		t.Errorf("WorkSinceLongBreak: expected %v, got %v", 22*time.Minute, work)
+	}
+}
+
+func testPausedDuration(t *testing.T, repo pomodoro.Repository) {
+	pausedOn := func(day time.Time) time.Duration {
+		t.Helper()
+		found, err := repo.Find(pomodoro.Filter{From: day, To: day.AddDate(0, 0, 1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var d time.Duration
+		for _, i := range found {
+			d += i.PausedDuration
+		}
+		return d
+	}
+
+	// Intervals that never paused add nothing
+	create(t, repo, done(day.Add(8*time.Hour), pomodoro.CategoryPomodoro, 25*time.Minute))
+	if d := pausedOn(day); d != 0 {
+		t.Errorf("expected no paused time, got %v", d)
+	}
+
+	// Paused time is recorded as intervals run
+	paused := func(start time.Time, d time.Duration) {
+		i := create(t, repo, done(start, pomodoro.CategoryPomodoro, 25*time.Minute))
+		i.PausedDuration = d
+		if err := repo.Update(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	paused(day.Add(9*time.Hour), 3*time.Minute)
+	paused(day.Add(10*time.Hour), 4*time.Minute)
+	paused(day.AddDate(0, 0, 1).Add(9*time.Hour), time.Minute)
+
+	if d := pausedOn(day); d != 7*time.Minute {
+		t.Errorf("expected 7m paused, got %v", d)
+	}
+	if d := pausedOn(day.AddDate(0, 0, -1)); d != 0 {
+		t.Errorf("expected no paused time the day before, got %v", d)
+	}
+}
+
+func testTaggedTotals(t *testing.T, repo pomodoro.Repository) {
+	tagged := func(h int, d time.Duration, tags ...string) {
+		i := create(t, repo, done(day.Add(time.Duration(h)*time.Hour), pomodoro.CategoryPomodoro, d))
+		for _, tag := range tags {
+			if err := repo.AddTag(i.ID, tag); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	tagged(9, 25*time.Minute, "client-a", "deep", "review")
+	tagged(10, 20*time.Minute, "client-a")
+	tagged(11, 15*time.Minute, "client-b")
+	tagged(12, 10*time.Minute)
+	tagged(33, 5*time.Minute, "client-a", "deep")
+
+	tests := []struct {
+		tag string
+		exp map[string]time.Duration
+	}{
+		{"", map[string]time.Duration{
+			day.Format(pomodoro.DayLayout):                  70 * time.Minute,
+			day.AddDate(0, 0, 1).Format(pomodoro.DayLayout): 5 * time.Minute,
+		}},
+		// Intervals with other tags too are summed once
+		{"client-a", map[string]time.Duration{
+			day.Format(pomodoro.DayLayout):                  45 * time.Minute,
+			day.AddDate(0, 0, 1).Format(pomodoro.DayLayout): 5 * time.Minute,
+		}},
+		{"client-b", map[string]time.Duration{day.Format(pomodoro.DayLayout): 15 * time.Minute}},
+		{"unused", map[string]time.Duration{}},
+	}
+	for _, tc := range tests {
+		got, err := repo.DailyTotals(day, day.AddDate(0, 0, 2), pomodoro.CategoryPomodoro, 0, tc.tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(tc.exp) {
+			t.Errorf("tag %q: expected %v, got %v", tc.tag, tc.exp, got)
+			continue
+		}
+		for d, exp := range tc.exp {
+			if got[d] != exp {
+				t.Errorf("tag %q: expected %v, got %v", tc.tag, tc.exp, got)
+				break
+			}
+		}
+	}
+}
+
+func testLabelSummary(t *testing.T, repo pomodoro.Repository) {
+	labelled := func(h int, category, label string, state int, d time.Duration) {
+		i := done(day.Add(time.Duration(h)*time.Hour), category, d)
+		i.Label = label
+		i.State = state
+		create(t, repo, i)
+	}
+	labelled(9, pomodoro.CategoryPomodoro, "report", pomodoro.StateDone, 25*time.Minute)
+	labelled(10, pomodoro.CategoryPomodoro, "report", pomodoro.StateDone, 25*time.Minute)
+	labelled(11, pomodoro.CategoryPomodoro, "review", pomodoro.StateDone, time.Hour)
+	labelled(12, pomodoro.CategoryPomodoro, "", pomodoro.StateDone, 25*time.Minute)
+	// Only done pomodoros in the range count
+	labelled(13, pomodoro.CategoryPomodoro, "report", pomodoro.StateCancelled, 20*time.Minute)
+	labelled(14, pomodoro.CategoryShortBreak, "report", pomodoro.StateDone, 5*time.Minute)
+	labelled(14, pomodoro.CategorySnooze, "report", pomodoro.StateDone, 5*time.Minute)
+	labelled(30, pomodoro.CategoryPomodoro, "report", pomodoro.StateDone, 25*time.Minute)
+
+	got, err := repo.LabelSummary(day, day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []pomodoro.LabelTotal{
+		{Label: "review", Pomodoros: 1, FocusTime: time.Hour},
+		{Label: "report", Pomodoros: 2, FocusTime: 50 * time.Minute},
+		{Label: pomodoro.NoLabel, Pomodoros: 1, FocusTime: 25 * time.Minute},
+	}
+	if len(got) != len(exp) {
+		t.Fatalf("expected %+v, got %+v", exp, got)
+	}
+	for k := range exp {
+		if got[k] != exp[k] {
+			t.Errorf("expected %+v, got %+v", exp, got)
+			break
+		}
+	}
+
+	if got, err := repo.LabelSummary(day.AddDate(0, 0, 2), day.AddDate(0, 0, 3)); err != nil || len(got) != 0 {
+		t.Errorf("expected no totals, got %+v, %v", got, err)
+	}
+}
+
+func testDeviceSummary(t *testing.T, repo pomodoro.Repository) {
+	recorded := func(h int, category, device string, state int, d time.Duration) {
+		i := done(day.Add(time.Duration(h)*time.Hour), category, d)
+		i.Device = device
+		i.State = state
+		create(t, repo, i)
+	}
+	recorded(9, pomodoro.CategoryPomodoro, "desk", pomodoro.StateDone, 25*time.Minute)
+	recorded(10, pomodoro.CategoryPomodoro, "desk", pomodoro.StateDone, 25*time.Minute)
+	recorded(11, pomodoro.CategoryPomodoro, "laptop", pomodoro.StateDone, time.Hour)
+	recorded(12, pomodoro.CategoryPomodoro, "", pomodoro.StateDone, 25*time.Minute)
+	// Only done pomodoros in the range count
+	recorded(13, pomodoro.CategoryPomodoro, "desk", pomodoro.StateCancelled, 20*time.Minute)
+	recorded(14, pomodoro.CategoryShortBreak, "desk", pomodoro.StateDone, 5*time.Minute)
+	recorded(30, pomodoro.CategoryPomodoro, "laptop", pomodoro.StateDone, 25*time.Minute)
+
+	got, err := repo.DeviceSummary(day, day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := map[string]time.Duration{"desk": 50 * time.Minute, "laptop": time.Hour, "": 25 * time.Minute}
+	if len(got) != len(exp) {
+		t.Fatalf("expected %v, got %v", exp, got)
+	}
+	for device, d := range exp {
+		if got[device] != d {
+			t.Errorf("expected %v, got %v", exp, got)
+			break
+		}
+	}
+
+	if got, err := repo.DeviceSummary(day.AddDate(0, 0, 2), day.AddDate(0, 0, 3)); err != nil || len(got) != 0 {
+		t.Errorf("expected no totals, got %v, %v", got, err)
+	}
+}
+
+func testCompletedPerDay(t *testing.T, repo pomodoro.Repository) {
+	start := time.Date(2023, time.March, 1, 0, 0, 0, 0, day.Location())
+	end := start.AddDate(0, 1, 0)
+	pomodoros := []time.Time{
+		start.Add(-time.Minute),
+		start.Add(9 * time.Hour),
+		start.Add(14 * time.Hour),
+		start.AddDate(0, 0, 2).Add(9 * time.Hour),
+		// The previous day in UTC
+		start.AddDate(0, 0, 14).Add(2 * time.Hour),
+		start.AddDate(0, 0, 30).Add(23 * time.Hour),
+		end,
+	}
+	for _, s := range pomodoros {
+		create(t, repo, done(s, pomodoro.CategoryPomodoro, 25*time.Minute))
+	}
+	// Breaks and unfinished pomodoros don't count
+	create(t, repo, done(start.Add(10*time.Hour), pomodoro.CategoryShortBreak, 5*time.Minute))
+	create(t, repo, pomodoro.Interval{
+		StartTime:       start.AddDate(0, 0, 2).Add(10 * time.Hour),
+		PlannedDuration: 25 * time.Minute,
+		ActualDuration:  25 * time.Minute,
+		Category:        pomodoro.CategoryPomodoro,
+		State:           pomodoro.StateCancelled,
+	})
+
+	counts, err := repo.CompletedPerDay(start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := map[string]int{"2023-03-01": 2, "2023-03-03": 1, "2023-03-15": 1, "2023-03-31": 1}
+	if len(counts) != len(exp) {
+		t.Errorf("expected %v, got %v", exp, counts)
+	}
+	for d, n := range exp {
+		if counts[d] != n {
+			t.Errorf("%s: expected %d, got %d", d, n, counts[d])
+		}
+	}
+
+	// Days are bucketed like Find
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		found, err := repo.Find(pomodoro.Filter{
+			From:       d,
+			To:         d.AddDate(0, 0, 1),
+			Categories: []string{pomodoro.CategoryPomodoro},
+			States:     []int{pomodoro.StateDone},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := counts[d.Format(pomodoro.DayLayout)]; got != len(found) {
+			t.Errorf("%s: expected %d like Find, got %d", d.Format(pomodoro.DayLayout), len(found), got)
+		}
+	}
+
+	if counts, err := repo.CompletedPerDay(end, start); err != nil || len(counts) != 0 {
+		t.Errorf("expected no counts for an empty range, got %v, %v", counts, err)
+	}
+}
+
+func testStateCounts(t *testing.T, repo pomodoro.Repository) {
+	ended := func(h int, category string, state int) {
+		i := done(day.Add(time.Duration(h)*time.Hour), category, 25*time.Minute)
+		i.State = state
+		create(t, repo, i)
+	}
+	ended(9, pomodoro.CategoryPomodoro, pomodoro.StateDone)
+	ended(10, pomodoro.CategoryPomodoro, pomodoro.StateDone)
+	ended(11, pomodoro.CategoryPomodoro, pomodoro.StateCancelled)
+	ended(12, pomodoro.CategoryPomodoro, pomodoro.StateSkipped)
+	// Other categories and days don't count
+	ended(13, pomodoro.CategoryShortBreak, pomodoro.StateDone)
+	ended(30, pomodoro.CategoryPomodoro, pomodoro.StateDone)
+	// Neither do pomodoros that never started
+	create(t, repo, pomodoro.Interval{
+		PlannedDuration: 25 * time.Minute,
+		Category:        pomodoro.CategoryPomodoro,
+		State:           pomodoro.StateNotStarted,
+	})
+
+	got, err := repo.StateCounts(day, day.AddDate(0, 0, 1), pomodoro.CategoryPomodoro)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := map[int]int{pomodoro.StateDone: 2, pomodoro.StateCancelled: 1, pomodoro.StateSkipped: 1}
+	if len(got) != len(exp) {
+		t.Fatalf("expected %v, got %v", exp, got)
+	}
+	for state, n := range exp {
+		if got[state] != n {
+			t.Errorf("expected %v, got %v", exp, got)
+			break
+		}
+	}
+
+	if got, err := repo.StateCounts(day.AddDate(0, 0, 2), day.AddDate(0, 0, 3), pomodoro.CategoryPomodoro); err != nil || len(got) != 0 {
+		t.Errorf("expected no counts, got %v, %v", got, err)
+	}
+}
+
+func testDelete(t *testing.T, repo pomodoro.Repository) {
+	i := create(t, repo, done(day.Add(9*time.Hour), pomodoro.CategoryPomodoro, 25*time.Minute))
+	running := create(t, repo, pomodoro.Interval{
+		StartTime: day.Add(10 * time.Hour),
+		Category:  pomodoro.CategoryPomodoro,
+		State:     pomodoro.StateRunning,
+	})
+
+	if err := repo.Delete(running.ID); !errors.Is(err, pomodoro.ErrIntervalAlreadyRunning) {
+		t.Errorf("expected error %q, got %v", pomodoro.ErrIntervalAlreadyRunning, err)
+	}
+	if err := repo.Delete(i.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.ByID(i.ID); !errors.Is(err, pomodoro.ErrInvalidID) {
+		t.Errorf("expected error %q, got %v", pomodoro.ErrInvalidID, err)
+	}
+	if err := repo.Delete(i.ID); !errors.Is(err, pomodoro.ErrInvalidID) {
+		t.Errorf("expected error %q, got %v", pomodoro.ErrInvalidID, err)
+	}
+}
+
+func testDeleteOlderThan(t *testing.T, repo pomodoro.Repository) {
+	create(t, repo, done(day.Add(9*time.Hour), pomodoro.CategoryPomodoro, 25*time.Minute))
+	kept := create(t, repo, done(day.Add(11*time.Hour), pomodoro.CategoryPomodoro, 25*time.Minute))
+	// The last interval is unfinished and kept even though it's old
+	last := create(t, repo, pomodoro.Interval{
+		StartTime: day.Add(8 * time.Hour),
+		Category:  pomodoro.CategoryShortBreak,
+		State:     pomodoro.StatePaused,
+	})
+
+	n, err := repo.DeleteOlderThan(day.Add(10 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 interval removed, got %d", n)
+	}
+	found, err := repo.Find(pomodoro.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []int64{kept.ID, last.ID}
+	if got := ids(found); !sameIDs(got, exp) {
+		t.Errorf("expected %v, got %v", exp, got)
+	}
+}
+
+func testArchive(t *testing.T, repo pomodoro.Repository) {
+	old := day.AddDate(0, 0, -2)
+	oldDone := create(t, repo, done(old.Add(9*time.Hour), pomodoro.CategoryPomodoro, 25*time.Minute))
+	oldCancelled := create(t, repo, pomodoro.Interval{
+		StartTime:       old.Add(10 * time.Hour),
+		PlannedDuration: 25 * time.Minute,
+		ActualDuration:  5 * time.Minute,
+		Category:        pomodoro.CategoryPomodoro,
+		State:           pomodoro.StateCancelled,
+	})
+	// Unfinished intervals aren't archived
+	oldPaused := create(t, repo, pomodoro.Interval{
+		StartTime: old.Add(11 * time.Hour),
+		Category:  pomodoro.CategoryPomodoro,
+		State:     pomodoro.StatePaused,
+	})
+	recent := create(t, repo, done(day.Add(9*time.Hour), pomodoro.CategoryPomodoro, 25*time.Minute))
+	recentBreak := create(t, repo, done(day.Add(10*time.Hour), pomodoro.CategoryShortBreak, 5*time.Minute))
+	// The last interval is never archived, however old
+	last := create(t, repo, done(old.Add(12*time.Hour), pomodoro.CategoryLongBreak, 15*time.Minute))
+
+	summary, err := repo.CategorySummary(day, pomodoro.CategoryPomodoro, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := repo.Archive(day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 intervals archived, got %d", n)
+	}
+
+	if got, err := repo.CategorySummary(day, pomodoro.CategoryPomodoro, 0); err != nil || got != summary {
+		t.Errorf("expected summary %v unchanged, got %v, %v", summary, got, err)
+	}
+	if got, err := repo.Last(); err != nil || got.ID != last.ID {
+		t.Errorf("expected last interval %d, got %d, %v", last.ID, got.ID, err)
+	}
+	for _, id := range []int64{oldDone.ID, oldCancelled.ID} {
+		if _, err := repo.ByID(id); !errors.Is(err, pomodoro.ErrInvalidID) {
+			t.Errorf("interval %d: expected error %q, got %v", id, pomodoro.ErrInvalidID, err)
+		}
+		if _, err := repo.TagsFor(id); err != nil {
+			t.Errorf("interval %d: expected tags of archived interval, got %v", id, err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter pomodoro.Filter
+		exp    []int64
+	}{
+		{"Live", pomodoro.Filter{}, []int64{oldPaused.ID, recent.ID, recentBreak.ID, last.ID}},
+		{"Archived", pomodoro.Filter{IncludeArchived: true},
+			[]int64{oldDone.ID, oldCancelled.ID, oldPaused.ID, recent.ID, recentBreak.ID, last.ID}},
+		{"Desc", pomodoro.Filter{IncludeArchived: true, Order: pomodoro.OrderDesc, Limit: 5},
+			[]int64{last.ID, recentBreak.ID, recent.ID, oldPaused.ID, oldCancelled.ID}},
+		{"Range", pomodoro.Filter{IncludeArchived: true, From: old, To: old.AddDate(0, 0, 1),
+			Categories: []string{pomodoro.CategoryPomodoro}},
+			[]int64{oldDone.ID, oldCancelled.ID, oldPaused.ID}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			found, err := repo.Find(tc.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(found); !sameIDs(got, tc.exp) {
+				t.Errorf("expected %v, got %v", tc.exp, got)
+			}
+		})
+	}
+
+	if n, err := repo.Archive(day); err != nil || n != 0 {
+		t.Errorf("expected nothing left to archive, got %d, %v", n, err)
+	}
+}
+
+// testArchivedIDs checks that IDs aren't reused once the intervals holding
+// them are archived and the rest pruned, which would hand the tags and
+// events of an archived interval to a new one
+func testArchivedIDs(t *testing.T, repo pomodoro.Repository) {
+	old := day.AddDate(0, 0, -2)
+	archived := create(t, repo, done(old.Add(9*time.Hour), pomodoro.CategoryPomodoro, 25*time.Minute))
+	if err := repo.AddTag(archived.ID, "deep"); err != nil {
+		t.Fatal(err)
+	}
+	pruned := create(t, repo, done(old.Add(10*time.Hour), pomodoro.CategoryPomodoro, 25*time.Minute))
+
+	if n, err := repo.Archive(day); err != nil || n != 1 {
+		t.Fatalf("expected 1 interval archived, got %d, %v", n, err)
+	}
+	if n, err := repo.DeleteOlderThan(day); err != nil || n != 1 {
+		t.Fatalf("expected 1 interval deleted, got %d, %v", n, err)
+	}
+
+	i := create(t, repo, done(day.Add(9*time.Hour), pomodoro.CategoryPomodoro, 25*time.Minute))
+	if i.ID == archived.ID || i.ID == pruned.ID {
+		t.Errorf("expected a new ID, got %d reused", i.ID)
+	}
+	if tags, err := repo.TagsFor(i.ID); err != nil || len(tags) != 0 {
+		t.Errorf("expected no tags, got %v, %v", tags, err)
+	}
+	found, err := repo.Find(pomodoro.Filter{IncludeArchived: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := []int64{archived.ID, i.ID}; !sameIDs(ids(found), exp) {
+		t.Errorf("expected %v, got %v", exp, ids(found))
+	}
+}
+
+func testTasks(t *testing.T, repo pomodoro.Repository) {
+	if tasks, err := repo.Tasks(); err != nil || len(tasks) != 0 {
+		t.Errorf("expected no tasks, got %v, %v", tasks, err)
+	}
+
+	first, err := repo.CreateTask(pomodoro.Task{Name: "report", Estimate: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := repo.CreateTask(pomodoro.Task{Name: "slides", Estimate: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateTask(pomodoro.Task{Name: "report"}); !errors.Is(err, pomodoro.ErrTaskExists) {
+		t.Errorf("expected error %q, got %v", pomodoro.ErrTaskExists, err)
+	}
+
+	task, err := repo.TaskByName("report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := (pomodoro.Task{ID: first, Name: "report", Estimate: 4}); task != exp {
+		t.Errorf("expected %+v, got %+v", exp, task)
+	}
+	if _, err := repo.TaskByName("missing"); !errors.Is(err, pomodoro.ErrTaskNotFound) {
+		t.Errorf("expected error %q, got %v", pomodoro.ErrTaskNotFound, err)
+	}
+
+	tasks, err := repo.Tasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 || tasks[0].ID != first || tasks[1].ID != second {
+		t.Errorf("expected tasks %d and %d in order, got %+v", first, second, tasks)
+	}
+
+	p := done(day.Add(9*time.Hour), pomodoro.CategoryPomodoro, 25*time.Minute)
+	p.TaskID = first
+	create(t, repo, p)
+	p.StartTime = day.Add(10 * time.Hour)
+	p.State = pomodoro.StateCancelled
+	p.ActualDuration = 10 * time.Minute
+	create(t, repo, p)
+
+	n, d, err := repo.TaskTotals(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || d != 35*time.Minute {
+		t.Errorf("expected 1 pomodoro and %v, got %d and %v", 35*time.Minute, n, d)
+	}
+}
+
+func testErrors(t *testing.T, repo pomodoro.Repository) {
+	i := create(t, repo, done(day.Add(9*time.Hour), pomodoro.CategoryPomodoro, 25*time.Minute))
+	missing := i.ID + 100
+
+	checks := []struct {
+		name string
+		err  error
+	}{
+		{"ByID", func() error { _, err := repo.ByID(missing); return err }()},
+		{"ByIDZero", func() error { _, err := repo.ByID(0); return err }()},
+		{"Update", repo.Update(pomodoro.Interval{ID: missing, Category: pomodoro.CategoryPomodoro})},
+		{"UpdateProgress", repo.UpdateProgress(missing, 0, time.Minute, pomodoro.StateRunning)},
+		{"AddInterruption", repo.AddInterruption(missing)},
+		{"SetNote", repo.SetNote(missing, "note")},
+		{"Delete", repo.Delete(missing)},
+		{"AddTag", repo.AddTag(missing, "deep")},
+		{"TagsFor", func() error { _, err := repo.TagsFor(missing); return err }()},
+		{"EventsFor", func() error { _, err := repo.EventsFor(missing); return err }()},
+	}
+	for _, c := range checks {
+		if !errors.Is(c.err, pomodoro.ErrInvalidID) {
+			t.Errorf("%s: expected error %q, got %v", c.name, pomodoro.ErrInvalidID, c.err)
+		}
+	}
+
+	if err := repo.AddTag(i.ID, "two words"); !errors.Is(err, pomodoro.ErrInvalidTag) {
+		t.Errorf("expected error %q, got %v", pomodoro.ErrInvalidTag, err)
+	}
+}
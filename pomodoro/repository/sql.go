@@ -0,0 +1,255 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro"
+)
+
+// intervalColumns lists the columns scanned by scanInterval, in order
+const intervalColumns string = `id, start_time, planned_duration, actual_duration,
+	category, state, label, daily_ordinal, interruptions, note, task_id,
+	auto_paused, paused_duration, paused_at, device, version`
+
+// querier runs statements on either a database or a transaction
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// Durations are stored by sqlite in whole seconds, so the database reads
+// well in plain SQL, and by postgres in nanoseconds. The scanners shared by
+// both take the unit of the stored values
+const (
+	sqliteUnit   = time.Second
+	postgresUnit = time.Nanosecond
+)
+
+// scanInterval reads the columns of intervalColumns. A NULL start time,
+// that of an interval that never started, is read as the zero time
+func scanInterval(s scanner, unit time.Duration) (pomodoro.Interval, error) {
+	var (
+		i                       pomodoro.Interval
+		start                   sql.NullTime
+		planned, actual, paused int64
+	)
+	err := s.Scan(
+		&i.ID,
+		&start,
+		&planned,
+		&actual,
+		&i.Category,
+		&i.State,
+		&i.Label,
+		&i.DailyOrdinal,
+		&i.Interruptions,
+		&i.Note,
+		&i.TaskID,
+		&i.AutoPaused,
+		&paused,
+		&i.PausedAt,
+		&i.Device,
+		&i.Version,
+	)
+	i.StartTime = start.Time
+	i.PlannedDuration = time.Duration(planned) * unit
+	i.ActualDuration = time.Duration(actual) * unit
+	i.PausedDuration = time.Duration(paused) * unit
+	return i, err
+}
+
+// startTime is the value stored for the start time of an interval: NULL
+// if it never started, so reports don't have to tell the zero time apart
+func startTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC()
+}
+
+// checkVersion fails with ErrConflict if an update guarded by the version
+// of interval id changed no rows, as another writer updated it first
+func checkVersion(res sql.Result, id int64) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: interval %d", pomodoro.ErrConflict, id)
+	}
+	return nil
+}
+
+// findQuery builds the statement selecting the intervals matched by f.
+// Values are always bound as arguments, never formatted into the query.
+// strpos and lower name the SQL functions returning the position of a
+// substring and lowering the case of a string, which differ between
+// databases. lower must handle more than ASCII, as Text does
+func findQuery(f pomodoro.Filter, strpos, lower string) (string, []any) {
+	var (
+		where []string
+		args  []any
+	)
+
+	if !f.From.IsZero() {
+		where = append(where, "start_time >= ?")
+		args = append(args, f.From.UTC())
+	}
+	if !f.To.IsZero() {
+		where = append(where, "start_time < ?")
+		args = append(args, f.To.UTC())
+	}
+	if len(f.Categories) > 0 {
+		where = append(where, "category IN ("+placeholders(len(f.Categories))+")")
+		for _, c := range f.Categories {
+			args = append(args, c)
+		}
+	}
+	if len(f.States) > 0 {
+		where = append(where, "state IN ("+placeholders(len(f.States))+")")
+		for _, s := range f.States {
+			args = append(args, s)
+		}
+	}
+	if f.Label != "" {
+		where = append(where, strpos+"(label, ?) > 0")
+		args = append(args, f.Label)
+	}
+	if f.Text != "" {
+		where = append(where, "("+lower+`(label) LIKE ? ESCAPE '\' OR `+lower+`(note) LIKE ? ESCAPE '\')`)
+		pattern := likePattern(strings.ToLower(f.Text))
+		args = append(args, pattern, pattern)
+	}
+
+	stmt := "SELECT " + intervalColumns + ` FROM "interval"`
+	if f.IncludeArchived {
+		stmt = "SELECT " + intervalColumns + " FROM (" + stmt +
+			" UNION ALL SELECT " + intervalColumns + ` FROM "interval_archive") AS "interval"`
+	}
+	if len(where) > 0 {
+		stmt += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	switch f.Order {
+	case pomodoro.OrderDesc:
+		stmt += " ORDER BY id DESC"
+	case pomodoro.OrderLatestFirst:
+		stmt += " ORDER BY start_time DESC NULLS LAST, id"
+	default:
+		stmt += " ORDER BY id"
+	}
+
+	if f.Limit > 0 || f.Offset > 0 {
+		limit := int64(f.Limit)
+		if limit <= 0 {
+			// sqlite only takes an offset after a limit
+			limit = math.MaxInt64
+		}
+		stmt += " LIMIT ?"
+		args = append(args, limit)
+	}
+	if f.Offset > 0 {
+		stmt += " OFFSET ?"
+		args = append(args, f.Offset)
+	}
+	return stmt, args
+}
+
+// likePattern turns text into a LIKE pattern matching it anywhere,
+// escaping the wildcards it contains with a backslash
+func likePattern(text string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(text)
+	return "%" + escaped + "%"
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func scanTags(rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// scanTotals reads rows of names and summed durations
+func scanTotals(rows *sql.Rows, unit time.Duration) (map[string]time.Duration, error) {
+	defer rows.Close()
+
+	totals := make(map[string]time.Duration)
+	for rows.Next() {
+		var (
+			t string
+			d int64
+		)
+		if err := rows.Scan(&t, &d); err != nil {
+			return nil, err
+		}
+		totals[t] = time.Duration(d) * unit
+	}
+	return totals, rows.Err()
+}
+
+// scanStateCounts reads rows of states and counts
+func scanStateCounts(rows *sql.Rows) (map[int]int, error) {
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var state, n int
+		if err := rows.Scan(&state, &n); err != nil {
+			return nil, err
+		}
+		counts[state] = n
+	}
+	return counts, rows.Err()
+}
+
+func scanLabelTotals(rows *sql.Rows, unit time.Duration) ([]pomodoro.LabelTotal, error) {
+	defer rows.Close()
+
+	var totals []pomodoro.LabelTotal
+	for rows.Next() {
+		var (
+			t pomodoro.LabelTotal
+			d int64
+		)
+		if err := rows.Scan(&t.Label, &t.Pomodoros, &d); err != nil {
+			return nil, err
+		}
+		t.FocusTime = time.Duration(d) * unit
+		totals = append(totals, t)
+	}
+	return totals, rows.Err()
+}
+
+func scanEvents(rows *sql.Rows) ([]pomodoro.StateEvent, error) {
+	defer rows.Close()
+
+	var events []pomodoro.StateEvent
+	for rows.Next() {
+		var e pomodoro.StateEvent
+		if err := rows.Scan(&e.IntervalID, &e.From, &e.To, &e.Time); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
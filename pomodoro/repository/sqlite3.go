@@ -1,13 +1,16 @@
-//go:build !inmemory
+//go:build !bolt
 
 package repository
 
 import (
 	"database/sql"
+	"fmt"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 	"github.com/snirkop89/pomo/pomodoro"
 )
 
@@ -21,89 +24,425 @@ const (
 		"state" INTEGER DEFAULT 1,
 		PRIMARY KEY("id")
 		);`
+
+	createIndexStartTime string = `CREATE INDEX IF NOT EXISTS "interval_start_time"
+		ON "interval" ("start_time");`
+
+	// createTableIntervalArchive holds the intervals moved by Archive,
+	// with the columns of interval as of the migration creating it
+	createTableIntervalArchive string = `CREATE TABLE IF NOT EXISTS "interval_archive" (
+		"id" INTEGER,
+		"start_time" DATETIME NOT NULL,
+		"planned_duration" INTEGER DEFAULT 0,
+		"actual_duration" INTEGER DEFAULT 0,
+		"category" TEXT NOT NULL,
+		"state" INTEGER DEFAULT 1,
+		"label" TEXT NOT NULL DEFAULT '',
+		"daily_ordinal" INTEGER NOT NULL DEFAULT 0,
+		"interruptions" INTEGER NOT NULL DEFAULT 0,
+		"note" TEXT NOT NULL DEFAULT '',
+		"task_id" INTEGER NOT NULL DEFAULT 0,
+		"auto_paused" INTEGER NOT NULL DEFAULT 0,
+		"paused_duration" INTEGER NOT NULL DEFAULT 0,
+		"paused_at" DATETIME NOT NULL DEFAULT '0001-01-01 00:00:00+00:00',
+		"version" INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY("id")
+		);`
+
+	createTableTask string = `CREATE TABLE IF NOT EXISTS "task" (
+		"id" INTEGER,
+		"name" TEXT NOT NULL UNIQUE,
+		"estimate" INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY("id")
+		);`
 )
 
+const (
+	insertInterval string = `INSERT INTO interval
+		(start_time, planned_duration, actual_duration, category, state, label,
+		daily_ordinal, task_id, device)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	updateInterval string = `UPDATE interval SET start_time=?, actual_duration=?, state=?, label=?,
+		auto_paused=?, paused_duration=?, paused_at=?, version=version+1
+		WHERE id=? AND version=?`
+
+	updateProgress string = `UPDATE interval SET actual_duration=?, state=?, version=version+1
+		WHERE id=? AND version=?`
+)
+
+func init() {
+	Register("sqlite3", func(path string) (pomodoro.Repository, error) {
+		r, err := NewSQLite3Repo(path)
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	})
+	RegisterUnlocked("sqlite3", func(path string) (pomodoro.Repository, error) {
+		r, err := NewSQLite3RepoUnlocked(path)
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	})
+	RegisterReadOnly("sqlite3", func(path string) (pomodoro.Repository, error) {
+		return NewSQLite3RepoReadOnly(path)
+	})
+}
+
 type dbRepo struct {
 	db *sql.DB
+	// tx is set on the repository given to RunInTransaction functions,
+	// which runs every statement in it
+	tx *sql.Tx
+	// The statements writing intervals are prepared once, as intervals
+	// are updated on every tick. database/sql prepares them again on new
+	// connections
+	insStmt  *sql.Stmt
+	updStmt  *sql.Stmt
+	progStmt *sql.Stmt
+	// now stamps the state events
+	now func() time.Time
+	// lock is held until the repository is closed, unless it was opened
+	// unlocked
+	lock *fileLock
 	sync.RWMutex
 }
 
+// NewSQLite3Repo opens the database in dbfile, creating or migrating its
+// schema. It locks the database so a single pomo instance changes it at a
+// time, failing with ErrDatabaseInUse if another one holds the lock
 func NewSQLite3Repo(dbfile string) (*dbRepo, error) {
-	db, err := sql.Open("sqlite3", dbfile)
+	if !lockable(dbfile) {
+		return openSQLite3(dbfile)
+	}
+
+	lock, err := lockDatabase(dbfile)
+	if err != nil {
+		return nil, err
+	}
+	r, err := openSQLite3(dbfile)
+	if err != nil {
+		lock.release()
+		return nil, err
+	}
+	r.lock = lock
+	return r, nil
+}
+
+// NewSQLite3RepoUnlocked opens the database in dbfile without taking its
+// lock, for commands that only read it while another instance may run
+func NewSQLite3RepoUnlocked(dbfile string) (*dbRepo, error) {
+	return openSQLite3(dbfile)
+}
+
+// NewSQLite3RepoReadOnly opens the existing database in dbfile read-only,
+// neither creating nor migrating its schema, for commands that only report
+// on it. It takes no lock. The writes of the repository fail with
+// pomodoro.ErrReadOnly
+func NewSQLite3RepoReadOnly(dbfile string) (pomodoro.Repository, error) {
+	if _, err := os.Stat(dbfile); err != nil {
+		return nil, fmt.Errorf("opening %s read-only: %w", dbfile, err)
+	}
+
+	db, err := openDB(readOnlyDSN(dbfile))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSchema(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	r, err := newDBRepo(db)
+	if err != nil {
+		return nil, err
+	}
+	return readOnly(r), nil
+}
+
+// readOnlyDSN returns the URI opening the database file at path read-only,
+// escaping the characters that would end its path
+func readOnlyDSN(path string) string {
+	escaped := strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23").Replace(path)
+	return "file:" + escaped + "?mode=ro"
+}
+
+func openSQLite3(dbfile string) (*dbRepo, error) {
+	db, err := openDB(dbfile)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return newDBRepo(db)
+}
+
+// sqliteDriver is the sqlite3 driver with the functions the queries of
+// dbRepo need. unicode_lower lowers the case of any script, where lower
+// only handles ASCII
+const sqliteDriver = "sqlite3_pomo"
+
+func init() {
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("unicode_lower", strings.ToLower, true)
+		},
+	})
+}
+
+func openDB(dsn string) (*sql.DB, error) {
+	db, err := sql.Open(sqliteDriver, dsn)
 	if err != nil {
 		return nil, err
 	}
 	db.SetConnMaxLifetime(30 * time.Minute)
 	db.SetMaxOpenConns(1)
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, err
 	}
+	return db, nil
+}
 
-	if _, err := db.Exec(createTableInterval); err != nil {
+// newDBRepo prepares the statements of a repository on db, closing it if
+// that fails
+func newDBRepo(db *sql.DB) (*dbRepo, error) {
+	insStmt, err := db.Prepare(insertInterval)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	updStmt, err := db.Prepare(updateInterval)
+	if err != nil {
+		insStmt.Close()
+		db.Close()
+		return nil, err
+	}
+	progStmt, err := db.Prepare(updateProgress)
+	if err != nil {
+		insStmt.Close()
+		updStmt.Close()
+		db.Close()
 		return nil, err
 	}
 
 	return &dbRepo{
-		db: db,
+		db:       db,
+		insStmt:  insStmt,
+		updStmt:  updStmt,
+		progStmt: progStmt,
+		now:      time.Now,
 	}, nil
 }
 
-func (r *dbRepo) Create(i pomodoro.Interval) (int64, error) {
-	// Create the entry in the repository
-	r.Lock()
-	defer r.Unlock()
+// DurationUnit is the second, as durations are stored in whole seconds
+func (r *dbRepo) DurationUnit() time.Duration {
+	return sqliteUnit
+}
 
-	insStmt, err := r.db.Prepare("INSERT INTO interval VALUES(NULL, ?, ?, ?, ?, ?)")
-	if err != nil {
-		return 0, err
+// SetNow sets the time the state events are stamped with
+func (r *dbRepo) SetNow(now func() time.Time) {
+	r.now = now
+}
+
+// seconds converts d to the whole seconds stored in the database, rounding
+// to the nearest
+func seconds(d time.Duration) int64 {
+	return int64(d.Round(time.Second) / time.Second)
+}
+
+// conn returns the transaction the repository is bound to, if any, or the
+// database
+func (r *dbRepo) conn() querier {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.db
+}
+
+// withTx runs fn in a transaction committed if fn succeeds. Repositories
+// bound to a transaction run fn in it, leaving the commit to its owner
+func (r *dbRepo) withTx(fn func(tx *sql.Tx) error) error {
+	if r.tx != nil {
+		return fn(r.tx)
 	}
-	defer insStmt.Close()
 
-	// EXEC insert statement
-	res, err := insStmt.Exec(i.StartTime, i.PlannedDuration, i.ActualDuration, i.Category, i.State)
+	tx, err := r.db.Begin()
 	if err != nil {
-		return 0, err
+		return err
 	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RunInTransaction calls fn with a repository running every statement in
+// one transaction, rolled back if fn fails. Other callers wait until it's
+// done, as sqlite allows a single writer
+func (r *dbRepo) RunInTransaction(fn func(pomodoro.Repository) error) error {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.withTx(func(tx *sql.Tx) error {
+		return fn(&dbRepo{
+			db:       r.db,
+			tx:       tx,
+			insStmt:  r.insStmt,
+			updStmt:  r.updStmt,
+			progStmt: r.progStmt,
+			now:      r.now,
+		})
+	})
+}
+
+// Backup copies the database to dest with VACUUM INTO, which reads a
+// consistent snapshot while other connections keep writing
+func (r *dbRepo) Backup(dest string, force bool) (int64, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	return writeBackup(dest, force, func(path string) error {
+		_, err := r.db.Exec("VACUUM INTO ?", path)
+		return err
+	})
+}
+
+// Close closes the prepared statements and the database, and releases its
+// lock
+func (r *dbRepo) Close() error {
+	r.Lock()
+	defer r.Unlock()
+
+	r.insStmt.Close()
+	r.updStmt.Close()
+	r.progStmt.Close()
+	err := r.db.Close()
+	r.lock.release()
+	return err
+}
+
+func (r *dbRepo) Create(i pomodoro.Interval) (int64, error) {
+	// Create the entry in the repository
+	r.Lock()
+	defer r.Unlock()
 
 	var id int64
-	if id, err = res.LastInsertId(); err != nil {
+	err := r.withTx(func(tx *sql.Tx) error {
+		res, err := tx.Stmt(r.insStmt).Exec(startTime(i.StartTime), seconds(i.PlannedDuration), seconds(i.ActualDuration),
+			i.Category, i.State, i.Label, i.DailyOrdinal, i.TaskID, i.Device)
+		if err != nil {
+			return err
+		}
+		id, err = res.LastInsertId()
+		return err
+	})
+	if err != nil {
 		return 0, err
 	}
 	return id, nil
 }
 
-func (r *dbRepo) Update(i pomodoro.Interval) error {
+// CreateBatch stores intervals in a single transaction, reusing the
+// prepared insert statement
+func (r *dbRepo) CreateBatch(is []pomodoro.Interval) ([]int64, error) {
 	r.Lock()
 	defer r.Unlock()
 
-	updStmt, err := r.db.Prepare(
-		"UPDATE interval SET start_time=?, actual_duration=?, state=? WHERE id=?")
+	ids := make([]int64, 0, len(is))
+	err := r.withTx(func(tx *sql.Tx) error {
+		ins := tx.Stmt(r.insStmt)
+		defer ins.Close()
+
+		for _, i := range is {
+			res, err := ins.Exec(startTime(i.StartTime), seconds(i.PlannedDuration), seconds(i.ActualDuration),
+				i.Category, i.State, i.Label, i.DailyOrdinal, i.TaskID, i.Device)
+			if err != nil {
+				return err
+			}
+			id, err := res.LastInsertId()
+			if err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+		return nil
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer updStmt.Close()
+	return ids, nil
+}
 
-	res, err := updStmt.Exec(i.StartTime, i.ActualDuration, i.State, i.ID)
-	if err != nil {
+func (r *dbRepo) Update(i pomodoro.Interval) error {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.update(i.ID, i.State, r.updStmt, startTime(i.StartTime), seconds(i.ActualDuration), i.State,
+		i.Label, i.AutoPaused, seconds(i.PausedDuration), i.PausedAt.UTC(), i.ID, i.Version)
+}
+
+// UpdateProgress stores the elapsed time and state of an interval
+func (r *dbRepo) UpdateProgress(id, version int64, actual time.Duration, state int) error {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.update(id, state, r.progStmt, seconds(actual), state, id, version)
+}
+
+// update runs stmt, which sets the state of interval id if it has the
+// expected version, and records the change of state if there's one.
+// Callers hold the lock
+func (r *dbRepo) update(id int64, state int, stmt *sql.Stmt, args ...any) error {
+	return r.withTx(func(tx *sql.Tx) error {
+		// The previous state is read in the same transaction so no change
+		// goes unrecorded
+		var prev int
+		err := tx.QueryRow("SELECT state FROM interval WHERE id=?", id).Scan(&prev)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: %d", pomodoro.ErrInvalidID, id)
+		}
+		if err != nil {
+			return err
+		}
+
+		res, err := tx.Stmt(stmt).Exec(args...)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion(res, id); err != nil {
+			return err
+		}
+		if prev == state {
+			return nil
+		}
+		_, err = tx.Exec(`INSERT INTO interval_events (interval_id, from_state, to_state, time)
+			VALUES (?, ?, ?, ?)`, id, prev, state, r.now().UTC())
 		return err
-	}
-	_, err = res.RowsAffected()
-	return err
+	})
 }
 
 func (r *dbRepo) ByID(id int64) (pomodoro.Interval, error) {
+	if id <= 0 {
+		return pomodoro.Interval{}, fmt.Errorf("%w: %d", pomodoro.ErrInvalidID, id)
+	}
+
 	r.RLock()
 	defer r.RUnlock()
 
-	var i pomodoro.Interval
-	if err := r.db.QueryRow("SELECT * FROM interval WHERE id=?", id).Scan(
-		&i.ID,
-		&i.StartTime,
-		&i.PlannedDuration,
-		&i.ActualDuration,
-		&i.Category,
-		&i.State,
-	); err != nil {
+	i, err := scanInterval(r.conn().QueryRow("SELECT "+intervalColumns+" FROM interval WHERE id=?", id), sqliteUnit)
+	if err == sql.ErrNoRows {
+		return i, fmt.Errorf("%w: %d", pomodoro.ErrInvalidID, id)
+	}
+	if err != nil {
 		return i, err
 	}
 
@@ -112,31 +451,31 @@ func (r *dbRepo) ByID(id int64) (pomodoro.Interval, error) {
 
 // Last searchs for the last item in the repository
 func (r *dbRepo) Last() (pomodoro.Interval, error) {
-	r.RLock()
-	defer r.RUnlock()
-
-	var last pomodoro.Interval
-	err := r.db.QueryRow("SELECT * FROM interval ORDER BY id desc LIMIT 1").Scan(
-		&last.ID, &last.StartTime, &last.PlannedDuration, &last.ActualDuration,
-		&last.Category, &last.State,
-	)
-	if err == sql.ErrNoRows {
-		return last, pomodoro.ErrNoIntervals
-	}
+	found, err := r.Find(pomodoro.Filter{Order: pomodoro.OrderDesc, Limit: 1})
 	if err != nil {
-		return last, err
+		return pomodoro.Interval{}, err
+	}
+	if len(found) == 0 {
+		return pomodoro.Interval{}, pomodoro.ErrNoIntervals
 	}
-	return last, nil
+	return found[0], nil
 }
 
 func (r *dbRepo) Breaks(n int) ([]pomodoro.Interval, error) {
+	return r.Find(pomodoro.Filter{
+		Categories: []string{pomodoro.CategoryShortBreak, pomodoro.CategoryLongBreak},
+		Order:      pomodoro.OrderDesc,
+		Limit:      n,
+	})
+}
+
+// Find returns the intervals matched by the filter
+func (r *dbRepo) Find(f pomodoro.Filter) ([]pomodoro.Interval, error) {
 	r.RLock()
 	defer r.RUnlock()
 
-	stmt := `SELECT * FROM interval WHERE category LIKE '%Break'
-		ORDER BY id DESC LIMIT ?`
-
-	rows, err := r.db.Query(stmt, n)
+	stmt, args := findQuery(f, "instr", "unicode_lower")
+	rows, err := r.conn().Query(stmt, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -144,8 +483,7 @@ func (r *dbRepo) Breaks(n int) ([]pomodoro.Interval, error) {
 
 	var data []pomodoro.Interval
 	for rows.Next() {
-		var i pomodoro.Interval
-		err = rows.Scan(&i.ID, &i.StartTime, &i.PlannedDuration, &i.ActualDuration, &i.Category, &i.State)
+		i, err := scanInterval(rows, sqliteUnit)
 		if err != nil {
 			return nil, err
 		}
@@ -158,25 +496,519 @@ func (r *dbRepo) Breaks(n int) ([]pomodoro.Interval, error) {
 	return data, nil
 }
 
-// CategorySummary returns a daily summary
-func (r *dbRepo) CategorySummary(day time.Time, filter string) (time.Duration, error) {
+// CategorySummary returns a daily summary of completed intervals. It
+// mirrors pomodoro.Interval.Completed
+func (r *dbRepo) CategorySummary(day time.Time, filter string, cancelledRatio float64) (time.Duration, error) {
 	r.RLock()
 	defer r.RUnlock()
 
 	stmt := `SELECT sum(actual_duration) FROM interval
 		WHERE category LIKE ? AND
-		strftime('%Y-%m-%d', start_time, 'localtime')=
-		strftime('%Y-%m-%d', ?, 'localtime')`
+		start_time IS NOT NULL AND start_time >= ? AND start_time < ? AND
+		(state=? OR
+		(? > 0 AND state=? AND actual_duration >= planned_duration * ?))`
 
+	start, end := dayBounds(day)
 	var ds sql.NullInt64
-	err := r.db.QueryRow(stmt, filter, day).Scan(&ds)
+	err := r.conn().QueryRow(stmt, filter, start, end, pomodoro.StateDone,
+		cancelledRatio, pomodoro.StateCancelled, cancelledRatio).Scan(&ds)
 	if err != nil {
 		return 0, err
 	}
 
 	var d time.Duration
 	if ds.Valid {
-		d = time.Duration(ds.Int64)
+		d = time.Duration(ds.Int64) * sqliteUnit
 	}
 	return d, nil
 }
+
+// DailyTotals sums the completed intervals of each day in a single query
+func (r *dbRepo) DailyTotals(start, end time.Time, filter string, cancelledRatio float64, tag string) (map[string]time.Duration, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	stmt, args, days := dailyTotalsQuery(start, end, filter, cancelledRatio, tag)
+	rows, err := r.conn().Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanDailyTotals(rows, days, sqliteUnit)
+}
+
+// CompletedPerDay groups by the day buckets of DailyTotals, so days are cut
+// at midnight in the location of start like in CategorySummary
+func (r *dbRepo) CompletedPerDay(start, end time.Time) (map[string]int, error) {
+	if !start.Before(end) {
+		return map[string]int{}, nil
+	}
+
+	r.RLock()
+	defer r.RUnlock()
+
+	stmt, args, days := completedPerDayQuery(start, end)
+	rows, err := r.conn().Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanCounts(rows, days)
+}
+
+// StateCounts counts the intervals of category started in [start, end)
+// by state
+func (r *dbRepo) StateCounts(start, end time.Time, category string) (map[int]int, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	rows, err := r.conn().Query(`SELECT state, count(*) FROM interval
+		WHERE category=? AND
+		start_time IS NOT NULL AND start_time >= ? AND start_time < ?
+		GROUP BY state`, category, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, err
+	}
+	return scanStateCounts(rows)
+}
+
+// AddTag links a tag to an interval, creating the tag if it's new
+func (r *dbRepo) AddTag(intervalID int64, tag string) error {
+	t, err := pomodoro.NormalizeTag(tag)
+	if err != nil {
+		return err
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	return r.withTx(func(tx *sql.Tx) error {
+		var n int
+		if err := tx.QueryRow("SELECT count(*) FROM interval WHERE id=?", intervalID).Scan(&n); err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("%w: %d", pomodoro.ErrInvalidID, intervalID)
+		}
+
+		if _, err := tx.Exec("INSERT OR IGNORE INTO tags (name) VALUES(?)", t); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`INSERT OR IGNORE INTO interval_tags (interval_id, tag_id)
+			SELECT ?, id FROM tags WHERE name=?`, intervalID, t)
+		return err
+	})
+}
+
+// TagsFor returns the tags of an interval in alphabetical order
+func (r *dbRepo) TagsFor(intervalID int64) ([]string, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	var n int
+	err := r.conn().QueryRow(`SELECT count(*) FROM (SELECT id FROM interval WHERE id=?1
+		UNION ALL SELECT id FROM interval_archive WHERE id=?1)`, intervalID).Scan(&n)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("%w: %d", pomodoro.ErrInvalidID, intervalID)
+	}
+
+	rows, err := r.conn().Query(`SELECT t.name FROM tags t
+		JOIN interval_tags it ON it.tag_id=t.id
+		WHERE it.interval_id=? ORDER BY t.name`, intervalID)
+	if err != nil {
+		return nil, err
+	}
+	return scanTags(rows)
+}
+
+// TagSummary sums the time of the done intervals started in [start, end)
+// by tag
+func (r *dbRepo) TagSummary(start, end time.Time) (map[string]time.Duration, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	rows, err := r.conn().Query(`SELECT t.name, sum(i.actual_duration) FROM interval i
+		JOIN interval_tags it ON it.interval_id=i.id
+		JOIN tags t ON t.id=it.tag_id
+		WHERE i.state=? AND
+		i.start_time IS NOT NULL AND i.start_time >= ? AND i.start_time < ?
+		GROUP BY t.name`, pomodoro.StateDone, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, err
+	}
+	return scanTotals(rows, sqliteUnit)
+}
+
+// DeviceSummary sums the time of the done pomodoros started in
+// [start, end) by device
+func (r *dbRepo) DeviceSummary(start, end time.Time) (map[string]time.Duration, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	rows, err := r.conn().Query(`SELECT device, sum(actual_duration) FROM interval
+		WHERE category=? AND state=? AND
+		start_time IS NOT NULL AND start_time >= ? AND start_time < ?
+		GROUP BY device`, pomodoro.CategoryPomodoro, pomodoro.StateDone, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, err
+	}
+	return scanTotals(rows, sqliteUnit)
+}
+
+// LabelSummary totals the done pomodoros started in [start, end) by label
+func (r *dbRepo) LabelSummary(start, end time.Time) ([]pomodoro.LabelTotal, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	rows, err := r.conn().Query(`SELECT CASE WHEN label='' THEN ? ELSE label END,
+		count(*), sum(actual_duration) FROM interval
+		WHERE category=? AND state=? AND
+		start_time IS NOT NULL AND start_time >= ? AND start_time < ?
+		GROUP BY 1 ORDER BY 3 DESC, 1`,
+		pomodoro.NoLabel, pomodoro.CategoryPomodoro, pomodoro.StateDone, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, err
+	}
+	return scanLabelTotals(rows, sqliteUnit)
+}
+
+// EventsFor returns the state changes of an interval, oldest first
+func (r *dbRepo) EventsFor(id int64) ([]pomodoro.StateEvent, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	var n int
+	if err := r.conn().QueryRow("SELECT count(*) FROM interval WHERE id=?", id).Scan(&n); err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("%w: %d", pomodoro.ErrInvalidID, id)
+	}
+
+	rows, err := r.conn().Query(`SELECT interval_id, from_state, to_state, time
+		FROM interval_events WHERE interval_id=? ORDER BY id`, id)
+	if err != nil {
+		return nil, err
+	}
+	return scanEvents(rows)
+}
+
+// TotalCompleted counts all the pomodoros ever completed
+func (r *dbRepo) TotalCompleted() (int64, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	var n int64
+	err := r.conn().QueryRow("SELECT count(*) FROM interval WHERE category=? AND state=?",
+		pomodoro.CategoryPomodoro, pomodoro.StateDone).Scan(&n)
+	return n, err
+}
+
+// AddInterruption increments the interruptions of an interval in place
+func (r *dbRepo) AddInterruption(id int64) error {
+	r.Lock()
+	defer r.Unlock()
+
+	res, err := r.conn().Exec("UPDATE interval SET interruptions=interruptions+1 WHERE id=?", id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: %d", pomodoro.ErrInvalidID, id)
+	}
+	return nil
+}
+
+// SetNote replaces the note of an interval
+func (r *dbRepo) SetNote(id int64, note string) error {
+	r.Lock()
+	defer r.Unlock()
+
+	res, err := r.conn().Exec("UPDATE interval SET note=? WHERE id=?", note, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: %d", pomodoro.ErrInvalidID, id)
+	}
+	return nil
+}
+
+// WorkSinceLongBreak sums the work done on day after the last long break
+func (r *dbRepo) WorkSinceLongBreak(day time.Time) (time.Duration, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	stmt := `SELECT coalesce(sum(actual_duration), 0) FROM interval
+		WHERE category IN (?, ?) AND
+		id > coalesce((SELECT max(id) FROM interval WHERE category=?), 0) AND
+		start_time IS NOT NULL AND start_time >= ? AND start_time < ?`
+
+	start, end := dayBounds(day)
+	var d int64
+	err := r.conn().QueryRow(stmt, pomodoro.CategoryPomodoro, pomodoro.CategorySnooze,
+		pomodoro.CategoryLongBreak, start, end).Scan(&d)
+	return time.Duration(d) * sqliteUnit, err
+}
+
+// CreateTask stores a task, failing if its name is taken
+func (r *dbRepo) CreateTask(t pomodoro.Task) (int64, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	var n int
+	if err := r.conn().QueryRow("SELECT count(*) FROM task WHERE name=?", t.Name).Scan(&n); err != nil {
+		return 0, err
+	}
+	if n > 0 {
+		return 0, fmt.Errorf("%w: %q", pomodoro.ErrTaskExists, t.Name)
+	}
+
+	res, err := r.conn().Exec("INSERT INTO task (name, estimate) VALUES(?, ?)", t.Name, t.Estimate)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// TaskByName returns the task with the given name
+func (r *dbRepo) TaskByName(name string) (pomodoro.Task, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	var t pomodoro.Task
+	err := r.conn().QueryRow("SELECT id, name, estimate FROM task WHERE name=?", name).
+		Scan(&t.ID, &t.Name, &t.Estimate)
+	if err == sql.ErrNoRows {
+		return t, fmt.Errorf("%w: %q", pomodoro.ErrTaskNotFound, name)
+	}
+	return t, err
+}
+
+// Tasks returns all the tasks
+func (r *dbRepo) Tasks() ([]pomodoro.Task, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	rows, err := r.conn().Query("SELECT id, name, estimate FROM task ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var data []pomodoro.Task
+	for rows.Next() {
+		var t pomodoro.Task
+		if err := rows.Scan(&t.ID, &t.Name, &t.Estimate); err != nil {
+			return nil, err
+		}
+		data = append(data, t)
+	}
+	return data, rows.Err()
+}
+
+// TaskTotals sums the pomodoros linked to a task
+func (r *dbRepo) TaskTotals(taskID int64) (int64, time.Duration, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	stmt := `SELECT coalesce(sum(state=?), 0), coalesce(sum(actual_duration), 0)
+		FROM interval WHERE category=? AND task_id=?`
+
+	var (
+		n int64
+		d int64
+	)
+	err := r.conn().QueryRow(stmt, pomodoro.StateDone, pomodoro.CategoryPomodoro, taskID).Scan(&n, &d)
+	return n, time.Duration(d) * sqliteUnit, err
+}
+
+// Delete removes an interval unless it's running
+func (r *dbRepo) Delete(id int64) error {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.withTx(func(tx *sql.Tx) error {
+		var state int
+		err := tx.QueryRow("SELECT state FROM interval WHERE id=?", id).Scan(&state)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: %d", pomodoro.ErrInvalidID, id)
+		}
+		if err != nil {
+			return err
+		}
+		if state == pomodoro.StateRunning {
+			return fmt.Errorf("%w: cannot delete interval %d", pomodoro.ErrIntervalAlreadyRunning, id)
+		}
+
+		if _, err := tx.Exec("DELETE FROM interval WHERE id=?", id); err != nil {
+			return err
+		}
+		// IDs may be reused, so tags and events mustn't outlive their interval
+		if _, err := tx.Exec("DELETE FROM interval_tags WHERE interval_id=?", id); err != nil {
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM interval_events WHERE interval_id=?", id)
+		return err
+	})
+}
+
+// DeleteOlderThan removes the intervals started before t, except for the
+// last one if it's unfinished. Intervals that never started count as
+// older than any time
+func (r *dbRepo) DeleteOlderThan(t time.Time) (int64, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	var n int64
+	err := r.withTx(func(tx *sql.Tx) error {
+		res, err := tx.Exec(`DELETE FROM interval WHERE (start_time IS NULL OR start_time < ?) AND
+			NOT (id = (SELECT max(id) FROM interval) AND state IN (?, ?, ?))`,
+			t.UTC(), pomodoro.StateNotStarted, pomodoro.StateRunning, pomodoro.StatePaused)
+		if err != nil {
+			return err
+		}
+		if n, err = res.RowsAffected(); err != nil {
+			return err
+		}
+
+		// Archived intervals keep their tags and events
+		_, err = tx.Exec(`DELETE FROM interval_tags WHERE interval_id NOT IN
+			(SELECT id FROM interval UNION ALL SELECT id FROM interval_archive)`)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`DELETE FROM interval_events WHERE interval_id NOT IN
+			(SELECT id FROM interval UNION ALL SELECT id FROM interval_archive)`)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Archive copies the intervals to interval_archive and deletes them in one
+// transaction. They keep their IDs, along with their tags and events.
+// Keeping the last interval means sqlite, which picks IDs after the
+// highest in use, doesn't give an archived ID to a new interval
+func (r *dbRepo) Archive(olderThan time.Time) (int64, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	const where = `(start_time IS NULL OR start_time < ?) AND state IN (?, ?, ?) AND
+		id < (SELECT max(id) FROM interval)`
+	args := []any{olderThan.UTC(), pomodoro.StateDone, pomodoro.StateCancelled, pomodoro.StateSkipped}
+
+	var n int64
+	err := r.withTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`INSERT INTO interval_archive (`+intervalColumns+`)
+			SELECT `+intervalColumns+` FROM interval WHERE `+where, args...)
+		if err != nil {
+			return err
+		}
+		res, err := tx.Exec(`DELETE FROM interval WHERE `+where, args...)
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// ByRange returns the intervals started in [start, end) by start time
+func (r *dbRepo) ByRange(start, end time.Time) ([]pomodoro.Interval, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	stmt := `SELECT ` + intervalColumns + ` FROM interval
+		WHERE start_time IS NOT NULL AND start_time >= ? AND start_time < ?
+		ORDER BY start_time`
+
+	rows, err := r.conn().Query(stmt, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var data []pomodoro.Interval
+	for rows.Next() {
+		i, err := scanInterval(rows, sqliteUnit)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, i)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Import stores intervals in a single transaction, skipping duplicates.
+// Its statements are prepared once for all intervals
+func (r *dbRepo) Import(intervals []pomodoro.Interval) (int, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	n := 0
+	err := r.withTx(func(tx *sql.Tx) error {
+		dupStmt, err := tx.Prepare(`SELECT count(*) FROM
+			(SELECT id FROM interval WHERE start_time=?1 AND category=?2
+			UNION ALL SELECT id FROM interval_archive WHERE start_time=?1 AND category=?2)`)
+		if err != nil {
+			return err
+		}
+		defer dupStmt.Close()
+		ins, err := tx.Prepare(`INSERT INTO interval
+			(start_time, planned_duration, actual_duration, category, state, label,
+			daily_ordinal, interruptions, note, task_id, auto_paused, paused_duration, paused_at,
+			device)
+			VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+		if err != nil {
+			return err
+		}
+		defer ins.Close()
+
+		for _, i := range intervals {
+			if i.StartTime.IsZero() {
+				continue
+			}
+
+			var dup int
+			if err := dupStmt.QueryRow(i.StartTime.UTC(), i.Category).Scan(&dup); err != nil {
+				return err
+			}
+			if dup > 0 {
+				continue
+			}
+
+			_, err = ins.Exec(i.StartTime.UTC(), seconds(i.PlannedDuration), seconds(i.ActualDuration),
+				i.Category, i.State, i.Label, i.DailyOrdinal, i.Interruptions, i.Note, i.TaskID,
+				i.AutoPaused, seconds(i.PausedDuration), i.PausedAt.UTC(), i.Device)
+			if err != nil {
+				return fmt.Errorf("importing interval %d: %w", i.ID, err)
+			}
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
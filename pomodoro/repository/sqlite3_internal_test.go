@@ -0,0 +1,46 @@
+//go:build !bolt
+
+package repository
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro"
+)
+
+func TestPreparedStatementsReconnect(t *testing.T) {
+	r, err := NewSQLite3Repo(filepath.Join(t.TempDir(), "pomo.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	i := pomodoro.Interval{StartTime: time.Now(), Category: pomodoro.CategoryPomodoro}
+	if i.ID, err = r.Create(i); err != nil {
+		t.Fatal(err)
+	}
+
+	// Expire the connection the statements were prepared on
+	r.db.SetConnMaxLifetime(time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+
+	i.ActualDuration = time.Minute
+	if err := r.Update(i); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Create(i); err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.ByID(i.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ActualDuration != time.Minute {
+		t.Errorf("expected %s, got %s", time.Minute, got.ActualDuration)
+	}
+	if s := r.db.Stats(); s.MaxLifetimeClosed == 0 {
+		t.Errorf("expected the connection to be recycled, got %+v", s)
+	}
+}
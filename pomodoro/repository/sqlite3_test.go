@@ -0,0 +1,501 @@
+//go:build !bolt
+
+package repository_test
+
+import (
+	"database/sql"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro"
+	"github.com/snirkop89/pomo/pomodoro/repository"
+)
+
+// TestMain runs the tests in a zone with daylight saving time, which is
+// read by both Go and sqlite's localtime modifier
+func TestMain(m *testing.M) {
+	os.Setenv("TZ", "Europe/Berlin")
+	os.Exit(m.Run())
+}
+
+// strftimeSummary is the CategorySummary query used before start times
+// were bucketed by range. It can't use an index on start_time
+const strftimeSummary = `SELECT coalesce(sum(actual_duration), 0) FROM interval
+	WHERE category LIKE ? AND state=? AND
+	strftime('%Y-%m-%d', start_time, 'localtime')=
+	strftime('%Y-%m-%d', ?, 'localtime')`
+
+func openSQLite(t testing.TB) (pomodoro.Repository, *sql.DB) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "pomo.db")
+	repo, err := repository.NewSQLite3Repo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		repo.Close()
+	})
+	return repo, db
+}
+
+func TestCategorySummaryDST(t *testing.T) {
+	if _, offset := time.Date(2023, time.July, 1, 0, 0, 0, 0, time.Local).Zone(); offset != 2*60*60 {
+		t.Skip("local time zone isn't Europe/Berlin")
+	}
+
+	repo, db := openSQLite(t)
+
+	// Clocks moved forward on March 26 and back on October 29 2023
+	var intervals []pomodoro.Interval
+	for _, day := range []time.Time{
+		time.Date(2023, time.March, 25, 0, 0, 0, 0, time.Local),
+		time.Date(2023, time.October, 28, 0, 0, 0, 0, time.Local),
+	} {
+		for h := 0; h < 72; h++ {
+			intervals = append(intervals, pomodoro.Interval{
+				StartTime: day.Add(time.Duration(h)*time.Hour + 30*time.Minute), PlannedDuration: 25 * time.Minute,
+				ActualDuration: time.Duration(h+1) * time.Minute, Category: pomodoro.CategoryPomodoro,
+				State: pomodoro.StateDone,
+			})
+		}
+	}
+	if _, err := repo.(pomodoro.Importer).Import(intervals); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, day := range []time.Time{
+		time.Date(2023, time.March, 25, 12, 0, 0, 0, time.Local),
+		time.Date(2023, time.March, 26, 12, 0, 0, 0, time.Local),
+		time.Date(2023, time.March, 27, 12, 0, 0, 0, time.Local),
+		time.Date(2023, time.October, 28, 12, 0, 0, 0, time.Local),
+		time.Date(2023, time.October, 29, 12, 0, 0, 0, time.Local),
+		time.Date(2023, time.October, 30, 12, 0, 0, 0, time.Local),
+	} {
+		got, err := repo.CategorySummary(day, "%", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var secs int64
+		if err := db.QueryRow(strftimeSummary, "%", pomodoro.StateDone, day).Scan(&secs); err != nil {
+			t.Fatal(err)
+		}
+		if exp := time.Duration(secs) * time.Second; got != exp {
+			t.Errorf("%s: expected %s, got %s", day.Format("2006-01-02"), exp, got)
+		}
+	}
+}
+
+func BenchmarkCategorySummary(b *testing.B) {
+	repo, db := openSQLite(b)
+
+	start := time.Date(2021, time.January, 1, 9, 0, 0, 0, time.Local)
+	intervals := make([]pomodoro.Interval, 0, 100000)
+	for k := 0; k < cap(intervals); k++ {
+		intervals = append(intervals, pomodoro.Interval{
+			StartTime: start.Add(time.Duration(k) * 15 * time.Minute), PlannedDuration: 25 * time.Minute,
+			ActualDuration: 25 * time.Minute, Category: pomodoro.CategoryPomodoro, State: pomodoro.StateDone,
+		})
+	}
+	if _, err := repo.(pomodoro.Importer).Import(intervals); err != nil {
+		b.Fatal(err)
+	}
+	day := start.AddDate(0, 6, 0)
+
+	b.Run("Range", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			if _, err := repo.CategorySummary(day, pomodoro.CategoryPomodoro, 0); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Strftime", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			var d int64
+			if err := db.QueryRow(strftimeSummary, pomodoro.CategoryPomodoro, pomodoro.StateDone, day).Scan(&d); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkUpdate(b *testing.B) {
+	repo, db := openSQLite(b)
+
+	i := pomodoro.Interval{
+		StartTime: time.Now(), PlannedDuration: 25 * time.Minute,
+		Category: pomodoro.CategoryPomodoro, State: pomodoro.StateRunning,
+	}
+	id, err := repo.Create(i)
+	if err != nil {
+		b.Fatal(err)
+	}
+	i.ID = id
+
+	b.Run("Prepared", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			i.ActualDuration = time.Duration(n) * time.Second
+			if err := repo.Update(i); err != nil {
+				b.Fatal(err)
+			}
+			i.Version++
+		}
+	})
+	// PrepareEach is how Update worked before the statement was prepared
+	// once by NewSQLite3Repo
+	b.Run("PrepareEach", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			stmt, err := db.Prepare(`UPDATE interval SET start_time=?, actual_duration=?, state=?, label=?,
+				auto_paused=?, paused_duration=?, paused_at=? WHERE id=?`)
+			if err != nil {
+				b.Fatal(err)
+			}
+			_, err = stmt.Exec(i.StartTime.UTC(), n, i.State, i.Label,
+				i.AutoPaused, 0, i.PausedAt.UTC(), i.ID)
+			stmt.Close()
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkCreateBatch(b *testing.B) {
+	repo, _ := openSQLite(b)
+
+	start := time.Date(2023, time.January, 1, 9, 0, 0, 0, time.Local)
+	intervals := make([]pomodoro.Interval, 1000)
+	for k := range intervals {
+		intervals[k] = pomodoro.Interval{
+			StartTime: start.Add(time.Duration(k) * 30 * time.Minute), PlannedDuration: 25 * time.Minute,
+			ActualDuration: 25 * time.Minute, Category: pomodoro.CategoryPomodoro, State: pomodoro.StateDone,
+		}
+	}
+
+	b.Run("Batch", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			if _, err := repo.(pomodoro.BatchCreator).CreateBatch(intervals); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Create", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			for _, i := range intervals {
+				if _, err := repo.Create(i); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
+
+func BenchmarkDailyTotals(b *testing.B) {
+	repo, _ := openSQLite(b)
+
+	start := time.Date(2022, time.January, 1, 9, 0, 0, 0, time.Local)
+	intervals := make([]pomodoro.Interval, 0, 20000)
+	for k := 0; k < cap(intervals); k++ {
+		intervals = append(intervals, pomodoro.Interval{
+			StartTime: start.Add(time.Duration(k) * 30 * time.Minute), PlannedDuration: 25 * time.Minute,
+			ActualDuration: 25 * time.Minute, Category: pomodoro.CategoryPomodoro, State: pomodoro.StateDone,
+		})
+	}
+	if _, err := repo.(pomodoro.Importer).Import(intervals); err != nil {
+		b.Fatal(err)
+	}
+	first := time.Date(2022, time.June, 1, 0, 0, 0, 0, time.Local)
+	end := first.AddDate(0, 0, 14)
+
+	b.Run("DailyTotals", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			if _, err := repo.DailyTotals(first, end, pomodoro.CategoryPomodoro, 0, ""); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("PerDay", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			for day := first; day.Before(end); day = day.AddDate(0, 0, 1) {
+				if _, err := repo.CategorySummary(day, pomodoro.CategoryPomodoro, 0); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
+
+func TestBackup(t *testing.T) {
+	repo, _ := openSQLite(t)
+	start := time.Date(2023, time.May, 10, 9, 0, 0, 0, time.Local)
+
+	// Keep writing while the backup is taken
+	stop := make(chan struct{})
+	written := make(chan error)
+	go func() {
+		for k := 0; ; k++ {
+			select {
+			case <-stop:
+				written <- nil
+				return
+			default:
+			}
+			i := pomodoro.Interval{
+				StartTime:      start.Add(time.Duration(k) * time.Minute),
+				Category:       pomodoro.CategoryPomodoro,
+				State:          pomodoro.StateDone,
+				ActualDuration: time.Minute,
+			}
+			if _, err := repo.Create(i); err != nil {
+				written <- err
+				return
+			}
+		}
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	dest := filepath.Join(t.TempDir(), "backup.db")
+	n, err := repo.(pomodoro.Backuper).Backup(dest, false)
+	close(stop)
+	if err := <-written; err != nil {
+		t.Fatal(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n == 0 || n != fi.Size() {
+		t.Errorf("expected the size of the backup, %d bytes, got %d", fi.Size(), n)
+	}
+
+	backup, err := repository.NewSQLite3Repo(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backup.Close()
+	if _, err := backup.Last(); err != nil {
+		t.Errorf("expected intervals in the backup, got %v", err)
+	}
+
+	if _, err := repo.(pomodoro.Backuper).Backup(dest, false); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("expected error %q, got %v", fs.ErrExist, err)
+	}
+	if _, err := repo.(pomodoro.Backuper).Backup(dest, true); err != nil {
+		t.Errorf("expected the backup to be overwritten, got %v", err)
+	}
+}
+
+func TestDatabaseLock(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("databases aren't locked on windows")
+	}
+
+	path := filepath.Join(t.TempDir(), "pomo.db")
+	repo, err := repository.NewSQLite3Repo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A second instance fails right away, naming the holder
+	start := time.Now()
+	_, err = repository.NewSQLite3Repo(path)
+	if !errors.Is(err, repository.ErrDatabaseInUse) {
+		t.Fatalf("expected error %q, got %v", repository.ErrDatabaseInUse, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected to fail fast, waited %s", elapsed)
+	}
+	if pid := strconv.Itoa(os.Getpid()); !strings.Contains(err.Error(), pid) {
+		t.Errorf("expected error naming process %s, got %q", pid, err)
+	}
+
+	// Readers don't need the lock
+	reader, err := repository.NewSQLite3RepoUnlocked(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.Last(); !errors.Is(err, pomodoro.ErrNoIntervals) {
+		t.Errorf("expected error %q, got %v", pomodoro.ErrNoIntervals, err)
+	}
+	reader.Close()
+
+	// Closing releases it
+	repo.Close()
+	repo, err = repository.NewSQLite3Repo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.Close()
+}
+
+func TestCreateBatchRollback(t *testing.T) {
+	repo, db := openSQLite(t)
+
+	// Fail the insert of the third interval
+	_, err := db.Exec(`CREATE TRIGGER fail BEFORE INSERT ON interval WHEN NEW.label='fail'
+		BEGIN SELECT RAISE(ABORT, 'injected'); END`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2023, time.May, 10, 9, 0, 0, 0, time.Local)
+	var intervals []pomodoro.Interval
+	for k, label := range []string{"a", "b", "fail", "c"} {
+		intervals = append(intervals, pomodoro.Interval{
+			StartTime: start.Add(time.Duration(k) * time.Hour), Category: pomodoro.CategoryPomodoro,
+			State: pomodoro.StateDone, Label: label,
+		})
+	}
+
+	ids, err := repo.(pomodoro.BatchCreator).CreateBatch(intervals)
+	if err == nil {
+		t.Fatalf("expected an error, got IDs %v", ids)
+	}
+	if _, err := repo.Last(); !errors.Is(err, pomodoro.ErrNoIntervals) {
+		t.Errorf("expected no intervals stored, got %v", err)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pomo #1.db")
+
+	// Missing databases aren't created
+	if _, err := repository.NewSQLite3RepoReadOnly(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected error %q, got %v", fs.ErrNotExist, err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected no database, got %v", err)
+	}
+
+	repo, err := repository.NewSQLite3Repo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := pomodoro.Interval{
+		StartTime:       time.Now().Add(-time.Hour).Truncate(time.Second),
+		PlannedDuration: 25 * time.Minute,
+		Category:        pomodoro.CategoryPomodoro,
+		State:           pomodoro.StateDone,
+	}
+	if i.ID, err = repo.Create(i); err != nil {
+		t.Fatal(err)
+	}
+	repo.Close()
+
+	mtime := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	// Read-only repositories don't need the lock, as they don't write
+	writer, err := repository.NewSQLite3Repo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	ro, err := repository.NewSQLite3RepoReadOnly(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ro.ByID(i.ID); err != nil || !got.StartTime.Equal(i.StartTime) {
+		t.Errorf("expected interval started at %s, got %+v, %v", i.StartTime, got, err)
+	}
+	if _, err := ro.Create(i); !errors.Is(err, pomodoro.ErrReadOnly) {
+		t.Errorf("expected error %q, got %v", pomodoro.ErrReadOnly, err)
+	}
+	if err := ro.SetNote(i.ID, "note"); !errors.Is(err, pomodoro.ErrReadOnly) {
+		t.Errorf("expected error %q, got %v", pomodoro.ErrReadOnly, err)
+	}
+	if err := ro.(interface{ Close() error }).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.ModTime().Equal(mtime) {
+		t.Errorf("expected database modified at %s, got %s", mtime, fi.ModTime())
+	}
+}
+
+func TestReadOnlyOutdatedSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pomo.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE interval (id INTEGER PRIMARY KEY)`); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repository.NewSQLite3RepoReadOnly(path)
+	if !errors.Is(err, repository.ErrSchemaTooOld) {
+		t.Errorf("expected error %q, got %v", repository.ErrSchemaTooOld, err)
+	}
+}
+
+func TestArchiveKeepsTags(t *testing.T) {
+	repo, db := openSQLite(t)
+
+	start := time.Date(2023, time.May, 10, 9, 0, 0, 0, time.UTC)
+	var ids []int64
+	for k := 0; k < 2; k++ {
+		id, err := repo.Create(pomodoro.Interval{
+			StartTime:       start.AddDate(0, 0, k),
+			PlannedDuration: 25 * time.Minute,
+			ActualDuration:  25 * time.Minute,
+			Category:        pomodoro.CategoryPomodoro,
+			State:           pomodoro.StateDone,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if err := repo.AddTag(ids[0], "deep"); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := repo.Archive(start.AddDate(0, 0, 1)); err != nil || n != 1 {
+		t.Fatalf("expected 1 interval archived, got %d, %v", n, err)
+	}
+	var archived int
+	if err := db.QueryRow("SELECT count(*) FROM interval_archive WHERE id=?", ids[0]).Scan(&archived); err != nil {
+		t.Fatal(err)
+	}
+	if archived != 1 {
+		t.Errorf("expected interval %d in interval_archive, found %d", ids[0], archived)
+	}
+
+	// Pruning the live intervals leaves the tags of archived ones
+	if _, err := repo.DeleteOlderThan(start.AddDate(0, 0, 1)); err != nil {
+		t.Fatal(err)
+	}
+	tags, err := repo.TagsFor(ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 1 || tags[0] != "deep" {
+		t.Errorf("expected tag deep, got %v", tags)
+	}
+}
@@ -0,0 +1,63 @@
+-- A database at schema version 20, the last storing durations as Go
+-- nanosecond counts. Interval 5 was written in seconds by another tool.
+CREATE TABLE "schema_migrations" (
+	"version" INTEGER NOT NULL,
+	"applied_at" DATETIME NOT NULL,
+	PRIMARY KEY("version")
+	);
+CREATE TABLE "interval" (
+	"id" INTEGER,
+	"start_time" DATETIME NOT NULL,
+	"planned_duration" INTEGER DEFAULT 0,
+	"actual_duration" INTEGER DEFAULT 0,
+	"category" TEXT NOT NULL,
+	"state" INTEGER DEFAULT 1, "label" TEXT NOT NULL DEFAULT '', "daily_ordinal" INTEGER NOT NULL DEFAULT 0, "interruptions" INTEGER NOT NULL DEFAULT 0, "note" TEXT NOT NULL DEFAULT '', "task_id" INTEGER NOT NULL DEFAULT 0, "auto_paused" INTEGER NOT NULL DEFAULT 0, "paused_duration" INTEGER NOT NULL DEFAULT 0, "paused_at" DATETIME NOT NULL DEFAULT '0001-01-01 00:00:00+00:00', "version" INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY("id")
+	);
+CREATE TABLE "task" (
+	"id" INTEGER,
+	"name" TEXT NOT NULL UNIQUE,
+	"estimate" INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY("id")
+	);
+CREATE INDEX "interval_start_time"
+	ON "interval" ("start_time");
+CREATE INDEX "interval_start_time_category"
+	ON "interval" ("start_time", "category");
+CREATE INDEX "interval_category_id"
+	ON "interval" ("category", "id" DESC);
+CREATE TABLE "tags" (
+	"id" INTEGER,
+	"name" TEXT NOT NULL UNIQUE,
+	PRIMARY KEY("id")
+	);
+CREATE TABLE "interval_tags" (
+	"interval_id" INTEGER NOT NULL,
+	"tag_id" INTEGER NOT NULL,
+	PRIMARY KEY("interval_id", "tag_id")
+	);
+CREATE TABLE "interval_events" (
+	"id" INTEGER,
+	"interval_id" INTEGER NOT NULL,
+	"from_state" INTEGER NOT NULL,
+	"to_state" INTEGER NOT NULL,
+	"time" DATETIME NOT NULL,
+	PRIMARY KEY("id")
+	);
+CREATE INDEX "interval_events_interval_id"
+	ON "interval_events" ("interval_id");
+CREATE INDEX "interval_category_start_time"
+	ON "interval" ("category", "start_time" DESC);
+
+WITH RECURSIVE v(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM v WHERE n < 20)
+INSERT INTO schema_migrations (version, applied_at) SELECT n, '2023-05-01 00:00:00+00:00' FROM v;
+
+INSERT INTO interval (id, start_time, planned_duration, actual_duration, category, state, label, paused_duration) VALUES
+	(1, '2023-05-10 09:00:00+00:00', 1500000000000, 1500000000000, 'Pomodoro', 3, 'report', 0),
+	(2, '2023-05-10 09:30:00+00:00', 1500000000000, 1500000000000, 'Pomodoro', 3, '', 0),
+	(3, '2023-05-10 10:00:00+00:00', 1500000000000, 1200000000000, 'Pomodoro', 4, '', 0),
+	(4, '2023-05-10 10:30:00+00:00', 300000000000, 300000000000, 'ShortBreak', 3, '', 90000000000),
+	(5, '2023-05-10 11:00:00+00:00', 900, 900, 'LongBreak', 3, '', 0),
+	(6, '2023-05-11 09:00:00+00:00', 1500000000000, 1500000000000, 'Pomodoro', 3, '', 0);
+INSERT INTO tags (id, name) VALUES (1, 'deep');
+INSERT INTO interval_tags (interval_id, tag_id) VALUES (1, 1);
@@ -0,0 +1,76 @@
+package pomodoro
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidRoundMode is returned by ParseRoundMode for unknown modes
+var ErrInvalidRoundMode = errors.New("invalid round mode")
+
+// RoundMode picks the way RoundDuration rounds
+type RoundMode int
+
+const (
+	// RoundNearest rounds to the nearest multiple, halfway values away
+	// from zero
+	RoundNearest RoundMode = iota
+	// RoundFloor rounds down
+	RoundFloor
+	// RoundCeil rounds up
+	RoundCeil
+)
+
+var roundModeNames = map[RoundMode]string{
+	RoundNearest: "nearest",
+	RoundFloor:   "floor",
+	RoundCeil:    "ceil",
+}
+
+func (m RoundMode) String() string {
+	if name, ok := roundModeNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("RoundMode(%d)", int(m))
+}
+
+// ParseRoundMode returns the mode named name, "nearest", "floor" or "ceil"
+func ParseRoundMode(name string) (RoundMode, error) {
+	for m, n := range roundModeNames {
+		if n == name {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %q", ErrInvalidRoundMode, name)
+}
+
+// RoundDuration rounds d to a multiple of to. Durations are left alone
+// when to isn't positive
+func RoundDuration(d, to time.Duration, mode RoundMode) time.Duration {
+	if to <= 0 {
+		return d
+	}
+
+	r := d % to
+	switch mode {
+	case RoundFloor:
+		if r < 0 {
+			return d - r - to
+		}
+		return d - r
+	case RoundCeil:
+		if r > 0 {
+			return d - r + to
+		}
+		return d - r
+	default:
+		return d.Round(to)
+	}
+}
+
+// RoundReport rounds a duration shown in reports as ReportRounding and
+// ReportRoundMode set. Stored durations are never rounded
+func (c *IntervalConfig) RoundReport(d time.Duration) time.Duration {
+	return RoundDuration(d, c.ReportRounding, c.ReportRoundMode)
+}
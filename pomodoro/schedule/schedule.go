@@ -0,0 +1,181 @@
+// Package schedule decides which interval comes next in a pomodoro cycle.
+// It works on a minimal view of the interval history and has no knowledge
+// of how intervals are stored or displayed, so it only depends on the
+// standard library.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Category constants, matching the ones of the pomodoro package
+const (
+	CategoryPomodoro   = "Pomodoro"
+	CategoryShortBreak = "ShortBreak"
+	CategoryLongBreak  = "LongBreak"
+	// CategorySnooze is extra work postponing a break. It continues the
+	// pomodoro before it and doesn't count as a break
+	CategorySnooze = "Snooze"
+)
+
+// DefaultLongBreakEvery is the number of pomodoros in a cycle when the
+// policy doesn't say otherwise
+const DefaultLongBreakEvery = 4
+
+// ErrNoHistory is returned by a History that holds no intervals
+var ErrNoHistory = errors.New("no history")
+
+// Entry is the part of a past interval the scheduler needs
+type Entry struct {
+	Category       string
+	StartTime      time.Time
+	ActualDuration time.Duration
+}
+
+// IsWork reports whether the entry is a pomodoro or a snooze extending it
+func (e Entry) IsWork() bool {
+	return e.Category == CategoryPomodoro || e.Category == CategorySnooze
+}
+
+// IsBreak reports whether the entry is a short or long break
+func (e Entry) IsBreak() bool {
+	return e.Category == CategoryShortBreak || e.Category == CategoryLongBreak
+}
+
+// History provides the recent intervals
+type History interface {
+	// Last returns the most recent interval or ErrNoHistory
+	Last() (Entry, error)
+	// Breaks returns up to n most recent breaks, most recent first
+	Breaks(n int) ([]Entry, error)
+}
+
+// FocusHistory is a History that can also tell how much work was done
+// since the last long break
+type FocusHistory interface {
+	History
+	// WorkSinceLongBreak sums today's work since the last long break
+	WorkSinceLongBreak() (time.Duration, error)
+}
+
+// ErrFocusUnsupported is returned when the policy needs a FocusHistory but
+// the history can't provide the work done since the last long break
+var ErrFocusUnsupported = errors.New("history doesn't track focus time")
+
+// Policy tunes how breaks are chosen. When LongBreakAfter is set it takes
+// precedence and LongBreakEvery is ignored.
+type Policy struct {
+	// LongBreakEvery is the number of pomodoros between long breaks.
+	// Zero means DefaultLongBreakEvery
+	LongBreakEvery int
+	// LongBreakAfter, when non-zero, triggers a long break once this much
+	// work was done today since the last long break
+	LongBreakAfter time.Duration
+}
+
+func (p Policy) longBreakEvery() int {
+	if p.LongBreakEvery <= 0 {
+		return DefaultLongBreakEvery
+	}
+	return p.LongBreakEvery
+}
+
+// Decision is the category chosen for the next interval along with the
+// reasons that led to it
+type Decision struct {
+	Category string
+	Reasons  []string
+}
+
+func decide(category string, reasons ...string) Decision {
+	return Decision{Category: category, Reasons: reasons}
+}
+
+// Next decides the category of the interval following the last one in h
+func Next(h History, p Policy) (Decision, error) {
+	last, err := h.Last()
+	if errors.Is(err, ErrNoHistory) {
+		return decide(CategoryPomodoro, "no previous intervals"), nil
+	}
+	if err != nil {
+		return Decision{}, err
+	}
+
+	// If we are in a break, next stage is work
+	if last.IsBreak() {
+		return decide(CategoryPomodoro, fmt.Sprintf("last interval was a %s", last.Category)), nil
+	}
+
+	if last.Category == CategorySnooze {
+		d, err := nextBreak(h, p)
+		d.Reasons = append(d.Reasons, "snooze continues the previous pomodoro")
+		return d, err
+	}
+
+	return nextBreak(h, p)
+}
+
+func nextBreak(h History, p Policy) (Decision, error) {
+	if p.LongBreakAfter > 0 {
+		return nextBreakByFocus(h, p)
+	}
+
+	every := p.longBreakEvery()
+	lastBreaks, err := h.Breaks(every - 1)
+	if err != nil {
+		return Decision{}, err
+	}
+
+	// After every nth work interval, there should be a long break
+	if len(lastBreaks) < every-1 {
+		return decide(CategoryShortBreak,
+			fmt.Sprintf("only %d breaks so far, long break comes after %d pomodoros", len(lastBreaks), every)), nil
+	}
+	// If there was already a long break in the cycle, we'll return a short break
+	for _, b := range lastBreaks {
+		if b.Category == CategoryLongBreak {
+			return decide(CategoryShortBreak,
+				fmt.Sprintf("a long break was taken within the last %d breaks", every-1)), nil
+		}
+	}
+	return decide(CategoryLongBreak,
+		fmt.Sprintf("%d pomodoros completed since the last long break", every)), nil
+}
+
+func nextBreakByFocus(h History, p Policy) (Decision, error) {
+	fh, ok := h.(FocusHistory)
+	if !ok {
+		return Decision{}, ErrFocusUnsupported
+	}
+	work, err := fh.WorkSinceLongBreak()
+	if err != nil {
+		return Decision{}, err
+	}
+	if work >= p.LongBreakAfter {
+		return decide(CategoryLongBreak,
+			fmt.Sprintf("%s of work since the last long break reached %s", work, p.LongBreakAfter)), nil
+	}
+	return decide(CategoryShortBreak,
+		fmt.Sprintf("%s of work since the last long break is below %s", work, p.LongBreakAfter)), nil
+}
+
+// CyclePosition returns the 1-based position within the cycle of the
+// current pomodoro, or of the upcoming one if the last interval was a break
+func CyclePosition(h History, p Policy) (int, error) {
+	every := p.longBreakEvery()
+	breaks, err := h.Breaks(every)
+	if err != nil {
+		return 0, err
+	}
+
+	shorts := 0
+	for _, b := range breaks {
+		if b.Category == CategoryLongBreak {
+			break
+		}
+		shorts++
+	}
+	return shorts%every + 1, nil
+}
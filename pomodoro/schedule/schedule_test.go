@@ -0,0 +1,213 @@
+package schedule_test
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro/schedule"
+)
+
+// history is an in-memory History built from categories, oldest first
+type history struct {
+	entries []schedule.Entry
+	err     error
+}
+
+func newHistory(categories ...string) *history {
+	h := &history{}
+	for _, c := range categories {
+		h.entries = append(h.entries, schedule.Entry{Category: c})
+	}
+	return h
+}
+
+func (h *history) Last() (schedule.Entry, error) {
+	if h.err != nil {
+		return schedule.Entry{}, h.err
+	}
+	if len(h.entries) == 0 {
+		return schedule.Entry{}, schedule.ErrNoHistory
+	}
+	return h.entries[len(h.entries)-1], nil
+}
+
+func (h *history) Breaks(n int) ([]schedule.Entry, error) {
+	if h.err != nil {
+		return nil, h.err
+	}
+	var breaks []schedule.Entry
+	for k := len(h.entries) - 1; k >= 0 && len(breaks) < n; k-- {
+		if h.entries[k].IsBreak() {
+			breaks = append(breaks, h.entries[k])
+		}
+	}
+	return breaks, nil
+}
+
+// focusHistory adds the work since the last long break to history
+type focusHistory struct {
+	*history
+	work time.Duration
+}
+
+func (h focusHistory) WorkSinceLongBreak() (time.Duration, error) {
+	return h.work, nil
+}
+
+const (
+	P = schedule.CategoryPomodoro
+	S = schedule.CategoryShortBreak
+	L = schedule.CategoryLongBreak
+	Z = schedule.CategorySnooze
+)
+
+func TestNext(t *testing.T) {
+	testCases := []struct {
+		name    string
+		history []string
+		policy  schedule.Policy
+		expect  string
+	}{
+		{name: "Empty", expect: P},
+		{name: "AfterShortBreak", history: []string{P, S}, expect: P},
+		{name: "AfterLongBreak", history: []string{P, S, P, S, P, S, P, L}, expect: P},
+		{name: "FirstPomodoro", history: []string{P}, expect: S},
+		{name: "ThirdPomodoro", history: []string{P, S, P, S, P}, expect: S},
+		{name: "FourthPomodoro", history: []string{P, S, P, S, P, S, P}, expect: L},
+		{name: "SecondCycle", history: []string{P, S, P, S, P, S, P, L, P}, expect: S},
+		{name: "SecondCycleEnd", history: []string{P, S, P, S, P, S, P, L, P, S, P, S, P, S, P}, expect: L},
+		{name: "LongBreakInWindow", history: []string{P, L, P, S, P, S, P}, expect: S},
+		{name: "CustomEveryTwo", history: []string{P, S, P}, policy: schedule.Policy{LongBreakEvery: 2}, expect: L},
+		{name: "CustomEveryTwoAfterLong", history: []string{P, S, P, L, P}, policy: schedule.Policy{LongBreakEvery: 2}, expect: S},
+		{name: "CustomEverySix", history: []string{P, S, P, S, P, S, P}, policy: schedule.Policy{LongBreakEvery: 6}, expect: S},
+		{name: "CustomEveryOne", history: []string{P, L, P}, policy: schedule.Policy{LongBreakEvery: 1}, expect: L},
+		{name: "AfterSnooze", history: []string{P, Z}, expect: S},
+		{name: "AfterTwoSnoozes", history: []string{P, Z, Z}, expect: S},
+		{name: "SnoozeBeforeLongBreak", history: []string{P, S, P, S, P, S, P, Z}, expect: L},
+		{name: "AfterSnoozedBreak", history: []string{P, Z, S}, expect: P},
+		{name: "NegativeEveryUsesDefault", history: []string{P, S, P, S, P, S, P}, policy: schedule.Policy{LongBreakEvery: -1}, expect: L},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := schedule.Next(newHistory(tt.history...), tt.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Category != tt.expect {
+				t.Errorf("expected %q, got %q (reasons: %v)", tt.expect, d.Category, d.Reasons)
+			}
+			if len(d.Reasons) == 0 {
+				t.Errorf("expected the decision to be explained")
+			}
+		})
+	}
+}
+
+func TestNextByFocus(t *testing.T) {
+	policy := schedule.Policy{LongBreakAfter: 90 * time.Minute}
+
+	testCases := []struct {
+		name    string
+		history []string
+		work    time.Duration
+		policy  schedule.Policy
+		expect  string
+	}{
+		{name: "BelowThreshold", history: []string{P}, work: 25 * time.Minute, policy: policy, expect: S},
+		{name: "AtThreshold", history: []string{P, S, P}, work: 90 * time.Minute, policy: policy, expect: L},
+		{name: "ExtendedPomodoros", history: []string{P, S, P}, work: 100 * time.Minute, policy: policy, expect: L},
+		{name: "ManyShortPomodoros", history: []string{P, S, P, S, P, S, P}, work: 60 * time.Minute, policy: policy, expect: S},
+		{name: "Snooze", history: []string{P, Z}, work: 95 * time.Minute, policy: policy, expect: L},
+		{name: "AfterBreakIsWork", history: []string{P, L}, work: 0, policy: policy, expect: P},
+		{name: "TakesPrecedence", history: []string{P, S, P, S, P, S, P}, work: time.Minute,
+			policy: schedule.Policy{LongBreakEvery: 4, LongBreakAfter: time.Hour}, expect: S},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			h := focusHistory{newHistory(tt.history...), tt.work}
+			d, err := schedule.Next(h, tt.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Category != tt.expect {
+				t.Errorf("expected %q, got %q (reasons: %v)", tt.expect, d.Category, d.Reasons)
+			}
+		})
+	}
+
+	t.Run("Unsupported", func(t *testing.T) {
+		_, err := schedule.Next(newHistory(P), policy)
+		if !errors.Is(err, schedule.ErrFocusUnsupported) {
+			t.Errorf("expected error %q, got %v", schedule.ErrFocusUnsupported, err)
+		}
+	})
+}
+
+func TestNextError(t *testing.T) {
+	expErr := errors.New("broken")
+	h := newHistory(P)
+	h.err = expErr
+
+	if _, err := schedule.Next(h, schedule.Policy{}); !errors.Is(err, expErr) {
+		t.Errorf("expected error %q, got %v", expErr, err)
+	}
+}
+
+func TestCyclePosition(t *testing.T) {
+	testCases := []struct {
+		name    string
+		history []string
+		policy  schedule.Policy
+		expect  int
+	}{
+		{name: "Empty", expect: 1},
+		{name: "FirstRunning", history: []string{P}, expect: 1},
+		{name: "AfterFirstBreak", history: []string{P, S}, expect: 2},
+		{name: "FourthRunning", history: []string{P, S, P, S, P, S, P}, expect: 4},
+		{name: "AfterLongBreak", history: []string{P, S, P, S, P, S, P, L}, expect: 1},
+		{name: "SecondCycle", history: []string{P, S, P, S, P, S, P, L, P, S, P}, expect: 2},
+		{name: "CustomEveryTwo", history: []string{P, S, P}, policy: schedule.Policy{LongBreakEvery: 2}, expect: 2},
+		{name: "Snoozing", history: []string{P, S, P, Z}, expect: 2},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			pos, err := schedule.CyclePosition(newHistory(tt.history...), tt.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pos != tt.expect {
+				t.Errorf("expected position %d, got %d", tt.expect, pos)
+			}
+		})
+	}
+}
+
+// TestNoStorageDependencies makes sure the package stays usable without
+// the repository or the terminal UI
+func TestNoStorageDependencies(t *testing.T) {
+	out, err := exec.Command("go", "list", "-deps", ".").Output()
+	if err != nil {
+		t.Skipf("cannot list dependencies: %v", err)
+	}
+
+	forbidden := []string{
+		"database/sql",
+		"github.com/mattn/go-sqlite3",
+		"github.com/mum4k/termdash",
+		"github.com/snirkop89/pomo/pomodoro/repository",
+		"github.com/snirkop89/pomo/tui",
+	}
+	for _, dep := range strings.Fields(string(out)) {
+		for _, f := range forbidden {
+			if dep == f || strings.HasPrefix(dep, f+"/") {
+				t.Errorf("schedule must not depend on %s", dep)
+			}
+		}
+	}
+}
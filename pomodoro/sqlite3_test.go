@@ -1,11 +1,16 @@
-//go:build !inmemory
+//go:build !inmemory && !postgres && !bolt && !file
 
 package pomodoro_test
 
 import (
+	"context"
+	"database/sql"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	_ "github.com/mattn/go-sqlite3"
 	"github.com/snirkop89/pomo/pomodoro"
 	"github.com/snirkop89/pomo/pomodoro/repository"
 )
@@ -25,7 +30,99 @@ func getRepo(t *testing.T) (pomodoro.Repository, func()) {
 	}
 
 	return dbRepo, func() {
+		if err := dbRepo.Close(); err != nil {
+			t.Error(err)
+		}
 		os.Remove(tf.Name())
+		os.Remove(tf.Name() + ".lock")
+	}
+}
+
+func TestEphemeralConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pomo.db")
+	repo, err := repository.NewSQLite3Repo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	if _, err := repo.Create(pomodoro.Interval{
+		StartTime: time.Date(2023, time.May, 10, 9, 0, 0, 0, time.UTC),
+		Category:  pomodoro.CategoryPomodoro,
+		State:     pomodoro.StateDone,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	rows := func() int {
+		t.Helper()
+		var n int
+		if err := db.QueryRow("SELECT count(*) FROM interval").Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	before := rows()
+
+	config := pomodoro.NewEphemeralConfig(4*time.Second, time.Second, 2*time.Second)
+	config.Clock = pomodoro.NewScaledClock(100)
+	noop := func(pomodoro.Interval) {}
+
+	// A pomodoro paused half way, resumed, then the rest of the cycle
+	i, err := pomodoro.GetInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pause := func(i pomodoro.Interval) {
+		if i.ActualDuration == 2*time.Second {
+			if err := i.Pause(config); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := i.Start(context.Background(), config, noop, pause, noop); err != nil {
+		t.Fatal(err)
+	}
+	i, _, err = pomodoro.PeekInterval(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.State != pomodoro.StatePaused {
+		t.Fatalf("expected state %d, got %d", pomodoro.StatePaused, i.State)
+	}
+	if err := i.Start(context.Background(), config, noop, noop, noop); err != nil {
+		t.Fatal(err)
+	}
+	for k := 0; k < 7; k++ {
+		i, err := pomodoro.GetInterval(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := i.Start(context.Background(), config, noop, noop, noop); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	total, err := pomodoro.TotalCompleted(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 4 {
+		t.Errorf("expected 4 completed pomodoros, got %d", total)
+	}
+	ds, err := pomodoro.DailySummary(config.Now(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds[0] == 0 || ds[1] == 0 {
+		t.Errorf("expected work and breaks in the summary, got %v", ds)
 	}
 
+	// Nothing reached the database
+	if after := rows(); after != before {
+		t.Errorf("expected %d rows left alone, got %d", before, after)
+	}
 }
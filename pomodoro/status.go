@@ -0,0 +1,53 @@
+package pomodoro
+
+import "time"
+
+// Status is a read-only snapshot of the timer, meant for status lines and
+// UIs
+type Status struct {
+	// Interval is the active interval, or the one that would be created
+	// next when Active is false
+	Interval Interval
+	Active   bool
+	// Remaining is the time left in Interval and EndsAt the time it's
+	// projected to end
+	Remaining time.Duration
+	EndsAt    time.Time
+	// NextCategory and NextDuration describe the interval coming after
+	// the active or most recent one. With nothing active, that's Interval
+	NextCategory   string
+	NextDuration   time.Duration
+	CompletedToday int64
+}
+
+// NextCategory returns the category and planned duration of the interval
+// coming after the running or most recent one. It never writes to the
+// repository, so it's safe to call while an interval is running.
+func NextCategory(config *IntervalConfig) (string, time.Duration, error) {
+	return next(config)
+}
+
+// GetStatus returns a snapshot of the timer without writing to the
+// repository
+func GetStatus(config *IntervalConfig) (Status, error) {
+	i, active, err := PeekInterval(config)
+	if err != nil {
+		return Status{}, err
+	}
+
+	s := Status{
+		Interval:  i,
+		Active:    active,
+		Remaining: i.PlannedDuration - i.ActualDuration,
+		EndsAt:    i.ProjectedEnd(config.Now()),
+	}
+
+	if s.NextCategory, s.NextDuration, err = NextCategory(config); err != nil {
+		return Status{}, err
+	}
+
+	if s.CompletedToday, err = completedOn(config.store(), config.today()); err != nil {
+		return Status{}, err
+	}
+	return s, nil
+}
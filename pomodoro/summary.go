@@ -1,19 +1,83 @@
 package pomodoro
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"time"
 )
 
-func DailySummary(day time.Time, config *IntervalConfig) ([]time.Duration, error) {
-	dPomo, err := config.repo.CategorySummary(day, CategoryPomodoro)
+// DayLayout formats the days keying Repository.DailyTotals and
+// Repository.CompletedPerDay
+const DayLayout = "2006-01-02"
+
+// SummaryOption narrows the intervals counted by a summary
+type SummaryOption func(*summaryOptions)
+
+type summaryOptions struct {
+	tag string
+}
+
+// WithTag only counts the intervals with tag
+func WithTag(tag string) SummaryOption {
+	return func(o *summaryOptions) {
+		o.tag = tag
+	}
+}
+
+// applySummaryOptions returns the options set by opts, the tag normalized.
+// It fails with ErrInvalidTag if the tag can't be
+func applySummaryOptions(opts []SummaryOption) (summaryOptions, error) {
+	var o summaryOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.tag != "" {
+		t, err := NormalizeTag(o.tag)
+		if err != nil {
+			return o, err
+		}
+		o.tag = t
+	}
+	return o, nil
+}
+
+// DailySummary returns the work and break durations for the calendar day of
+// day in the configured location. Only completed intervals are counted, see
+// IntervalConfig.SummaryCancelledRatio. By default intervals aren't filtered.
+// Failures are SummaryErrors for the day
+func DailySummary(day time.Time, config *IntervalConfig, opts ...SummaryOption) ([]time.Duration, error) {
+	day = day.In(config.location())
+
+	o, err := applySummaryOptions(opts)
 	if err != nil {
 		return nil, err
 	}
+	if o.tag != "" {
+		first := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+		focus, breaks, err := dailyTotals(config, first, first.AddDate(0, 0, 1), o)
+		if err != nil {
+			return nil, err
+		}
+		key := first.Format(DayLayout)
+		return []time.Duration{focus[key], breaks[key]}, nil
+	}
 
-	dBreaks, err := config.repo.CategorySummary(day, "%Break")
+	dPomo, err := config.store().CategorySummary(day, CategoryPomodoro, config.SummaryCancelledRatio)
 	if err != nil {
-		return nil, err
+		return nil, daySummaryError(day, CategoryPomodoro, err)
+	}
+
+	// Snoozing a break is extra work
+	dSnooze, err := config.store().CategorySummary(day, CategorySnooze, config.SummaryCancelledRatio)
+	if err != nil {
+		return nil, daySummaryError(day, CategorySnooze, err)
+	}
+	dPomo += dSnooze
+
+	dBreaks, err := config.store().CategorySummary(day, "%Break", config.SummaryCancelledRatio)
+	if err != nil {
+		return nil, daySummaryError(day, "%Break", err)
 	}
 
 	return []time.Duration{
@@ -22,13 +86,307 @@ func DailySummary(day time.Time, config *IntervalConfig) ([]time.Duration, error
 	}, nil
 }
 
+// DailyInterruptions returns the number of interruptions recorded on day
+func DailyInterruptions(day time.Time, config *IntervalConfig) (int, error) {
+	intervals, err := config.store().Find(dayFilter(day.In(config.location())))
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for _, i := range intervals {
+		n += i.Interruptions
+	}
+	return n, nil
+}
+
+// CountStates counts the intervals of category in intervals started in
+// [start, end) by state, as Repository.StateCounts does. Repositories that
+// filter in Go use it
+func CountStates(intervals []Interval, start, end time.Time, category string) map[int]int {
+	counts := make(map[int]int)
+	for _, i := range intervals {
+		if i.Category != category || i.StartTime.IsZero() ||
+			i.StartTime.Before(start) || !i.StartTime.Before(end) {
+			continue
+		}
+		counts[i.State]++
+	}
+	return counts
+}
+
+// DayStats counts the intervals of category started on day, in the
+// location of config, by state. Intervals that never started aren't
+// counted
+func DayStats(day time.Time, category string, config *IntervalConfig) (map[int]int, error) {
+	f := dayFilter(day.In(config.location()))
+	return config.store().StateCounts(f.From, f.To, category)
+}
+
+// FocusQuality is how much the work of a day was disrupted
+type FocusQuality struct {
+	Paused        time.Duration
+	Interruptions int
+}
+
+// FocusQualitySummary returns the paused time and interruptions of the
+// intervals started on day
+func FocusQualitySummary(day time.Time, config *IntervalConfig) (FocusQuality, error) {
+	intervals, err := config.store().Find(dayFilter(day.In(config.location())))
+	if err != nil {
+		return FocusQuality{}, err
+	}
+	var q FocusQuality
+	for _, i := range intervals {
+		q.Paused += i.PausedDuration
+		q.Interruptions += i.Interruptions
+	}
+	return q, nil
+}
+
+// NoLabel is the label LabelSummary groups unlabeled intervals under
+const NoLabel = "(none)"
+
+// LabelTotal is the work done under a label
+type LabelTotal struct {
+	Label string
+	// Pomodoros counts the done pomodoros with the label
+	Pomodoros int64
+	// FocusTime is the time spent in them
+	FocusTime time.Duration
+}
+
+// LabelSummary returns the done pomodoros started in [start, end) totalled
+// by label, the longest focus time first
+func LabelSummary(config *IntervalConfig, start, end time.Time) ([]LabelTotal, error) {
+	return config.store().LabelSummary(start, end)
+}
+
+// SumByLabel totals the done pomodoros in intervals started in
+// [start, end) as Repository.LabelSummary does. Repositories that filter
+// in Go use it
+func SumByLabel(intervals []Interval, start, end time.Time) []LabelTotal {
+	byLabel := make(map[string]*LabelTotal)
+	for _, i := range intervals {
+		if i.Category != CategoryPomodoro || i.State != StateDone ||
+			i.StartTime.Before(start) || !i.StartTime.Before(end) {
+			continue
+		}
+		label := i.Label
+		if label == "" {
+			label = NoLabel
+		}
+		t, ok := byLabel[label]
+		if !ok {
+			t = &LabelTotal{Label: label}
+			byLabel[label] = t
+		}
+		t.Pomodoros++
+		t.FocusTime += i.ActualDuration
+	}
+
+	var totals []LabelTotal
+	for _, t := range byLabel {
+		totals = append(totals, *t)
+	}
+	sort.Slice(totals, func(a, b int) bool {
+		if totals[a].FocusTime != totals[b].FocusTime {
+			return totals[a].FocusTime > totals[b].FocusTime
+		}
+		return totals[a].Label < totals[b].Label
+	})
+	return totals
+}
+
+// CompletedPerDay counts the pomodoros completed on each day of
+// [start, end) in the configured location, keyed by DayLayout. Days
+// without any are left out, for callers to fill
+func CompletedPerDay(config *IntervalConfig, start, end time.Time) (map[string]int, error) {
+	return config.store().CompletedPerDay(start.In(config.location()), end)
+}
+
+// DeviceSummary returns the time spent in the done pomodoros started in
+// [start, end), by the device they were recorded on. Intervals recorded
+// before devices were are totalled under ""
+func DeviceSummary(config *IntervalConfig, start, end time.Time) (map[string]time.Duration, error) {
+	return config.store().DeviceSummary(start, end)
+}
+
+// SumByDevice totals the done pomodoros in intervals started in
+// [start, end) as Repository.DeviceSummary does. Repositories that filter
+// in Go use it
+func SumByDevice(intervals []Interval, start, end time.Time) map[string]time.Duration {
+	totals := make(map[string]time.Duration)
+	for _, i := range intervals {
+		if i.Category != CategoryPomodoro || i.State != StateDone ||
+			i.StartTime.Before(start) || !i.StartTime.Before(end) {
+			continue
+		}
+		totals[i.Device] += i.ActualDuration
+	}
+	return totals
+}
+
+// Stats counts how the pomodoros started in a range ended
+type Stats struct {
+	Done      int
+	Cancelled int
+	Skipped   int
+	// Ratio is the share of done pomodoros among those that ended, 0
+	// unless HasRatio
+	Ratio float64
+	// HasRatio is false when no pomodoro ended, so there's no ratio
+	HasRatio bool
+}
+
+// CompletionStats counts the pomodoros started in [start, end) by how
+// they ended. Those that never started aren't counted
+func CompletionStats(start, end time.Time, config *IntervalConfig) (Stats, error) {
+	counts, err := config.store().StateCounts(start, end, CategoryPomodoro)
+	if err != nil {
+		return Stats{}, fmt.Errorf("counting pomodoros: %w", err)
+	}
+
+	s := Stats{
+		Done:      counts[StateDone],
+		Cancelled: counts[StateCancelled],
+		Skipped:   counts[StateSkipped],
+	}
+	if ended := s.Done + s.Cancelled + s.Skipped; ended > 0 {
+		s.Ratio = float64(s.Done) / float64(ended)
+		s.HasRatio = true
+	}
+	return s, nil
+}
+
+// SessionSummary describes the intervals started in a range, session by
+// session
+type SessionSummary struct {
+	// AverageLength is the mean actual duration of the done pomodoros, 0
+	// if none was done
+	AverageLength time.Duration
+	// LongestStreak is the most pomodoros done in a row, a pomodoro
+	// cancelled or skipped ending the run. Breaks and snoozes don't
+	LongestStreak int
+	// States counts the intervals of every category by state
+	States map[int]int
+}
+
+// SessionStats summarizes the intervals started in [start, end), in the
+// order they started
+func SessionStats(start, end time.Time, config *IntervalConfig) (SessionSummary, error) {
+	intervals, err := config.store().ByRange(start, end)
+	if err != nil {
+		return SessionSummary{}, fmt.Errorf("finding intervals: %w", err)
+	}
+
+	s := SessionSummary{States: make(map[int]int)}
+	var (
+		total        time.Duration
+		done, streak int
+	)
+	for _, i := range intervals {
+		s.States[i.State]++
+		if i.Category != CategoryPomodoro {
+			continue
+		}
+		switch i.State {
+		case StateDone:
+			total += i.ActualDuration
+			done++
+			streak++
+			if streak > s.LongestStreak {
+				s.LongestStreak = streak
+			}
+		case StateCancelled, StateSkipped:
+			streak = 0
+		}
+	}
+	if done > 0 {
+		s.AverageLength = total / time.Duration(done)
+	}
+	return s, nil
+}
+
+// HourlyHistogram returns the work done in [start, end) by the hour of the
+// day in the configured location. Intervals are counted as DailySummary
+// counts them and taken to have run without pauses from their start, the
+// time in each hour going to that hour
+func HourlyHistogram(start, end time.Time, config *IntervalConfig) ([24]time.Duration, error) {
+	var hours [24]time.Duration
+
+	intervals, err := config.store().Find(Filter{
+		From:       start,
+		To:         end,
+		Categories: []string{CategoryPomodoro, CategorySnooze},
+		States:     []int{StateDone, StateCancelled},
+	})
+	if err != nil {
+		return hours, fmt.Errorf("finding intervals: %w", err)
+	}
+
+	loc := config.location()
+	for _, i := range intervals {
+		if !i.Completed(config.SummaryCancelledRatio) {
+			continue
+		}
+		t, left := i.StartTime.In(loc), i.ActualDuration
+		for left > 0 {
+			next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			d := next.Sub(t)
+			if d > left {
+				d = left
+			}
+			hours[t.Hour()] += d
+			t, left = next, left-d
+		}
+	}
+	return hours, nil
+}
+
 type LineSeries struct {
 	Name   string
 	Labels map[int]string
 	Values []float64
 }
 
-func RangeSummary(start time.Time, n int, config *IntervalConfig) ([]LineSeries, error) {
+// ErrInvalidRange is returned when asked to summarize no days
+var ErrInvalidRange = errors.New("invalid range")
+
+// RangeOpts selects the days summarized by RangeSummaryOpts
+type RangeOpts struct {
+	// End is any time on the last day summarized
+	End time.Time
+	// Days is the number of days summarized, that of End included. It
+	// must be positive
+	Days int
+}
+
+// RangeSummary returns the work and break durations of the n days ending
+// on the day of start, the last day first. See RangeSummaryOpts
+func RangeSummary(start time.Time, n int, config *IntervalConfig, opts ...SummaryOption) ([]LineSeries, error) {
+	return RangeSummaryOpts(RangeOpts{End: start, Days: n}, config, opts...)
+}
+
+// RangeSummaryOpts returns the work and break durations of the days
+// selected by opts in the configured location, the last day first, as
+// DailySummary would for each. Both series hold exactly opts.Days values,
+// zero on days without work, and as many labels, the day of the month and
+// month of each value. By default intervals aren't filtered.
+//
+// If the days can't be totalled at once, they're totalled one by one. When
+// only some of them fail, the series are returned along with a
+// PartialSummaryError, the failed days being zero. When all of them fail,
+// the SummaryError of the whole range is returned alone
+func RangeSummaryOpts(r RangeOpts, config *IntervalConfig, opts ...SummaryOption) ([]LineSeries, error) {
+	n := r.Days
+	if n <= 0 {
+		return nil, fmt.Errorf("%w: %d days", ErrInvalidRange, n)
+	}
+	o, err := applySummaryOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
 	pomodoroSeries := LineSeries{
 		Name:   "Pomodoro",
 		Labels: make(map[int]string),
@@ -40,22 +398,425 @@ func RangeSummary(start time.Time, n int, config *IntervalConfig) ([]LineSeries,
 		Values: make([]float64, n),
 	}
 
-	for i := 0; i < n; i++ {
-		day := start.AddDate(0, 0, -i)
-		ds, err := DailySummary(day, config)
-		if err != nil {
+	last := r.End.In(config.location())
+	last = time.Date(last.Year(), last.Month(), last.Day(), 0, 0, 0, 0, last.Location())
+	end := last.AddDate(0, 0, 1)
+	first := end.AddDate(0, 0, -n)
+
+	focus, breaks, err := dailyTotals(config, first, end, o)
+	if err != nil {
+		var partial error
+		if focus, breaks, partial = dailyTotalsByDay(config, first, n, o); focus == nil {
 			return nil, err
 		}
+		err = partial
+	}
+
+	for i := 0; i < n; i++ {
+		day := last.AddDate(0, 0, -i)
+		key := day.Format(DayLayout)
 		label := fmt.Sprintf("%02d/%s", day.Day(), day.Format("Jan"))
 		pomodoroSeries.Labels[i] = label
-		pomodoroSeries.Values[i] = ds[0].Seconds()
+		pomodoroSeries.Values[i] = focus[key].Seconds()
 
 		breakSeries.Labels[i] = label
-		breakSeries.Values[i] = ds[1].Seconds()
+		breakSeries.Values[i] = breaks[key].Seconds()
 	}
 
 	return []LineSeries{
 		pomodoroSeries,
 		breakSeries,
-	}, nil
+	}, err
+}
+
+// SummaryError is returned when the repository fails to total the days
+// of a summary
+type SummaryError struct {
+	// First and Last are the first and last days totalled, in DayLayout
+	First string
+	Last  string
+	// Filter is the category filter of the failed query
+	Filter string
+	Err    error
+}
+
+func (e *SummaryError) Error() string {
+	return fmt.Sprintf("summarizing %s from %s to %s: %v", e.Filter, e.First, e.Last, e.Err)
+}
+
+func (e *SummaryError) Unwrap() error { return e.Err }
+
+// daySummaryError returns a SummaryError for the day of day
+func daySummaryError(day time.Time, filter string, err error) *SummaryError {
+	key := day.Format(DayLayout)
+	return &SummaryError{First: key, Last: key, Filter: filter, Err: err}
+}
+
+// PartialSummaryError is returned along with the results of a summary when
+// some of its days couldn't be totalled
+type PartialSummaryError struct {
+	// Days is the number of days summarized
+	Days int
+	// Errs holds the error of each failed day, in order
+	Errs []*SummaryError
+}
+
+func (e *PartialSummaryError) Error() string {
+	return fmt.Sprintf("%d of %d days not summarized: %v", len(e.Errs), e.Days, e.Errs[0])
+}
+
+// Unwrap returns the error of the first failed day
+func (e *PartialSummaryError) Unwrap() error { return e.Errs[0] }
+
+// dailyTotalsByDay returns the totals of the n days from first as
+// dailyTotals does, one day at a time. Failed days are left out and
+// reported by a PartialSummaryError. The totals are nil if every day
+// failed
+func dailyTotalsByDay(config *IntervalConfig, first time.Time, n int, o summaryOptions) (focus, breaks map[string]time.Duration, err error) {
+	focus = make(map[string]time.Duration)
+	breaks = make(map[string]time.Duration)
+	partial := &PartialSummaryError{Days: n}
+
+	for k := 0; k < n; k++ {
+		day := first.AddDate(0, 0, k)
+		f, b, err := dailyTotals(config, day, day.AddDate(0, 0, 1), o)
+		if err != nil {
+			var serr *SummaryError
+			if !errors.As(err, &serr) {
+				serr = daySummaryError(day, "", err)
+			}
+			partial.Errs = append(partial.Errs, serr)
+			continue
+		}
+		for d, v := range f {
+			focus[d] += v
+		}
+		for d, v := range b {
+			breaks[d] += v
+		}
+	}
+
+	switch len(partial.Errs) {
+	case 0:
+		return focus, breaks, nil
+	case n:
+		return nil, nil, partial
+	}
+	return focus, breaks, partial
+}
+
+// dailyTotals returns the work and break durations of each day in
+// [start, end) as DailySummary does, keyed by DayLayout in the location of
+// start. Each total is fetched for the whole range at once, failing with a
+// SummaryError
+func dailyTotals(config *IntervalConfig, start, end time.Time, o summaryOptions) (focus, breaks map[string]time.Duration, err error) {
+	var totals [3]map[string]time.Duration
+	for k, filter := range []string{CategoryPomodoro, CategorySnooze, "%Break"} {
+		totals[k], err = config.store().DailyTotals(start, end, filter, config.SummaryCancelledRatio, o.tag)
+		if err != nil {
+			return nil, nil, &SummaryError{
+				First:  start.Format(DayLayout),
+				Last:   end.Add(-time.Nanosecond).Format(DayLayout),
+				Filter: filter,
+				Err:    err,
+			}
+		}
+	}
+
+	// Snoozing a break is extra work
+	focus = make(map[string]time.Duration)
+	for _, t := range totals[:2] {
+		for day, d := range t {
+			focus[day] += d
+		}
+	}
+	return focus, totals[2], nil
+}
+
+// WeekdayMode is how WeekdaySummary combines the weeks it covers
+type WeekdayMode int
+
+const (
+	// WeekdayAverage averages the focus time of each weekday over the weeks
+	WeekdayAverage WeekdayMode = iota
+	// WeekdayTotal totals it
+	WeekdayTotal
+)
+
+// WeekdaySummary returns the focus time of each weekday over the last
+// weeks weeks, today included, in the configured location. Durations are
+// those of DailySummary, days without work counting as zero. See
+// IntervalConfig.WeekdayMode
+func WeekdaySummary(weeks int, config *IntervalConfig) (map[time.Weekday]time.Duration, error) {
+	days := make(map[time.Weekday]time.Duration)
+	if weeks <= 0 {
+		return days, nil
+	}
+
+	today := config.today()
+	end := time.Date(today.Year(), today.Month(), today.Day()+1, 0, 0, 0, 0, today.Location())
+	start := end.AddDate(0, 0, -7*weeks)
+
+	focus, _, err := dailyTotals(config, start, end, summaryOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		days[day.Weekday()] += focus[day.Format(DayLayout)]
+	}
+	if config.WeekdayMode == WeekdayAverage {
+		for wd, d := range days {
+			days[wd] = d / time.Duration(weeks)
+		}
+	}
+	return days, nil
+}
+
+// WeekSummary is the work done in a week, up to End
+type WeekSummary struct {
+	Start time.Time
+	End   time.Time
+	Focus time.Duration
+	// Pomodoros counts the pomodoros done
+	Pomodoros  int
+	Completion Stats
+}
+
+// Change is the relative change of a value from one week to the next
+type Change struct {
+	// Percent is the change in percent of the value of the earlier week,
+	// 0 unless Valid
+	Percent float64
+	// Valid is false when the earlier value is zero, so there's no change
+	// to tell
+	Valid bool
+}
+
+// change returns the change from the value from to the value to
+func change(from, to float64) Change {
+	if from == 0 {
+		return Change{}
+	}
+	return Change{Percent: (to - from) / from * 100, Valid: true}
+}
+
+// WeekComparison compares the work of a week with that of the week before
+type WeekComparison struct {
+	ThisWeek WeekSummary
+	LastWeek WeekSummary
+
+	Focus      Change
+	Pomodoros  Change
+	Completion Change
+}
+
+// CompareWeeks compares the work of the week of asOf up to asOf with that
+// of the same span of the week before, in the configured location. Weeks
+// start on Monday, see IntervalConfig.WeekStartsSunday
+func CompareWeeks(config *IntervalConfig, asOf time.Time) (WeekComparison, error) {
+	asOf = asOf.In(config.location())
+	start := StartOfWeek(asOf, config)
+
+	var (
+		c   WeekComparison
+		err error
+	)
+	if c.ThisWeek, err = weekSummary(config, start, asOf); err != nil {
+		return WeekComparison{}, err
+	}
+	if c.LastWeek, err = weekSummary(config, start.AddDate(0, 0, -7), asOf.AddDate(0, 0, -7)); err != nil {
+		return WeekComparison{}, err
+	}
+
+	this, last := c.ThisWeek, c.LastWeek
+	c.Focus = change(float64(last.Focus), float64(this.Focus))
+	c.Pomodoros = change(float64(last.Pomodoros), float64(this.Pomodoros))
+	c.Completion = change(last.Completion.Ratio, this.Completion.Ratio)
+	return c, nil
+}
+
+// StartOfWeek returns the start of the week of t in the configured
+// location. Weeks start on Monday, see IntervalConfig.WeekStartsSunday
+func StartOfWeek(t time.Time, config *IntervalConfig) time.Time {
+	t = t.In(config.location())
+	first := time.Monday
+	if config.WeekStartsSunday {
+		first = time.Sunday
+	}
+	offset := (int(t.Weekday()) - int(first) + 7) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, t.Location())
+}
+
+// weekSummary summarizes the work done in [start, end)
+func weekSummary(config *IntervalConfig, start, end time.Time) (WeekSummary, error) {
+	focus, _, err := dailyTotals(config, start, end, summaryOptions{})
+	if err != nil {
+		return WeekSummary{}, err
+	}
+	stats, err := CompletionStats(start, end, config)
+	if err != nil {
+		return WeekSummary{}, err
+	}
+
+	s := WeekSummary{
+		Start:      start,
+		End:        end,
+		Pomodoros:  stats.Done,
+		Completion: stats,
+	}
+	for _, d := range focus {
+		s.Focus += d
+	}
+	return s, nil
+}
+
+// DaySummary is the work done on a calendar day
+type DaySummary struct {
+	// Day is the start of the day in the configured location
+	Day    time.Time
+	Focus  time.Duration
+	Breaks time.Duration
+	// Pomodoros counts the pomodoros completed
+	Pomodoros int
+}
+
+// MonthSummary is the work done in a calendar month
+type MonthSummary struct {
+	// Days holds every day of the month in order, including those without
+	// any work
+	Days      []DaySummary
+	Focus     time.Duration
+	Breaks    time.Duration
+	Pomodoros int
+}
+
+// MonthlySummary returns the work and break durations of each day of the
+// month of month in the configured location, along with the totals of the
+// month. Durations are those of DailySummary
+func MonthlySummary(month time.Time, config *IntervalConfig) (MonthSummary, error) {
+	month = month.In(config.location())
+	first := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, month.Location())
+
+	days, err := DaySummaries(first, first.AddDate(0, 1, 0), config)
+	if err != nil {
+		return MonthSummary{}, err
+	}
+
+	s := MonthSummary{Days: days}
+	for _, d := range days {
+		s.Focus += d.Focus
+		s.Breaks += d.Breaks
+		s.Pomodoros += d.Pomodoros
+	}
+	return s, nil
+}
+
+// DaySummaries returns the work done on each calendar day from that of
+// start up to end in the configured location, in order. Days without work
+// are included
+func DaySummaries(start, end time.Time, config *IntervalConfig) ([]DaySummary, error) {
+	start = start.In(config.location())
+	first := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+
+	focus, breaks, err := dailyTotals(config, first, end, summaryOptions{})
+	if err != nil {
+		return nil, err
+	}
+	counts, err := config.store().CompletedPerDay(first, end)
+	if err != nil {
+		return nil, fmt.Errorf("counting pomodoros: %w", err)
+	}
+
+	var days []DaySummary
+	for day := first; day.Before(end); day = day.AddDate(0, 0, 1) {
+		key := day.Format(DayLayout)
+		days = append(days, DaySummary{
+			Day:       day,
+			Focus:     focus[key],
+			Breaks:    breaks[key],
+			Pomodoros: counts[key],
+		})
+	}
+	return days, nil
+}
+
+// streakWindow is how many days Streak counts at a time
+const streakWindow = 90
+
+// Streak returns the number of days in a row up to today, in the
+// configured location, with a pomodoro completed. Today doesn't break the
+// streak before it's over, so while no pomodoro is done today the streak
+// runs up to yesterday
+func Streak(config *IntervalConfig) (int, error) {
+	now := config.today()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	streak := 0
+	for end := today.AddDate(0, 0, 1); ; end = end.AddDate(0, 0, -streakWindow) {
+		start := end.AddDate(0, 0, -streakWindow)
+		counts, err := config.store().CompletedPerDay(start, end)
+		if err != nil {
+			return 0, fmt.Errorf("counting pomodoros: %w", err)
+		}
+		for day := end.AddDate(0, 0, -1); !day.Before(start); day = day.AddDate(0, 0, -1) {
+			if counts[day.Format(DayLayout)] > 0 {
+				streak++
+			} else if !day.Equal(today) {
+				return streak, nil
+			}
+		}
+	}
+}
+
+// HeatmapNoDay marks the cells of HeatmapData out of the range
+const HeatmapNoDay = -1
+
+// HeatmapData returns the pomodoros completed on each of the weeks*7 days
+// up to the day of end in the configured location, laid out in columns of
+// a week each starting on the first day of the week. Cells before the
+// first day and after the last are HeatmapNoDay. Each column is labeled
+// with the abbreviated month of its first day in the range when it
+// differs from that of the column before, and left blank otherwise
+func HeatmapData(end time.Time, weeks int, config *IntervalConfig) ([][7]int, []string, error) {
+	if weeks <= 0 {
+		return nil, nil, fmt.Errorf("%w: %d weeks", ErrInvalidRange, weeks)
+	}
+
+	end = end.In(config.location())
+	last := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, end.Location())
+	first := last.AddDate(0, 0, 1-weeks*7)
+
+	counts, err := CompletedPerDay(config, first, last.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		grid   [][7]int
+		labels []string
+		month  time.Month
+	)
+	for week := StartOfWeek(first, config); !week.After(last); week = week.AddDate(0, 0, 7) {
+		var column [7]int
+		label, labeled := "", false
+		for k := range column {
+			day := week.AddDate(0, 0, k)
+			if day.Before(first) || day.After(last) {
+				column[k] = HeatmapNoDay
+				continue
+			}
+			if !labeled {
+				if day.Month() != month {
+					month = day.Month()
+					label = day.Format("Jan")
+				}
+				labeled = true
+			}
+			column[k] = counts[day.Format(DayLayout)]
+		}
+		grid = append(grid, column)
+		labels = append(labels, label)
+	}
+	return grid, labels, nil
 }
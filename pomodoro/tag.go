@@ -0,0 +1,45 @@
+package pomodoro
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrInvalidTag = errors.New("invalid tag")
+
+// NormalizeTag returns tag in the form it's stored in: lowercase, without
+// a leading # or surrounding space. Tags can't be empty or contain spaces
+// or commas, which separate them in exports
+func NormalizeTag(tag string) (string, error) {
+	t := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+	if t == "" || strings.ContainsAny(t, ", \t\n") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidTag, tag)
+	}
+	return t, nil
+}
+
+// HasTag reports whether tag is in tags, sorted as repositories keep
+// them. Repositories that filter in Go use it
+func HasTag(tags []string, tag string) bool {
+	k := sort.SearchStrings(tags, tag)
+	return k < len(tags) && tags[k] == tag
+}
+
+// TagInterval adds tags to an interval. Tags it already has are ignored
+func TagInterval(config *IntervalConfig, id int64, tags ...string) error {
+	for _, tag := range tags {
+		if err := config.store().AddTag(id, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TagSummary returns the time spent in the done intervals started in
+// [start, end), by tag
+func TagSummary(config *IntervalConfig, start, end time.Time) (map[string]time.Duration, error) {
+	return config.store().TagSummary(start, end)
+}
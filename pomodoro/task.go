@@ -0,0 +1,86 @@
+package pomodoro
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrTaskNotFound = errors.New("task not found")
+	ErrTaskExists   = errors.New("task already exists")
+	ErrInvalidTask  = errors.New("invalid task")
+)
+
+// Task is a piece of work with an estimate of the pomodoros it takes.
+// Pomodoros are linked to it through Interval.TaskID
+type Task struct {
+	ID       int64
+	Name     string
+	Estimate int
+}
+
+// TaskStats compares the estimate of a task with the work done on it
+type TaskStats struct {
+	Task
+	// Completed counts the pomodoros done for the task
+	Completed int64
+	// FocusTime is the time spent in the task's pomodoros, including the
+	// ones that were cancelled
+	FocusTime time.Duration
+}
+
+// AddTask stores a new task with an estimate in pomodoros
+func AddTask(config *IntervalConfig, name string, estimate int) (Task, error) {
+	if name == "" {
+		return Task{}, fmt.Errorf("%w: empty name", ErrInvalidTask)
+	}
+	t := Task{Name: name, Estimate: estimate}
+	id, err := config.store().CreateTask(t)
+	if err != nil {
+		return Task{}, err
+	}
+	t.ID = id
+	return t, nil
+}
+
+// Tasks returns all the stored tasks
+func Tasks(config *IntervalConfig) ([]Task, error) {
+	return config.store().Tasks()
+}
+
+// TaskSummary returns the estimated and completed pomodoros of a task
+func TaskSummary(config *IntervalConfig, taskName string) (TaskStats, error) {
+	t, err := config.store().TaskByName(taskName)
+	if err != nil {
+		return TaskStats{}, err
+	}
+
+	completed, focus, err := config.store().TaskTotals(t.ID)
+	if err != nil {
+		return TaskStats{}, err
+	}
+
+	return TaskStats{
+		Task:      t,
+		Completed: completed,
+		FocusTime: focus,
+	}, nil
+}
+
+// configTask returns the ID of the configured task, creating it when
+// AutoCreateTasks is set. It's zero when no task is configured.
+func (c *IntervalConfig) configTask() (int64, error) {
+	if c.Task == "" {
+		return 0, nil
+	}
+
+	t, err := c.store().TaskByName(c.Task)
+	if errors.Is(err, ErrTaskNotFound) && c.AutoCreateTasks {
+		return c.store().CreateTask(Task{Name: c.Task})
+	}
+	if err != nil {
+		return 0, err
+	}
+	return t.ID, nil
+}
@@ -0,0 +1,150 @@
+package pomodoro
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// YearReview is the work done in a calendar year
+type YearReview struct {
+	Year int
+	// Start is the first day of the year and End the end of its last day,
+	// or of today while the year isn't over
+	Start time.Time
+	End   time.Time
+	// Partial is set for the current year, summarized up to today
+	Partial   bool
+	Pomodoros int
+	Focus     time.Duration
+	// BusiestDay is the day with the most focus time, with a zero Day if
+	// no work was done
+	BusiestDay DaySummary
+	// BusiestMonth is the month with the most focus time, 0 if no work
+	// was done
+	BusiestMonth      time.Month
+	BusiestMonthFocus time.Duration
+	// LongestStreak is the most days in a row with a pomodoro done
+	LongestStreak int
+	// WorkingDays counts the days with any focus time, which
+	// AveragePerWorkingDay averages over
+	WorkingDays          int
+	AveragePerWorkingDay time.Duration
+}
+
+// YearSummary reviews the work done in year in the configured location,
+// from the totals of each day. The current year is reviewed up to today
+// and years yet to come are an ErrInvalidRange
+func YearSummary(year int, config *IntervalConfig) (YearReview, error) {
+	loc := config.location()
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+	end := start.AddDate(1, 0, 0)
+
+	r := YearReview{Year: year, Start: start, End: end}
+	today := config.today()
+	if today.Before(start) {
+		return r, fmt.Errorf("%w: %d hasn't started", ErrInvalidRange, year)
+	}
+	if today.Before(end) {
+		r.End = time.Date(today.Year(), today.Month(), today.Day()+1, 0, 0, 0, 0, loc)
+		r.Partial = true
+	}
+
+	days, err := DaySummaries(start, r.End, config)
+	if err != nil {
+		return r, err
+	}
+
+	var (
+		months [13]time.Duration
+		streak int
+	)
+	for _, d := range days {
+		r.Pomodoros += d.Pomodoros
+		r.Focus += d.Focus
+		months[d.Day.Month()] += d.Focus
+		if d.Focus > r.BusiestDay.Focus {
+			r.BusiestDay = d
+		}
+		if d.Focus > 0 {
+			r.WorkingDays++
+		}
+
+		if d.Pomodoros == 0 {
+			streak = 0
+			continue
+		}
+		streak++
+		if streak > r.LongestStreak {
+			r.LongestStreak = streak
+		}
+	}
+	for m := time.January; m <= time.December; m++ {
+		if months[m] > r.BusiestMonthFocus {
+			r.BusiestMonth, r.BusiestMonthFocus = m, months[m]
+		}
+	}
+	if r.WorkingDays > 0 {
+		r.AveragePerWorkingDay = r.Focus / time.Duration(r.WorkingDays)
+	}
+	return r, nil
+}
+
+// yearReviewJSON is the JSON representation of a YearReview
+type yearReviewJSON struct {
+	Year                 int               `json:"year"`
+	Start                string            `json:"start"`
+	End                  string            `json:"end"`
+	Partial              bool              `json:"partial"`
+	Pomodoros            int               `json:"pomodoros"`
+	Focus                string            `json:"focus"`
+	FocusHours           float64           `json:"focus_hours"`
+	BusiestDay           *busiestDayJSON   `json:"busiest_day"`
+	BusiestMonth         *busiestMonthJSON `json:"busiest_month"`
+	LongestStreak        int               `json:"longest_streak"`
+	WorkingDays          int               `json:"working_days"`
+	AveragePerWorkingDay string            `json:"average_per_working_day"`
+}
+
+type busiestDayJSON struct {
+	Day       string `json:"day"`
+	Focus     string `json:"focus"`
+	Pomodoros int    `json:"pomodoros"`
+}
+
+type busiestMonthJSON struct {
+	Month string `json:"month"`
+	Focus string `json:"focus"`
+}
+
+// MarshalJSON writes the review with days as YYYY-MM-DD, End being the
+// last day included, durations like "25m0s" and the busiest day and month
+// null if no work was done
+func (r YearReview) MarshalJSON() ([]byte, error) {
+	v := yearReviewJSON{
+		Year:                 r.Year,
+		Start:                r.Start.Format(DayLayout),
+		End:                  r.End.AddDate(0, 0, -1).Format(DayLayout),
+		Partial:              r.Partial,
+		Pomodoros:            r.Pomodoros,
+		Focus:                r.Focus.String(),
+		FocusHours:           r.Focus.Hours(),
+		LongestStreak:        r.LongestStreak,
+		WorkingDays:          r.WorkingDays,
+		AveragePerWorkingDay: r.AveragePerWorkingDay.String(),
+	}
+	if !r.BusiestDay.Day.IsZero() {
+		v.BusiestDay = &busiestDayJSON{
+			Day:       r.BusiestDay.Day.Format(DayLayout),
+			Focus:     r.BusiestDay.Focus.String(),
+			Pomodoros: r.BusiestDay.Pomodoros,
+		}
+	}
+	if r.BusiestMonth != 0 {
+		v.BusiestMonth = &busiestMonthJSON{
+			Month: r.BusiestMonth.String(),
+			Focus: r.BusiestMonthFocus.String(),
+		}
+	}
+	return json.Marshal(v)
+}
@@ -2,12 +2,14 @@ package tui
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"image"
+	"log"
 	"time"
 
 	"github.com/mum4k/termdash"
 	"github.com/mum4k/termdash/terminal/tcell"
-	"github.com/mum4k/termdash/terminal/terminalapi"
 	"github.com/snirkop89/pomo/pomodoro"
 )
 
@@ -15,46 +17,130 @@ type App struct {
 	ctx        context.Context
 	controller *termdash.Controller
 	redrawCh   chan bool
-	errorCh    chan error
+	screen     *screen
+	errs       *errorSink
+	errorLog   *errorLog
 	term       *tcell.Terminal
 	size       image.Point
 }
 
-func New(config *pomodoro.IntervalConfig) (*App, error) {
-	ctx, cancel := context.WithCancel(context.Background())
+// Options set how the app looks and tells an interval ended
+type Options struct {
+	Theme Theme
+	// Bell rings the terminal bell when an interval ends
+	Bell bool
+	// Flash makes the interval type blink when an interval ends
+	Flash bool
+	// TimerStyle is one of TimerStyles, TimerDonut by default
+	TimerStyle string
+	// Keys are the keys of the actions, DefaultKeyMap if none is set
+	Keys KeyMap
+	// PromptLabel asks what the pomodoro is about before starting it
+	PromptLabel bool
+	// SummaryRefresh is how often the summaries refresh while shown,
+	// besides when intervals end. DefaultSummaryRefresh if 0
+	SummaryRefresh time.Duration
+}
 
-	quitter := func(k *terminalapi.Keyboard) {
-		if k.Key == 'q' || k.Key == 'Q' {
-			cancel()
-		}
+// New returns the app running intervals as config sets
+func New(config *pomodoro.IntervalConfig, opts Options) (*App, error) {
+	theme := opts.Theme
+	keyMap := opts.Keys
+	if keyMap.empty() {
+		keyMap = DefaultKeyMap()
 	}
+	bindings, err := keyMap.bindings()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
 
 	redrawCh := make(chan bool)
-	errorCh := make(chan error)
+	errs := newErrorSink(errorQueueSize)
+
+	keys := newKeyHandler(bindings)
+	quit := &quitAction{
+		config:   config,
+		key:      keyName(keys.keysFor(actionQuit)[0]),
+		cancel:   cancel,
+		redrawCh: redrawCh,
+		errs:     errs,
+	}
+	keys.bind(actionQuit, quit.run)
+	// Exiting pauses the running interval, to be resumed on the next run
+	config.PauseOnExit = true
+
+	w, err := newWidgets(ctx, opts, redrawCh, errs)
+	if err != nil {
+		return nil, err
+	}
+	quit.w = w
 
-	w, err := newWidgets(ctx, errorCh)
+	s, err := newSummary(ctx, config, theme, w, opts.SummaryRefresh, redrawCh, errs)
 	if err != nil {
 		return nil, err
 	}
 
-	s, err := newSummary(ctx, config, redrawCh, errorCh)
+	var prompt *labelPrompt
+	if opts.PromptLabel {
+		prompt = &labelPrompt{keys: keys, theme: theme, redrawCh: redrawCh}
+	}
+	st, err := newStatsView(ctx, config, theme, errs)
 	if err != nil {
 		return nil, err
 	}
 
-	b, err := newButtonSet(ctx, config, w, s, redrawCh, errorCh)
+	b, err := newButtonSet(ctx, config, theme, w, s, st, keys, prompt, redrawCh, errs)
 	if err != nil {
 		return nil, err
 	}
+	keys.bind(actionSkip, b.skip)
+	keys.bind(actionStop, b.stop)
 	term, err := tcell.New()
 	if err != nil {
 		return nil, err
 	}
-	c, err := newGrid(b, w, s, term)
+	errorLog, err := newErrorLog(theme)
 	if err != nil {
 		return nil, err
 	}
-	controller, err := termdash.NewController(term, c, termdash.KeyboardSubscriber(quitter))
+	main, err := mainLayout(b, w, s, errorLog, keys)
+	if err != nil {
+		return nil, err
+	}
+	compact, err := compactLayout(w)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := statsLayout(st)
+	if err != nil {
+		return nil, err
+	}
+	scr, err := newScreen(term, []layout{main, stats}, compact, s.show)
+	if err != nil {
+		return nil, err
+	}
+	if prompt != nil {
+		prompt.screen = scr
+	}
+	help, err := newHelpScreen(scr, theme, keys, redrawCh, errs)
+	if err != nil {
+		return nil, err
+	}
+	keys.bind(actionHelp, help.show)
+	keys.bind(actionView, func() {
+		view, err := scr.next()
+		if err != nil {
+			errs.report(err)
+			return
+		}
+		if view == statsViewIndex {
+			st.update(redrawCh)
+			return
+		}
+		redrawCh <- true
+	})
+	controller, err := termdash.NewController(term, scr.c, termdash.KeyboardSubscriber(keys.handle))
 	if err != nil {
 		return nil, err
 	}
@@ -63,17 +149,64 @@ func New(config *pomodoro.IntervalConfig) (*App, error) {
 		ctx:        ctx,
 		controller: controller,
 		redrawCh:   redrawCh,
-		errorCh:    errorCh,
+		screen:     scr,
+		errs:       errs,
+		errorLog:   errorLog,
 		term:       term,
 	}, nil
 }
 
+// statsViewIndex is the place of the stats among the views of the screen
+const statsViewIndex = 1
+
+// quitAction exits the app. While an interval is running it asks for a
+// second press of key, then pauses the interval before exiting so the pause
+// is stored by the time the app is gone
+type quitAction struct {
+	config   *pomodoro.IntervalConfig
+	key      string
+	w        *widgets
+	cancel   func()
+	redrawCh chan<- bool
+	errs     *errorSink
+	guard    quitGuard
+}
+
+func (q *quitAction) run() {
+	// Failing that, quit right away: exiting pauses the interval anyway
+	i, ok, err := pomodoro.PeekInterval(q.config)
+	if err != nil {
+		q.errs.report(err)
+		q.cancel()
+		return
+	}
+	running := ok && i.State == pomodoro.StateRunning
+
+	if q.guard.press(running, time.Now()) == quitConfirm {
+		what := "pomodoro"
+		if i.Category != pomodoro.CategoryPomodoro && i.Category != pomodoro.CategorySnooze {
+			what = "break"
+		}
+		q.w.update([]int{}, "", fmt.Sprintf("Press %s again to quit (%s will be paused)", q.key, what), "", q.redrawCh)
+		return
+	}
+	if running {
+		if err := i.Pause(q.config); err != nil && !errors.Is(err, pomodoro.ErrIntervalNotRunning) {
+			q.errs.report(err)
+		}
+	}
+	q.cancel()
+}
+
 func (a *App) resize() error {
 	if a.size.Eq(a.term.Size()) {
 		return nil
 	}
 
 	a.size = a.term.Size()
+	if err := a.screen.fit(a.size); err != nil {
+		return err
+	}
 	if err := a.term.Clear(); err != nil {
 		return err
 	}
@@ -81,6 +214,12 @@ func (a *App) resize() error {
 	return a.controller.Redraw()
 }
 
+// Logger returns a logger writing to the error log of the app, for what
+// runs alongside it and can't write to the terminal it draws on
+func (a *App) Logger() *log.Logger {
+	return log.New(a.errs, "", 0)
+}
+
 func (a *App) Run() error {
 	defer a.term.Close()
 	defer a.controller.Close()
@@ -94,8 +233,12 @@ func (a *App) Run() error {
 			if err := a.controller.Redraw(); err != nil {
 				return err
 			}
-		case err := <-a.errorCh:
-			if err != nil {
+		case err := <-a.errs.ch:
+			// Errors are shown in the log, so the app keeps going
+			if err := a.errorLog.add(time.Now(), err, a.errs.droppedCount()); err != nil {
+				return err
+			}
+			if err := a.controller.Redraw(); err != nil {
 				return err
 			}
 		case <-a.ctx.Done():
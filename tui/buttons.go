@@ -2,9 +2,13 @@ package tui
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
 
-	"github.com/mum4k/termdash/cell"
+	"github.com/mum4k/termdash/keyboard"
 	"github.com/mum4k/termdash/widgets/button"
 	"github.com/snirkop89/pomo/pomodoro"
 )
@@ -12,78 +16,322 @@ import (
 type buttonSet struct {
 	btStart *button.Button
 	btPause *button.Button
+	// skip skips the active interval and arms the next one, asking first
+	// for work more than halfway done
+	skip func()
+	// stop cancels the active interval, taking the path of the completion
+	// threshold and the cancel hooks, which quitting doesn't as it pauses
+	stop func()
 }
 
-func newButtonSet(ctx context.Context, config *pomodoro.IntervalConfig,
-	w *widgets, s *summary, redrawCh chan<- bool, errorCh chan<- error) (*buttonSet, error) {
-	startInterval := func() {
-		i, err := pomodoro.GetInterval(config)
-		errorCh <- err
+func newButtonSet(ctx context.Context, config *pomodoro.IntervalConfig, theme Theme, w *widgets, s *summary,
+	st *statsView, keys *keyHandler, prompt *labelPrompt, redrawCh chan<- bool, errs *errorSink) (*buttonSet, error) {
+	start := func(i pomodoro.Interval) {
+		message := "Take a break"
+		if i.Category == pomodoro.CategoryPomodoro {
+			message = "Focus on your task"
+			if i.DailyOrdinal > 0 {
+				message = fmt.Sprintf("Pomodoro #%d: focus on your task", i.DailyOrdinal)
+			}
+		}
+		end := i.ProjectedEnd(config.Now())
+		message = fmt.Sprintf("%s (ends at %s)", message, end.Format("15:04"))
+		w.update([]int{}, i.Category, message, "", redrawCh)
+	}
 
-		start := func(i pomodoro.Interval) {
-			message := "Take a break"
-			if i.Category == pomodoro.CategoryPomodoro {
-				message = "Focus on your task"
+	end := func(i pomodoro.Interval) {
+		w.alert()
+		message := "Nothing running..."
+		if next, _, err := pomodoro.NextCategory(config); err == nil {
+			message = fmt.Sprintf("Nothing running... Up next: %s", next)
+		}
+		if p, err := pomodoro.GoalSummary(config, config.Now()); err == nil {
+			message = fmt.Sprintf("%s Goal: %d/%d", message, p.Completed, p.Goal)
+			if !p.Reachable {
+				message += " (out of reach today)"
 			}
-			w.update([]int{}, i.Category, message, "", redrawCh)
 		}
+		if q, err := pomodoro.FocusQualitySummary(config.Now(), config); err == nil &&
+			(q.Paused > 0 || q.Interruptions > 0) {
+			message = fmt.Sprintf("%s Today: paused %s, %d interruptions", message,
+				q.Paused.Round(time.Minute), q.Interruptions)
+		}
+		w.update([]int{}, "", message, "", redrawCh)
+		s.update(redrawCh)
+		st.update(redrawCh)
+	}
+
+	periodic := func(i pomodoro.Interval) {
+		w.update(
+			[]int{int(i.ActualDuration), int(i.PlannedDuration)},
+			"", "",
+			fmt.Sprint(i.PlannedDuration-i.ActualDuration),
+			redrawCh,
+		)
+	}
+
+	if config.MicroBreakEvery > 0 && config.OnMicroBreak == nil {
+		config.OnMicroBreak = func(i pomodoro.Interval) {
+			w.update([]int{}, "", "Micro break: look away for a few seconds", "", redrawCh)
+		}
+	}
 
-		end := func(i pomodoro.Interval) {
-			w.update([]int{}, "", "Nothing running...", "", redrawCh)
-			s.update(redrawCh)
+	// While paused, the type display says so and the info text counts the
+	// time paused. Resuming runs start, which restores them
+	var clock pauseClock
+	showPaused := func(i pomodoro.Interval) {
+		message := "Paused"
+		if i.AutoPaused {
+			message = "Paused while you were away"
+		}
+		if !i.PausedAt.IsZero() {
+			message = fmt.Sprintf("%s for %s", message, config.Now().Sub(i.PausedAt).Round(time.Second))
+		}
+		message += "... press start to continue"
+		w.update([]int{}, i.Category+" (paused)", message, "", redrawCh)
+	}
+	onPause := config.OnPause
+	config.OnPause = func(i pomodoro.Interval) {
+		if onPause != nil {
+			onPause(i)
+		}
+		showPaused(i)
+		clock.start(ctx, func() { showPaused(i) })
+	}
+	onResume := config.OnResume
+	config.OnResume = func(i pomodoro.Interval) {
+		clock.stop()
+		if onResume != nil {
+			onResume(i)
 		}
+	}
+
+	// With auto start, a single cycle loop runs all intervals and the start
+	// button resumes it after a pause
+	var cycling int32
+	resumeCh := make(chan struct{}, 1)
 
-		periodic := func(i pomodoro.Interval) {
-			w.update(
-				[]int{int(i.ActualDuration), int(i.PlannedDuration)},
-				"", "",
-				fmt.Sprint(i.PlannedDuration-i.ActualDuration),
-				redrawCh,
-			)
+	// labelNext asks for the label of the interval about to start when it's
+	// a pomodoro, unless prompt is nil. It returns false while already
+	// asking
+	var asking int32
+	labelNext := func() bool {
+		if prompt == nil {
+			return true
+		}
+		if !atomic.CompareAndSwapInt32(&asking, 0, 1) {
+			return false
 		}
+		defer atomic.StoreInt32(&asking, 0)
 
-		errorCh <- i.Start(ctx, config, start, periodic, end)
+		i, err := pomodoro.GetInterval(config)
+		if err != nil || i.Category != pomodoro.CategoryPomodoro || i.State != pomodoro.StateNotStarted {
+			// Starting reports the error
+			return true
+		}
+		prev := i.Label
+		if prev == "" {
+			if prev, err = pomodoro.LastLabel(config); err != nil {
+				errs.report(err)
+			}
+		}
+		label, ok, err := prompt.ask(prev)
+		if err != nil {
+			errs.report(err)
+			return true
+		}
+		if ok && label != i.Label {
+			if _, err := pomodoro.SetLabel(config, i.ID, label); err != nil {
+				errs.report(err)
+			}
+		}
+		return true
 	}
 
-	pauseInterval := func() {
+	startInterval := func() {
+		if atomic.LoadInt32(&asking) == 1 {
+			return
+		}
+		if config.AutoStart {
+			if !atomic.CompareAndSwapInt32(&cycling, 0, 1) {
+				select {
+				case resumeCh <- struct{}{}:
+				default:
+				}
+				return
+			}
+			defer atomic.StoreInt32(&cycling, 0)
+			// Only the first pomodoro of the cycle is labeled, the others
+			// start unattended
+			labelNext()
+			_, err := pomodoro.RunCycle(ctx, config, resumeCh, start, periodic, end)
+			errs.report(err)
+			return
+		}
+
+		if !labelNext() {
+			return
+		}
 		i, err := pomodoro.GetInterval(config)
 		if err != nil {
-			errorCh <- err
+			errs.report(err)
+			return
+		}
+
+		err = i.Start(ctx, config, start, periodic, end)
+		if errors.Is(err, pomodoro.ErrIntervalAlreadyRunning) {
+			return
+		}
+		errs.report(err)
+	}
+
+	pauseInterval := func() {
+		i, ok, err := pomodoro.PeekInterval(config)
+		if err != nil {
+			errs.report(err)
+			return
+		}
+		if !ok {
 			return
 		}
 		if err := i.Pause(config); err != nil {
-			if err == pomodoro.ErrIntervalNotRunning {
+			if errors.Is(err, pomodoro.ErrIntervalNotRunning) {
 				return
 			}
-			errorCh <- err
+			errs.report(err)
+		}
+	}
+
+	skipInterval := func() {
+		clock.stop()
+		skipped, err := pomodoro.Skip(config)
+		if errors.Is(err, pomodoro.ErrNothingToSkip) {
+			w.update([]int{}, "", "Nothing to skip", "", redrawCh)
+			return
+		}
+		if err != nil {
+			errs.report(err)
+			return
+		}
+
+		// With auto start the cycle moves on by itself, to the same
+		// interval
+		next, err := pomodoro.GetInterval(config)
+		if err != nil {
+			errs.report(err)
+			return
+		}
+		w.update([]int{0, int(next.PlannedDuration)}, next.Category,
+			fmt.Sprintf("Skipped %s. Up next: %s", skipped.Category, next.Category),
+			fmt.Sprint(next.PlannedDuration), redrawCh)
+		s.update(redrawCh)
+		st.update(redrawCh)
+	}
+
+	askSkip := func() {
+		i, ok, err := pomodoro.PeekInterval(config)
+		if err != nil {
+			errs.report(err)
 			return
 		}
-		w.update([]int{}, "", "Paused... press start to continue", "", redrawCh)
+		if !ok {
+			w.update([]int{}, "", "Nothing to skip", "", redrawCh)
+			return
+		}
+		work := i.Category == pomodoro.CategoryPomodoro || i.Category == pomodoro.CategorySnooze
+		if !work || i.ActualDuration*2 <= i.PlannedDuration {
+			skipInterval()
+			return
+		}
+
+		keys.ask(skipInterval, func() {
+			w.update([]int{}, "", "Not skipped", "", redrawCh)
+		})
+		w.update([]int{}, "", fmt.Sprintf("%s more than halfway done, skip it? (y/n)", i.Category), "", redrawCh)
 	}
 
-	btStart, err := button.New("(s)tart", func() error {
+	stopInterval := func() {
+		i, ok, err := pomodoro.PeekInterval(config)
+		if err != nil {
+			errs.report(err)
+			return
+		}
+		if !ok || (i.State != pomodoro.StateRunning && i.State != pomodoro.StatePaused) {
+			w.update([]int{}, "", "Nothing to stop", "", redrawCh)
+			return
+		}
+		clock.stop()
+		reason, err := i.Stop(config)
+		if errors.Is(err, pomodoro.ErrIntervalNotRunning) {
+			w.update([]int{}, "", "Nothing to stop", "", redrawCh)
+			return
+		}
+		if err != nil {
+			errs.report(err)
+			return
+		}
+
+		message := fmt.Sprintf("Stopped %s", i.Category)
+		if reason == pomodoro.ExitCompletedEarly {
+			message = fmt.Sprintf("Stopped %s, counted as done", i.Category)
+		}
+		next, _, err := pomodoro.PeekInterval(config)
+		if err != nil {
+			errs.report(err)
+			return
+		}
+		w.update([]int{0, int(next.PlannedDuration)}, next.Category,
+			fmt.Sprintf("%s. Up next: %s", message, next.Category),
+			fmt.Sprint(next.PlannedDuration), redrawCh)
+		s.update(redrawCh)
+		st.update(redrawCh)
+	}
+
+	startLabel := buttonLabel(actionStart, keys.keysFor(actionStart))
+	pauseLabel := buttonLabel(actionPause, keys.keysFor(actionPause))
+	widest := startLabel
+	if len(pauseLabel) > len(widest) {
+		widest = pauseLabel
+	}
+
+	btStart, err := button.New(startLabel, func() error {
 		go startInterval()
 		return nil
 	},
-		button.GlobalKey('s'),
-		button.WidthFor("(p)ause"),
+		button.FillColor(theme.Start),
+		button.GlobalKeys(keys.keysFor(actionStart)...),
+		button.WidthFor(widest),
 		button.Height(2),
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	btPause, err := button.New("(p)ause", func() error {
+	btPause, err := button.New(pauseLabel, func() error {
 		go pauseInterval()
 		return nil
 	},
-		button.FillColor(cell.ColorNumber(220)),
-		button.GlobalKey('p'),
+		button.FillColor(theme.Pause),
+		button.GlobalKeys(keys.keysFor(actionPause)...),
 		button.Height(2),
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	return &buttonSet{btStart, btPause}, nil
+	return &buttonSet{btStart, btPause, askSkip, stopInterval}, nil
+}
+
+// buttonLabel names a button after its action, marking its first key like
+// (s)tart, or after it when the action doesn't have it, like start (x)
+func buttonLabel(action string, keys []keyboard.Key) string {
+	if len(keys) == 0 {
+		return action
+	}
+	if keys[0] >= 0 {
+		if i := strings.IndexRune(action, rune(keys[0])); i >= 0 {
+			return action[:i] + "(" + string(rune(keys[0])) + ")" + action[i+1:]
+		}
+	}
+	return fmt.Sprintf("%s (%s)", action, keyName(keys[0]))
 }
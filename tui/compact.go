@@ -0,0 +1,113 @@
+package tui
+
+import (
+	"fmt"
+	"image"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mum4k/termdash/cell"
+	"github.com/mum4k/termdash/widgets/text"
+)
+
+// The main layout doesn't fit terminals smaller than this, which get the
+// compact one instead
+const (
+	compactRows = 20
+	compactCols = 60
+)
+
+// fitsMain tells if a terminal of size has room for the main layout
+func fitsMain(size image.Point) bool {
+	return size.Y >= compactRows && size.X >= compactCols
+}
+
+// compactGaugeWidth is the count of cells of the gauge in the compact line
+const compactGaugeWidth = 10
+
+// compactLine is the whole app in one line: the interval type, the time
+// left, a short gauge and the pomodoros done today
+type compactLine struct {
+	*text.Text
+	theme Theme
+	errs  *errorSink
+
+	mu       sync.Mutex
+	category string
+	done     time.Duration
+	total    time.Duration
+	today    int
+}
+
+func newCompactLine(theme Theme, errs *errorSink) (*compactLine, error) {
+	txt, err := text.New()
+	if err != nil {
+		return nil, err
+	}
+	l := &compactLine{Text: txt, theme: theme, errs: errs}
+	return l, l.draw()
+}
+
+func (l *compactLine) setCategory(category string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.category = category
+	l.errs.report(l.draw())
+}
+
+func (l *compactLine) setProgress(done, total time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.done, l.total = done, total
+	l.errs.report(l.draw())
+}
+
+func (l *compactLine) setToday(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.today = n
+	l.errs.report(l.draw())
+}
+
+// draw writes the line, the gauge in the timer color
+func (l *compactLine) draw() error {
+	head, gauge, tail := compactText(l.category, l.done, l.total, l.today, l.theme.ASCII)
+	l.Reset()
+	if err := l.Write(head, text.WriteCellOpts(cell.FgColor(l.theme.Text))); err != nil {
+		return err
+	}
+	if err := l.Write(gauge, text.WriteCellOpts(cell.FgColor(l.theme.Timer))); err != nil {
+		return err
+	}
+	return l.Write(tail, text.WriteCellOpts(cell.FgColor(l.theme.Text)))
+}
+
+// compactText writes the compact line in three parts, like "Pomodoro
+// 12:30 ", "█████░░░░░" and " 3 today"
+func compactText(category string, done, total time.Duration, today int, ascii bool) (string, string, string) {
+	if category == "" {
+		category = "Ready"
+	}
+	left := total - done
+	if left < 0 {
+		left = 0
+	}
+	secs := int(left.Round(time.Second) / time.Second)
+	head := fmt.Sprintf("%s %02d:%02d ", category, secs/60, secs%60)
+
+	filled := 0
+	if total > 0 && done > 0 {
+		filled = int(int64(compactGaugeWidth) * int64(done) / int64(total))
+		if filled > compactGaugeWidth {
+			filled = compactGaugeWidth
+		}
+	}
+	full, empty := "█", "░"
+	if ascii {
+		full, empty = "#", "-"
+	}
+	gauge := strings.Repeat(full, filled) + strings.Repeat(empty, compactGaugeWidth-filled)
+
+	return head, gauge, fmt.Sprintf(" %d today", today)
+}
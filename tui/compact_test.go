@@ -0,0 +1,36 @@
+package tui
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCompactText(t *testing.T) {
+	testCases := []struct {
+		name     string
+		category string
+		done     time.Duration
+		total    time.Duration
+		ascii    bool
+		exp      string
+	}{
+		{name: "Idle", exp: "Ready 00:00 ░░░░░░░░░░ 3 today"},
+		{name: "Started", category: "Pomodoro", total: 25 * time.Minute,
+			exp: "Pomodoro 25:00 ░░░░░░░░░░ 3 today"},
+		{name: "Halfway", category: "Pomodoro", done: 12*time.Minute + 30*time.Second, total: 25 * time.Minute,
+			exp: "Pomodoro 12:30 █████░░░░░ 3 today"},
+		{name: "Overdue", category: "ShortBreak", done: 6 * time.Minute, total: 5 * time.Minute,
+			exp: "ShortBreak 00:00 ██████████ 3 today"},
+		{name: "ASCII", category: "Pomodoro", done: 5 * time.Minute, total: 25 * time.Minute, ascii: true,
+			exp: "Pomodoro 20:00 ##-------- 3 today"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			head, gauge, tail := compactText(tt.category, tt.done, tt.total, 3, tt.ascii)
+			if got := head + gauge + tail; got != tt.exp {
+				t.Errorf("expected %q, got %q", tt.exp, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mum4k/termdash/cell"
+	"github.com/mum4k/termdash/widgets/text"
+)
+
+const (
+	// errorLogSize is how many errors the error log keeps
+	errorLogSize = 50
+	// errorQueueSize is how many errors may wait to be logged before
+	// more are dropped
+	errorQueueSize = 16
+)
+
+// errorEntry is an error logged at a time
+type errorEntry struct {
+	at  time.Time
+	err error
+}
+
+// errorRing keeps the last errors added, dropping the oldest once full
+type errorRing struct {
+	entries []errorEntry
+	next    int
+	full    bool
+}
+
+func newErrorRing(size int) *errorRing {
+	return &errorRing{entries: make([]errorEntry, size)}
+}
+
+func (r *errorRing) add(at time.Time, err error) {
+	if len(r.entries) == 0 {
+		return
+	}
+	r.entries[r.next] = errorEntry{at, err}
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// list returns the errors kept, oldest first
+func (r *errorRing) list() []errorEntry {
+	if !r.full {
+		return append([]errorEntry(nil), r.entries[:r.next]...)
+	}
+	return append(append([]errorEntry(nil), r.entries[r.next:]...), r.entries[:r.next]...)
+}
+
+// errorSink passes the errors of the widgets to the app. Reporting never
+// blocks: errors that don't fit in the queue are counted as dropped
+type errorSink struct {
+	ch      chan error
+	dropped int64
+}
+
+func newErrorSink(size int) *errorSink {
+	return &errorSink{ch: make(chan error, size)}
+}
+
+// report queues err, if any
+func (s *errorSink) report(err error) {
+	if err == nil {
+		return
+	}
+	select {
+	case s.ch <- err:
+	default:
+		atomic.AddInt64(&s.dropped, 1)
+	}
+}
+
+// Write reports p as an error, so loggers can write to the sink. It never
+// fails
+func (s *errorSink) Write(p []byte) (int, error) {
+	s.report(errors.New(strings.TrimSuffix(string(p), "\n")))
+	return len(p), nil
+}
+
+// droppedCount returns how many errors didn't fit in the queue
+func (s *errorSink) droppedCount() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// errorLog shows the last errors, newest last
+type errorLog struct {
+	ring  *errorRing
+	txt   *text.Text
+	theme Theme
+}
+
+func newErrorLog(theme Theme) (*errorLog, error) {
+	txt, err := text.New(text.RollContent(), text.WrapAtWords())
+	if err != nil {
+		return nil, err
+	}
+	return &errorLog{ring: newErrorRing(errorLogSize), txt: txt, theme: theme}, nil
+}
+
+// add logs err at the time given, along with the count of errors dropped
+func (l *errorLog) add(at time.Time, err error, dropped int64) error {
+	l.ring.add(at, err)
+
+	var sb strings.Builder
+	for _, e := range l.ring.list() {
+		fmt.Fprintf(&sb, "%s %v\n", e.at.Format("15:04:05"), e.err)
+	}
+	if dropped > 0 {
+		fmt.Fprintf(&sb, "(%d more dropped)\n", dropped)
+	}
+
+	l.txt.Reset()
+	return l.txt.Write(sb.String(), text.WriteCellOpts(cell.FgColor(l.theme.Text)))
+}
@@ -0,0 +1,80 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestErrorRing(t *testing.T) {
+	start := time.Date(2024, time.June, 3, 9, 0, 0, 0, time.UTC)
+	errs := func(r *errorRing) []string {
+		var got []string
+		for _, e := range r.list() {
+			got = append(got, fmt.Sprintf("%s %v", e.at.Format("15:04"), e.err))
+		}
+		return got
+	}
+
+	testCases := []struct {
+		name  string
+		size  int
+		added int
+		exp   []string
+	}{
+		{name: "Empty", size: 3, added: 0, exp: nil},
+		{name: "NotFull", size: 3, added: 2, exp: []string{"09:00 error 0", "09:01 error 1"}},
+		{name: "Full", size: 3, added: 3, exp: []string{"09:00 error 0", "09:01 error 1", "09:02 error 2"}},
+		{name: "Wrapped", size: 3, added: 5, exp: []string{"09:02 error 2", "09:03 error 3", "09:04 error 4"}},
+		{name: "NoRoom", size: 0, added: 2, exp: nil},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newErrorRing(tt.size)
+			for n := 0; n < tt.added; n++ {
+				r.add(start.Add(time.Duration(n)*time.Minute), fmt.Errorf("error %d", n))
+			}
+
+			got := errs(r)
+			if fmt.Sprint(got) != fmt.Sprint(tt.exp) {
+				t.Errorf("expected %v, got %v", tt.exp, got)
+			}
+		})
+	}
+}
+
+func TestErrorSink(t *testing.T) {
+	s := newErrorSink(2)
+
+	s.report(nil)
+	if len(s.ch) != 0 {
+		t.Errorf("expected nil errors not queued, got %d queued", len(s.ch))
+	}
+
+	for n := 0; n < 5; n++ {
+		s.report(errors.New("failed"))
+	}
+	if len(s.ch) != 2 {
+		t.Errorf("expected 2 errors queued, got %d", len(s.ch))
+	}
+	if n := s.droppedCount(); n != 3 {
+		t.Errorf("expected 3 errors dropped, got %d", n)
+	}
+}
+
+func TestErrorSinkLogger(t *testing.T) {
+	s := newErrorSink(2)
+	log.New(s, "", 0).Printf("hook %q: failed", "notify-send")
+
+	select {
+	case err := <-s.ch:
+		if exp := `hook "notify-send": failed`; err.Error() != exp {
+			t.Errorf("expected %q, got %q", exp, err.Error())
+		}
+	default:
+		t.Fatal("expected the line logged to be queued")
+	}
+}
@@ -1,43 +1,195 @@
 package tui
 
 import (
+	"fmt"
+	"image"
+	"sync"
+
 	"github.com/mum4k/termdash/align"
 	"github.com/mum4k/termdash/container"
 	"github.com/mum4k/termdash/container/grid"
 	"github.com/mum4k/termdash/linestyle"
 	"github.com/mum4k/termdash/terminal/terminalapi"
+	"github.com/mum4k/termdash/widgetapi"
+	"github.com/mum4k/termdash/widgets/text"
 )
 
-func newGrid(b *buttonSet, w *widgets, s *summary, t terminalapi.Terminal) (*container.Container, error) {
-	builder := grid.New()
+// rootID identifies the container holding the layout shown
+const rootID = "root"
+
+// layout is the options of a container showing a layout, along with the
+// widgets it places
+type layout struct {
+	opts    []container.Option
+	widgets []widgetapi.Widget
+}
+
+// layoutBuilder is a grid builder keeping track of the widgets placed
+type layoutBuilder struct {
+	*grid.Builder
+	widgets []widgetapi.Widget
+}
+
+func newLayoutBuilder() *layoutBuilder {
+	return &layoutBuilder{Builder: grid.New()}
+}
+
+// widget places w in the grid
+func (b *layoutBuilder) widget(w widgetapi.Widget, opts ...container.Option) grid.Element {
+	b.widgets = append(b.widgets, w)
+	return grid.Widget(w, opts...)
+}
+
+func (b *layoutBuilder) build() (layout, error) {
+	opts, err := b.Build()
+	if err != nil {
+		return layout{}, err
+	}
+	return layout{opts: opts, widgets: b.widgets}, nil
+}
+
+// newGrid returns the app container showing l
+func newGrid(l layout, t terminalapi.Terminal) (*container.Container, error) {
+	opts := append([]container.Option{container.ID(rootID)}, l.opts...)
+	return container.New(t, opts...)
+}
+
+// setLayout replaces the layout shown in c
+func setLayout(c *container.Container, l layout) error {
+	opts := append([]container.Option{container.Clear()}, l.opts...)
+	return c.Update(rootID, opts...)
+}
+
+// screen shows one of its views, or the compact layout when the terminal
+// is too small for them, switching as the terminal is resized
+type screen struct {
+	c       *container.Container
+	views   []layout
+	compact layout
+
+	mu    sync.Mutex
+	view  int
+	small bool
+	// held is set while something is shown over the layout, which is
+	// switched on release
+	held bool
+	// onShow is told after every switch whether the first view or the
+	// compact layout is on screen
+	onShow func(main, compact bool)
+}
+
+// newScreen returns the screen showing the first of views, telling onShow
+// what's on screen
+func newScreen(t terminalapi.Terminal, views []layout, compact layout,
+	onShow func(main, compact bool)) (*screen, error) {
+	s := &screen{views: views, compact: compact, small: !fitsMain(t.Size()), onShow: onShow}
+	c, err := newGrid(s.current(), t)
+	if err != nil {
+		return nil, err
+	}
+	s.c = c
+	s.notify()
+	return s, nil
+}
+
+// notify calls onShow with what's on screen
+func (s *screen) notify() {
+	if s.onShow != nil {
+		shown := !s.held
+		s.onShow(shown && !s.small && s.view == 0, shown && s.small)
+	}
+}
+
+func (s *screen) current() layout {
+	if s.small {
+		return s.compact
+	}
+	return s.views[s.view]
+}
+
+// next switches to the next view, cycling back to the first, and returns
+// it. It's shown once the terminal is large enough
+func (s *screen) next() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.notify()
+	s.view = (s.view + 1) % len(s.views)
+	if s.held || s.small {
+		return s.view, nil
+	}
+	return s.view, setLayout(s.c, s.current())
+}
+
+// fit shows the layout fitting a terminal of size
+func (s *screen) fit(size image.Point) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.notify()
+	small := !fitsMain(size)
+	if small == s.small {
+		return nil
+	}
+	s.small = small
+	if s.held {
+		return nil
+	}
+	return setLayout(s.c, s.current())
+}
+
+// hold keeps the layout from switching until release, while something is
+// shown over it
+func (s *screen) hold() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.notify()
+	s.held = true
+}
+
+// release shows the layout fitting the terminal again
+func (s *screen) release() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.notify()
+	s.held = false
+	return setLayout(s.c, s.current())
+}
+
+// mainLayout is the timer, buttons and summaries
+func mainLayout(b *buttonSet, w *widgets, s *summary, l *errorLog, keys *keyHandler) (layout, error) {
+	builder := newLayoutBuilder()
 
 	// Add first row
+	timerWidth := w.timer.widthPerc()
 	builder.Add(grid.RowHeightPerc(30,
-		grid.ColWidthPercWithOpts(30,
+		grid.ColWidthPercWithOpts(timerWidth,
 			[]container.Option{
 				container.Border(linestyle.Light),
-				container.BorderTitle("Press Q to Quit"),
+				container.BorderTitle(fmt.Sprintf("Press %s to Quit, %s for Help",
+					keyName(keys.keysFor(actionQuit)[0]), keyName(keys.keysFor(actionHelp)[0]))),
 			},
 			// Add inside row
 			grid.RowHeightPerc(80,
-				grid.Widget(w.donTimer)),
+				builder.widget(w.timer)),
 			grid.RowHeightPercWithOpts(20,
 				[]container.Option{
 					container.AlignHorizontal(align.HorizontalCenter),
 				},
-				grid.Widget(w.txtTimer,
+				builder.widget(w.txtTimer,
 					container.AlignHorizontal(align.HorizontalCenter),
 					container.AlignVertical(align.VerticalMiddle),
 					container.PaddingLeftPercent(49),
 				),
 			),
 		),
-		grid.ColWidthPerc(70,
-			grid.RowHeightPerc(80,
-				grid.Widget(w.disType, container.Border(linestyle.Light)),
+		grid.ColWidthPerc(100-timerWidth,
+			grid.RowHeightPerc(60,
+				builder.widget(w.disType, container.Border(linestyle.Light)),
 			),
 			grid.RowHeightPerc(20,
-				grid.Widget(w.txtInfo, container.Border(linestyle.Light)),
+				builder.widget(s.txtToday, container.Border(linestyle.Light)),
+			),
+			grid.RowHeightPerc(20,
+				builder.widget(w.txtInfo, container.Border(linestyle.Light), container.ID(infoID)),
 			),
 		),
 	),
@@ -46,9 +198,9 @@ func newGrid(b *buttonSet, w *widgets, s *summary, t terminalapi.Terminal) (*con
 	builder.Add(
 		grid.RowHeightPerc(10,
 			grid.ColWidthPerc(50,
-				grid.Widget(b.btStart)),
+				builder.widget(b.btStart)),
 			grid.ColWidthPerc(50,
-				grid.Widget(b.btPause)),
+				builder.widget(b.btPause)),
 		),
 	)
 
@@ -56,27 +208,82 @@ func newGrid(b *buttonSet, w *widgets, s *summary, t terminalapi.Terminal) (*con
 	builder.Add(
 		grid.RowHeightPerc(60,
 			grid.ColWidthPerc(30,
-				grid.Widget(s.bcDay,
-					container.Border(linestyle.Light),
-					container.BorderTitle("Daily Summary (minutes)"),
+				grid.RowHeightPerc(40,
+					builder.widget(s.bcDay,
+						container.Border(linestyle.Light),
+						container.BorderTitle("Daily Summary (minutes)"),
+					),
+				),
+				grid.RowHeightPerc(45,
+					builder.widget(s.txtHeatmap,
+						container.Border(linestyle.Light),
+						container.BorderTitle("Pomodoros per Day"),
+					),
+				),
+				grid.RowHeightPerc(15,
+					builder.widget(s.txtStreak,
+						container.Border(linestyle.Light),
+						container.BorderTitle("Streak"),
+					),
 				),
 			),
 			grid.ColWidthPerc(70,
-				grid.Widget(s.lcWeekly,
-					container.Border(linestyle.Light),
-					container.BorderTitle("Weekly Summary"),
+				grid.RowHeightPerc(75,
+					builder.widget(s.lcWeekly,
+						container.Border(linestyle.Light),
+						container.BorderTitle("Weekly Summary"),
+					),
+				),
+				grid.RowHeightPerc(25,
+					builder.widget(l.txt,
+						container.Border(linestyle.Light),
+						container.BorderTitle("Errors"),
+					),
 				),
 			),
 		),
 	)
 
-	gridOpts, err := builder.Build()
-	if err != nil {
-		return nil, err
-	}
-	c, err := container.New(t, gridOpts...)
-	if err != nil {
-		return nil, err
-	}
-	return c, nil
+	return builder.build()
+}
+
+// statsLayout is the stats text over the weekday breakdown
+func statsLayout(st *statsView) (layout, error) {
+	builder := newLayoutBuilder()
+	builder.Add(
+		grid.RowHeightPerc(30,
+			builder.widget(st.txtStats,
+				container.Border(linestyle.Light),
+				container.BorderTitle("Stats"),
+			),
+		),
+		grid.RowHeightPerc(70,
+			builder.widget(st.bcWeekday,
+				container.Border(linestyle.Light),
+				container.BorderTitle(fmt.Sprintf("Weekday Breakdown (minutes, last %d weeks)", statsWeeks)),
+			),
+		),
+	)
+	return builder.build()
+}
+
+// compactLayout is the interval in a single line, for small terminals
+func compactLayout(w *widgets) (layout, error) {
+	builder := newLayoutBuilder()
+	builder.Add(
+		builder.widget(w.compact, container.ID(infoID)),
+	)
+	return builder.build()
+}
+
+// helpLayout is the list of keys in txt
+func helpLayout(txt *text.Text) (layout, error) {
+	builder := newLayoutBuilder()
+	builder.Add(
+		builder.widget(txt,
+			container.Border(linestyle.Light),
+			container.BorderTitle("Keys"),
+		),
+	)
+	return builder.build()
 }
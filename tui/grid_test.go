@@ -0,0 +1,134 @@
+package tui
+
+import (
+	"image"
+	"testing"
+
+	"github.com/mum4k/termdash/widgetapi"
+	"github.com/mum4k/termdash/widgets/barchart"
+	"github.com/mum4k/termdash/widgets/button"
+	"github.com/mum4k/termdash/widgets/donut"
+	"github.com/mum4k/termdash/widgets/linechart"
+	"github.com/mum4k/termdash/widgets/segmentdisplay"
+	"github.com/mum4k/termdash/widgets/text"
+)
+
+// testWidgets returns the widgets of the layouts, not updated by anything
+func testWidgets(t *testing.T) (*buttonSet, *widgets, *summary, *errorLog, *keyHandler) {
+	t.Helper()
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	newText := func() *text.Text {
+		txt, err := text.New()
+		must(err)
+		return txt
+	}
+	theme := themes[DefaultTheme]
+
+	var (
+		b   buttonSet
+		w   widgets
+		s   summary
+		err error
+	)
+	noop := func() error { return nil }
+	b.btStart, err = button.New("start", noop)
+	must(err)
+	b.btPause, err = button.New("pause", noop)
+	must(err)
+
+	don, err := donut.New()
+	must(err)
+	w.timer = donutTimer{don}
+	w.disType, err = segmentdisplay.New()
+	must(err)
+	w.txtInfo, w.txtTimer = newText(), newText()
+	w.compact, err = newCompactLine(theme, newErrorSink(1))
+	must(err)
+
+	s.bcDay, err = barchart.New()
+	must(err)
+	s.lcWeekly, err = linechart.New()
+	must(err)
+	s.txtToday, s.txtStreak, s.txtHeatmap = newText(), newText(), newText()
+
+	l, err := newErrorLog(theme)
+	must(err)
+	bindings, err := DefaultKeyMap().bindings()
+	must(err)
+
+	return &b, &w, &s, l, newKeyHandler(bindings)
+}
+
+func TestLayouts(t *testing.T) {
+	b, w, s, l, keys := testWidgets(t)
+	txt, err := text.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc, err := barchart.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := &statsView{txtStats: txt, bcWeekday: bc}
+	main := []widgetapi.Widget{w.timer, w.txtTimer, w.disType, s.txtToday, w.txtInfo,
+		b.btStart, b.btPause, s.bcDay, s.txtHeatmap, s.txtStreak, s.lcWeekly, l.txt}
+
+	testCases := []struct {
+		name   string
+		layout func() (layout, error)
+		exp    []widgetapi.Widget
+	}{
+		{name: "Main", exp: main, layout: func() (layout, error) {
+			return mainLayout(b, w, s, l, keys)
+		}},
+		{name: "Stats", exp: []widgetapi.Widget{txt, bc}, layout: func() (layout, error) {
+			return statsLayout(st)
+		}},
+		{name: "Compact", exp: []widgetapi.Widget{w.compact}, layout: func() (layout, error) {
+			return compactLayout(w)
+		}},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.layout()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got.widgets) != len(tt.exp) {
+				t.Fatalf("expected %d widgets, got %d", len(tt.exp), len(got.widgets))
+			}
+			for i := range tt.exp {
+				if got.widgets[i] != tt.exp[i] {
+					t.Errorf("expected widget %d to be %T, got %T", i, tt.exp[i], got.widgets[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFitsMain(t *testing.T) {
+	testCases := []struct {
+		name string
+		size image.Point
+		exp  bool
+	}{
+		{name: "Large", size: image.Pt(120, 40), exp: true},
+		{name: "Smallest", size: image.Pt(compactCols, compactRows), exp: true},
+		{name: "Short", size: image.Pt(120, 10), exp: false},
+		{name: "Narrow", size: image.Pt(40, 40), exp: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fitsMain(tt.size); got != tt.exp {
+				t.Errorf("expected %t, got %t", tt.exp, got)
+			}
+		})
+	}
+}
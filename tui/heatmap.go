@@ -0,0 +1,120 @@
+package tui
+
+import (
+	"os"
+	"strings"
+
+	"github.com/mum4k/termdash/cell"
+	"github.com/mum4k/termdash/widgets/text"
+	"github.com/snirkop89/pomo/pomodoro"
+)
+
+// heatmapWeeks is how many weeks the heatmap covers
+const heatmapWeeks = 12
+
+// heatmapSteps is the count of shades of the heatmap, the first one for
+// days without pomodoros
+const heatmapSteps = 5
+
+// heatmapDensity draws the shades as characters where colors are limited
+var heatmapDensity = [heatmapSteps]string{".", ":", "+", "*", "#"}
+
+// heatmapCell is the width of the cell of a day
+const heatmapCell = 2
+
+// heatBucket returns the shade of a day with count pomodoros, max being
+// the most of any day. Shade 0 is for days without any, the others split
+// 1 to max evenly
+func heatBucket(count, max int) int {
+	if count <= 0 || max <= 0 {
+		return 0
+	}
+	if count >= max {
+		return heatmapSteps - 1
+	}
+	// Rounding up, so a single pomodoro gets the first shade
+	return (count*(heatmapSteps-1) + max - 1) / max
+}
+
+// limitedColors tells if the terminal described by the TERM and COLORTERM
+// variables has too few colors for the shades of the heatmap, or if
+// colors are turned off by setting NO_COLOR
+func limitedColors(term, colorTerm, noColor string) bool {
+	if noColor != "" {
+		return true
+	}
+	return colorTerm == "" && !strings.Contains(term, "256color")
+}
+
+// newHeatmap draws the pomodoros done each day of the last weeks, a row
+// per weekday and a column per week. It's updated by calling the function
+// returned
+func newHeatmap(config *pomodoro.IntervalConfig, theme Theme) (*text.Text, func() error, error) {
+	txt, err := text.New()
+	if err != nil {
+		return nil, nil, err
+	}
+	limited := limitedColors(os.Getenv("TERM"), os.Getenv("COLORTERM"), os.Getenv("NO_COLOR"))
+	textOpts := text.WriteCellOpts(cell.FgColor(theme.Text))
+	days := weekdays(config)
+
+	updateWidget := func() error {
+		grid, labels, err := pomodoro.HeatmapData(config.Now(), heatmapWeeks, config)
+		if err != nil {
+			return err
+		}
+		max := 0
+		for _, column := range grid {
+			for _, n := range column {
+				if n > max {
+					max = n
+				}
+			}
+		}
+
+		txt.Reset()
+		if err := txt.Write("    "+heatmapHeader(labels)+"\n", textOpts); err != nil {
+			return err
+		}
+		for k, day := range days {
+			if err := txt.Write(day.String()[:3]+" ", textOpts); err != nil {
+				return err
+			}
+			for _, column := range grid {
+				var err error
+				switch n := column[k]; {
+				case n == pomodoro.HeatmapNoDay:
+					err = txt.Write(strings.Repeat(" ", heatmapCell))
+				case limited:
+					err = txt.Write(heatmapDensity[heatBucket(n, max)]+" ", textOpts)
+				default:
+					err = txt.Write(strings.Repeat(" ", heatmapCell),
+						text.WriteCellOpts(cell.BgColor(theme.Heatmap[heatBucket(n, max)])))
+				}
+				if err != nil {
+					return err
+				}
+			}
+			if err := txt.Write("\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return txt, updateWidget, nil
+}
+
+// heatmapHeader writes the month labels over the columns of the weeks. A
+// label running into the next one hides it
+func heatmapHeader(labels []string) string {
+	line := []byte(strings.Repeat(" ", len(labels)*heatmapCell+len("Jan")))
+	end := 0
+	for i, l := range labels {
+		at := i * heatmapCell
+		if l == "" || at < end {
+			continue
+		}
+		end = at + copy(line[at:], l)
+	}
+	return strings.TrimRight(string(line), " ")
+}
@@ -0,0 +1,64 @@
+package tui
+
+import "testing"
+
+func TestHeatBucket(t *testing.T) {
+	testCases := []struct {
+		name  string
+		count int
+		max   int
+		exp   int
+	}{
+		{name: "None", count: 0, max: 8, exp: 0},
+		{name: "NoneAtAll", count: 0, max: 0, exp: 0},
+		{name: "One", count: 1, max: 8, exp: 1},
+		{name: "Quarter", count: 2, max: 8, exp: 1},
+		{name: "AboveQuarter", count: 3, max: 8, exp: 2},
+		{name: "Half", count: 4, max: 8, exp: 2},
+		{name: "ThreeQuarters", count: 6, max: 8, exp: 3},
+		{name: "AlmostMax", count: 7, max: 8, exp: 4},
+		{name: "Max", count: 8, max: 8, exp: heatmapSteps - 1},
+		{name: "OnlyDay", count: 1, max: 1, exp: heatmapSteps - 1},
+		{name: "AboveMax", count: 9, max: 8, exp: heatmapSteps - 1},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := heatBucket(tt.count, tt.max); got != tt.exp {
+				t.Errorf("expected shade %d, got %d", tt.exp, got)
+			}
+		})
+	}
+}
+
+func TestLimitedColors(t *testing.T) {
+	testCases := []struct {
+		name      string
+		term      string
+		colorTerm string
+		noColor   string
+		exp       bool
+	}{
+		{name: "256Colors", term: "xterm-256color", exp: false},
+		{name: "TrueColor", term: "xterm", colorTerm: "truecolor", exp: false},
+		{name: "8Colors", term: "xterm", exp: true},
+		{name: "Console", term: "linux", exp: true},
+		{name: "Unknown", exp: true},
+		{name: "NoColor", term: "xterm-256color", noColor: "1", exp: true},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := limitedColors(tt.term, tt.colorTerm, tt.noColor); got != tt.exp {
+				t.Errorf("expected %t, got %t", tt.exp, got)
+			}
+		})
+	}
+}
+
+func TestHeatmapHeader(t *testing.T) {
+	got := heatmapHeader([]string{"Jan", "", "", "", "Feb", "Mar", "", "Apr"})
+	if exp := "Jan     Feb   Apr"; got != exp {
+		t.Errorf("expected %q, got %q", exp, got)
+	}
+}
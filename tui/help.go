@@ -0,0 +1,74 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mum4k/termdash/cell"
+	"github.com/mum4k/termdash/widgets/text"
+)
+
+// helpText renders bindings as one line per binding, its keys then its
+// description in aligned columns
+func helpText(bindings []keyBinding) string {
+	keys := make([]string, len(bindings))
+	width := 0
+	for i, b := range bindings {
+		names := make([]string, len(b.keys))
+		for j, k := range b.keys {
+			names[j] = keyName(k)
+		}
+		keys[i] = strings.Join(names, ", ")
+		if len(keys[i]) > width {
+			width = len(keys[i])
+		}
+	}
+
+	var sb strings.Builder
+	for i, b := range bindings {
+		fmt.Fprintf(&sb, "  %-*s  %s\n", width, keys[i], b.help)
+	}
+	sb.WriteString("\n  Press any key to go back")
+	return sb.String()
+}
+
+// helpScreen swaps the app layout for the list of keys until the next key
+type helpScreen struct {
+	screen *screen
+	help   layout
+	keys   *keyHandler
+	update func(error)
+}
+
+func newHelpScreen(s *screen, theme Theme, keys *keyHandler,
+	redrawCh chan<- bool, errs *errorSink) (*helpScreen, error) {
+	txt, err := text.New()
+	if err != nil {
+		return nil, err
+	}
+	if err := txt.Write(helpText(keys.bindings), text.WriteCellOpts(cell.FgColor(theme.Text))); err != nil {
+		return nil, err
+	}
+	help, err := helpLayout(txt)
+	if err != nil {
+		return nil, err
+	}
+
+	update := func(err error) {
+		if err != nil {
+			errs.report(err)
+			return
+		}
+		redrawCh <- true
+	}
+	return &helpScreen{screen: s, help: help, keys: keys, update: update}, nil
+}
+
+// show shows the help, going back to the previous layout on the next key
+func (h *helpScreen) show() {
+	h.keys.next(func() {
+		h.update(h.screen.release())
+	})
+	h.screen.hold()
+	h.update(setLayout(h.screen.c, h.help))
+}
@@ -0,0 +1,147 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mum4k/termdash/keyboard"
+)
+
+var (
+	// ErrKeyConflict is returned for a key bound to more than one action
+	ErrKeyConflict = errors.New("key bound twice")
+	// ErrNoKey is returned for an action without any key
+	ErrNoKey = errors.New("action without a key")
+	// ErrInvalidKey is returned by ParseKeys for keys it doesn't know
+	ErrInvalidKey = errors.New("invalid key")
+)
+
+// KeyMap assigns keys to the actions of the app
+type KeyMap struct {
+	// Start starts or resumes the interval and Pause pauses it
+	Start []keyboard.Key
+	Pause []keyboard.Key
+	// Skip skips to the next interval
+	Skip []keyboard.Key
+	// Stop cancels the interval, or counts it as done past the
+	// completion threshold
+	Stop []keyboard.Key
+	// Help shows the keys
+	Help []keyboard.Key
+	// View switches to the next view, cycling back to the first
+	View []keyboard.Key
+	// Quit exits, pausing the running interval
+	Quit []keyboard.Key
+}
+
+// DefaultKeyMap returns the keys used unless others are set
+func DefaultKeyMap() KeyMap {
+	return KeyMap{
+		Start: []keyboard.Key{'s'},
+		Pause: []keyboard.Key{'p'},
+		Skip:  []keyboard.Key{'n', 'N'},
+		Stop:  []keyboard.Key{'x'},
+		Help:  []keyboard.Key{'?'},
+		View:  []keyboard.Key{keyboard.KeyTab},
+		Quit:  []keyboard.Key{'q', 'Q', keyboard.KeyCtrlC},
+	}
+}
+
+// empty reports whether no action has keys
+func (m KeyMap) empty() bool {
+	return len(m.Start)+len(m.Pause)+len(m.Skip)+len(m.Stop)+len(m.Help)+len(m.View)+len(m.Quit) == 0
+}
+
+// bindings returns the keys of each action, in the order they're listed in
+// the help. Actions without keys and keys bound to more than one action
+// are an error
+func (m KeyMap) bindings() ([]keyBinding, error) {
+	bindings := []keyBinding{
+		{m.Start, actionStart, "Start or resume the interval"},
+		{m.Pause, actionPause, "Pause the running interval"},
+		{m.Skip, actionSkip, "Skip to the next interval"},
+		{m.Stop, actionStop, "Stop the interval, cancelling it"},
+		{m.Help, actionHelp, "Show this help"},
+		{m.View, actionView, "Switch between the timer and the stats"},
+		{m.Quit, actionQuit, "Quit, pausing the running interval"},
+	}
+
+	bound := make(map[keyboard.Key]string)
+	for _, b := range bindings {
+		if len(b.keys) == 0 {
+			return nil, fmt.Errorf("%w: %s", ErrNoKey, b.action)
+		}
+		for _, k := range b.keys {
+			if action, ok := bound[k]; ok {
+				if action == b.action {
+					continue
+				}
+				return nil, fmt.Errorf("%w: %s is bound to both %s and %s", ErrKeyConflict, keyName(k), action, b.action)
+			}
+			bound[k] = b.action
+		}
+	}
+	return bindings, nil
+}
+
+// keyNames are the names of keys that aren't printable
+var keyNames = map[keyboard.Key]string{
+	keyboard.KeyCtrlC: "Ctrl+C",
+	keyboard.KeySpace: "Space",
+	keyboard.KeyEnter: "Enter",
+	keyboard.KeyTab:   "Tab",
+	keyboard.KeyEsc:   "Esc",
+}
+
+func keyName(k keyboard.Key) string {
+	if name, ok := keyNames[k]; ok {
+		return name
+	}
+	return k.String()
+}
+
+// ParseKeys parses a comma separated list of keys, each a character or
+// the name of a key like Ctrl+C, Space, Enter, Tab or Esc. Names aren't
+// case sensitive, characters are. A comma is written as "comma"
+func ParseKeys(s string) ([]keyboard.Key, error) {
+	var keys []keyboard.Key
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		k, err := parseKey(field)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+func parseKey(s string) (keyboard.Key, error) {
+	if utf8.RuneCountInString(s) == 1 {
+		r, _ := utf8.DecodeRuneInString(s)
+		return keyboard.Key(r), nil
+	}
+	if strings.EqualFold(s, "comma") {
+		return ',', nil
+	}
+	for k, name := range keyNames {
+		if strings.EqualFold(s, name) {
+			return k, nil
+		}
+	}
+	return 0, fmt.Errorf("%w %q", ErrInvalidKey, s)
+}
+
+// FormatKeys writes keys as ParseKeys reads them
+func FormatKeys(keys []keyboard.Key) string {
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = keyName(k)
+		if k == ',' {
+			names[i] = "comma"
+		}
+	}
+	return strings.Join(names, ",")
+}
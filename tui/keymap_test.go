@@ -0,0 +1,123 @@
+package tui
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mum4k/termdash/keyboard"
+	"github.com/mum4k/termdash/terminal/terminalapi"
+)
+
+func TestKeyMapBindings(t *testing.T) {
+	conflict := DefaultKeyMap()
+	conflict.Skip = []keyboard.Key{'j', 'p'}
+
+	noQuit := DefaultKeyMap()
+	noQuit.Quit = nil
+
+	repeated := DefaultKeyMap()
+	repeated.Quit = []keyboard.Key{'q', 'q'}
+
+	testCases := []struct {
+		name   string
+		m      KeyMap
+		expErr error
+	}{
+		{name: "Default", m: DefaultKeyMap()},
+		{name: "Repeated", m: repeated},
+		{name: "Conflict", m: conflict, expErr: ErrKeyConflict},
+		{name: "NoKey", m: noQuit, expErr: ErrNoKey},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			bindings, err := tt.m.bindings()
+			if tt.expErr != nil {
+				if !errors.Is(err, tt.expErr) {
+					t.Fatalf("expected error %q, got %v", tt.expErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(bindings) != 7 {
+				t.Errorf("expected 7 bindings, got %d", len(bindings))
+			}
+		})
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	keys, err := ParseKeys("j, J,ctrl+c,Space,comma")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []keyboard.Key{'j', 'J', keyboard.KeyCtrlC, keyboard.KeySpace, ','}
+	if FormatKeys(keys) != FormatKeys(exp) {
+		t.Errorf("expected keys %s, got %s", FormatKeys(exp), FormatKeys(keys))
+	}
+
+	// Formatted keys parse back
+	if again, err := ParseKeys(FormatKeys(keys)); err != nil || FormatKeys(again) != FormatKeys(keys) {
+		t.Errorf("expected keys %s, got %s, %v", FormatKeys(keys), FormatKeys(again), err)
+	}
+
+	for _, s := range []string{"", "jk", "hyper+q"} {
+		if _, err := ParseKeys(s); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("expected error %q for %q, got %v", ErrInvalidKey, s, err)
+		}
+	}
+}
+
+func TestKeyHandler(t *testing.T) {
+	m := DefaultKeyMap()
+	m.Skip = []keyboard.Key{'j', keyboard.KeyEnter}
+	bindings, err := m.bindings()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ran := make(chan string, 1)
+	h := newKeyHandler(bindings)
+	for _, action := range []string{actionSkip, actionQuit} {
+		action := action
+		h.bind(action, func() { ran <- action })
+	}
+	press := func(k keyboard.Key) string {
+		t.Helper()
+		h.handle(&terminalapi.Keyboard{Key: k})
+		select {
+		case action := <-ran:
+			return action
+		case <-time.After(time.Second):
+			return ""
+		}
+	}
+
+	if action := press(keyboard.KeyEnter); action != actionSkip {
+		t.Errorf("expected %q, got %q", actionSkip, action)
+	}
+	if action := press(keyboard.KeyCtrlC); action != actionQuit {
+		t.Errorf("expected %q, got %q", actionQuit, action)
+	}
+
+	// Keys of buttons and unbound keys run nothing
+	h.handle(&terminalapi.Keyboard{Key: 's'})
+	h.handle(&terminalapi.Keyboard{Key: 'x'})
+	select {
+	case action := <-ran:
+		t.Errorf("expected no action, got %q", action)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// An answer isn't taken as an action
+	h.ask(func() { ran <- "yes" }, func() { ran <- "no" })
+	if action := press('j'); action != "no" {
+		t.Errorf("expected the key to answer no, got %q", action)
+	}
+	if action := press('j'); action != actionSkip {
+		t.Errorf("expected %q after the answer, got %q", actionSkip, action)
+	}
+}
@@ -0,0 +1,160 @@
+package tui
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mum4k/termdash/keyboard"
+	"github.com/mum4k/termdash/terminal/terminalapi"
+)
+
+// Actions bound to keys
+const (
+	actionStart = "start"
+	actionPause = "pause"
+	actionSkip  = "skip"
+	actionStop  = "stop"
+	actionHelp  = "help"
+	actionView  = "view"
+	actionQuit  = "quit"
+)
+
+// keyBinding maps keys to an action, described by help
+type keyBinding struct {
+	keys   []keyboard.Key
+	action string
+	help   string
+}
+
+// confirmKeys answer yes to a question asked by keyHandler.ask
+var confirmKeys = []keyboard.Key{'y', 'Y'}
+
+// keyHandler runs the action bound to each key pressed. Actions run in
+// their own goroutine, so they may update the widgets. Start and pause are
+// handled by their buttons, which take their keys from here
+type keyHandler struct {
+	bindings []keyBinding
+	actions  map[string]func()
+
+	mu      sync.Mutex
+	yes, no func()
+	asking  bool
+	grabbed func(keyboard.Key)
+}
+
+func newKeyHandler(bindings []keyBinding) *keyHandler {
+	return &keyHandler{bindings: bindings, actions: make(map[string]func())}
+}
+
+// bind sets the function run for action
+func (h *keyHandler) bind(action string, fn func()) {
+	h.actions[action] = fn
+}
+
+// ask takes the next key as the answer to a question: yes runs on one of
+// confirmKeys and no on any other key, which isn't otherwise handled
+func (h *keyHandler) ask(yes, no func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.yes, h.no, h.asking = yes, no, true
+}
+
+// next runs fn on the next key, which isn't otherwise handled
+func (h *keyHandler) next(fn func()) {
+	h.ask(fn, fn)
+}
+
+// keysFor returns the keys bound to action
+func (h *keyHandler) keysFor(action string) []keyboard.Key {
+	for _, b := range h.bindings {
+		if b.action == action {
+			return b.keys
+		}
+	}
+	return nil
+}
+
+// grab passes every key to fn instead of running actions, until release
+func (h *keyHandler) grab(fn func(keyboard.Key)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.grabbed = fn
+}
+
+func (h *keyHandler) release() {
+	h.grab(nil)
+}
+
+func (h *keyHandler) handle(k *terminalapi.Keyboard) {
+	h.mu.Lock()
+	if h.grabbed != nil {
+		fn := h.grabbed
+		h.mu.Unlock()
+		fn(k.Key)
+		return
+	}
+	if h.asking {
+		answer := h.no
+		if hasKey(confirmKeys, k.Key) {
+			answer = h.yes
+		}
+		h.yes, h.no, h.asking = nil, nil, false
+		h.mu.Unlock()
+		if answer != nil {
+			go answer()
+		}
+		return
+	}
+	h.mu.Unlock()
+
+	for _, b := range h.bindings {
+		if fn, ok := h.actions[b.action]; ok && hasKey(b.keys, k.Key) {
+			go fn()
+			return
+		}
+	}
+}
+
+func hasKey(keys []keyboard.Key, key keyboard.Key) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// quitConfirmTimeout is how long a quit keypress made while an interval is
+// running waits for the second one
+const quitConfirmTimeout = 3 * time.Second
+
+// quitStep is what a quit keypress does
+type quitStep int
+
+const (
+	// quitNow exits
+	quitNow quitStep = iota
+	// quitConfirm asks for a second keypress
+	quitConfirm
+)
+
+// quitGuard keeps the app from exiting on a single quit keypress while an
+// interval is running
+type quitGuard struct {
+	mu    sync.Mutex
+	armed time.Time
+}
+
+// press decides what a quit keypress at now does. While running, only a
+// press within quitConfirmTimeout of the previous one quits
+func (g *quitGuard) press(running bool, now time.Time) quitStep {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !running || (!g.armed.IsZero() && now.Sub(g.armed) < quitConfirmTimeout) {
+		g.armed = time.Time{}
+		return quitNow
+	}
+	g.armed = now
+	return quitConfirm
+}
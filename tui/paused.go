@@ -0,0 +1,64 @@
+package tui
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// pauseClock runs a function every second while the interval is paused,
+// e.g. to show for how long
+type pauseClock struct {
+	mu   sync.Mutex
+	done chan struct{}
+}
+
+// start runs tick every second until stop is called or ctx is done,
+// replacing any function already running
+func (p *pauseClock) start(ctx context.Context, tick func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stopLocked()
+	stop := make(chan struct{})
+	p.done = stop
+
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			case <-ctx.Done():
+				return
+			}
+
+			// Holding the lock, tick never runs once stop returns
+			p.mu.Lock()
+			select {
+			case <-stop:
+				p.mu.Unlock()
+				return
+			default:
+			}
+			tick()
+			p.mu.Unlock()
+		}
+	}()
+}
+
+// stop stops the function started, if any
+func (p *pauseClock) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopLocked()
+}
+
+func (p *pauseClock) stopLocked() {
+	if p.done != nil {
+		close(p.done)
+		p.done = nil
+	}
+}
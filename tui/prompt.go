@@ -0,0 +1,76 @@
+package tui
+
+import (
+	"github.com/mum4k/termdash/cell"
+	"github.com/mum4k/termdash/container"
+	"github.com/mum4k/termdash/keyboard"
+	"github.com/mum4k/termdash/widgets/textinput"
+)
+
+// infoID identifies the container of the info text, where the label is
+// asked
+const infoID = "info"
+
+// labelAnswer is the label entered, ok false if the question was skipped
+type labelAnswer struct {
+	label string
+	ok    bool
+}
+
+// labelPrompt asks for the label of a pomodoro in place of the info text
+type labelPrompt struct {
+	// screen is set once the layouts are built
+	screen   *screen
+	keys     *keyHandler
+	theme    Theme
+	redrawCh chan<- bool
+}
+
+// ask asks for the label, with prev entered already. Enter confirms the
+// label and Esc skips the question, returning false
+func (p *labelPrompt) ask(prev string) (string, bool, error) {
+	answers := make(chan labelAnswer, 1)
+	answer := func(a labelAnswer) {
+		select {
+		case answers <- a:
+		default:
+		}
+	}
+
+	input, err := textinput.New(
+		textinput.Label("What are you working on? ", cell.FgColor(p.theme.Text)),
+		textinput.DefaultText(prev),
+		textinput.TextColor(p.theme.Text),
+		textinput.ExclusiveKeyboardOnFocus(),
+		textinput.OnSubmit(func(label string) error {
+			answer(labelAnswer{label, true})
+			return nil
+		}),
+	)
+	if err != nil {
+		return "", false, err
+	}
+
+	// The input takes the keys, but the actions mustn't
+	p.keys.grab(func(k keyboard.Key) {
+		if k == keyboard.KeyEsc {
+			answer(labelAnswer{})
+		}
+	})
+	defer p.keys.release()
+
+	// The layout stays while asking, so the input isn't lost on resize
+	p.screen.hold()
+	if err := p.screen.c.Update(infoID, container.PlaceWidget(input), container.Focused()); err != nil {
+		p.screen.release()
+		return "", false, err
+	}
+	p.redrawCh <- true
+
+	a := <-answers
+	if err := p.screen.release(); err != nil {
+		return "", false, err
+	}
+	p.redrawCh <- true
+	return a.label, a.ok, nil
+}
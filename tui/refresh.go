@@ -0,0 +1,71 @@
+package tui
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultSummaryRefresh is how often the summaries refresh unless set
+const DefaultSummaryRefresh = time.Minute
+
+// refresher drives the updates of summary widgets: on every update sent
+// and periodically while the widgets are shown, so they catch up with
+// midnight and with changes made elsewhere
+type refresher struct {
+	every    time.Duration
+	redrawCh chan<- bool
+	// invalidate drops the cached summaries before a periodic refresh, so
+	// it sees the changes made elsewhere. It may be nil
+	invalidate func()
+	hidden     int32
+}
+
+func newRefresher(every time.Duration, redrawCh chan<- bool, invalidate func()) *refresher {
+	if every <= 0 {
+		every = DefaultSummaryRefresh
+	}
+	return &refresher{every: every, redrawCh: redrawCh, invalidate: invalidate}
+}
+
+// show sets whether the widgets are on screen. The periodic refresh is
+// skipped while they aren't
+func (r *refresher) show(shown bool) {
+	var hidden int32
+	if !shown {
+		hidden = 1
+	}
+	atomic.StoreInt32(&r.hidden, hidden)
+}
+
+// run calls fn on every update sent and every r.every while shown, until
+// ctx is done. Only this goroutine calls fn, so two refreshes never run at
+// once, and an update sent restarts the period so a periodic refresh
+// doesn't follow it right away. Periodic refreshes invalidate the cache
+// first, as nothing else tells of changes made elsewhere
+func (r *refresher) run(ctx context.Context, update <-chan bool, fn func()) {
+	ticker := time.NewTicker(r.every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-update:
+			fn()
+			ticker.Reset(r.every)
+		case <-ticker.C:
+			if atomic.LoadInt32(&r.hidden) == 1 {
+				continue
+			}
+			if r.invalidate != nil {
+				r.invalidate()
+			}
+			fn()
+			select {
+			case r.redrawCh <- true:
+			case <-ctx.Done():
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
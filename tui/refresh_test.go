@@ -0,0 +1,167 @@
+package tui
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro"
+	"github.com/snirkop89/pomo/pomodoro/repository"
+)
+
+func TestRefresher(t *testing.T) {
+	testCases := []struct {
+		name    string
+		shown   bool
+		updates int
+		// expPeriodic tells if refreshes run besides the updates
+		expPeriodic bool
+	}{
+		{name: "Shown", shown: true, updates: 2, expPeriodic: true},
+		{name: "Hidden", shown: false, updates: 2, expPeriodic: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			redrawCh := make(chan bool)
+			go func() {
+				for {
+					select {
+					case <-redrawCh:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+
+			r := newRefresher(5*time.Millisecond, redrawCh, nil)
+			r.show(tt.shown)
+
+			var calls, running int32
+			done := make(chan struct{})
+			update := make(chan bool)
+			go func() {
+				r.run(ctx, update, func() {
+					if !atomic.CompareAndSwapInt32(&running, 0, 1) {
+						t.Error("expected refreshes not to overlap")
+					}
+					atomic.AddInt32(&calls, 1)
+					time.Sleep(time.Millisecond)
+					atomic.StoreInt32(&running, 0)
+				})
+				close(done)
+			}()
+
+			for n := 0; n < tt.updates; n++ {
+				update <- true
+			}
+			time.Sleep(50 * time.Millisecond)
+			cancel()
+			<-done
+
+			got := int(atomic.LoadInt32(&calls))
+			if tt.expPeriodic && got <= tt.updates {
+				t.Errorf("expected more than %d refreshes, got %d", tt.updates, got)
+			}
+			if !tt.expPeriodic && got != tt.updates {
+				t.Errorf("expected %d refreshes, got %d", tt.updates, got)
+			}
+		})
+	}
+}
+
+func TestRefresherLeak(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	// Nothing redraws, so the refreshes are stuck redrawing until ctx is
+	// done
+	redrawCh := make(chan bool)
+	for n := 0; n < 10; n++ {
+		r := newRefresher(time.Millisecond, redrawCh, nil)
+		go r.run(ctx, make(chan bool), func() {})
+	}
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d goroutines once done, got %d", before, runtime.NumGoroutine())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRefresherInvalidates(t *testing.T) {
+	repo := repository.NewInMemoryRepo()
+	config := pomodoro.NewConfig(pomodoro.NewSummaryCache(repo), 0, 0, 0)
+	day := time.Date(2023, time.May, 10, 0, 0, 0, 0, time.UTC)
+	work := func() time.Duration {
+		t.Helper()
+		ds, err := pomodoro.DailySummary(day, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ds[0]
+	}
+
+	// Written by another process, the pomodoro isn't seen through the
+	// cache
+	if d := work(); d != 0 {
+		t.Fatalf("expected no work, got %s", d)
+	}
+	_, err := repo.Create(pomodoro.Interval{
+		StartTime:       day.Add(9 * time.Hour),
+		PlannedDuration: 25 * time.Minute,
+		ActualDuration:  25 * time.Minute,
+		Category:        pomodoro.CategoryPomodoro,
+		State:           pomodoro.StateDone,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := work(); d != 0 {
+		t.Fatalf("expected the cached summary, got %s", d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	redrawCh := make(chan bool)
+	go func() {
+		for {
+			select {
+			case <-redrawCh:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Until the next periodic refresh
+	r := newRefresher(5*time.Millisecond, redrawCh, func() { pomodoro.InvalidateCache(config) })
+	refreshed := make(chan time.Duration)
+	go r.run(ctx, make(chan bool), func() {
+		ds, err := pomodoro.DailySummary(day, config)
+		if err != nil {
+			t.Error(err)
+		}
+		select {
+		case refreshed <- ds[0]:
+		case <-ctx.Done():
+		}
+	})
+	select {
+	case d := <-refreshed:
+		if d != 25*time.Minute {
+			t.Errorf("expected %s of work, got %s", 25*time.Minute, d)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a periodic refresh")
+	}
+}
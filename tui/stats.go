@@ -0,0 +1,168 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mum4k/termdash/cell"
+	"github.com/mum4k/termdash/widgets/barchart"
+	"github.com/mum4k/termdash/widgets/text"
+	"github.com/snirkop89/pomo/pomodoro"
+)
+
+// statsWeeks is how many weeks the weekday breakdown and the average
+// cover
+const statsWeeks = 4
+
+// statsView is the screen of statistics, refreshed when shown and when an
+// interval ends
+type statsView struct {
+	txtStats    *text.Text
+	bcWeekday   *barchart.BarChart
+	updateStats chan bool
+}
+
+func (s *statsView) update(redrawCh chan<- bool) {
+	s.updateStats <- true
+	redrawCh <- true
+}
+
+// statLine is a line of the stats text, computed by value
+type statLine struct {
+	name  string
+	value func() (string, error)
+}
+
+func newStatsView(ctx context.Context, config *pomodoro.IntervalConfig, theme Theme,
+	errs *errorSink) (*statsView, error) {
+	s := &statsView{updateStats: make(chan bool)}
+
+	txt, err := text.New()
+	if err != nil {
+		return nil, err
+	}
+	s.txtStats = txt
+
+	days := weekdays(config)
+	labels := make([]string, len(days))
+	colors := make([]cell.Color, len(days))
+	values := make([]cell.Color, len(days))
+	for i, d := range days {
+		labels[i] = d.String()[:3]
+		colors[i], values[i] = theme.Work, theme.Values
+	}
+	bc, err := barchart.New(
+		barchart.ShowValues(),
+		barchart.BarColors(colors),
+		barchart.ValueColors(values),
+		barchart.Labels(labels),
+	)
+	if err != nil {
+		return nil, err
+	}
+	s.bcWeekday = bc
+
+	lines := []statLine{
+		{"Completion this week", func() (string, error) {
+			start := pomodoro.StartOfWeek(config.Now(), config)
+			st, err := pomodoro.CompletionStats(start, start.AddDate(0, 0, 7), config)
+			return completionText(st), err
+		}},
+		{"Streak", func() (string, error) {
+			n, err := pomodoro.Streak(config)
+			return streakText(n, theme.ASCII), err
+		}},
+		{fmt.Sprintf("Average over %d weeks", statsWeeks), func() (string, error) {
+			now := config.Now()
+			ds, err := pomodoro.DaySummaries(now.AddDate(0, 0, 1-7*statsWeeks), now, config)
+			return averageText(ds), err
+		}},
+	}
+
+	// Each stat failing shows as such, leaving the others in place
+	updateText := func() {
+		var sb strings.Builder
+		for _, l := range lines {
+			value, err := l.value()
+			if err != nil {
+				errs.report(fmt.Errorf("%s: %w", strings.ToLower(l.name), err))
+				value = "unavailable"
+			}
+			fmt.Fprintf(&sb, "%s: %s\n", l.name, value)
+		}
+		txt.Reset()
+		errs.report(txt.Write(sb.String(), text.WriteCellOpts(cell.FgColor(theme.Text))))
+	}
+
+	// The chart keeps its previous values when the breakdown fails
+	updateChart := func() error {
+		totals, err := pomodoro.WeekdaySummary(statsWeeks, config)
+		if err != nil {
+			return fmt.Errorf("weekday breakdown: %w", err)
+		}
+		minutes := make([]int, len(days))
+		max := 0
+		for i, d := range days {
+			minutes[i] = int(totals[d].Minutes())
+			if minutes[i] > max {
+				max = minutes[i]
+			}
+		}
+		return bc.Values(minutes, max+max/10+1)
+	}
+
+	refresh := func() {
+		updateText()
+		errs.report(updateChart())
+	}
+
+	go func() {
+		for {
+			select {
+			case <-s.updateStats:
+				refresh()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	refresh()
+	return s, nil
+}
+
+// weekdays lists the days of the week, from the first one
+func weekdays(config *pomodoro.IntervalConfig) []time.Weekday {
+	first := time.Monday
+	if config.WeekStartsSunday {
+		first = time.Sunday
+	}
+	days := make([]time.Weekday, 7)
+	for i := range days {
+		days[i] = (first + time.Weekday(i)) % 7
+	}
+	return days
+}
+
+// completionText writes the share of pomodoros done, like "80% (8 of 10)"
+func completionText(st pomodoro.Stats) string {
+	if !st.HasRatio {
+		return "no pomodoro yet"
+	}
+	ended := st.Done + st.Cancelled + st.Skipped
+	return fmt.Sprintf("%.0f%% (%d of %d)", st.Ratio*100, st.Done, ended)
+}
+
+// averageText writes the pomodoros done per day over ds, like "4.5 a day"
+func averageText(ds []pomodoro.DaySummary) string {
+	if len(ds) == 0 {
+		return "no days yet"
+	}
+	total := 0
+	for _, d := range ds {
+		total += d.Pomodoros
+	}
+	return fmt.Sprintf("%.1f pomodoros a day", float64(total)/float64(len(ds)))
+}
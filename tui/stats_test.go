@@ -0,0 +1,42 @@
+package tui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/snirkop89/pomo/pomodoro"
+)
+
+func TestStatsText(t *testing.T) {
+	testCases := []struct {
+		name string
+		got  string
+		exp  string
+	}{
+		{name: "CompletionNone", got: completionText(pomodoro.Stats{}), exp: "no pomodoro yet"},
+		{name: "Completion", exp: "80% (8 of 10)",
+			got: completionText(pomodoro.Stats{Done: 8, Cancelled: 1, Skipped: 1, Ratio: 0.8, HasRatio: true})},
+		{name: "AverageNone", got: averageText(nil), exp: "no days yet"},
+		{name: "Average", exp: "1.5 pomodoros a day",
+			got: averageText([]pomodoro.DaySummary{{Pomodoros: 3}, {Pomodoros: 0}})},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.exp {
+				t.Errorf("expected %q, got %q", tt.exp, tt.got)
+			}
+		})
+	}
+}
+
+func TestWeekdays(t *testing.T) {
+	config := &pomodoro.IntervalConfig{}
+	if days := weekdays(config); days[0] != time.Monday || days[6] != time.Sunday {
+		t.Errorf("expected Monday to Sunday, got %v", days)
+	}
+	config.WeekStartsSunday = true
+	if days := weekdays(config); days[0] != time.Sunday || days[6] != time.Saturday {
+		t.Errorf("expected Sunday to Saturday, got %v", days)
+	}
+}
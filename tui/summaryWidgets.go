@@ -2,57 +2,106 @@ package tui
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math"
 	"time"
 
 	"github.com/mum4k/termdash/cell"
 	"github.com/mum4k/termdash/widgets/barchart"
 	"github.com/mum4k/termdash/widgets/linechart"
+	"github.com/mum4k/termdash/widgets/text"
 	"github.com/snirkop89/pomo/pomodoro"
 )
 
 type summary struct {
 	bcDay        *barchart.BarChart
 	lcWeekly     *linechart.LineChart
+	txtToday     *text.Text
+	txtStreak    *text.Text
+	txtHeatmap   *text.Text
 	updateDaily  chan bool
 	updateWeekey chan bool
+	updateToday  chan bool
+	// charts refreshes the charts and today the count of today, which the
+	// compact layout shows too
+	charts *refresher
+	today  *refresher
+}
+
+// show sets which of the summaries are on screen, refreshing only those
+func (s *summary) show(main, compact bool) {
+	s.charts.show(main)
+	s.today.show(main || compact)
 }
 
 func (s *summary) update(redrawCh chan<- bool) {
 	s.updateDaily <- true
 	s.updateWeekey <- true
+	s.updateToday <- true
 	redrawCh <- true
 }
 
-func newSummary(ctx context.Context, config *pomodoro.IntervalConfig, redrawCh chan<- bool, errorCh chan<- error) (*summary, error) {
+// newSummary returns the summaries, refreshed every refresh besides when
+// intervals end
+func newSummary(ctx context.Context, config *pomodoro.IntervalConfig, theme Theme, w *widgets,
+	refresh time.Duration, redrawCh chan<- bool, errs *errorSink) (*summary, error) {
 	var s summary
 	var err error
 
+	// Periodic refreshes catch up with other processes, so they skip the
+	// cached summaries
+	invalidate := func() { pomodoro.InvalidateCache(config) }
+	s.charts = newRefresher(refresh, redrawCh, invalidate)
+	s.today = newRefresher(refresh, redrawCh, invalidate)
 	s.updateDaily = make(chan bool)
 	s.updateWeekey = make(chan bool)
+	s.updateToday = make(chan bool)
+
+	var updateStreak func() error
+	s.txtStreak, updateStreak, err = newStreak(config, theme)
+	if err != nil {
+		return nil, err
+	}
 
-	s.bcDay, err = newBarChar(ctx, config, s.updateDaily, errorCh)
+	// The streak changes with the daily summary
+	s.bcDay, err = newBarChar(ctx, config, theme, s.updateDaily, s.charts, updateStreak, errs)
 	if err != nil {
 		return nil, err
 	}
 
-	s.lcWeekly, err = newLineChart(ctx, config, s.updateWeekey, errorCh)
+	var updateHeatmap func() error
+	s.txtHeatmap, updateHeatmap, err = newHeatmap(config, theme)
+	if err != nil {
+		return nil, err
+	}
+
+	// The heatmap changes with the weekly summary
+	s.lcWeekly, err = newLineChart(ctx, config, theme, w, s.updateWeekey, s.charts, updateHeatmap, errs)
+	if err != nil {
+		return nil, err
+	}
+
+	// The compact line counts the pomodoros of today too
+	s.txtToday, err = newToday(ctx, config, theme, s.updateToday, s.today, w.compact.setToday, errs)
 	if err != nil {
 		return nil, err
 	}
 	return &s, nil
 }
 
-func newBarChar(ctx context.Context, config *pomodoro.IntervalConfig, update <-chan bool, errorCh chan<- error) (*barchart.BarChart, error) {
+// newBarChar draws today's summary, running also after every update
+func newBarChar(ctx context.Context, config *pomodoro.IntervalConfig, theme Theme, update <-chan bool,
+	r *refresher, also func() error, errs *errorSink) (*barchart.BarChart, error) {
 	bc, err := barchart.New(
 		barchart.ShowValues(),
 		barchart.BarColors([]cell.Color{
-			cell.ColorBlue,
-			cell.ColorYellow,
+			theme.Work,
+			theme.Break,
 		}),
 		barchart.ValueColors([]cell.Color{
-			cell.ColorBlack,
-			cell.ColorBlack,
+			theme.Values,
+			theme.Values,
 		}),
 		barchart.Labels([]string{
 			"Pomodoro",
@@ -64,30 +113,27 @@ func newBarChar(ctx context.Context, config *pomodoro.IntervalConfig, update <-c
 	}
 
 	updateWidget := func() error {
-		ds, err := pomodoro.DailySummary(time.Now(), config)
+		ds, err := pomodoro.DailySummary(config.Now(), config)
 		if err != nil {
 			return err
 		}
 
-		return bc.Values(
+		err = bc.Values(
 			[]int{
 				int(ds[0].Minutes()),
 				int(ds[1].Minutes()),
 			},
 			int(math.Max(ds[0].Minutes(), ds[1].Minutes())*1.1)+1,
 		)
+		if err != nil {
+			return err
+		}
+		return also()
 	}
 
-	go func() {
-		for {
-			select {
-			case <-update:
-				errorCh <- updateWidget()
-			case <-ctx.Done():
-				return
-			}
-		}
-	}()
+	go r.run(ctx, update, func() {
+		errs.report(updateWidget())
+	})
 
 	if err := updateWidget(); err != nil {
 		return nil, err
@@ -95,13 +141,17 @@ func newBarChar(ctx context.Context, config *pomodoro.IntervalConfig, update <-c
 	return bc, nil
 }
 
-func newLineChart(ctx context.Context, config *pomodoro.IntervalConfig, update <-chan bool, errorCh chan<- error) (*linechart.LineChart, error) {
+// newLineChart draws the last week, running also after every update even
+// if it failed. Days that can't be summarized are drawn as zero with a
+// warning in the info text, rather than failing
+func newLineChart(ctx context.Context, config *pomodoro.IntervalConfig, theme Theme, w *widgets,
+	update <-chan bool, r *refresher, also func() error, errs *errorSink) (*linechart.LineChart, error) {
 	// Initialize LineChart
 
 	lc, err := linechart.New(
-		linechart.AxesCellOpts(cell.FgColor(cell.ColorRed)),
-		linechart.YLabelCellOpts(cell.FgColor(cell.ColorBlue)),
-		linechart.XLabelCellOpts(cell.FgColor(cell.ColorCyan)),
+		linechart.AxesCellOpts(cell.FgColor(theme.Axis)),
+		linechart.YLabelCellOpts(cell.FgColor(theme.YLabel)),
+		linechart.XLabelCellOpts(cell.FgColor(theme.XLabel)),
 		linechart.YAxisFormattedValues(
 			linechart.ValueFormatterSingleUnitDuration(time.Second, 0),
 		),
@@ -111,13 +161,17 @@ func newLineChart(ctx context.Context, config *pomodoro.IntervalConfig, update <
 	}
 
 	updateWidget := func() error {
-		ws, err := pomodoro.RangeSummary(time.Now(), 7, config)
-		if err != nil {
+		ws, err := pomodoro.RangeSummary(config.Now(), 7, config)
+		var partial *pomodoro.PartialSummaryError
+		switch {
+		case errors.As(err, &partial):
+			w.updateTxtInfo <- fmt.Sprintf("Weekly summary incomplete: %v", partial)
+		case err != nil:
 			return err
 		}
 
 		err = lc.Series(ws[0].Name, ws[0].Values,
-			linechart.SeriesCellOpts(cell.FgColor(cell.ColorBlue)),
+			linechart.SeriesCellOpts(cell.FgColor(theme.Work)),
 			linechart.SeriesXLabels(ws[0].Labels),
 		)
 		if err != nil {
@@ -125,25 +179,104 @@ func newLineChart(ctx context.Context, config *pomodoro.IntervalConfig, update <
 		}
 
 		return lc.Series(ws[1].Name, ws[1].Values,
-			linechart.SeriesCellOpts(cell.FgColor(cell.ColorYellow)),
+			linechart.SeriesCellOpts(cell.FgColor(theme.Break)),
 			linechart.SeriesXLabels(ws[1].Labels),
 		)
 	}
 
-	go func() {
-		for {
-			select {
-			case <-update:
-				errorCh <- updateWidget()
-			case <-ctx.Done():
-				return
-			}
-		}
-	}()
+	go r.run(ctx, update, func() {
+		errs.report(updateWidget())
+		errs.report(also())
+	})
 
 	// Force update lineChart at start
 	if err := updateWidget(); err != nil {
 		return nil, err
 	}
+	if err := also(); err != nil {
+		return nil, err
+	}
 	return lc, nil
 }
+
+// newToday shows the pomodoros completed today and their focus time, also
+// passing the count to counted. It's left empty while they can't be
+// summarized rather than failing the app
+func newToday(ctx context.Context, config *pomodoro.IntervalConfig, theme Theme,
+	update <-chan bool, r *refresher, counted func(int), errs *errorSink) (*text.Text, error) {
+	txt, err := text.New()
+	if err != nil {
+		return nil, err
+	}
+
+	updateWidget := func() error {
+		txt.Reset()
+		st, err := pomodoro.GetStatus(config)
+		if err != nil {
+			return nil
+		}
+		counted(int(st.CompletedToday))
+		ds, err := pomodoro.DailySummary(config.Now(), config)
+		if err != nil {
+			return nil
+		}
+		format := "Today: %d 🍅 · %s focus"
+		if theme.ASCII {
+			format = "Today: %d pomodoros - %s focus"
+		}
+		return txt.Write(fmt.Sprintf(format, st.CompletedToday, formatFocus(ds[0])),
+			text.WriteCellOpts(cell.FgColor(theme.Text)))
+	}
+
+	go r.run(ctx, update, func() {
+		errs.report(updateWidget())
+	})
+
+	if err := updateWidget(); err != nil {
+		return nil, err
+	}
+	return txt, nil
+}
+
+// formatFocus writes d in minutes, like 2h05m
+func formatFocus(d time.Duration) string {
+	m := int(d.Round(time.Minute) / time.Minute)
+	if m < 60 {
+		return fmt.Sprintf("%dm", m)
+	}
+	return fmt.Sprintf("%dh%02dm", m/60, m%60)
+}
+
+// newStreak shows the days in a row with a pomodoro done. It's updated
+// by calling the function returned
+func newStreak(config *pomodoro.IntervalConfig, theme Theme) (*text.Text, func() error, error) {
+	txt, err := text.New()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	updateWidget := func() error {
+		n, err := pomodoro.Streak(config)
+		if err != nil {
+			return err
+		}
+		txt.Reset()
+		return txt.Write(streakText(n, theme.ASCII), text.WriteCellOpts(cell.FgColor(theme.Text)))
+	}
+	return txt, updateWidget, nil
+}
+
+// streakText writes a streak of n days, like "🔥 12 days"
+func streakText(n int, ascii bool) string {
+	if n == 0 {
+		return "start today!"
+	}
+	days := "days"
+	if n == 1 {
+		days = "day"
+	}
+	if ascii {
+		return fmt.Sprintf("%d %s in a row", n, days)
+	}
+	return fmt.Sprintf("🔥 %d %s", n, days)
+}
@@ -0,0 +1,113 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/mum4k/termdash/cell"
+)
+
+// ErrUnknownTheme is returned by LookupTheme for themes that aren't built in
+var ErrUnknownTheme = errors.New("unknown theme")
+
+// DefaultTheme is the name of the theme used unless another one is picked
+const DefaultTheme = "default"
+
+// Theme holds the colors of the app
+type Theme struct {
+	// Timer is the color of the timer donut
+	Timer cell.Color
+	// Work and Break color the time spent on pomodoros and on breaks in
+	// the summaries
+	Work  cell.Color
+	Break cell.Color
+	// Values colors the numbers drawn on the bars of the daily summary
+	Values cell.Color
+	// Axis, XLabel and YLabel color the weekly summary axes and labels
+	Axis   cell.Color
+	XLabel cell.Color
+	YLabel cell.Color
+	// Text colors the interval type, the info and timer texts and the help
+	Text cell.Color
+	// Start and Pause fill the buttons
+	Start cell.Color
+	Pause cell.Color
+	// Heatmap shades the days of the heatmap from none to the most
+	// pomodoros
+	Heatmap [heatmapSteps]cell.Color
+	// ASCII draws symbols as plain text, for fonts without emoji
+	ASCII bool
+}
+
+var themes = map[string]Theme{
+	"default": {
+		Timer:  cell.ColorBlue,
+		Work:   cell.ColorBlue,
+		Break:  cell.ColorYellow,
+		Values: cell.ColorBlack,
+		Axis:   cell.ColorRed,
+		XLabel: cell.ColorCyan,
+		YLabel: cell.ColorBlue,
+		Text:   cell.ColorDefault,
+		Start:  cell.ColorNumber(117),
+		Pause:  cell.ColorNumber(220),
+		Heatmap: [heatmapSteps]cell.Color{
+			cell.ColorNumber(236), cell.ColorNumber(22), cell.ColorNumber(28),
+			cell.ColorNumber(34), cell.ColorNumber(40),
+		},
+	},
+	// The solarized palette as the closest of the 256 terminal colors
+	"solarized": {
+		Timer:  cell.ColorNumber(33),
+		Work:   cell.ColorNumber(33),
+		Break:  cell.ColorNumber(136),
+		Values: cell.ColorNumber(234),
+		Axis:   cell.ColorNumber(240),
+		XLabel: cell.ColorNumber(37),
+		YLabel: cell.ColorNumber(33),
+		Text:   cell.ColorNumber(245),
+		Start:  cell.ColorNumber(37),
+		Pause:  cell.ColorNumber(166),
+		Heatmap: [heatmapSteps]cell.Color{
+			cell.ColorNumber(235), cell.ColorNumber(23), cell.ColorNumber(24),
+			cell.ColorNumber(31), cell.ColorNumber(37),
+		},
+	},
+	// Shades of gray, for terminals with few colors
+	"mono": {
+		Timer:  cell.ColorWhite,
+		Work:   cell.ColorWhite,
+		Break:  cell.ColorNumber(244),
+		Values: cell.ColorBlack,
+		Axis:   cell.ColorNumber(244),
+		XLabel: cell.ColorWhite,
+		YLabel: cell.ColorWhite,
+		Text:   cell.ColorDefault,
+		Start:  cell.ColorWhite,
+		Pause:  cell.ColorNumber(244),
+		Heatmap: [heatmapSteps]cell.Color{
+			cell.ColorNumber(235), cell.ColorNumber(239), cell.ColorNumber(243),
+			cell.ColorNumber(247), cell.ColorNumber(251),
+		},
+	},
+}
+
+// LookupTheme returns the built-in theme called name
+func LookupTheme(name string) (Theme, error) {
+	t, ok := themes[name]
+	if !ok {
+		return Theme{}, fmt.Errorf("%w %q, available themes are %v", ErrUnknownTheme, name, Themes())
+	}
+	return t, nil
+}
+
+// Themes returns the names of the built-in themes in order
+func Themes() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
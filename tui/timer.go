@@ -0,0 +1,106 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mum4k/termdash/cell"
+	"github.com/mum4k/termdash/widgetapi"
+	"github.com/mum4k/termdash/widgets/donut"
+	"github.com/mum4k/termdash/widgets/gauge"
+)
+
+// ErrUnknownTimerStyle is returned for timer styles other than TimerDonut
+// and TimerGauge
+var ErrUnknownTimerStyle = errors.New("unknown timer style")
+
+// Timer styles
+const (
+	// TimerDonut draws the progress around a circle
+	TimerDonut = "donut"
+	// TimerGauge draws the progress as a horizontal bar, readable on
+	// narrow terminals
+	TimerGauge = "gauge"
+)
+
+// TimerStyles returns the names of the timer styles
+func TimerStyles() []string {
+	return []string{TimerDonut, TimerGauge}
+}
+
+// timerWidget draws the progress of the interval
+type timerWidget interface {
+	widgetapi.Widget
+	// progress draws current out of total, both in nanoseconds
+	progress(current, total int) error
+	// widthPerc is the share of the screen width the timer needs
+	widthPerc() int
+}
+
+type donutTimer struct {
+	*donut.Donut
+}
+
+func (d donutTimer) progress(current, total int) error {
+	return d.Absolute(current, total)
+}
+
+func (donutTimer) widthPerc() int { return 30 }
+
+type gaugeTimer struct {
+	*gauge.Gauge
+}
+
+// progress draws the percent done followed by the time left
+func (g gaugeTimer) progress(current, total int) error {
+	left := time.Duration(total - current).Round(time.Second)
+	return g.Absolute(current, total, gauge.TextLabel(fmt.Sprintf(" - %s left", left)))
+}
+
+// The bar needs the width to show the percent and time left
+func (gaugeTimer) widthPerc() int { return 50 }
+
+// newTimer returns the timer widget of style, updated with the current and
+// total durations sent on update
+func newTimer(ctx context.Context, style string, theme Theme, update <-chan []int,
+	errs *errorSink) (timerWidget, error) {
+	var w timerWidget
+	switch style {
+	case TimerDonut, "":
+		don, err := donut.New(donut.Clockwise(), donut.CellOpts(cell.FgColor(theme.Timer)))
+		if err != nil {
+			return nil, err
+		}
+		w = donutTimer{don}
+	case TimerGauge:
+		g, err := gauge.New(
+			gauge.Height(1),
+			gauge.Color(theme.Timer),
+			gauge.FilledTextColor(cell.ColorBlack),
+			gauge.EmptyTextColor(theme.Text),
+		)
+		if err != nil {
+			return nil, err
+		}
+		w = gaugeTimer{g}
+	default:
+		return nil, fmt.Errorf("%w %q, available styles are %v", ErrUnknownTimerStyle, style, TimerStyles())
+	}
+
+	go func() {
+		for {
+			select {
+			case d := <-update:
+				if d[0] <= d[1] {
+					errs.report(w.progress(d[0], d[1]))
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return w, nil
+}
@@ -2,62 +2,91 @@ package tui
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
 
 	"github.com/mum4k/termdash/cell"
-	"github.com/mum4k/termdash/widgets/donut"
 	"github.com/mum4k/termdash/widgets/segmentdisplay"
 	"github.com/mum4k/termdash/widgets/text"
 )
 
 type widgets struct {
-	donTimer       *donut.Donut
-	disType        *segmentdisplay.SegmentDisplay
-	txtInfo        *text.Text
-	txtTimer       *text.Text
-	updateDonTimer chan []int
+	timer    timerWidget
+	disType  *segmentdisplay.SegmentDisplay
+	txtInfo  *text.Text
+	txtTimer *text.Text
+	// compact shows the interval in one line for the compact layout
+	compact        *compactLine
+	updateTimer    chan []int
 	updateTxtInfo  chan string
 	updateTxtTimer chan string
 	updateTxtType  chan string
+	flashType      chan time.Duration
+	// bell is where the terminal bell is rung, nil to keep quiet
+	bell io.Writer
 }
 
-func newWidgets(ctx context.Context, errorCh chan<- error) (*widgets, error) {
-	donTimerCh := make(chan []int)
+const (
+	// flashDuration is how long the interval type flashes when an
+	// interval ends, switching every flashBlink
+	flashDuration = 1500 * time.Millisecond
+	flashBlink    = 250 * time.Millisecond
+)
+
+func newWidgets(ctx context.Context, opts Options, redrawCh chan<- bool, errs *errorSink) (*widgets, error) {
+	theme := opts.Theme
+	timerCh := make(chan []int)
 	txtTypeCh := make(chan string)
+	flashTypeCh := make(chan time.Duration)
 	txtInfoCh := make(chan string)
 	txtTimerCh := make(chan string)
 
-	donTimer, err := newDonut(ctx, donTimerCh, errorCh)
+	timer, err := newTimer(ctx, opts.TimerStyle, theme, timerCh, errs)
 	if err != nil {
 		return nil, err
 	}
 
-	disType, err := newSegmentDisplay(ctx, txtTypeCh, errorCh)
+	disType, err := newSegmentDisplay(ctx, theme, txtTypeCh, flashTypeCh, redrawCh, errs)
 	if err != nil {
 		return nil, err
 	}
 
-	txtInfo, err := newText(ctx, txtInfoCh, errorCh)
+	txtInfo, err := newText(ctx, theme, txtInfoCh, errs)
+	if err != nil {
+		return nil, err
+	}
+	txtTimer, err := newText(ctx, theme, txtTimerCh, errs)
 	if err != nil {
 		return nil, err
 	}
-	txtTimer, err := newText(ctx, txtTimerCh, errorCh)
+	compact, err := newCompactLine(theme, errs)
 	if err != nil {
 		return nil, err
 	}
 
-	return &widgets{
-		donTimer:       donTimer,
+	w := &widgets{
+		timer:          timer,
 		disType:        disType,
 		txtInfo:        txtInfo,
 		txtTimer:       txtTimer,
-		updateDonTimer: donTimerCh,
+		compact:        compact,
+		updateTimer:    timerCh,
 		updateTxtInfo:  txtInfoCh,
 		updateTxtTimer: txtTimerCh,
 		updateTxtType:  txtTypeCh,
-	}, nil
+	}
+	if opts.Flash {
+		w.flashType = flashTypeCh
+	}
+	if opts.Bell {
+		w.bell = os.Stdout
+	}
+	return w, nil
 }
 
-func newText(ctx context.Context, updateText <-chan string, errorCh chan<- error) (*text.Text, error) {
+func newText(ctx context.Context, theme Theme, updateText <-chan string, errs *errorSink) (*text.Text, error) {
 	txt, err := text.New()
 	if err != nil {
 		return nil, err
@@ -69,7 +98,7 @@ func newText(ctx context.Context, updateText <-chan string, errorCh chan<- error
 			select {
 			case t := <-updateText:
 				txt.Reset()
-				errorCh <- txt.Write(t)
+				errs.report(txt.Write(t, text.WriteCellOpts(cell.FgColor(theme.Text))))
 			case <-ctx.Done():
 				return
 			}
@@ -79,47 +108,64 @@ func newText(ctx context.Context, updateText <-chan string, errorCh chan<- error
 	return txt, nil
 }
 
-func newDonut(ctx context.Context, donUpdater <-chan []int, errorCh chan<- error) (*donut.Donut, error) {
-	don, err := donut.New(donut.Clockwise(), donut.CellOpts(cell.FgColor(cell.ColorBlue)))
+// newSegmentDisplay shows the text sent on updateText. A duration sent on
+// flash makes it blink for that long, redrawing by itself
+func newSegmentDisplay(ctx context.Context, theme Theme, updateText <-chan string, flash <-chan time.Duration,
+	redrawCh chan<- bool, errs *errorSink) (*segmentdisplay.SegmentDisplay, error) {
+	sd, err := segmentdisplay.New()
 	if err != nil {
 		return nil, err
 	}
 
-	go func() {
-		for {
-			select {
-			case d := <-donUpdater:
-				if d[0] <= d[1] {
-					errorCh <- don.Absolute(d[0], d[1])
-				}
-			case <-ctx.Done():
-				return
-			}
+	write := func(t string, lit bool) error {
+		opts := []cell.Option{cell.FgColor(theme.Text)}
+		if lit {
+			opts = []cell.Option{cell.FgColor(theme.Timer), cell.Inverse()}
 		}
-	}()
-
-	return don, nil
-}
-
-func newSegmentDisplay(ctx context.Context, updateText <-chan string, errorCh chan<- error) (*segmentdisplay.SegmentDisplay, error) {
-	sd, err := segmentdisplay.New()
-	if err != nil {
-		return nil, err
+		return sd.Write([]*segmentdisplay.TextChunk{
+			segmentdisplay.NewChunk(t, segmentdisplay.WriteCellOpts(opts...)),
+		})
 	}
 
 	go func() {
+		var (
+			last  = " "
+			lit   bool
+			blink *time.Ticker
+			// blinkC and stop are nil, blocking, while not flashing
+			blinkC <-chan time.Time
+			stop   <-chan time.Time
+		)
+		stopBlink := func() {
+			if blink != nil {
+				blink.Stop()
+			}
+			blink, blinkC, stop, lit = nil, nil, nil, false
+		}
+		defer stopBlink()
+
 		for {
 			select {
 			case t := <-updateText:
 				if t == "" {
 					t = " "
 				}
-				errorCh <- sd.Write([]*segmentdisplay.TextChunk{
-					segmentdisplay.NewChunk(t),
-				})
+				last = t
+				errs.report(write(last, lit))
+				continue
+			case d := <-flash:
+				stopBlink()
+				blink = time.NewTicker(flashBlink)
+				blinkC, stop, lit = blink.C, time.After(d), true
+			case <-blinkC:
+				lit = !lit
+			case <-stop:
+				stopBlink()
 			case <-ctx.Done():
 				return
 			}
+			errs.report(write(last, lit))
+			redrawCh <- true
 		}
 	}()
 	return sd, nil
@@ -131,12 +177,25 @@ func (w *widgets) update(timer []int, txtType, txtInfo, txtTimer string, redrawC
 	}
 	if txtType != "" {
 		w.updateTxtType <- txtType
+		w.compact.setCategory(txtType)
 	}
 	if txtTimer != "" {
 		w.updateTxtTimer <- txtTimer
 	}
 	if len(timer) > 0 {
-		w.updateDonTimer <- timer
+		w.updateTimer <- timer
+		w.compact.setProgress(time.Duration(timer[0]), time.Duration(timer[1]))
 	}
 	redrawCh <- true
 }
+
+// alert tells an interval ended, ringing the bell and flashing the
+// interval type as set
+func (w *widgets) alert() {
+	if w.bell != nil {
+		fmt.Fprint(w.bell, "\a")
+	}
+	if w.flashType != nil {
+		w.flashType <- flashDuration
+	}
+}